@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 
@@ -13,12 +19,52 @@ import (
 	zap "github.com/andrsj/feedback-service/pkg/logger/zap"
 )
 
+const (
+	defaultDBTimeout     = 5 * time.Second
+	defaultCacheTimeout  = 500 * time.Millisecond
+	defaultBrokerTimeout = 5 * time.Second
+
+	defaultReadYourWritesWindow = 5 * time.Second
+	defaultReplicaCheckInterval = 10 * time.Second
+
+	defaultExportLinkTTL = 15 * time.Minute
+
+	defaultRetentionBatch = 500
+
+	defaultStatsCacheTTL = 30 * time.Second
+
+	defaultKafkaQueueSize = 1000
+	defaultKafkaBatchSize = 100
+	defaultKafkaLinger    = 50 * time.Millisecond
+
+	defaultKafkaReconnectBackoff    = time.Second
+	defaultKafkaMaxReconnectBackoff = time.Minute
+	defaultKafkaBufferSize          = 10000
+	defaultKafkaCheckInterval       = 10 * time.Second
+
+	defaultKafkaGroupID       = "feedback-projections"
+	defaultProjectionLatestN  = 20
+	defaultProjectionEventTTL = 7 * 24 * time.Hour
+
+	defaultDLQReplayRate = 50
+
+	defaultWebhookTimeout      = 5 * time.Second
+	defaultWebhookMaxAttempts  = 8
+	defaultWebhookBackoff      = 10 * time.Second
+	defaultWebhookMaxBackoff   = time.Hour
+	defaultWebhookDisableAfter = 20
+	defaultWebhookLogTTL       = 7 * 24 * time.Hour
+
+	bytesInMB = 1 << 20
+)
+
 func main() {
 	zap := zap.New()
 
 	var configFile string
 
 	flag.StringVar(&configFile, "c", "", "path to config file (required)")
+	flag.Usage = usage
 	flag.Parse()
 
 	if configFile == "" {
@@ -31,6 +77,77 @@ func main() {
 		zap.Fatal("error loading .env file", log.M{"err": err})
 	}
 
+	// Backends config
+	repository := os.Getenv("REPOSITORY")
+	cache := os.Getenv("CACHE")
+	broker := os.Getenv("BROKER")
+	brokerFile := os.Getenv("BROKER_FILE")
+	sqlitePath := os.Getenv("SQLITE_PATH")
+	auditFile := os.Getenv("AUDIT_FILE")
+
+	// The broker file is rotated after BROKER_FILE_MAX_MB, BROKER_FILE_MAX_FILES rotated files are kept,
+	// empty values mean no rotation and all files.
+	brokerFileMaxMB := 0
+	if os.Getenv("BROKER_FILE_MAX_MB") != "" {
+		brokerFileMaxMB = intEnv(zap, "BROKER_FILE_MAX_MB", 0)
+	}
+
+	brokerFileMaxFiles := 0
+	if os.Getenv("BROKER_FILE_MAX_FILES") != "" {
+		brokerFileMaxFiles = intEnv(zap, "BROKER_FILE_MAX_FILES", 0)
+	}
+
+	// Encryption of personal data, the keys aren't logged.
+	encryptionKeys := os.Getenv("ENCRYPTION_KEYS")
+	encryptionKeyID := os.Getenv("ENCRYPTION_KEY_ID")
+	blindIndexKey := os.Getenv("BLIND_INDEX_KEY")
+	receiptKey := os.Getenv("RECEIPT_KEY")
+
+	// Exports of customer data: the archives are kept in EXPORT_DIR until the link expires.
+	exportDir := os.Getenv("EXPORT_DIR")
+	if exportDir == "" {
+		exportDir = filepath.Join(os.TempDir(), "feedback-exports")
+	}
+
+	exportKey := os.Getenv("EXPORT_KEY")
+	exportLinkTTL := durationEnv(zap, "EXPORT_LINK_TTL", defaultExportLinkTTL)
+
+	// Retention: "<scope>=<action>:<age>,...", the purge runs every RETENTION_INTERVAL (0 is off).
+	retentionPolicies := os.Getenv("RETENTION_POLICIES")
+	retentionInterval := durationEnv(zap, "RETENTION_INTERVAL", 0)
+	retentionBatch := defaultRetentionBatch
+
+	if value := os.Getenv("RETENTION_BATCH"); value != "" {
+		retentionBatch, err = strconv.Atoi(value)
+		if err != nil || retentionBatch <= 0 {
+			zap.Fatal("RETENTION_BATCH must be a positive integer", log.M{"value": value})
+		}
+	}
+
+	// Stats are cached for the short time, they aren't invalidated by new feedbacks.
+	statsCacheTTL := durationEnv(zap, "STATS_CACHE_TTL", defaultStatsCacheTTL)
+	// The projections are built by the 'worker' command, they are empty without it.
+	statsFromProjections := boolEnv(zap, "STATS_FROM_PROJECTIONS")
+
+	zap.Info("Retention", log.M{
+		"policies": retentionPolicies,
+		"interval": retentionInterval.String(),
+		"batch":    retentionBatch,
+	})
+
+	zap.Info("Backends", log.M{
+		"repository": repository,
+		"cache":      cache,
+		"broker":     broker,
+		"brokerFile": brokerFile,
+		"maxMB":      brokerFileMaxMB,
+		"maxFiles":   brokerFileMaxFiles,
+		"sqlitePath": sqlitePath,
+		"auditFile":  auditFile,
+		"keyID":      encryptionKeyID,
+		"exportDir":  exportDir,
+	})
+
 	// Postgresql config
 	dbHost := os.Getenv("DATABASE_HOST")
 	dbPort := os.Getenv("DATABASE_PORT")
@@ -53,6 +170,32 @@ func main() {
 
 	zap.Info("DB URL", log.M{"dsn": dsn})
 
+	dbTimeout := durationEnv(zap, "DATABASE_TIMEOUT", defaultDBTimeout)
+
+	// Read replicas with the same user, password and DB name: "host1:5432,host2:5432"
+	var replicaDSNs []string
+
+	for _, replicaHost := range strings.Split(os.Getenv("DATABASE_REPLICA_HOSTS"), ",") {
+		host, port, found := strings.Cut(strings.TrimSpace(replicaHost), ":")
+		if host == "" {
+			continue
+		}
+
+		if !found {
+			port = dbPort
+		}
+
+		replicaDSNs = append(replicaDSNs, fmt.Sprintf(
+			"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+			host, port, dbUser, dbPass, dbName,
+		))
+
+		zap.Info("DB replica", log.M{"host": host, "port": port})
+	}
+
+	readYourWritesWindow := durationEnv(zap, "READ_YOUR_WRITES_WINDOW", defaultReadYourWritesWindow)
+	replicaCheckInterval := durationEnv(zap, "REPLICA_CHECK_INTERVAL", defaultReplicaCheckInterval)
+
 	// Memcached config
 	memcachedHost := fmt.Sprintf(
 		"%s:%s",
@@ -60,17 +203,22 @@ func main() {
 		os.Getenv("MEMCACHED_PORT"),
 	)
 	memcachedSecondsLiveStr := os.Getenv("MEMCACHED_LIVE_TIME")
-	memcachedSecondsLive, err := strconv.Atoi(memcachedSecondsLiveStr)
 
 	zap.Info("Memcached data", log.M{
 		"URL":       memcachedHost,
 		"live time": memcachedSecondsLiveStr,
 	})
 
-	if err != nil {
-		zap.Fatal("can't convert the Memcached live time seconds into integer", log.M{"err": err})
+	var memcachedSecondsLive int
+	if memcachedSecondsLiveStr != "" {
+		memcachedSecondsLive, err = strconv.Atoi(memcachedSecondsLiveStr)
+		if err != nil {
+			zap.Fatal("can't convert the Memcached live time seconds into integer", log.M{"err": err})
+		}
 	}
 
+	memcachedTimeout := durationEnv(zap, "MEMCACHED_TIMEOUT", defaultCacheTimeout)
+
 	// Kafka config
 	kafkaHost := os.Getenv("KAFKA_HOST")
 	kafkaPort := os.Getenv("KAFKA_PORT")
@@ -80,29 +228,284 @@ func main() {
 		kafkaPort,
 	)
 	kafkaTopic := os.Getenv("KAFKA_TOPIC")
+	kafkaTimeout := durationEnv(zap, "KAFKA_TIMEOUT", defaultBrokerTimeout)
+
+	// Async mode batches the messages in the bounded queue, the full queue blocks, drops or rejects (503).
+	kafkaMode := os.Getenv("KAFKA_MODE")
+	kafkaQueuePolicy := os.Getenv("KAFKA_QUEUE_POLICY")
+
+	if kafkaQueuePolicy == "" {
+		kafkaQueuePolicy = "block"
+	}
+
+	kafkaQueueSize := intEnv(zap, "KAFKA_QUEUE_SIZE", defaultKafkaQueueSize)
+	kafkaBatchSize := intEnv(zap, "KAFKA_BATCH_SIZE", defaultKafkaBatchSize)
+	kafkaLinger := durationEnv(zap, "KAFKA_LINGER", defaultKafkaLinger)
+
+	// The producer is connected in the background, while Kafka is down the events of the new feedbacks
+	// are kept in memory (KAFKA_BUFFER_SIZE=0 is off) and the reconnects go by the exponential backoff.
+	kafkaReconnectBackoff := durationEnv(zap, "KAFKA_RECONNECT_BACKOFF", defaultKafkaReconnectBackoff)
+	kafkaMaxReconnectBackoff := durationEnv(zap, "KAFKA_MAX_RECONNECT_BACKOFF", defaultKafkaMaxReconnectBackoff)
+	kafkaCheckInterval := durationEnv(zap, "KAFKA_CHECK_INTERVAL", defaultKafkaCheckInterval)
+
+	kafkaBufferSize := 0
+	if os.Getenv("KAFKA_BUFFER_SIZE") != "0" {
+		kafkaBufferSize = intEnv(zap, "KAFKA_BUFFER_SIZE", defaultKafkaBufferSize)
+	}
+
+	// Events are CloudEvents keyed by the feedback ID, KAFKA_EVENT_MODE=structured|binary.
+	kafkaEventMode := os.Getenv("KAFKA_EVENT_MODE")
+	kafkaEventSource := os.Getenv("KAFKA_EVENT_SOURCE")
+
+	// The feedback is serialized by the schema, KAFKA_FORMAT=json|avro|protobuf.
+	kafkaFormat := os.Getenv("KAFKA_FORMAT")
+	schemaRegistryURL := os.Getenv("SCHEMA_REGISTRY_URL")
+
+	// The worker consumes the topic by the group, every instance gets its own partitions.
+	kafkaGroupID := os.Getenv("KAFKA_GROUP_ID")
+	if kafkaGroupID == "" {
+		kafkaGroupID = defaultKafkaGroupID
+	}
+
+	projectionLatestN := intEnv(zap, "PROJECTION_LATEST_N", defaultProjectionLatestN)
+	projectionEventTTL := durationEnv(zap, "PROJECTION_EVENT_TTL", defaultProjectionEventTTL)
+
+	// The failed events are kept in the dead_letters table, the replay publishes them by the rate per second.
+	dlqReplayRate := intEnv(zap, "DLQ_REPLAY_RATE", defaultDLQReplayRate)
+
+	// The deliveries of the webhooks are retried by the exponential backoff,
+	// the webhook is disabled after the failures in a row.
+	webhookTimeout := durationEnv(zap, "WEBHOOK_TIMEOUT", defaultWebhookTimeout)
+	webhookMaxAttempts := intEnv(zap, "WEBHOOK_MAX_ATTEMPTS", defaultWebhookMaxAttempts)
+	webhookBackoff := durationEnv(zap, "WEBHOOK_BACKOFF", defaultWebhookBackoff)
+	webhookMaxBackoff := durationEnv(zap, "WEBHOOK_MAX_BACKOFF", defaultWebhookMaxBackoff)
+	webhookDisableAfter := intEnv(zap, "WEBHOOK_DISABLE_AFTER", defaultWebhookDisableAfter)
+	webhookLogTTL := durationEnv(zap, "WEBHOOK_LOG_TTL", defaultWebhookLogTTL)
+	webhookAllowPrivate := boolEnv(zap, "WEBHOOK_ALLOW_PRIVATE")
 
 	zap.Info("Apache Kafka Configuration", log.M{
-		"host":  kafkaHost,
-		"port":  kafkaPort,
-		"topic": kafkaTopic,
+		"host":        kafkaHost,
+		"port":        kafkaPort,
+		"topic":       kafkaTopic,
+		"mode":        kafkaMode,
+		"queueSize":   kafkaQueueSize,
+		"queuePolicy": kafkaQueuePolicy,
+		"bufferSize":  kafkaBufferSize,
+		"eventMode":   kafkaEventMode,
+		"format":      kafkaFormat,
+		"groupID":     kafkaGroupID,
 	})
 
-	// App creating
-	app, err := app.New(&app.Params{
+	params := &app.Params{
+		Repository:       repository,
+		Cache:            cache,
+		Broker:           broker,
+		BrokerFile:       brokerFile,
+		SQLitePath:       sqlitePath,
+		AuditFile:        auditFile,
+		EncryptionKeys:   encryptionKeys,
+		EncryptionKeyID:  encryptionKeyID,
+		BlindIndexKey:    blindIndexKey,
+		ReceiptKey:       receiptKey,
+		ExportDir:        exportDir,
+		ExportKey:        exportKey,
+		ExportLinkTTL:    exportLinkTTL,
 		DsnDB:            dsn,
 		CacheSecondsLive: int32(memcachedSecondsLive),
 		CacheHost:        memcachedHost,
 		KafkaHost:        kafkaURL,
 		KafkaTopic:       kafkaTopic,
 		Logger:           zap,
-	})
+		DBTimeout:        dbTimeout,
+		CacheTimeout:     memcachedTimeout,
+		BrokerTimeout:    kafkaTimeout,
+
+		BrokerFileMaxSize:  int64(brokerFileMaxMB) * bytesInMB,
+		BrokerFileMaxFiles: brokerFileMaxFiles,
+
+		DsnReplicas:          replicaDSNs,
+		ReadYourWritesWindow: readYourWritesWindow,
+		ReplicaCheckInterval: replicaCheckInterval,
+
+		RetentionPolicies: retentionPolicies,
+		RetentionInterval: retentionInterval,
+		RetentionBatch:    retentionBatch,
+
+		StatsCacheTTL:        statsCacheTTL,
+		StatsFromProjections: statsFromProjections,
+
+		KafkaMode:          kafkaMode,
+		KafkaQueueSize:     kafkaQueueSize,
+		KafkaQueuePolicy:   kafkaQueuePolicy,
+		KafkaBatchSize:     kafkaBatchSize,
+		KafkaLinger:        kafkaLinger,
+		KafkaEventMode:     kafkaEventMode,
+		KafkaEventSource:   kafkaEventSource,
+		KafkaFormat:        kafkaFormat,
+		SchemaRegistryURL:  schemaRegistryURL,
+		KafkaGroupID:       kafkaGroupID,
+		ProjectionLatestN:  projectionLatestN,
+		ProjectionEventTTL: projectionEventTTL,
+		DLQReplayRate:      dlqReplayRate,
+
+		WebhookTimeout:      webhookTimeout,
+		WebhookMaxAttempts:  webhookMaxAttempts,
+		WebhookBackoff:      webhookBackoff,
+		WebhookMaxBackoff:   webhookMaxBackoff,
+		WebhookDisableAfter: webhookDisableAfter,
+		WebhookLogTTL:       webhookLogTTL,
+		WebhookAllowPrivate: webhookAllowPrivate,
+
+		KafkaReconnectBackoff:    kafkaReconnectBackoff,
+		KafkaMaxReconnectBackoff: kafkaMaxReconnectBackoff,
+		KafkaBufferSize:          kafkaBufferSize,
+		KafkaCheckInterval:       kafkaCheckInterval,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	command := flag.Arg(0)
+
+	// Commands without the whole application.
+	if command == "migrate" {
+		err = app.Migrate(ctx, params, flag.Args()[1:])
+		if err != nil {
+			zap.Fatal("can't migrate the database", log.M{"err": err})
+		}
+
+		return
+	}
+
+	if command == "replay-log" {
+		err = app.ReplayLog(ctx, params, flag.Args()[1:])
+		if err != nil {
+			zap.Fatal("can't replay the broker file", log.M{"err": err})
+		}
+
+		return
+	}
+
+	// App creating
+	app, err := app.New(params)
 	if err != nil {
 		zap.Fatal("can't configure the app", log.M{"err": err})
 	}
 
-	// App starting
-	err = app.Start()
+	switch command {
+	case "", "serve":
+		// App starting
+		err = app.Start()
+		if err != nil {
+			zap.Fatal("can't start the application", log.M{"err": err})
+		}
+	case "backfill":
+		err = app.Backfill(ctx)
+		if err != nil {
+			zap.Fatal("can't backfill the rules", log.M{"err": err})
+		}
+	case "import":
+		err = app.Import(ctx, flag.Args()[1:])
+		if err != nil {
+			zap.Fatal("can't import the feedbacks", log.M{"err": err})
+		}
+	case "purge":
+		err = app.Purge(ctx, flag.Args()[1:])
+		if err != nil {
+			zap.Fatal("can't purge the expired feedbacks", log.M{"err": err})
+		}
+	case "worker":
+		err = app.Worker(ctx)
+		if err != nil {
+			zap.Fatal("can't run the worker", log.M{"err": err})
+		}
+	case "dlq":
+		err = app.DLQ(ctx, flag.Args()[1:])
+		if err != nil {
+			zap.Fatal("can't run the dead-letter command", log.M{"err": err})
+		}
+	case "rotate-keys":
+		err = app.RotateKeys(ctx, flag.Args()[1:])
+		if err != nil {
+			zap.Fatal("can't rotate the encryption keys", log.M{"err": err})
+		}
+	default:
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	err = app.Close()
 	if err != nil {
-		zap.Fatal("can't start the application", log.M{"err": err})
+		zap.Fatal("can't close the app", log.M{"err": err})
 	}
 }
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s -c <config> [command]
+
+Commands:
+  serve                          start HTTP server (default)
+  backfill                       re-apply routing rules to existing feedbacks
+  import [-dry-run] [-format ndjson|csv] <file|->
+                                 import feedbacks from NDJSON or CSV file
+  rotate-keys [-batch N]         re-encrypt personal data by the active key
+  purge [-dry-run] [-batch N]    delete or anonymize feedbacks by the retention policies
+  migrate up|down|status|to <N>  apply or roll back the database migrations
+  replay-log [-dry-run] [-batch N] [-skip N] <file>...
+                                 publish the events of the broker file to Kafka
+  worker                         build the stats projections from the feedback topic
+  dlq list [-limit N] [-after ID]
+                                 print the events failed to be published as NDJSON
+  dlq replay -all | <ID>...      publish the failed events again by DLQ_REPLAY_RATE
+  dlq purge -all | -older-than D | <ID>...
+                                 delete the failed events without publishing
+
+Flags:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+// intEnv parses the positive integer from the env variable.
+func intEnv(logger log.Logger, name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		logger.Fatal("must be a positive integer", log.M{"name": name, "value": value})
+	}
+
+	return number
+}
+
+// boolEnv parses "true" or "false" from the env variable, empty means false.
+func boolEnv(logger log.Logger, name string) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return false
+	}
+
+	result, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Fatal("must be true or false", log.M{"name": name, "value": value})
+	}
+
+	return result
+}
+
+// durationEnv parses duration like "5s" or "300ms" from the env variable.
+func durationEnv(logger log.Logger, name string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Fatal("can't parse the duration", log.M{"name": name, "value": value, "err": err})
+	}
+
+	return duration
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -17,87 +18,352 @@ import (
 	"github.com/andrsj/feedback-service/internal/delivery/http/handlers"
 	"github.com/andrsj/feedback-service/internal/delivery/http/router"
 	"github.com/andrsj/feedback-service/internal/delivery/http/server"
-	"github.com/andrsj/feedback-service/internal/infrastructure/broker/kafka"
-	"github.com/andrsj/feedback-service/internal/infrastructure/cache/memcached"
-	repo "github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	"github.com/andrsj/feedback-service/internal/services/access"
+	"github.com/andrsj/feedback-service/internal/services/audit"
+	"github.com/andrsj/feedback-service/internal/services/dlq"
+	"github.com/andrsj/feedback-service/internal/services/erasure"
 	"github.com/andrsj/feedback-service/internal/services/feedback"
+	"github.com/andrsj/feedback-service/internal/services/retention"
+	"github.com/andrsj/feedback-service/internal/services/rules"
+	"github.com/andrsj/feedback-service/internal/services/sentiment"
+	"github.com/andrsj/feedback-service/internal/services/stats"
+	"github.com/andrsj/feedback-service/internal/services/webhooks"
 	log "github.com/andrsj/feedback-service/pkg/logger"
 )
 
-const timeoutShutdown = 5
+const (
+	timeoutShutdown = 5
+	backfillBatch   = 100
+	// The depth of the dead-letter queue is also changed by the 'dlq' command and other instances.
+	dlqRefreshInterval = 30 * time.Second
+)
+
+var (
+	errNoReceiptKey = errors.New("RECEIPT_KEY is required to sign the erasure receipts")
+	errNoExportKey  = errors.New("EXPORT_KEY is required to sign the download links of the exports")
+)
 
 type App struct {
-	server *http.Server
+	server    *http.Server
+	service   *feedback.Service
+	retention *retention.Service
+	dlq       *dlq.Service
+	webhooks  *webhooks.Service
+	access    *access.Service
+	repos     *repositories
+	// The producer without the dead-letter queue, the services get it by dlq.Producer.
+	broker dlq.Publisher
 	logger log.Logger
+
+	retentionInterval time.Duration
+	// The worker has its own consumer, see Worker.
+	params *Params
 }
 
 type Params struct {
+	// Backends, see the constants in backends.go,
+	// empty values mean Postgres, Memcached and Kafka.
+	Repository string
+	Cache      string
+	Broker     string
+	BrokerFile string
+	// Rotation of the broker file: the size in bytes and the number of the rotated files, zero is off.
+	BrokerFileMaxSize  int64
+	BrokerFileMaxFiles int
+	SQLitePath         string
+	// NDJSON copy of the audit log, empty means no copy.
+	AuditFile string
+
+	// Encryption of the personal data in SQL repositories, see the envelope package.
+	// Empty keys mean plaintext.
+	EncryptionKeys  string
+	EncryptionKeyID string
+	BlindIndexKey   string
+	// HMAC key of the erasure receipts, required.
+	ReceiptKey string
+	// Exports of customer data: directory of the archives,
+	// HMAC key of the download links (required) and their lifetime.
+	ExportDir     string
+	ExportKey     string
+	ExportLinkTTL time.Duration
+
+	// Retention policies, see retention.ParsePolicies. The purge is run by the server every interval,
+	// zero interval means only by the 'purge' command.
+	RetentionPolicies string
+	RetentionInterval time.Duration
+	RetentionBatch    int
+
+	// Lifetime of the cached stats, zero means no cache.
+	StatsCacheTTL time.Duration
+	// The stats are read from the projections of the worker instead of scanning the feedbacks.
+	StatsFromProjections bool
+
+	// Worker: the consumer group of the feedback topic, the number of the latest feedbacks per source host
+	// and the time the IDs of the applied events are kept.
+	KafkaGroupID       string
+	ProjectionLatestN  int
+	ProjectionEventTTL time.Duration
+
+	// Events per second published by the replay of the dead-letter queue.
+	DLQReplayRate int
+
+	// Deliveries of the webhooks: the timeout of the request, the attempts,
+	// the first and the longest delay between them, the failures in a row which disable the webhook
+	// and the lifetime of the completed deliveries in the log, see webhooks.Config.
+	WebhookTimeout      time.Duration
+	WebhookMaxAttempts  int
+	WebhookBackoff      time.Duration
+	WebhookMaxBackoff   time.Duration
+	WebhookDisableAfter int
+	WebhookLogTTL       time.Duration
+	// The webhooks on the private addresses are allowed.
+	WebhookAllowPrivate bool
+
 	DsnDB            string
 	CacheSecondsLive int32
 	CacheHost        string
 	KafkaHost        string
 	KafkaTopic       string
 	Logger           log.Logger
+
+	// Kafka producer: sync waits for every message, async batches them in the bounded queue,
+	// the policy of the full queue is block, drop or reject (503), see kafka.AsyncConfig.
+	KafkaMode        string
+	KafkaQueueSize   int
+	KafkaQueuePolicy string
+	KafkaBatchSize   int
+	KafkaLinger      time.Duration
+	// CloudEvents envelope: structured or binary mode and the source of the events, see kafka.EventConfig.
+	KafkaEventMode   string
+	KafkaEventSource string
+	// Format of the feedback in the events (json, avro or protobuf) and the Confluent schema registry,
+	// the empty URL means the in-process registry, see schema.Serializer.
+	KafkaFormat       string
+	SchemaRegistryURL string
+
+	// Reconnects of the Kafka producer by the exponential backoff, the events of the new feedbacks
+	// kept in memory while Kafka is down (zero is off) and the interval of the checks, see kafka.ResilientConfig.
+	KafkaReconnectBackoff    time.Duration
+	KafkaMaxReconnectBackoff time.Duration
+	KafkaBufferSize          int
+	KafkaCheckInterval       time.Duration
+
+	// Timeouts for one call of the dependency.
+	DBTimeout     time.Duration
+	CacheTimeout  time.Duration
+	BrokerTimeout time.Duration
+
+	// Read replicas of Postgres, see the Replicas of the Gorm repository.
+	DsnReplicas          []string
+	ReadYourWritesWindow time.Duration
+	ReplicaCheckInterval time.Duration
 }
 
 func New(params *Params) (*App, error) {
 	logger := params.Logger.Named("app")
 
-	//nolint:varnamelen
-	db, err := gorm.Open(
-		postgres.Open(params.DsnDB),
-		//nolint:exhaustivestruct,exhaustruct
-		&gorm.Config{
-			Logger: gormLogger.Default.LogMode(gormLogger.Info),
-		},
-	)
-	if err != nil {
-		logger.Error("Can't connect to DB", log.M{"err": err, "dsn": params.DsnDB})
-
-		return nil, fmt.Errorf("can't connect to DB: %w", err)
-	}
-
-	feedbackRepo, err := repo.NewFeedbackRepository(db, logger)
+	repos, err := newRepositories(params, logger)
 	if err != nil {
 		logger.Error("Can't up repository", log.M{"err": err})
 
 		return nil, fmt.Errorf("can't up repository: %w", err)
 	}
 
-	broker, err := kafka.New(logger, params.KafkaHost, params.KafkaTopic)
+	recorder := dlq.NewRecorder(repos.deadLetters, logger)
+
+	broker, err := newBroker(params, recorder.Undelivered, recorder, logger)
 	if err != nil {
 		logger.Error("Can't up broker", log.M{"err": err})
 
 		return nil, fmt.Errorf("can't up broker: %w", err)
 	}
 
-	service := feedback.New(feedbackRepo, broker, logger)
-	handlers := handlers.New(service, logger)
+	cache, err := newCache(params, logger)
+	if err != nil {
+		logger.Error("Can't up cache", log.M{"err": err})
+
+		return nil, fmt.Errorf("can't up cache: %w", err)
+	}
+
+	analyzer, err := sentiment.New()
+	if err != nil {
+		logger.Error("Can't up sentiment analyzer", log.M{"err": err})
+
+		return nil, fmt.Errorf("can't up sentiment analyzer: %w", err)
+	}
+
+	if params.ReceiptKey == "" {
+		logger.Error("Receipt key isn't set", nil)
+
+		return nil, errNoReceiptKey
+	}
+
+	policies, err := retention.ParsePolicies(params.RetentionPolicies)
+	if err != nil {
+		logger.Error("Can't parse retention policies", log.M{"err": err})
+
+		return nil, fmt.Errorf("can't parse retention policies: %w", err)
+	}
+
+	if params.ExportKey == "" {
+		logger.Error("Export key isn't set", nil)
+
+		return nil, errNoExportKey
+	}
+
+	accessService, err := access.New(
+		repos.feedbacks,
+		repos.audit,
+		params.ExportDir,
+		params.ExportKey,
+		params.ExportLinkTTL,
+		logger,
+	)
+	if err != nil {
+		logger.Error("Can't up access service", log.M{"err": err})
+
+		return nil, fmt.Errorf("can't up access service: %w", err)
+	}
+
+	if params.StatsFromProjections && repos.projections == nil {
+		logger.Warn("Projections aren't kept by the memory repository, the stats scan the feedbacks", nil)
+	}
+
+	webhookService := webhooks.New(repos.webhooks, repos.feedbacks, webhooks.Config{
+		Timeout:      params.WebhookTimeout,
+		MaxAttempts:  params.WebhookMaxAttempts,
+		Backoff:      params.WebhookBackoff,
+		MaxBackoff:   params.WebhookMaxBackoff,
+		DisableAfter: params.WebhookDisableAfter,
+		LogTTL:       params.WebhookLogTTL,
+		AllowPrivate: params.WebhookAllowPrivate,
+	}, logger)
+
+	// The failed events are kept in the dead-letter queue, the writes don't fail with the broker.
+	// The webhooks get the events after the broker.
+	producer := webhooks.NewProducer(dlq.NewProducer(broker, recorder), webhookService)
+
+	ruleService := rules.New(repos.rules, logger)
+	service := feedback.New(repos.feedbacks, producer, analyzer, ruleService, logger)
+	auditService := audit.New(repos.audit, logger)
+	// Only the file log keeps the events, its records of the customer are purged by the erasure.
+	var purger erasure.Purger
+	if filePurger, ok := broker.(erasure.Purger); ok {
+		purger = filePurger
+	}
+
+	erasureService := erasure.New(repos.feedbacks, producer, purger, cache, repos.audit, params.ReceiptKey, logger)
+	retentionService := retention.New(repos.feedbacks, producer, cache, policies, params.RetentionBatch, logger)
+	dlqService := dlq.New(repos.deadLetters, repos.feedbacks, broker, params.DLQReplayRate, logger)
+	statsService := stats.New(
+		repos.feedbacks,
+		repos.projections,
+		params.StatsFromProjections,
+		cache,
+		params.StatsCacheTTL,
+		logger,
+	)
+	handlers := handlers.New(
+		service,
+		ruleService,
+		auditService,
+		erasureService,
+		accessService,
+		statsService,
+		dlqService,
+		webhookService,
+		brokerHealth(broker),
+		logger,
+	)
 
-	cache := memcached.New(params.CacheHost, params.CacheSecondsLive, logger)
 	router := router.New(cache, logger)
 	router.Register(handlers)
 
 	server := server.New(router)
 
 	return &App{
-		server: server,
-		logger: logger,
+		server:    server,
+		service:   service,
+		retention: retentionService,
+		dlq:       dlqService,
+		webhooks:  webhookService,
+		access:    accessService,
+		repos:     repos,
+		broker:    broker,
+		logger:    logger,
+
+		retentionInterval: params.RetentionInterval,
+		params:            params,
 	}, nil
 }
 
+func openDB(dsn string, logger log.Logger) (*gorm.DB, error) {
+	//nolint:varnamelen
+	db, err := gorm.Open(
+		postgres.Open(dsn),
+		//nolint:exhaustivestruct,exhaustruct
+		&gorm.Config{
+			Logger: gormLogger.Default.LogMode(gormLogger.Info),
+		},
+	)
+	if err != nil {
+		logger.Error("Can't connect to DB", log.M{"err": err, "dsn": dsn})
+
+		return nil, fmt.Errorf("can't connect to DB: %w", err)
+	}
+
+	return db, nil
+}
+
+// openReplica doesn't ping the replica, the app starts with a replica down,
+// it's checked by the Replicas.
+func openReplica(dsn string, logger log.Logger) (*gorm.DB, error) {
+	//nolint:varnamelen
+	db, err := gorm.Open(
+		postgres.Open(dsn),
+		//nolint:exhaustivestruct,exhaustruct
+		&gorm.Config{
+			Logger:               gormLogger.Default.LogMode(gormLogger.Info),
+			DisableAutomaticPing: true,
+		},
+	)
+	if err != nil {
+		logger.Error("Can't connect to DB", log.M{"err": err, "dsn": dsn})
+
+		return nil, fmt.Errorf("can't connect to DB: %w", err)
+	}
+
+	return db, nil
+}
+
 func (a *App) Start() error {
 	a.logger.Info("Starting the application", log.M{"address": a.server.Addr})
 
 	osSignals := make(chan os.Signal, 1)
 	signal.Notify(osSignals, syscall.SIGINT, syscall.SIGTERM)
 
+	// All requests are derived from this context,
+	// so it cancels DB queries, cache and broker calls of requests
+	// that are still running after the shutdown timeout.
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	a.server.BaseContext = func(net.Listener) context.Context {
+		return requestsCtx
+	}
+
 	go func() {
 		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			a.logger.Error("Server error", log.M{"error": err.Error()})
 		}
 	}()
 
+	go a.scheduleRetention(requestsCtx)
+	go a.refreshDLQ(requestsCtx)
+	go a.webhooks.Run(requestsCtx)
+	go a.access.Run(requestsCtx)
+
 	sig := <-osSignals
 
 	a.logger.Info("Received signal", log.M{"signal": sig})
@@ -107,9 +373,57 @@ func (a *App) Start() error {
 
 	if err := a.server.Shutdown(ctx); err != nil {
 		a.logger.Error("Server shutdown error", log.M{"error": err.Error()})
+		cancelRequests()
 	}
 
 	a.logger.Info("Application stopped", nil)
 
 	return nil
 }
+
+// Close flushes the queued events and closes the broker, then the databases,
+// it's called after the server or the command. The first error is returned.
+func (a *App) Close() error {
+	var result error
+
+	err := a.broker.Close()
+	if err != nil {
+		a.logger.Error("Broker closing error", log.M{"err": err})
+
+		result = fmt.Errorf("closing broker: %w", err)
+	}
+
+	for _, closer := range a.repos.closers {
+		err = closer.Close()
+		if err != nil {
+			a.logger.Error("Repository closing error", log.M{"err": err})
+
+			if result == nil {
+				result = fmt.Errorf("closing repository: %w", err)
+			}
+		}
+	}
+
+	return result
+}
+
+// Backfill re-applies the current routing rules to all existing feedbacks.
+func (a *App) Backfill(ctx context.Context) error {
+	a.logger.Info("Starting the backfill of rules", nil)
+
+	updated, err := a.service.ReapplyRules(commandContext(ctx, "backfill"), backfillBatch)
+	if err != nil {
+		a.logger.Error("Backfill error", log.M{"err": err, "updated": updated})
+
+		return fmt.Errorf("backfill error: %w", err)
+	}
+
+	a.logger.Info("Backfill is done", log.M{"updated": updated})
+
+	return nil
+}
+
+// commandContext sets the actor of the command, so its changes are seen in the audit log.
+func commandContext(ctx context.Context, command string) context.Context {
+	return reqctx.WithActor(ctx, reqctx.Actor{Subject: "cli:" + command, Role: "system"})
+}
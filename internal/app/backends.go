@@ -0,0 +1,498 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/andrsj/feedback-service/internal/delivery/http/handlers"
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	auditFile "github.com/andrsj/feedback-service/internal/infrastructure/audit/file"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/file"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/kafka"
+	brokerMemory "github.com/andrsj/feedback-service/internal/infrastructure/broker/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/noop"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/schema"
+	"github.com/andrsj/feedback-service/internal/infrastructure/cache"
+	"github.com/andrsj/feedback-service/internal/infrastructure/cache/memcached"
+	memoryCache "github.com/andrsj/feedback-service/internal/infrastructure/cache/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/cache/none"
+	repo "github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/migrate"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/sqlite"
+	"github.com/andrsj/feedback-service/internal/infrastructure/envelope"
+	"github.com/andrsj/feedback-service/internal/services/access"
+	auditService "github.com/andrsj/feedback-service/internal/services/audit"
+	"github.com/andrsj/feedback-service/internal/services/dlq"
+	"github.com/andrsj/feedback-service/internal/services/erasure"
+	"github.com/andrsj/feedback-service/internal/services/feedback"
+	"github.com/andrsj/feedback-service/internal/services/projection"
+	"github.com/andrsj/feedback-service/internal/services/retention"
+	"github.com/andrsj/feedback-service/internal/services/rules"
+	"github.com/andrsj/feedback-service/internal/services/stats"
+	"github.com/andrsj/feedback-service/internal/services/webhooks"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// Names of the backends for Params.
+const (
+	RepositoryPostgres = "postgres"
+	RepositoryMemory   = "memory"
+	RepositorySQLite   = "sqlite"
+
+	CacheMemcached = "memcached"
+	CacheMemory    = "memory"
+	CacheNone      = "none"
+
+	BrokerKafka = "kafka"
+	BrokerNoop  = "noop"
+	BrokerFile  = "file"
+	// BrokerMemory keeps the events in the process, see memory.Recorder.
+	BrokerMemory = "memory"
+
+	KafkaSync  = "sync"
+	KafkaAsync = "async"
+)
+
+var (
+	errUnknownBackend = errors.New("unknown backend")
+	errNotPositive    = errors.New("must be positive")
+)
+
+type repositories struct {
+	feedbacks feedbackRepository
+	rules     rules.Repository
+	audit     auditLog
+	// Read models of the worker, nil for the memory repository.
+	projections projectionRepository
+	deadLetters dlq.Repository
+	webhooks    webhooks.Repository
+	// closers are closed by App.Close after the broker, it still records the dead letters while closing.
+	closers []io.Closer
+}
+
+// feedbackRepository is used by the feedback service, the retention, the stats and the webhooks.
+type feedbackRepository interface {
+	feedback.Repository
+	retention.Repository
+	stats.Repository
+	webhooks.FeedbackReader
+}
+
+// projectionRepository is written by the worker and read by the stats.
+type projectionRepository interface {
+	projection.Repository
+	stats.Projections
+}
+
+// auditLog is read by the audit service and gets the entries of the erasure service.
+type auditLog interface {
+	auditService.Repository
+	erasure.AuditLog
+	access.AuditRepository
+}
+
+func newRepositories(params *Params, logger log.Logger) (*repositories, error) {
+	sink, err := newAuditSink(params, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	cipher, err := newCipher(params, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err := openRepositories(params, sink, cipher, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// The sink is closed after the databases, it gets the entries of their last changes.
+	if closer, ok := sink.(io.Closer); ok {
+		repos.closers = append(repos.closers, closer)
+	}
+
+	return repos, nil
+}
+
+// openRepositories opens the database of the repository from the params.
+func openRepositories(
+	params *Params,
+	sink audit.Sink,
+	cipher *envelope.Cipher,
+	logger log.Logger,
+) (*repositories, error) {
+	// IDs are time-ordered, so the pages go by the primary key.
+	generator := ids.NewV7()
+
+	switch params.Repository {
+	case RepositoryPostgres, "":
+		//nolint:varnamelen
+		db, err := openDB(params.DsnDB, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		// The server refuses to start with the old schema,
+		// the migrations are applied by the 'migrate' command.
+		err = checkSchema(db, params.DBTimeout, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		replicas, err := newReplicas(params, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("can't get SQL DB: %w", err)
+		}
+
+		auditLog := repo.NewAuditRepository(db, sink, params.DBTimeout, logger)
+
+		return &repositories{
+			feedbacks:   repo.NewFeedbackRepository(db, replicas, auditLog, cipher, generator, params.DBTimeout, logger),
+			rules:       repo.NewRuleRepository(db, auditLog, params.DBTimeout, logger),
+			audit:       auditLog,
+			projections: repo.NewProjectionRepository(db, replicas, params.DBTimeout, logger),
+			deadLetters: repo.NewDeadLetterRepository(db, generator, params.DBTimeout, logger),
+			webhooks:    repo.NewWebhookRepository(db, auditLog, cipher, generator, params.DBTimeout, logger),
+			closers:     closers(sqlDB, replicas),
+		}, nil
+	case RepositorySQLite:
+		//nolint:varnamelen
+		db, err := sqlite.Open(params.SQLitePath, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		// Single node owns the file, so it's safe to migrate on startup.
+		err = migrateUp(db.Gorm(), migrate.SQLite, params.DBTimeout, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		auditLog := sqlite.NewAuditRepository(db, sink, params.DBTimeout, logger)
+
+		return &repositories{
+			feedbacks: sqlite.NewFeedbackRepository(
+				db, auditLog.AuditRepository, cipher, generator, params.DBTimeout, logger,
+			),
+			rules:       sqlite.NewRuleRepository(db, auditLog.AuditRepository, params.DBTimeout, logger),
+			audit:       auditLog,
+			projections: sqlite.NewProjectionRepository(db, params.DBTimeout, logger),
+			deadLetters: sqlite.NewDeadLetterRepository(db, generator, params.DBTimeout, logger),
+			webhooks: sqlite.NewWebhookRepository(
+				db, auditLog.AuditRepository, cipher, generator, params.DBTimeout, logger,
+			),
+			closers: []io.Closer{db},
+		}, nil
+	case RepositoryMemory:
+		auditLog := memory.NewAuditRepository(sink, logger)
+
+		return &repositories{
+			feedbacks:   memory.New(auditLog, generator, logger),
+			rules:       memory.NewRuleRepository(auditLog, logger),
+			audit:       auditLog,
+			projections: nil,
+			deadLetters: memory.NewDeadLetterRepository(generator, logger),
+			webhooks:    memory.NewWebhookRepository(auditLog, generator, logger),
+			closers:     nil,
+		}, nil
+	default:
+		return nil, fmt.Errorf("repository '%s': %w", params.Repository, errUnknownBackend)
+	}
+}
+
+// newAuditSink returns nil without AuditFile, the audit log is kept only by the repository.
+func newAuditSink(params *Params, logger log.Logger) (audit.Sink, error) { //nolint:ireturn
+	if params.AuditFile == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	sink, err := auditFile.New(logger, params.AuditFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't up audit file: %w", err)
+	}
+
+	return sink, nil
+}
+
+// newCipher returns nil without keys, the personal data is stored as is.
+func newCipher(params *Params, logger log.Logger) (*envelope.Cipher, error) {
+	if params.EncryptionKeys == "" {
+		if params.Repository != RepositoryMemory {
+			logger.Warn("Encryption keys aren't set, personal data is stored in plaintext", nil)
+		}
+
+		return nil, nil //nolint:nilnil
+	}
+
+	cipher, err := envelope.New(params.EncryptionKeys, params.EncryptionKeyID, params.BlindIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("can't up encryption: %w", err)
+	}
+
+	logger.Info("Personal data is encrypted", log.M{"activeKey": cipher.ActiveKeyID()})
+
+	return cipher, nil
+}
+
+// closers of the Postgres repositories, the replicas are optional.
+func closers(primary io.Closer, replicas *repo.Replicas) []io.Closer {
+	if replicas == nil {
+		return []io.Closer{primary}
+	}
+
+	return []io.Closer{replicas, primary}
+}
+
+// newReplicas returns nil without replicas, so all reads go to the primary.
+func newReplicas(params *Params, logger log.Logger) (*repo.Replicas, error) {
+	if len(params.DsnReplicas) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	// The interval of the ticker, zero or negative one panics.
+	if params.ReplicaCheckInterval <= 0 {
+		return nil, fmt.Errorf("replica check interval '%s': %w", params.ReplicaCheckInterval, errNotPositive)
+	}
+
+	var (
+		names = make([]string, 0, len(params.DsnReplicas))
+		dbs   = make([]*gorm.DB, 0, len(params.DsnReplicas))
+	)
+
+	for i, dsn := range params.DsnReplicas {
+		//nolint:varnamelen
+		db, err := openReplica(dsn, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, fmt.Sprintf("replica-%d", i+1))
+		dbs = append(dbs, db)
+	}
+
+	return repo.NewReplicas(
+		names,
+		dbs,
+		params.ReadYourWritesWindow,
+		params.ReplicaCheckInterval,
+		params.DBTimeout,
+		logger,
+	), nil
+}
+
+func newCache(params *Params, logger log.Logger) (cache.Cache, error) { //nolint:ireturn
+	switch params.Cache {
+	case CacheMemcached, "":
+		return memcached.New(params.CacheHost, params.CacheSecondsLive, params.CacheTimeout, logger), nil
+	case CacheMemory:
+		return memoryCache.New(logger), nil
+	case CacheNone:
+		return none.New(), nil
+	default:
+		return nil, fmt.Errorf("cache '%s': %w", params.Cache, errUnknownBackend)
+	}
+}
+
+// newBroker returns the producer without the dead-letter queue, undelivered is called
+// for the events failed after Publish of the async producer returned, journal keeps the buffered events of Kafka.
+func newBroker( //nolint:ireturn
+	params *Params,
+	undelivered kafka.UndeliveredFunc,
+	journal kafka.Journal,
+	logger log.Logger,
+) (dlq.Publisher, error) {
+	switch params.Broker {
+	case BrokerKafka, "":
+		broker, err := newKafka(params, undelivered, journal, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		return broker, nil
+	case BrokerNoop:
+		return noop.New(logger), nil
+	case BrokerFile:
+		broker, err := file.New(logger, params.BrokerFile, file.Config{
+			MaxSize:  params.BrokerFileMaxSize,
+			MaxFiles: params.BrokerFileMaxFiles,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("can't up file broker: %w", err)
+		}
+
+		return broker, nil
+	case BrokerMemory:
+		return brokerMemory.New(logger), nil
+	default:
+		return nil, fmt.Errorf("broker '%s': %w", params.Broker, errUnknownBackend)
+	}
+}
+
+// newKafka doesn't wait for Kafka, the producer is connected in the background, see kafka.ResilientProducer.
+// The configuration and the schemas of the format are checked before, so the wrong ones stop the start.
+// The schema is registered by the connect, the events are buffered while the registry is down too.
+func newKafka(
+	params *Params,
+	undelivered kafka.UndeliveredFunc,
+	journal kafka.Journal,
+	logger log.Logger,
+) (*kafka.ResilientProducer, error) {
+	_, err := schema.Load(kafkaFormat(params))
+	if err != nil {
+		return nil, fmt.Errorf("can't set up the schema: %w", err)
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	events := &kafka.EventConfig{
+		Mode:   params.KafkaEventMode,
+		Source: params.KafkaEventSource,
+	}
+
+	// The connects are sequential, the serializer is set by the first registration and kept for the reconnects.
+	registry := newRegistry(params)
+	register := func() (*kafka.EventConfig, error) {
+		if events.Serializer == nil {
+			serializer, err := newSerializer(params, registry)
+			if err != nil {
+				return nil, err
+			}
+
+			events.Serializer = serializer
+		}
+
+		return events, nil
+	}
+
+	var connect kafka.ConnectFunc
+
+	switch params.KafkaMode {
+	case KafkaSync, "":
+		err = kafka.CheckConfig(events, nil)
+		if err != nil {
+			return nil, fmt.Errorf("can't up kafka: %w", err)
+		}
+
+		connect = func() (kafka.Publisher, error) {
+			events, err := register()
+			if err != nil {
+				return nil, err
+			}
+
+			broker, err := kafka.New(logger, params.KafkaHost, params.KafkaTopic, params.BrokerTimeout, events)
+			if err != nil {
+				return nil, fmt.Errorf("can't up kafka: %w", err)
+			}
+
+			return broker, nil
+		}
+	case KafkaAsync:
+		//nolint:exhaustivestruct,exhaustruct
+		queue := &kafka.AsyncConfig{
+			QueueSize: params.KafkaQueueSize,
+			Policy:    params.KafkaQueuePolicy,
+			BatchSize: params.KafkaBatchSize,
+			Linger:    params.KafkaLinger,
+
+			OnUndelivered: undelivered,
+		}
+
+		err = kafka.CheckConfig(events, queue)
+		if err != nil {
+			return nil, fmt.Errorf("can't up async kafka: %w", err)
+		}
+
+		connect = func() (kafka.Publisher, error) {
+			events, err := register()
+			if err != nil {
+				return nil, err
+			}
+
+			broker, err := kafka.NewAsync(logger, params.KafkaHost, params.KafkaTopic, params.BrokerTimeout, events, queue)
+			if err != nil {
+				return nil, fmt.Errorf("can't up async kafka: %w", err)
+			}
+
+			return broker, nil
+		}
+	default:
+		return nil, fmt.Errorf("kafka mode '%s': %w", params.KafkaMode, errUnknownBackend)
+	}
+
+	return kafka.NewResilient(logger, connect, kafka.ResilientConfig{
+		Backoff:       params.KafkaReconnectBackoff,
+		MaxBackoff:    params.KafkaMaxReconnectBackoff,
+		BufferSize:    params.KafkaBufferSize,
+		CheckInterval: params.KafkaCheckInterval,
+		Timeout:       params.BrokerTimeout,
+		OnUndelivered: undelivered,
+		Journal:       journal,
+	}), nil
+}
+
+// upBroker is the health of the brokers without the connection: file, memory and noop.
+type upBroker struct {
+	since time.Time
+}
+
+func (b upBroker) Health() *models.BrokerHealth {
+	return &models.BrokerHealth{
+		State:      models.BrokerUp,
+		Buffered:   0,
+		BufferSize: 0,
+		Error:      "",
+		Since:      b.since,
+	}
+}
+
+func brokerHealth(broker dlq.Publisher) handlers.HealthChecker { //nolint:ireturn
+	if checker, ok := broker.(handlers.HealthChecker); ok {
+		return checker
+	}
+
+	return upBroker{since: time.Now().UTC()}
+}
+
+// newSerializer registers the schema of the format, the producer is connected after it,
+// so nothing is published by the schema incompatible with the registry.
+func newSerializer(params *Params, registry schema.Registry) (*schema.Serializer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), params.BrokerTimeout)
+	defer cancel()
+
+	serializer, err := schema.New(ctx, kafkaFormat(params), registry, schema.Subject(params.KafkaTopic))
+	if err != nil {
+		return nil, fmt.Errorf("can't set up the schema: %w", err)
+	}
+
+	return serializer, nil
+}
+
+func kafkaFormat(params *Params) string {
+	if params.KafkaFormat == "" {
+		return schema.FormatJSON
+	}
+
+	return params.KafkaFormat
+}
+
+// newRegistry returns the in-process registry without the URL.
+func newRegistry(params *Params) schema.Registry { //nolint:ireturn
+	if params.SchemaRegistryURL == "" {
+		return schema.NewMemory()
+	}
+
+	return schema.NewClient(params.SchemaRegistryURL, params.BrokerTimeout)
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) log.Logger { return l }
+func (nopLogger) Debug(string, log.M)       {}
+func (nopLogger) Info(string, log.M)        {}
+func (nopLogger) Warn(string, log.M)        {}
+func (nopLogger) Error(string, log.M)       {}
+func (nopLogger) Fatal(string, log.M)       {}
+
+func TestKafkaStartsWithoutRegistry(t *testing.T) {
+	t.Parallel()
+
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer registry.Close()
+
+	//nolint:exhaustivestruct,exhaustruct
+	params := &Params{
+		KafkaHost:             "127.0.0.1:1",
+		KafkaTopic:            "feedback",
+		KafkaFormat:           "avro",
+		SchemaRegistryURL:     registry.URL,
+		BrokerTimeout:         time.Second,
+		KafkaReconnectBackoff: time.Hour,
+		KafkaBufferSize:       10,
+	}
+
+	producer, err := newKafka(params, nil, nil, nopLogger{})
+	if err != nil {
+		t.Fatalf("newKafka: %v", err)
+	}
+	defer producer.Close()
+
+	//nolint:exhaustivestruct,exhaustruct
+	err = producer.Publish(context.Background(), models.NewFeedbackEvent(models.FeedbackCreated, &models.Feedback{ID: uuid.New()}))
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	// The failed registration is the failed connect: the event waits in the buffer.
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		health := producer.Health()
+		if strings.Contains(health.Error, "registering") || strings.Contains(health.Error, "checking") {
+			if health.State == models.BrokerUp || health.Buffered != 1 {
+				t.Errorf("health: got %+v", health)
+			}
+
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("no registry error in the health: %+v", health)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestKafkaChecksFormat(t *testing.T) {
+	t.Parallel()
+
+	//nolint:exhaustivestruct,exhaustruct
+	params := &Params{KafkaTopic: "feedback", KafkaFormat: "xml", BrokerTimeout: time.Second}
+
+	if _, err := newKafka(params, nil, nil, nopLogger{}); err == nil {
+		t.Error("the unknown format doesn't stop the start")
+	}
+}
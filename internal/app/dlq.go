@@ -0,0 +1,166 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+const defaultDLQListLimit = 100
+
+var errDLQUsage = errors.New(
+	"usage: dlq list [-limit N] [-after ID] | dlq replay -all | <ID>... | dlq purge -all | -older-than D | <ID>...",
+)
+
+// DLQ runs the 'dlq' command: lists, replays or purges the events failed to be published.
+func (a *App) DLQ(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errDLQUsage
+	}
+
+	ctx = commandContext(ctx, "dlq")
+
+	switch args[0] {
+	case "list":
+		return a.listDLQ(ctx, args[1:])
+	case "replay":
+		return a.replayDLQ(ctx, args[1:])
+	case "purge":
+		return a.purgeDLQ(ctx, args[1:])
+	default:
+		return errDLQUsage
+	}
+}
+
+// listDLQ writes the letters to stdout as NDJSON.
+func (a *App) listDLQ(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("dlq list", flag.ContinueOnError)
+	limit := flags.Int("limit", defaultDLQListLimit, "letters to list")
+	after := flags.String("after", "", "the last ID of the previous page")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 || *limit <= 0 {
+		return errDLQUsage
+	}
+
+	letters, err := a.dlq.List(ctx, *limit, *after)
+	if err != nil {
+		return fmt.Errorf("dlq list error: %w", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+
+	for _, letter := range letters {
+		err = encoder.Encode(letter)
+		if err != nil {
+			return fmt.Errorf("writing dead letter: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (a *App) replayDLQ(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("dlq replay", flag.ContinueOnError)
+	all := flags.Bool("all", false, "replay the whole queue")
+
+	if err := flags.Parse(args); err != nil || *all == (flags.NArg() != 0) {
+		return errDLQUsage
+	}
+
+	var (
+		report *models.ReplayReport
+		err    error
+	)
+
+	if *all {
+		report, err = a.dlq.ReplayAll(ctx)
+	} else {
+		report, err = a.dlq.Replay(ctx, flags.Args())
+	}
+
+	if report != nil {
+		a.logger.Info("Replay of dead letters", log.M{
+			"replayed": report.Replayed,
+			"failed":   report.Failed,
+			"skipped":  report.Skipped,
+			"notFound": report.NotFound,
+		})
+	}
+
+	if err != nil {
+		a.logger.Error("Replay error", log.M{"err": err})
+
+		return fmt.Errorf("dlq replay error: %w", err)
+	}
+
+	return nil
+}
+
+func (a *App) purgeDLQ(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("dlq purge", flag.ContinueOnError)
+	all := flags.Bool("all", false, "purge the whole queue")
+	olderThan := flags.Duration("older-than", 0, "purge the letters added before this time ago")
+
+	err := flags.Parse(args)
+	if err != nil || *olderThan < 0 {
+		return errDLQUsage
+	}
+
+	// Exactly one of the modes.
+	modes := 0
+
+	for _, set := range []bool{*all, *olderThan > 0, flags.NArg() != 0} {
+		if set {
+			modes++
+		}
+	}
+
+	if modes != 1 {
+		return errDLQUsage
+	}
+
+	var purged int
+
+	switch {
+	case *all:
+		purged, err = a.dlq.PurgeBefore(ctx, time.Time{})
+	case *olderThan > 0:
+		purged, err = a.dlq.PurgeBefore(ctx, time.Now().Add(-*olderThan))
+	default:
+		purged, err = a.dlq.Purge(ctx, flags.Args())
+	}
+
+	if err != nil {
+		a.logger.Error("Purge of dead letters error", log.M{"err": err})
+
+		return fmt.Errorf("dlq purge error: %w", err)
+	}
+
+	a.logger.Info("Dead letters are purged", log.M{"purged": purged})
+
+	return nil
+}
+
+// refreshDLQ sets the depth metric of the dead-letter queue until the context is cancelled.
+func (a *App) refreshDLQ(ctx context.Context) {
+	a.dlq.Refresh(ctx)
+
+	ticker := time.NewTicker(dlqRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.dlq.Refresh(ctx)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andrsj/feedback-service/internal/services/feedback"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+var errImportUsage = errors.New("usage: import [-dry-run] [-format ndjson|csv] <file|->")
+
+// Import runs the 'import' command: feedbacks from NDJSON or CSV file, '-' is stdin.
+func (a *App) Import(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "validate the file without saving")
+	format := flags.String("format", "", "ndjson or csv, by default it's taken from the file extension")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errImportUsage
+	}
+
+	path := flags.Arg(0)
+
+	if *format == "" {
+		*format = feedback.ImportNDJSON
+		if strings.EqualFold(filepath.Ext(path), "."+feedback.ImportCSV) {
+			*format = feedback.ImportCSV
+		}
+	}
+
+	var reader io.Reader = os.Stdin
+
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("can't open the file: %w", err)
+		}
+		defer file.Close()
+
+		reader = file
+	}
+
+	a.logger.Info("Starting the import", log.M{"path": path, "format": *format, "dryRun": *dryRun})
+
+	report, err := a.service.Import(commandContext(ctx, "import"), reader, *format, *dryRun)
+	if err != nil {
+		a.logger.Error("Import error", log.M{"err": err})
+
+		return fmt.Errorf("import error: %w", err)
+	}
+
+	for _, lineErr := range report.Errors {
+		a.logger.Warn("Skipped line", log.M{"line": lineErr.Line, "err": lineErr.Error})
+	}
+
+	a.logger.Info("Import is done", log.M{
+		"dryRun":          report.DryRun,
+		"total":           report.Total,
+		"valid":           report.Valid,
+		"imported":        report.Imported,
+		"failed":          report.Failed,
+		"errorsTruncated": report.ErrorsTruncated,
+	})
+
+	return nil
+}
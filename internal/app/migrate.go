@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/migrate"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/sqlite"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+var (
+	errMigrateUsage = errors.New("usage: migrate up|down|status|to <version>")
+	errNoMigrations = errors.New("repository doesn't have migrations")
+)
+
+// Migrate runs the 'migrate' command: up, down, status or to N.
+func Migrate(ctx context.Context, params *Params, args []string) error {
+	logger := params.Logger.Named("app")
+
+	migrator, err := openMigrator(params, logger)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		err = migrator.Down(ctx)
+	case "to":
+		if len(args) != 2 { //nolint:gomnd
+			return errMigrateUsage
+		}
+
+		version, parseErr := strconv.ParseInt(args[1], 10, 64)
+		if parseErr != nil {
+			return fmt.Errorf("wrong version '%s': %w", args[1], errMigrateUsage)
+		}
+
+		err = migrator.To(ctx, version)
+	case "status":
+		return logStatus(ctx, migrator, logger)
+	default:
+		return errMigrateUsage
+	}
+
+	if err != nil {
+		logger.Error("Migration error", log.M{"err": err})
+
+		return fmt.Errorf("migration error: %w", err)
+	}
+
+	return logStatus(ctx, migrator, logger)
+}
+
+func logStatus(ctx context.Context, migrator *migrate.Migrator, logger log.Logger) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("can't get migrations status: %w", err)
+	}
+
+	for _, status := range statuses {
+		args := log.M{"version": status.Version, "name": status.Name, "applied": status.Applied}
+		if status.Applied {
+			args["appliedAt"] = status.AppliedAt
+		}
+
+		logger.Info("Migration", args)
+	}
+
+	return nil
+}
+
+// openMigrator opens the database of the SQL repository.
+func openMigrator(params *Params, logger log.Logger) (*migrate.Migrator, error) {
+	switch params.Repository {
+	case RepositoryPostgres, "":
+		//nolint:varnamelen
+		db, err := openDB(params.DsnDB, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		return newMigrator(db, migrate.Postgres, logger)
+	case RepositorySQLite:
+		//nolint:varnamelen
+		db, err := sqlite.Open(params.SQLitePath, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		return newMigrator(db.Gorm(), migrate.SQLite, logger)
+	default:
+		return nil, fmt.Errorf("repository '%s': %w", params.Repository, errNoMigrations)
+	}
+}
+
+func checkSchema(db *gorm.DB, timeout time.Duration, logger log.Logger) error {
+	migrator, err := newMigrator(db, migrate.Postgres, logger)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err = migrator.Check(ctx)
+	if err != nil {
+		logger.Error("Database schema check error", log.M{"err": err})
+
+		return fmt.Errorf("database schema check error: %w", err)
+	}
+
+	return nil
+}
+
+// migrateUp applies all migrations on startup.
+func migrateUp(db *gorm.DB, dialect string, timeout time.Duration, logger log.Logger) error {
+	migrator, err := newMigrator(db, dialect, logger)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err = migrator.Up(ctx)
+	if err != nil {
+		logger.Error("Migration error", log.M{"err": err})
+
+		return fmt.Errorf("migration error: %w", err)
+	}
+
+	return nil
+}
+
+//nolint:varnamelen
+func newMigrator(db *gorm.DB, dialect string, logger log.Logger) (*migrate.Migrator, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("can't get SQL DB: %w", err)
+	}
+
+	migrator, err := migrate.New(sqlDB, dialect, logger)
+	if err != nil {
+		return nil, fmt.Errorf("can't up migrator: %w", err)
+	}
+
+	return migrator, nil
+}
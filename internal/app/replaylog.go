@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/file"
+	"github.com/andrsj/feedback-service/internal/services/dlq"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+const defaultReplayLogBatch = 100
+
+var errReplayLogUsage = errors.New("usage: replay-log [-dry-run] [-batch N] [-skip N] <file>...")
+
+// ReplayLog runs the 'replay-log' command: publishes the events of the file broker to Kafka in order.
+// It's without the whole application, so BROKER may stay 'file', the Kafka settings are used anyway.
+// The files are replayed in the order of the arguments, see file.Rotated.
+func ReplayLog(ctx context.Context, params *Params, args []string) error {
+	logger := params.Logger.Named("app")
+
+	flags := flag.NewFlagSet("replay-log", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "read the files without publishing")
+	batch := flags.Int("batch", defaultReplayLogBatch, "events per send")
+	skip := flags.Int("skip", 0, "events to skip, e.g. the published ones of the failed run")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 || *batch <= 0 || *skip < 0 {
+		return errReplayLogUsage
+	}
+
+	replayer := &logReplayer{
+		producer: nil,
+		batch:    *batch,
+		skip:     *skip,
+		pending:  nil,
+		read:     0,
+		sent:     0,
+	}
+
+	if !*dryRun {
+		producer, err := newKafka(params, nil, nil, logger)
+		if err != nil {
+			return err
+		}
+		defer producer.Close()
+
+		replayer.producer = producer
+	}
+
+	ctx = commandContext(ctx, "replay-log")
+
+	for _, path := range flags.Args() {
+		logger.Info("Replaying the file", log.M{"path": path, "dryRun": *dryRun})
+
+		err := replayer.replayFile(ctx, path)
+		if err != nil {
+			logger.Error("Replay error", log.M{"err": err, "path": path, "read": replayer.read, "sent": replayer.sent})
+
+			return fmt.Errorf("replay of '%s' error, %d events are sent: %w", path, replayer.sent, err)
+		}
+	}
+
+	logger.Info("Replay is done", log.M{"read": replayer.read, "sent": replayer.sent, "dryRun": *dryRun})
+
+	return nil
+}
+
+// logReplayer sends the events by batches in order, the events keep their IDs, so the consumers apply them once.
+type logReplayer struct {
+	producer dlq.Publisher
+	batch    int
+	skip     int
+	pending  []*models.FeedbackEvent
+	// Events read from all files and sent, the skipped ones are counted as sent.
+	read int
+	sent int
+}
+
+func (r *logReplayer) replayFile(ctx context.Context, path string) error {
+	logFile, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("can't open the file: %w", err)
+	}
+	defer logFile.Close()
+
+	err = file.ReadRecords(logFile, func(line int, record *file.Record) error {
+		r.read++
+
+		if r.read <= r.skip {
+			r.sent++
+
+			return nil
+		}
+
+		if len(r.pending) == r.batch {
+			err := r.flush(ctx)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+
+		r.pending = append(r.pending, record.Event())
+
+		return nil
+	})
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	// The batch doesn't go over the files, so the error is about one file.
+	return r.flush(ctx)
+}
+
+func (r *logReplayer) flush(ctx context.Context) error {
+	if len(r.pending) == 0 {
+		return nil
+	}
+
+	events := r.pending
+	r.pending = nil
+
+	if r.producer == nil {
+		r.sent += len(events)
+
+		return nil
+	}
+
+	err := r.producer.PublishBatch(ctx, events)
+	if err != nil {
+		return fmt.Errorf("sending %d events: %w", len(events), err)
+	}
+
+	r.sent += len(events)
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+var errPurgeUsage = errors.New("usage: purge [-dry-run] [-batch N]")
+
+// Purge runs the 'purge' command: one run of the retention policies.
+func (a *App) Purge(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "count the expired feedbacks without changes")
+	batch := flags.Int("batch", 0, "feedbacks per transaction, RETENTION_BATCH by default")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 || *batch < 0 {
+		return errPurgeUsage
+	}
+
+	if len(a.retention.Policies()) == 0 {
+		a.logger.Warn("Retention policies aren't configured, nothing to purge", nil)
+
+		return nil
+	}
+
+	err := a.purge(commandContext(ctx, "purge"), *dryRun, *batch)
+	if err != nil {
+		return fmt.Errorf("purge error: %w", err)
+	}
+
+	return nil
+}
+
+// scheduleRetention runs the purge every retention interval until the context is cancelled.
+// Every instance of the server runs it, the concurrent runs are safe: the rows are locked
+// and the processed ones are skipped, but one instance or the 'purge' command by cron is enough.
+func (a *App) scheduleRetention(ctx context.Context) {
+	if a.retentionInterval <= 0 || len(a.retention.Policies()) == 0 {
+		return
+	}
+
+	a.logger.Info("Retention is scheduled", log.M{
+		"interval": a.retentionInterval.String(),
+		"policies": len(a.retention.Policies()),
+	})
+
+	ctx = reqctx.WithActor(ctx, reqctx.Actor{Subject: "job:retention", Role: "system"})
+
+	ticker := time.NewTicker(a.retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// The error is logged, the next run tries again.
+			_ = a.purge(ctx, false, 0)
+		}
+	}
+}
+
+func (a *App) purge(ctx context.Context, dryRun bool, batch int) error {
+	report, err := a.retention.Purge(ctx, dryRun, batch)
+
+	for _, result := range report.Policies {
+		a.logger.Info("Retention policy", log.M{
+			"policy":        result.Policy,
+			"createdBefore": result.CreatedBefore,
+			"matched":       result.Matched,
+			"processed":     result.Processed,
+			"dryRun":        report.DryRun,
+		})
+	}
+
+	if err != nil {
+		a.logger.Error("Purge error", log.M{"err": err})
+
+		return err //nolint:wrapcheck
+	}
+
+	a.logger.Info("Purge is done", log.M{
+		"dryRun":     report.DryRun,
+		"deleted":    report.Deleted,
+		"anonymized": report.Anonymized,
+		"duration":   report.Duration,
+	})
+
+	return nil
+}
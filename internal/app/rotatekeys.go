@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	repo "github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/sqlite"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+const defaultRotateBatch = 500
+
+var (
+	errRotateKeysUsage = errors.New("usage: rotate-keys [-batch N]")
+	errNoEncryptedData = errors.New("the repository doesn't keep encrypted data")
+)
+
+type keyRotator interface {
+	RotateKeys(ctx context.Context, batchSize int) (rotated int, err error)
+}
+
+// Check that actual implementation fits the interface.
+var (
+	_ keyRotator = (*repo.FeedbackRepository)(nil)
+	_ keyRotator = (*sqlite.FeedbackRepository)(nil)
+)
+
+// RotateKeys runs the 'rotate-keys' command: re-encrypts the personal data by the active key.
+// It's safe to run with the working server and to run again after the failure.
+func (a *App) RotateKeys(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("rotate-keys", flag.ContinueOnError)
+	batch := flags.Int("batch", defaultRotateBatch, "rows per transaction")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 || *batch <= 0 {
+		return errRotateKeysUsage
+	}
+
+	rotator, ok := a.repos.feedbacks.(keyRotator)
+	if !ok {
+		return errNoEncryptedData
+	}
+
+	a.logger.Info("Starting the key rotation", log.M{"batch": *batch})
+
+	rotated, err := rotator.RotateKeys(ctx, *batch)
+	if err != nil {
+		a.logger.Error("Key rotation error", log.M{"err": err, "rotated": rotated})
+
+		return fmt.Errorf("key rotation error: %w", err)
+	}
+
+	a.logger.Info("Key rotation is done", log.M{"rotated": rotated})
+
+	return nil
+}
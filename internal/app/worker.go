@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/kafka"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/schema"
+	"github.com/andrsj/feedback-service/internal/services/projection"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+var (
+	errWorkerRepository = errors.New("worker needs the postgres or sqlite repository for the projections")
+	errWorkerBroker     = errors.New("worker consumes only from kafka")
+)
+
+// Worker runs the 'worker' command: the consumer of the feedback topic builds the projections
+// until the context is cancelled. The offsets are committed after the events are applied,
+// so the restarted worker continues from the last applied event of every partition.
+func (a *App) Worker(ctx context.Context) error {
+	if a.repos.projections == nil {
+		return errWorkerRepository
+	}
+
+	if a.params.Broker != BrokerKafka && a.params.Broker != "" {
+		return errWorkerBroker
+	}
+
+	registry := newRegistry(a.params)
+
+	// The in-process registry knows only its own schemas, the latest one gets the same ID as in the server.
+	if _, ok := registry.(*schema.Memory); ok {
+		_, err := newSerializer(a.params, registry)
+		if err != nil {
+			return err
+		}
+	}
+
+	service := projection.New(a.repos.projections, a.params.ProjectionLatestN, a.params.ProjectionEventTTL, a.logger)
+
+	consumer, err := kafka.NewConsumer(
+		a.logger,
+		a.params.KafkaHost,
+		a.params.KafkaTopic,
+		a.params.KafkaGroupID,
+		a.params.BrokerTimeout,
+		schema.NewDeserializer(registry),
+		service,
+	)
+	if err != nil {
+		return fmt.Errorf("can't up kafka consumer: %w", err)
+	}
+
+	a.logger.Info("Starting the worker", log.M{"topic": a.params.KafkaTopic, "group": a.params.KafkaGroupID})
+
+	go service.Run(ctx)
+
+	err = consumer.Run(ctx)
+	if err != nil {
+		a.logger.Error("Worker error", log.M{"err": err})
+
+		return fmt.Errorf("worker error: %w", err)
+	}
+
+	// The session is over after Run, Close leaves the group, so the partitions are reassigned at once.
+	err = consumer.Close()
+	if err != nil {
+		a.logger.Error("Consumer closing error", log.M{"err": err})
+
+		return fmt.Errorf("closing consumer: %w", err)
+	}
+
+	a.logger.Info("Worker stopped", nil)
+
+	return nil
+}
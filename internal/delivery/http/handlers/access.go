@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andrsj/feedback-service/internal/services/access"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// ExportCustomer GET /customers/by-email/{email}/export
+// Starts the export of all data of the customer, the status is returned by GetCustomerExport.
+func (h *Handlers) ExportCustomer(w http.ResponseWriter, r *http.Request) {
+	export, err := h.accessService.Start(r.Context(), chi.URLParam(r, "email"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, access.ErrInvalidEmail) {
+			status = http.StatusBadRequest
+		}
+
+		h.handleError(w, status, err)
+
+		return
+	}
+
+	w.Header().Set("Location", "/customers/exports/"+export.ID.String())
+	h.writeJSON(w, http.StatusAccepted, export)
+}
+
+// GetCustomerExport GET /customers/exports/{id}
+// Returns the status of the export and the download link when it's done.
+func (h *Handlers) GetCustomerExport(w http.ResponseWriter, r *http.Request) {
+	export, err := h.accessService.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, access.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+
+		h.handleError(w, status, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, export)
+}
+
+// DownloadCustomerExport GET /customers/exports/{id}/download?expires=...&signature=...
+// The link is signed, so it doesn't need JWT.
+func (h *Handlers) DownloadCustomerExport(w http.ResponseWriter, r *http.Request) {
+	exportID := chi.URLParam(r, "id")
+	query := r.URL.Query()
+
+	file, err := h.accessService.Open(exportID, query.Get("expires"), query.Get("signature"))
+	if err != nil {
+		status := http.StatusInternalServerError
+
+		switch {
+		case errors.Is(err, access.ErrInvalidLink):
+			status = http.StatusForbidden
+		case errors.Is(err, access.ErrLinkExpired):
+			status = http.StatusGone
+		case errors.Is(err, access.ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, access.ErrNotReady):
+			status = http.StatusConflict
+		}
+
+		h.handleError(w, status, err)
+
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="customer-export-`+exportID+`.zip"`)
+	w.WriteHeader(http.StatusOK)
+
+	_, err = io.Copy(w, file)
+	if err != nil {
+		h.logger.Error("sending export archive", logger.M{"export": exportID, "err": err})
+	}
+}
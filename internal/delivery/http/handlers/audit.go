@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/andrsj/feedback-service/internal/services/audit"
+)
+
+const entityQueryParam = "entity"
+
+// GetAudit GET /audit?entity=feedback|rule&id=...
+// Returns the history of the entity from the oldest change.
+func (h *Handlers) GetAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	entityID := query.Get("id")
+	if entityID == "" {
+		h.handleError(w, http.StatusBadRequest, errIDParamIsMissing)
+
+		return
+	}
+
+	entries, err := h.auditService.GetByEntity(r.Context(), query.Get(entityQueryParam), entityID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, audit.ErrInvalidQuery) {
+			status = http.StatusBadRequest
+		}
+
+		h.handleError(w, status, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/andrsj/feedback-service/internal/services/dlq"
+)
+
+const afterQueryParam = "after"
+
+// GetDeadLetters GET /dlq?limit=100&after=<id>
+// The page of the dead-letter queue in the order of the failures, after is the last ID of the previous page.
+func (h *Handlers) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit, err := checkLimit(r.URL.Query())
+	if err != nil {
+		h.handleError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	letters, err := h.dlqService.List(r.Context(), limit, r.URL.Query().Get(afterQueryParam))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, dlq.ErrInvalidRequest) {
+			status = http.StatusBadRequest
+		}
+
+		h.handleError(w, status, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, letters)
+}
+
+// ReplayDeadLetters POST /dlq/replay {"ids": ["<id>", ...]}
+// Publishes the events of the letters by the rate of DLQ_REPLAY_RATE and returns the report,
+// one replay runs at a time, the other one gets 409.
+func (h *Handlers) ReplayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		IDs []string `json:"ids"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		h.handleError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON: %w", err))
+
+		return
+	}
+
+	report, err := h.dlqService.Replay(r.Context(), request.IDs)
+	if err != nil {
+		status := http.StatusInternalServerError
+
+		switch {
+		case errors.Is(err, dlq.ErrInvalidRequest):
+			status = http.StatusBadRequest
+		case errors.Is(err, dlq.ErrReplayRunning):
+			status = http.StatusConflict
+		}
+
+		h.handleError(w, status, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andrsj/feedback-service/internal/services/erasure"
+)
+
+// EraseCustomer DELETE /customers/by-email/{email}
+// Deletes all data of the customer and returns the signed receipt,
+// it's also saved in the audit log with entity "erasure".
+func (h *Handlers) EraseCustomer(w http.ResponseWriter, r *http.Request) {
+	email := chi.URLParam(r, "email")
+
+	receipt, err := h.erasureService.EraseByEmail(r.Context(), email)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, erasure.ErrInvalidEmail) {
+			status = http.StatusBadRequest
+		}
+
+		h.handleError(w, status, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, receipt)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+const (
+	formatQueryParam = "format"
+	formatNDJSON     = "ndjson"
+	formatCSV        = "csv"
+)
+
+var errFormatParam = errors.New("invalid format parameter")
+
+// ExportFeedbacks GET /feedbacks/export?format=ndjson|csv.
+func (h *Handlers) ExportFeedbacks(w http.ResponseWriter, r *http.Request) {
+	filter, err := validateFilter(r.URL.Query())
+	if err != nil {
+		h.handleError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	format := r.URL.Query().Get(formatQueryParam)
+
+	var (
+		stream *streamWriter
+		write  func(record *models.FeedbackRecord) error
+		// finish is called after the last record, e.g. for the export without records.
+		finish = func() error { return nil }
+	)
+
+	switch format {
+	case formatNDJSON, "":
+		format = formatNDJSON
+		stream = newStreamWriter(w, r, "application/x-ndjson")
+		encoder := json.NewEncoder(stream)
+
+		write = func(record *models.FeedbackRecord) error {
+			return encoder.Encode(record) //nolint:wrapcheck
+		}
+	case formatCSV:
+		stream = newStreamWriter(w, r, "text/csv; charset=utf-8")
+		writer := csv.NewWriter(stream)
+		header := true
+
+		// The header isn't written before the first record,
+		// so the error of the first query is still sent as JSON with the status.
+		writeHeader := func() error {
+			if !header {
+				return nil
+			}
+
+			header = false
+
+			return writer.Write(models.CSVHeader) //nolint:wrapcheck
+		}
+
+		write = func(record *models.FeedbackRecord) error {
+			if err := writeHeader(); err != nil {
+				return err
+			}
+
+			if err := writer.Write(record.CSV()); err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			// Flushed on every record, csv.Writer has its own buffer.
+			writer.Flush()
+
+			return writer.Error() //nolint:wrapcheck
+		}
+
+		// The empty export is the header only.
+		finish = func() error {
+			if err := writeHeader(); err != nil {
+				return err
+			}
+
+			writer.Flush()
+
+			return writer.Error() //nolint:wrapcheck
+		}
+	default:
+		h.handleError(w, http.StatusBadRequest, fmt.Errorf("wrong format '%s': %w", format, errFormatParam))
+
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="feedbacks.%s"`, format))
+
+	err = h.feedbackService.Stream(r.Context(), filter, func(feedbacks []*models.Feedback) error {
+		for _, feedback := range feedbacks {
+			if err := write(models.NewFeedbackRecord(feedback)); err != nil {
+				return err
+			}
+		}
+
+		return stream.Flush()
+	})
+	if err == nil {
+		err = finish()
+	}
+
+	h.finishStream(w, stream, err)
+}
+
+// finishStream closes the successful stream. After an error the status is already sent,
+// so the connection is aborted and the client doesn't get truncated data as the whole.
+func (h *Handlers) finishStream(w http.ResponseWriter, stream *streamWriter, err error) {
+	if err == nil {
+		err = stream.Close()
+		if err == nil {
+			return
+		}
+	}
+
+	if !stream.started {
+		h.handleError(w, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.logger.Error("streaming error, aborting the response", logger.M{"err": err})
+
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,224 @@
+package handlers_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/delivery/http/handlers"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+)
+
+var errDatabase = errors.New("database is down")
+
+// streamService returns the chunks by Stream, the other methods aren't used by the export.
+type streamService struct {
+	handlers.Service
+	chunks [][]*models.Feedback
+	err    error
+	filter *models.FeedbackFilter
+}
+
+func (s *streamService) Stream(
+	_ context.Context,
+	filter *models.FeedbackFilter,
+	callback func(feedbacks []*models.Feedback) error,
+) error {
+	s.filter = filter
+
+	for _, chunk := range s.chunks {
+		if err := callback(chunk); err != nil {
+			return err
+		}
+	}
+
+	return s.err
+}
+
+func newChunks(sizes ...int) [][]*models.Feedback {
+	chunks := make([][]*models.Feedback, 0, len(sizes))
+
+	for _, size := range sizes {
+		chunk := make([]*models.Feedback, 0, size)
+
+		for i := 0; i < size; i++ {
+			//nolint:exhaustivestruct,exhaustruct
+			chunk = append(chunk, &models.Feedback{
+				ID:           uuid.New(),
+				CustomerName: "Jane",
+				Email:        "jane@example.com",
+				FeedbackText: "ok, \"quoted\"\nnext line",
+				Source:       "https://shop.example.com",
+				Tags:         []string{"shop"},
+			})
+		}
+
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+func export(service *streamService, target, acceptEncoding string) *httptest.ResponseRecorder {
+	h := handlers.New(service, nil, nil, nil, nil, nil, nil, nil, nil, nopLogger{})
+
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	if acceptEncoding != "" {
+		request.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	recorder := httptest.NewRecorder()
+	h.ExportFeedbacks(recorder, request)
+
+	return recorder
+}
+
+func TestExportNDJSON(t *testing.T) {
+	t.Parallel()
+
+	//nolint:exhaustivestruct,exhaustruct
+	service := &streamService{chunks: newChunks(2, 1)}
+
+	recorder := export(service, "/feedbacks/export?format=ndjson&email=%20jane@example.com&sentiment=positive", "")
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("got %d %q: %s", recorder.Code, recorder.Header().Get("Content-Type"), recorder.Body)
+	}
+
+	if service.filter.Email != "jane@example.com" || service.filter.Sentiment != "positive" {
+		t.Errorf("filter: got %+v", service.filter)
+	}
+
+	// Every feedback of every chunk is one line in the order of the chunks.
+	var ids []string
+
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+
+		id, _ := record["id"].(string)
+		ids = append(ids, id)
+	}
+
+	want := []*models.Feedback{service.chunks[0][0], service.chunks[0][1], service.chunks[1][0]}
+	if len(ids) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(ids), len(want))
+	}
+
+	for i, feedback := range want {
+		if ids[i] != feedback.ID.String() {
+			t.Errorf("line %d: got %s, want %s", i, ids[i], feedback.ID)
+		}
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	t.Parallel()
+
+	//nolint:exhaustivestruct,exhaustruct
+	service := &streamService{chunks: newChunks(1, 2)}
+
+	recorder := export(service, "/feedbacks/export?format=csv", "gzip")
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("got %d %v", recorder.Code, recorder.Header())
+	}
+
+	reader, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+
+	rows, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+
+	if len(rows) != 4 || strings.Join(rows[0], ",") != strings.Join(models.CSVHeader, ",") {
+		t.Fatalf("got %v", rows)
+	}
+
+	// The text with the quotes and the line break is one value.
+	if rows[1][0] != service.chunks[0][0].ID.String() || rows[1][3] != service.chunks[0][0].FeedbackText {
+		t.Errorf("first row: got %v", rows[1])
+	}
+
+	// The empty export is the header only.
+	//nolint:exhaustivestruct,exhaustruct
+	empty := export(&streamService{}, "/feedbacks/export?format=csv", "")
+	if body := empty.Body.String(); body != strings.Join(models.CSVHeader, ",")+"\n" {
+		t.Errorf("empty export: got %q", body)
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	t.Parallel()
+
+	for target, want := range map[string]int{
+		"/feedbacks/export?format=xml":       http.StatusBadRequest,
+		"/feedbacks/export?sentiment=wrong":  http.StatusBadRequest,
+		"/feedbacks/export?format=csv&err=1": http.StatusInternalServerError,
+	} {
+		//nolint:exhaustivestruct,exhaustruct
+		recorder := export(&streamService{err: errDatabase}, target, "")
+
+		var body map[string]string
+		if recorder.Code != want || json.NewDecoder(recorder.Body).Decode(&body) != nil || body["error"] == "" {
+			t.Errorf("%s: got %d %q, want %d with the error", target, recorder.Code, recorder.Body, want)
+		}
+	}
+
+	// After the first chunk the status is sent, the response is aborted instead of the truncated data.
+	//nolint:exhaustivestruct,exhaustruct
+	service := &streamService{chunks: newChunks(1), err: errDatabase}
+
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler { //nolint:errorlint
+			t.Errorf("got %v, want %v", recovered, http.ErrAbortHandler)
+		}
+	}()
+
+	export(service, "/feedbacks/export", "")
+	t.Error("the response isn't aborted")
+}
+
+func TestGetAllFeedbackStreamsJSONArray(t *testing.T) {
+	t.Parallel()
+
+	h := func(service *streamService) []*models.Feedback {
+		recorder := httptest.NewRecorder()
+		handlers.New(service, nil, nil, nil, nil, nil, nil, nil, nil, nopLogger{}).
+			GetAllFeedback(recorder, httptest.NewRequest(http.MethodGet, "/feedbacks", nil))
+
+		body, _ := io.ReadAll(recorder.Body)
+
+		var feedbacks []*models.Feedback
+		if err := json.Unmarshal(body, &feedbacks); err != nil || feedbacks == nil {
+			t.Fatalf("got %q: %v", body, err)
+		}
+
+		return feedbacks
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	if got := h(&streamService{chunks: newChunks(2, 3)}); len(got) != 5 {
+		t.Errorf("got %d feedbacks, want 5", len(got))
+	}
+
+	// Nothing is the empty array, not null.
+	//nolint:exhaustivestruct,exhaustruct
+	if got := h(&streamService{}); len(got) != 0 {
+		t.Errorf("got %d feedbacks, want 0", len(got))
+	}
+}
@@ -4,20 +4,29 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/google/uuid"
 
 	"github.com/andrsj/feedback-service/internal/domain/models"
+	feedbackService "github.com/andrsj/feedback-service/internal/services/feedback"
+	"github.com/andrsj/feedback-service/internal/services/sentiment"
 )
 
 const (
-	defaultLimit    = 10
-	limitQueryParam = "limit"
-	nextQueryParam  = "next"
+	// Seconds for the client to wait when the broker queue is full.
+	retryAfterBusy = "1"
+
+	defaultLimit        = 10
+	limitQueryParam     = "limit"
+	nextQueryParam      = "next"
+	sentimentQueryParam = "sentiment"
+	searchQueryParam    = "q"
+	emailQueryParam     = "email"
 )
 
 var (
@@ -25,6 +34,7 @@ var (
 	errLimitParam       = errors.New("invalid limit parameter")
 	errNextParam        = errors.New("invalid next parameter")
 	errNoValuesNext     = errors.New("no values after 'next'")
+	errSentimentParam   = errors.New("invalid sentiment parameter")
 )
 
 // GetFeedback GET /feedback/{id}.
@@ -36,7 +46,7 @@ func (h *Handlers) GetFeedback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	feedback, err := h.feedbackService.GetByID(feedbackID)
+	feedback, err := h.feedbackService.GetByID(r.Context(), feedbackID)
 	if err != nil {
 		h.handleError(w, http.StatusNotFound, err)
 
@@ -54,24 +64,55 @@ func (h *Handlers) GetFeedback(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// GetAllFeedback GET /feedbacks.
-func (h *Handlers) GetAllFeedback(w http.ResponseWriter, _ *http.Request) {
-	feedbacks, err := h.feedbackService.GetAll()
+// GetAllFeedback GET /feedbacks, the JSON array is streamed by chunks.
+func (h *Handlers) GetAllFeedback(w http.ResponseWriter, r *http.Request) {
+	filter, err := validateFilter(r.URL.Query())
 	if err != nil {
 		h.handleError(w, http.StatusBadRequest, err)
 
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(feedbacks)
-	if err != nil {
-		h.handleError(w, http.StatusInternalServerError, err)
+	var (
+		stream = newStreamWriter(w, r, "application/json")
+		count  int
+	)
 
-		return
+	err = h.feedbackService.Stream(r.Context(), filter, func(feedbacks []*models.Feedback) error {
+		for _, feedback := range feedbacks {
+			data, err := json.Marshal(feedback)
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			separator := ","
+			if count == 0 {
+				separator = "["
+			}
+
+			if _, err = io.WriteString(stream, separator); err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			if _, err = stream.Write(data); err != nil {
+				return err
+			}
+
+			count++
+		}
+
+		return stream.Flush()
+	})
+	if err == nil {
+		end := "]\n"
+		if count == 0 {
+			end = "[]\n"
+		}
+
+		_, err = io.WriteString(stream, end)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	h.finishStream(w, stream, err)
 }
 
 // CreateFeedback POST /feedback.
@@ -85,7 +126,14 @@ func (h *Handlers) CreateFeedback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	feedbackID, err := h.feedbackService.Create(&feedback)
+	feedbackID, err := h.feedbackService.Create(r.Context(), &feedback)
+	if errors.Is(err, feedbackService.ErrBrokerBusy) {
+		w.Header().Set("Retry-After", retryAfterBusy)
+		h.handleError(w, http.StatusServiceUnavailable, err)
+
+		return
+	}
+
 	if err != nil {
 		h.handleError(w, http.StatusInternalServerError, err)
 
@@ -112,6 +160,7 @@ func (h *Handlers) GetPageFeedbacks(w http.ResponseWriter, r *http.Request) {
 		nextURL    string
 		err        error
 		feedbacks  []*models.Feedback
+		filter     *models.FeedbackFilter
 	)
 
 	limit, nextInput, err = validatePaginator(r.URL.Query())
@@ -121,7 +170,14 @@ func (h *Handlers) GetPageFeedbacks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	feedbacks, nextOutput, err = h.feedbackService.GetPage(limit, nextInput)
+	filter, err = validateFilter(r.URL.Query())
+	if err != nil {
+		h.handleError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	feedbacks, nextOutput, err = h.feedbackService.GetPage(r.Context(), limit, nextInput, filter)
 	if err != nil {
 		h.handleError(w, http.StatusBadRequest, err)
 
@@ -136,6 +192,18 @@ func (h *Handlers) GetPageFeedbacks(w http.ResponseWriter, r *http.Request) {
 	}
 
 	nextURL = fmt.Sprintf("/p-feedbacks?%s=%d&%s=%s", limitQueryParam, limit, nextQueryParam, nextOutput)
+	if filter.Sentiment != "" {
+		nextURL += fmt.Sprintf("&%s=%s", sentimentQueryParam, filter.Sentiment)
+	}
+
+	if filter.Search != "" {
+		nextURL += fmt.Sprintf("&%s=%s", searchQueryParam, url.QueryEscape(filter.Search))
+	}
+
+	if filter.Email != "" {
+		nextURL += fmt.Sprintf("&%s=%s", emailQueryParam, url.QueryEscape(filter.Email))
+	}
+
 	w.Header().Set("URL-cursor-next", nextURL)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -149,6 +217,19 @@ func (h *Handlers) GetPageFeedbacks(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func validateFilter(queryParams url.Values) (*models.FeedbackFilter, error) {
+	sentimentLabel := queryParams.Get(sentimentQueryParam)
+	if sentimentLabel != "" && !sentiment.IsValidLabel(sentimentLabel) {
+		return nil, fmt.Errorf("wrong sentiment param '%s': %w", sentimentLabel, errSentimentParam)
+	}
+
+	return &models.FeedbackFilter{
+		Sentiment: sentimentLabel,
+		Search:    strings.TrimSpace(queryParams.Get(searchQueryParam)),
+		Email:     strings.TrimSpace(queryParams.Get(emailQueryParam)),
+	}, nil
+}
+
 func validatePaginator(queryParams url.Values) (int, string, error) {
 	var (
 		err   error
@@ -177,7 +258,7 @@ func checkNext(queryParams url.Values) (string, error) {
 
 	next = queryParams.Get(nextQueryParam)
 	if next != "" {
-		_, err = uuid.Parse(next)
+		_, err = models.ParsePageCursor(next)
 		if err != nil {
 			return next, fmt.Errorf("wrong format of next ID: %w", errNextParam)
 		}
@@ -1,36 +1,141 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"strconv"
 	"time"
 
 	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/services/access"
+	"github.com/andrsj/feedback-service/internal/services/audit"
+	"github.com/andrsj/feedback-service/internal/services/dlq"
+	"github.com/andrsj/feedback-service/internal/services/erasure"
 	"github.com/andrsj/feedback-service/internal/services/feedback"
+	"github.com/andrsj/feedback-service/internal/services/rules"
+	"github.com/andrsj/feedback-service/internal/services/stats"
+	"github.com/andrsj/feedback-service/internal/services/webhooks"
 	"github.com/andrsj/feedback-service/pkg/logger"
 )
 
 type Service interface {
-	Create(feedback *models.FeedbackInput) (string, error)
-	GetByID(feedbackID string) (*models.Feedback, error)
-	GetAll() ([]*models.Feedback, error)
-	GetPage(limit int, next string) ([]*models.Feedback, string, error)
+	Create(ctx context.Context, feedback *models.FeedbackInput) (string, error)
+	GetByID(ctx context.Context, feedbackID string) (*models.Feedback, error)
+	Import(ctx context.Context, reader io.Reader, format string, dryRun bool) (*models.ImportReport, error)
+	Stream(
+		ctx context.Context,
+		filter *models.FeedbackFilter,
+		callback func(feedbacks []*models.Feedback) error,
+	) error
+	GetPage(
+		ctx context.Context,
+		limit int,
+		next string,
+		filter *models.FeedbackFilter,
+	) ([]*models.Feedback, string, error)
+	DryRun(ctx context.Context, feedback *models.FeedbackInput) (*models.DryRunResult, error)
+}
+
+type RuleService interface {
+	Create(ctx context.Context, input *models.RuleInput) (*models.Rule, error)
+	GetByID(ctx context.Context, ruleID string) (*models.Rule, error)
+	GetAll(ctx context.Context) ([]*models.Rule, error)
+	Update(ctx context.Context, ruleID string, input *models.RuleInput) (*models.Rule, error)
+	Delete(ctx context.Context, ruleID string) error
+}
+
+type AuditService interface {
+	GetByEntity(ctx context.Context, entity, entityID string) ([]*models.AuditEntry, error)
+}
+
+type ErasureService interface {
+	EraseByEmail(ctx context.Context, email string) (*models.ErasureReceipt, error)
+}
+
+type AccessService interface {
+	Start(ctx context.Context, email string) (*models.AccessExport, error)
+	Get(ctx context.Context, exportID string) (*models.AccessExport, error)
+	Open(exportID, expires, signature string) (*os.File, error)
+}
+
+type StatsService interface {
+	Get(ctx context.Context, groupBy []string, filter *models.FeedbackFilter) ([]*models.FeedbackStat, error)
+	Sentiment(ctx context.Context) ([]*models.SentimentStat, error)
+	Latest(ctx context.Context, sourceHost string, limit int) ([]*models.LatestFeedback, error)
+}
+
+type DLQService interface {
+	List(ctx context.Context, limit int, after string) ([]*models.DeadLetter, error)
+	Replay(ctx context.Context, ids []string) (*models.ReplayReport, error)
+}
+
+type WebhookService interface {
+	Create(ctx context.Context, input *models.WebhookInput) (*models.Webhook, error)
+	GetByID(ctx context.Context, webhookID string) (*models.Webhook, error)
+	GetAll(ctx context.Context) ([]*models.Webhook, error)
+	Update(ctx context.Context, webhookID string, input *models.WebhookInput) (*models.Webhook, error)
+	Delete(ctx context.Context, webhookID string) error
+	Deliveries(ctx context.Context, webhookID string, limit int, before string) ([]*models.WebhookDelivery, error)
+	Test(ctx context.Context, webhookID string) (*models.WebhookDelivery, error)
+}
+
+// HealthChecker is the connection of the broker, see kafka.ResilientProducer.
+type HealthChecker interface {
+	Health() *models.BrokerHealth
 }
 
 // Check if the actual implementation fits the interface.
-var _ Service = (*feedback.Service)(nil)
+var (
+	_ Service        = (*feedback.Service)(nil)
+	_ RuleService    = (*rules.Service)(nil)
+	_ AuditService   = (*audit.Service)(nil)
+	_ ErasureService = (*erasure.Service)(nil)
+	_ AccessService  = (*access.Service)(nil)
+	_ StatsService   = (*stats.Service)(nil)
+	_ DLQService     = (*dlq.Service)(nil)
+	_ WebhookService = (*webhooks.Service)(nil)
+)
 
 type Handlers struct {
 	logger          logger.Logger
 	feedbackService Service
+	ruleService     RuleService
+	auditService    AuditService
+	erasureService  ErasureService
+	accessService   AccessService
+	statsService    StatsService
+	dlqService      DLQService
+	webhookService  WebhookService
+	brokerHealth    HealthChecker
 }
 
-func New(service Service, logger logger.Logger) *Handlers {
+func New(
+	service Service,
+	ruleService RuleService,
+	auditService AuditService,
+	erasureService ErasureService,
+	accessService AccessService,
+	statsService StatsService,
+	dlqService DLQService,
+	webhookService WebhookService,
+	brokerHealth HealthChecker,
+	logger logger.Logger,
+) *Handlers {
 	return &Handlers{
 		logger:          logger.Named("handlers"),
 		feedbackService: service,
+		ruleService:     ruleService,
+		auditService:    auditService,
+		erasureService:  erasureService,
+		accessService:   accessService,
+		statsService:    statsService,
+		dlqService:      dlqService,
+		webhookService:  webhookService,
+		brokerHealth:    brokerHealth,
 	}
 }
 
@@ -49,6 +154,16 @@ func (h *Handlers) handleError(w http.ResponseWriter, statusCode int, err error)
 	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()}) //nolint:errchkjson
 }
 
+func (h *Handlers) writeJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		h.logger.Error("encoding response error", logger.M{"err": err})
+	}
+}
+
 // ChatGPT's generated code for testing graceful shutdown.
 func (h *Handlers) FakeLongWork(w http.ResponseWriter, r *http.Request) {
 	queryValues := r.URL.Query()
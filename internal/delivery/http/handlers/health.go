@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+)
+
+// Health is 200 while the server works, the status is degraded while the broker isn't up.
+func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.health())
+}
+
+// Ready is 503 when the events of the new feedbacks can be kept only by the dead-letter queue:
+// the broker is down and its buffer is full or off.
+func (h *Handlers) Ready(w http.ResponseWriter, r *http.Request) {
+	health := h.health()
+
+	status := http.StatusOK
+	if !health.Broker.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+
+	h.writeJSON(w, status, health)
+}
+
+func (h *Handlers) health() *models.Health {
+	broker := h.brokerHealth.Health()
+
+	status := models.HealthOK
+	if broker.State != models.BrokerUp {
+		status = models.HealthDegraded
+	}
+
+	return &models.Health{
+		Status: status,
+		Broker: broker,
+	}
+}
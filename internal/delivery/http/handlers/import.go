@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/andrsj/feedback-service/internal/services/feedback"
+)
+
+const (
+	dryRunQueryParam = "dry_run"
+	// Name of the file field of multipart upload.
+	importFileField = "file"
+	// Max size of the request body, the bigger files are imported by the 'import' command.
+	maxImportSize = 64 << 20
+)
+
+var (
+	errDryRunParam = errors.New("invalid dry_run parameter")
+	errMissingFile = errors.New("missing 'file' field")
+)
+
+// ImportFeedbacks POST /feedbacks/import?format=ndjson|csv&dry_run=true.
+// The file is the request body or the 'file' field of multipart form,
+// without the format parameter it's taken from Content-Type or the file extension.
+func (h *Handlers) ImportFeedbacks(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+
+	if value := r.URL.Query().Get(dryRunQueryParam); value != "" {
+		var err error
+
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			h.handleError(w, http.StatusBadRequest, fmt.Errorf("wrong dry_run '%s': %w", value, errDryRunParam))
+
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	body, format, err := importBody(r)
+	if err != nil {
+		h.handleError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if value := r.URL.Query().Get(formatQueryParam); value != "" {
+		format = value
+	}
+
+	report, err := h.feedbackService.Import(r.Context(), body, format, dryRun)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+
+		status := http.StatusInternalServerError
+
+		switch {
+		case errors.Is(err, feedback.ErrInvalidImport):
+			status = http.StatusBadRequest
+		case errors.As(err, &tooLarge):
+			status = http.StatusRequestEntityTooLarge
+		}
+
+		h.handleError(w, status, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, report)
+}
+
+// importBody returns the uploaded file and the format guessed by its type.
+func importBody(r *http.Request) (io.Reader, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, formatByType(mediaType), nil
+	}
+
+	// The parts are read as a stream, the file isn't kept in memory or on disk.
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", fmt.Errorf("can't read multipart form: %w", err)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, "", errMissingFile
+		}
+
+		if err != nil {
+			return nil, "", fmt.Errorf("can't read multipart form: %w", err)
+		}
+
+		if part.FormName() != importFileField {
+			continue
+		}
+
+		format := formatByType(part.Header.Get("Content-Type"))
+		if strings.EqualFold(filepath.Ext(part.FileName()), "."+formatCSV) {
+			format = formatCSV
+		}
+
+		return part, format, nil
+	}
+}
+
+func formatByType(contentType string) string {
+	if strings.HasPrefix(contentType, "text/csv") {
+		return formatCSV
+	}
+
+	return formatNDJSON
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"net/http"
@@ -15,16 +16,20 @@ import (
 )
 
 const (
+	adminKeyHeader    = "X-Admin-Key"
 	defaultMinutes    = 10
 	defaultRole       = "all"
 	minutesQueryParam = "minutes"
 	roleQueryParam    = "role"
+	subjectQueryParam = "subject"
+	tenantQueryParam  = "tenant"
 	tokenPrefix       = "Bearer"
 )
 
 var (
 	errRoleParam    = errors.New("invalid role parameter")
 	errMinutesParam = errors.New("invalid minutes parameter")
+	errAdminKey     = errors.New("the admin role, subject and tenant need the admin key")
 )
 
 func (h *Handlers) Token(w http.ResponseWriter, r *http.Request) {
@@ -52,12 +57,24 @@ func (h *Handlers) Token(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	subject := queryParams.Get(subjectQueryParam)
+	tenant := queryParams.Get(tenantQueryParam)
+
+	err = checkAdminKey(r, role, subject, tenant)
+	if err != nil {
+		h.handleError(w, http.StatusForbidden, err)
+
+		return
+	}
+
 	h.logger.Info("Received data", logger.M{
-		"role": role,
-		"time": minutes,
+		"role":    role,
+		"time":    minutes,
+		"subject": subject,
+		"tenant":  tenant,
 	})
 
-	token, err := generateJWTToken(minutes, role)
+	token, err := generateJWTToken(minutes, role, subject, tenant)
 	if err != nil {
 		h.handleError(w, http.StatusInternalServerError, fmt.Errorf("can't create a token: %w", err))
 
@@ -75,7 +92,7 @@ func checkRole(queryParams url.Values) (string, error) {
 	role := queryParams.Get(roleQueryParam)
 	if role != "" {
 		switch role {
-		case "get", "post", "all":
+		case "get", "post", "all", "admin":
 			return role, nil
 		default:
 			return "", fmt.Errorf("wrong role '%s': %w", role, errRoleParam)
@@ -85,6 +102,22 @@ func checkRole(queryParams url.Values) (string, error) {
 	return defaultRole, nil
 }
 
+// checkAdminKey allows the admin role and the chosen subject and tenant
+// only for the requests with the configured ADMIN_KEY, so the open endpoint issues only the anonymous tokens.
+// There are no admin tokens without ADMIN_KEY.
+func checkAdminKey(r *http.Request, role, subject, tenant string) error {
+	if role != "admin" && subject == "" && tenant == "" {
+		return nil
+	}
+
+	adminKey := os.Getenv("ADMIN_KEY")
+	if adminKey == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(adminKeyHeader)), []byte(adminKey)) != 1 {
+		return errAdminKey
+	}
+
+	return nil
+}
+
 func checkMinutes(queryParams url.Values) (int64, error) {
 	var (
 		err     error
@@ -106,10 +139,12 @@ func checkMinutes(queryParams url.Values) (int64, error) {
 	return minutes, nil
 }
 
-func generateJWTToken(minutes int64, role string) (string, error) {
+func generateJWTToken(minutes int64, role, subject, tenant string) (string, error) {
 	const (
 		expiredAtKey = "expiredAt"
 		roleKey      = "role"
+		subjectKey   = "sub"
+		tenantKey    = "tenant"
 	)
 
 	secret := []byte(os.Getenv("SECRET"))
@@ -121,6 +156,14 @@ func generateJWTToken(minutes int64, role string) (string, error) {
 		roleKey:      role,
 	}
 
+	if subject != "" {
+		claims[subjectKey] = subject
+	}
+
+	if tenant != "" {
+		claims[tenantKey] = tenant
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	tokenString, err := token.SignedString(secret)
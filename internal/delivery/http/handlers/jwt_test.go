@@ -0,0 +1,69 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andrsj/feedback-service/internal/delivery/http/handlers"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) logger.Logger { return l }
+func (nopLogger) Debug(string, logger.M)       {}
+func (nopLogger) Info(string, logger.M)        {}
+func (nopLogger) Warn(string, logger.M)        {}
+func (nopLogger) Error(string, logger.M)       {}
+func (nopLogger) Fatal(string, logger.M)       {}
+
+func TestTokenNeedsAdminKey(t *testing.T) {
+	h := handlers.New(nil, nil, nil, nil, nil, nil, nil, nil, nil, nopLogger{})
+
+	token := func(query, adminKey string) int {
+		request := httptest.NewRequest(http.MethodGet, "/token?"+query, nil)
+		if adminKey != "" {
+			request.Header.Set("X-Admin-Key", adminKey)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.Token(recorder, request)
+
+		return recorder.Code
+	}
+
+	t.Setenv("SECRET", "secret")
+	t.Setenv("ADMIN_KEY", "")
+
+	// There are no admin tokens without ADMIN_KEY.
+	for query, want := range map[string]int{
+		"role=all":        http.StatusOK,
+		"role=get":        http.StatusOK,
+		"role=admin":      http.StatusForbidden,
+		"subject=andrii":  http.StatusForbidden,
+		"tenant=acme":     http.StatusForbidden,
+		"role=superadmin": http.StatusBadRequest,
+	} {
+		if got := token(query, "anything"); got != want {
+			t.Errorf("without ADMIN_KEY %s: got %d, want %d", query, got, want)
+		}
+	}
+
+	t.Setenv("ADMIN_KEY", "admin-key")
+
+	for _, test := range []struct {
+		query, adminKey string
+		want            int
+	}{
+		{"role=admin", "", http.StatusForbidden},
+		{"role=admin", "wrong", http.StatusForbidden},
+		{"role=all&tenant=acme", "wrong", http.StatusForbidden},
+		{"role=admin", "admin-key", http.StatusOK},
+		{"role=all&subject=andrii&tenant=acme", "admin-key", http.StatusOK},
+	} {
+		if got := token(test.query, test.adminKey); got != test.want {
+			t.Errorf("%s with key %q: got %d, want %d", test.query, test.adminKey, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/services/rules"
+)
+
+// GetRules GET /rules.
+func (h *Handlers) GetRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.ruleService.GetAll(r.Context())
+	if err != nil {
+		h.handleError(w, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, rules)
+}
+
+// GetRule GET /rules/{id}.
+func (h *Handlers) GetRule(w http.ResponseWriter, r *http.Request) {
+	ruleID := chi.URLParam(r, "id")
+	if ruleID == "" {
+		h.handleError(w, http.StatusBadRequest, errIDParamIsMissing)
+
+		return
+	}
+
+	rule, err := h.ruleService.GetByID(r.Context(), ruleID)
+	if err != nil {
+		h.handleError(w, http.StatusNotFound, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, rule)
+}
+
+// CreateRule POST /rules.
+func (h *Handlers) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var input models.RuleInput
+
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.handleError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	rule, err := h.ruleService.Create(r.Context(), &input)
+	if err != nil {
+		h.handleError(w, ruleErrorStatus(err, http.StatusInternalServerError), err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, rule)
+}
+
+// UpdateRule PUT /rules/{id}.
+func (h *Handlers) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	var input models.RuleInput
+
+	ruleID := chi.URLParam(r, "id")
+	if ruleID == "" {
+		h.handleError(w, http.StatusBadRequest, errIDParamIsMissing)
+
+		return
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.handleError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	rule, err := h.ruleService.Update(r.Context(), ruleID, &input)
+	if err != nil {
+		h.handleError(w, ruleErrorStatus(err, http.StatusNotFound), err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, rule)
+}
+
+// DeleteRule DELETE /rules/{id}.
+func (h *Handlers) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	ruleID := chi.URLParam(r, "id")
+	if ruleID == "" {
+		h.handleError(w, http.StatusBadRequest, errIDParamIsMissing)
+
+		return
+	}
+
+	err := h.ruleService.Delete(r.Context(), ruleID)
+	if err != nil {
+		h.handleError(w, http.StatusNotFound, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DryRunRules POST /rules/dry-run.
+func (h *Handlers) DryRunRules(w http.ResponseWriter, r *http.Request) {
+	var feedback models.FeedbackInput
+
+	err := json.NewDecoder(r.Body).Decode(&feedback)
+	if err != nil {
+		h.handleError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	result, err := h.feedbackService.DryRun(r.Context(), &feedback)
+	if err != nil {
+		h.handleError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// ruleErrorStatus returns 400 for invalid rules and otherStatus for the rest.
+func ruleErrorStatus(err error, otherStatus int) int {
+	if errors.Is(err, rules.ErrInvalidRule) {
+		return http.StatusBadRequest
+	}
+
+	return otherStatus
+}
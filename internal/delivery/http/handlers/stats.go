@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/andrsj/feedback-service/internal/services/stats"
+)
+
+const (
+	groupByQueryParam = "group_by"
+	sourceQueryParam  = "source"
+)
+
+// GetStats GET /feedbacks/stats?group_by=source,week
+// Counts feedbacks by the dimensions: source, day, week, month and tag.
+// The filter params are the same as for the list of feedbacks.
+func (h *Handlers) GetStats(w http.ResponseWriter, r *http.Request) {
+	filter, err := validateFilter(r.URL.Query())
+	if err != nil {
+		h.handleError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	groupBy := make([]string, 0)
+
+	for _, dimension := range strings.Split(r.URL.Query().Get(groupByQueryParam), ",") {
+		dimension = strings.ToLower(strings.TrimSpace(dimension))
+		if dimension != "" {
+			groupBy = append(groupBy, dimension)
+		}
+	}
+
+	result, err := h.statsService.Get(r.Context(), groupBy, filter)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, stats.ErrInvalidQuery) {
+			status = http.StatusBadRequest
+		}
+
+		h.handleError(w, status, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// GetSentimentStats GET /feedbacks/stats/sentiment.
+func (h *Handlers) GetSentimentStats(w http.ResponseWriter, r *http.Request) {
+	result, err := h.statsService.Sentiment(r.Context())
+	if err != nil {
+		h.handleError(w, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// GetLatestFeedbacks GET /feedbacks/latest?source=example.com&limit=10
+// The latest feedbacks of the source host from the projection of the worker.
+func (h *Handlers) GetLatestFeedbacks(w http.ResponseWriter, r *http.Request) {
+	limit, err := checkLimit(r.URL.Query())
+	if err != nil {
+		h.handleError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	result, err := h.statsService.Latest(r.Context(), r.URL.Query().Get(sourceQueryParam), limit)
+	if err != nil {
+		status := http.StatusInternalServerError
+
+		switch {
+		case errors.Is(err, stats.ErrInvalidQuery):
+			status = http.StatusBadRequest
+		case errors.Is(err, stats.ErrNoProjections):
+			status = http.StatusNotImplemented
+		}
+
+		h.handleError(w, status, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// streamWriter writes the response by chunks: the status and headers
+// are sent with the first chunk and every chunk is flushed to the client.
+// The body is compressed by gzip if the client accepts it.
+type streamWriter struct {
+	w           http.ResponseWriter
+	gzip        *gzip.Writer
+	out         io.Writer
+	contentType string
+	started     bool
+}
+
+func newStreamWriter(w http.ResponseWriter, r *http.Request, contentType string) *streamWriter {
+	stream := &streamWriter{
+		w:           w,
+		gzip:        nil,
+		out:         w,
+		contentType: contentType,
+		started:     false,
+	}
+
+	if acceptsGzip(r) {
+		stream.gzip = gzip.NewWriter(w)
+		stream.out = stream.gzip
+	}
+
+	return stream
+}
+
+func (s *streamWriter) Write(data []byte) (int, error) {
+	if !s.started {
+		s.start()
+	}
+
+	return s.out.Write(data) //nolint:wrapcheck
+}
+
+// Flush sends everything written to the client.
+func (s *streamWriter) Flush() error {
+	if s.gzip != nil {
+		err := s.gzip.Flush()
+		if err != nil {
+			return fmt.Errorf("can't flush gzip: %w", err)
+		}
+	}
+
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	return nil
+}
+
+// Close finishes the response, it must not be called after an error,
+// so the client gets the broken gzip stream instead of the truncated valid one.
+func (s *streamWriter) Close() error {
+	if !s.started {
+		s.start()
+	}
+
+	if s.gzip != nil {
+		err := s.gzip.Close()
+		if err != nil {
+			return fmt.Errorf("can't close gzip: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *streamWriter) start() {
+	s.started = true
+
+	s.w.Header().Set("Content-Type", s.contentType)
+
+	if s.gzip != nil {
+		s.w.Header().Set("Content-Encoding", "gzip")
+		s.w.Header().Add("Vary", "Accept-Encoding")
+	}
+
+	s.w.WriteHeader(http.StatusOK)
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/services/webhooks"
+)
+
+const beforeQueryParam = "before"
+
+// GetWebhooks GET /webhooks.
+func (h *Handlers) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.webhookService.GetAll(r.Context())
+	if err != nil {
+		h.handleError(w, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, webhooks)
+}
+
+// GetWebhook GET /webhooks/{id}.
+func (h *Handlers) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID := chi.URLParam(r, "id")
+	if webhookID == "" {
+		h.handleError(w, http.StatusBadRequest, errIDParamIsMissing)
+
+		return
+	}
+
+	webhook, err := h.webhookService.GetByID(r.Context(), webhookID)
+	if err != nil {
+		h.handleError(w, http.StatusNotFound, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, webhook)
+}
+
+// CreateWebhook POST /webhooks
+// The secret of the signatures is returned only here, it's generated if the input is without it.
+func (h *Handlers) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var input models.WebhookInput
+
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.handleError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	webhook, err := h.webhookService.Create(r.Context(), &input)
+	if err != nil {
+		h.handleError(w, webhookErrorStatus(err, http.StatusInternalServerError), err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, struct {
+		*models.Webhook
+		Secret string `json:"secret"`
+	}{webhook, webhook.Secret})
+}
+
+// UpdateWebhook PUT /webhooks/{id}
+// The empty secret keeps the current one, enabling the webhook resets its failures.
+func (h *Handlers) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	var input models.WebhookInput
+
+	webhookID := chi.URLParam(r, "id")
+	if webhookID == "" {
+		h.handleError(w, http.StatusBadRequest, errIDParamIsMissing)
+
+		return
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.handleError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	webhook, err := h.webhookService.Update(r.Context(), webhookID, &input)
+	if err != nil {
+		h.handleError(w, webhookErrorStatus(err, http.StatusNotFound), err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, webhook)
+}
+
+// DeleteWebhook DELETE /webhooks/{id}.
+func (h *Handlers) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID := chi.URLParam(r, "id")
+	if webhookID == "" {
+		h.handleError(w, http.StatusBadRequest, errIDParamIsMissing)
+
+		return
+	}
+
+	err := h.webhookService.Delete(r.Context(), webhookID)
+	if err != nil {
+		h.handleError(w, http.StatusNotFound, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries GET /webhooks/{id}/deliveries?limit=100&before=<id>
+// The log of the deliveries from the newest one, before is the last ID of the previous page.
+func (h *Handlers) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhookID := chi.URLParam(r, "id")
+	if webhookID == "" {
+		h.handleError(w, http.StatusBadRequest, errIDParamIsMissing)
+
+		return
+	}
+
+	limit, err := checkLimit(r.URL.Query())
+	if err != nil {
+		h.handleError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	deliveries, err := h.webhookService.Deliveries(
+		r.Context(), webhookID, limit, r.URL.Query().Get(beforeQueryParam),
+	)
+	if err != nil {
+		h.handleError(w, webhookErrorStatus(err, http.StatusNotFound), err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, deliveries)
+}
+
+// TestWebhook POST /webhooks/{id}/test
+// Sends the test event once and returns its delivery, the failed one is 200 too, see its status and error.
+func (h *Handlers) TestWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID := chi.URLParam(r, "id")
+	if webhookID == "" {
+		h.handleError(w, http.StatusBadRequest, errIDParamIsMissing)
+
+		return
+	}
+
+	delivery, err := h.webhookService.Test(r.Context(), webhookID)
+	if err != nil {
+		h.handleError(w, http.StatusNotFound, err)
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, delivery)
+}
+
+// webhookErrorStatus returns 400 for invalid webhooks and otherStatus for the rest.
+func webhookErrorStatus(err error, otherStatus int) int {
+	if errors.Is(err, webhooks.ErrInvalidWebhook) {
+		return http.StatusBadRequest
+	}
+
+	return otherStatus
+}
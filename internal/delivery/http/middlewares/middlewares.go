@@ -4,19 +4,27 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
 	jwt "github.com/golang-jwt/jwt/v5"
 
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
 	"github.com/andrsj/feedback-service/internal/infrastructure/cache"
 	"github.com/andrsj/feedback-service/pkg/logger"
 )
 
 const (
 	authorizationHeader = "Authorization"
+	requestIDHeader     = "X-Request-ID"
+	lastWriteHeader     = "X-Last-Write"
+	lastWriteCookie     = "last_write"
+	traceParentHeader   = "Traceparent"
 	tokenPrefix         = "Bearer"
 )
 
@@ -29,9 +37,12 @@ var (
 	errTokenRole           = errors.New("token has wrong role")
 	errTokenMissingRole    = errors.New("token missing role value")
 	errInvalidToken        = errors.New("invalid token")
+	errAccessDenied        = errors.New("access denied")
 )
 
-func CacheMiddleware(cache cache.Cache) func(next http.Handler) http.Handler {
+// CacheMiddleware caches successful GET responses by URL,
+// the keys are in the current generation of the cache, see cache.Invalidate.
+func CacheMiddleware(responses cache.Cache) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodGet {
@@ -40,8 +51,14 @@ func CacheMiddleware(cache cache.Cache) func(next http.Handler) http.Handler {
 				return
 			}
 
-			cacheKey := r.URL.String()
-			val, cacheExist, err := cache.Get(cacheKey)
+			cacheKey, err := cache.Key(r.Context(), responses, r.URL.String())
+			if err != nil {
+				handleError(w, fmt.Errorf("caching problem: %w", err), http.StatusInternalServerError)
+
+				return
+			}
+
+			val, cacheExist, err := responses.Get(r.Context(), cacheKey)
 			if err != nil {
 				handleError(w, fmt.Errorf("caching problem: %w", err), http.StatusInternalServerError)
 
@@ -61,7 +78,7 @@ func CacheMiddleware(cache cache.Cache) func(next http.Handler) http.Handler {
 			next.ServeHTTP(rw, r)
 
 			if rw.Status() == http.StatusOK {
-				err = cache.Set(cacheKey, rw.Body.Bytes())
+				err = responses.Set(r.Context(), cacheKey, rw.Body.Bytes())
 				if err != nil {
 					handleError(w, err, http.StatusInternalServerError)
 				}
@@ -70,6 +87,76 @@ func CacheMiddleware(cache cache.Cache) func(next http.Handler) http.Handler {
 	}
 }
 
+// ContextMiddleware puts request-scoped values into the request context,
+// it must be used after chi's RequestID middleware.
+func ContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := middleware.GetReqID(r.Context())
+		w.Header().Set(requestIDHeader, requestID)
+
+		ctx := reqctx.WithRequestID(r.Context(), requestID)
+		ctx = reqctx.WithClientIP(ctx, clientIP(r))
+		ctx = reqctx.WithTraceParent(ctx, r.Header.Get(traceParentHeader))
+
+		if wroteAt, ok := lastWrite(r); ok {
+			ctx = reqctx.WithLastWrite(ctx, wroteAt)
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions {
+			setLastWrite(w, time.Now())
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// lastWrite returns the time of the last write which the client sent back
+// in the header or in the cookie, it's Unix time in milliseconds.
+func lastWrite(r *http.Request) (time.Time, bool) {
+	value := r.Header.Get(lastWriteHeader)
+	if value == "" {
+		cookie, err := r.Cookie(lastWriteCookie)
+		if err != nil {
+			return time.Time{}, false
+		}
+
+		value = cookie.Value
+	}
+
+	milliseconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.UnixMilli(milliseconds), true
+}
+
+// setLastWrite gives the client the time of its write for the read-your-writes window of the replicas,
+// the browsers send the cookie back, other clients copy the header into their next requests.
+func setLastWrite(w http.ResponseWriter, wroteAt time.Time) {
+	value := strconv.FormatInt(wroteAt.UnixMilli(), 10)
+
+	w.Header().Set(lastWriteHeader, value)
+	//nolint:exhaustivestruct,exhaustruct
+	http.SetCookie(w, &http.Cookie{
+		Name:     lastWriteCookie,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clientIP returns the host of the remote address without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
 func JWTMiddleware(log logger.Logger) func(next http.Handler) http.Handler {
 	var errMSG string
 
@@ -104,7 +191,43 @@ func JWTMiddleware(log logger.Logger) func(next http.Handler) http.Handler {
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			// Claims are already validated.
+			claims, _ := token.Claims.(jwt.MapClaims)
+			role, _ := claims["role"].(string)
+			subject, _ := claims["sub"].(string)
+			// The tenant is signed with the token, the client can't choose another one.
+			tenant, _ := claims["tenant"].(string)
+
+			ctx := reqctx.WithActor(r.Context(), reqctx.Actor{
+				Subject: subject,
+				Role:    role,
+			})
+			ctx = reqctx.WithTenant(ctx, tenant)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RoleMiddleware allows only tokens with one of the roles,
+// it must be used after JWTMiddleware.
+func RoleMiddleware(log logger.Logger, roles ...string) func(next http.Handler) http.Handler {
+	log = log.Named("role")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role := reqctx.ActorFrom(r.Context()).Role
+
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+
+					return
+				}
+			}
+
+			log.Error("access denied", logger.M{"role": role, "allowed": roles})
+			handleError(w, fmt.Errorf("role '%s': %w", role, errAccessDenied), http.StatusForbidden)
 		})
 	}
 }
@@ -188,7 +311,7 @@ func validateRole(claims jwt.MapClaims, httpMethod string) error {
 		if httpMethod != http.MethodPost {
 			return fmt.Errorf("wrong role for 'POST': %w", errTokenRole)
 		}
-	case "all":
+	case "all", "admin":
 		return nil
 	default:
 		return fmt.Errorf("not existing role role: %w", errTokenRole)
@@ -0,0 +1,77 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/andrsj/feedback-service/internal/delivery/http/middlewares"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+)
+
+func TestContextMiddlewareLastWrite(t *testing.T) {
+	t.Parallel()
+
+	var lastWrite time.Time
+
+	handler := middlewares.ContextMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastWrite = reqctx.LastWrite(r.Context())
+	}))
+
+	serve := func(request *http.Request) *http.Response {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		return recorder.Result()
+	}
+
+	// The write gives the client its time.
+	before := time.Now().UnixMilli()
+	response := serve(httptest.NewRequest(http.MethodPost, "/feedback", nil))
+	_ = response.Body.Close()
+
+	wroteAt, err := strconv.ParseInt(response.Header.Get("X-Last-Write"), 10, 64)
+	if err != nil || wroteAt < before || len(response.Cookies()) != 1 {
+		t.Fatalf("POST: got header %q, cookies %v", response.Header.Get("X-Last-Write"), response.Cookies())
+	}
+
+	// The reads don't change it.
+	response = serve(httptest.NewRequest(http.MethodGet, "/feedbacks", nil))
+	_ = response.Body.Close()
+
+	if response.Header.Get("X-Last-Write") != "" || !lastWrite.IsZero() {
+		t.Errorf("GET without the time: got header %q, last write %v", response.Header.Get("X-Last-Write"), lastWrite)
+	}
+
+	// The time comes back in the cookie or in the header.
+	cookieRequest := httptest.NewRequest(http.MethodGet, "/feedbacks", nil)
+	cookieRequest.AddCookie(&http.Cookie{Name: "last_write", Value: strconv.FormatInt(wroteAt, 10)}) //nolint:exhaustruct
+
+	headerRequest := httptest.NewRequest(http.MethodGet, "/feedbacks", nil)
+	headerRequest.Header.Set("X-Last-Write", strconv.FormatInt(wroteAt, 10))
+
+	for name, request := range map[string]*http.Request{"cookie": cookieRequest, "header": headerRequest} {
+		lastWrite = time.Time{}
+
+		response = serve(request)
+		_ = response.Body.Close()
+
+		if lastWrite.UnixMilli() < wroteAt {
+			t.Errorf("%s: got last write %v, want %d", name, lastWrite, wroteAt)
+		}
+	}
+
+	// The broken value is ignored.
+	request := httptest.NewRequest(http.MethodGet, "/feedbacks", nil)
+	request.Header.Set("X-Last-Write", "yesterday")
+	lastWrite = time.Time{}
+
+	response = serve(request)
+	_ = response.Body.Close()
+
+	if !lastWrite.IsZero() {
+		t.Errorf("broken header: got last write %v", lastWrite)
+	}
+}
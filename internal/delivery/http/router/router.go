@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/andrsj/feedback-service/internal/delivery/http/middlewares"
 	"github.com/andrsj/feedback-service/internal/infrastructure/cache"
@@ -18,33 +19,70 @@ type Router struct {
 
 	cacheMiddleware func(next http.Handler) http.Handler
 	jwtMiddleware   func(next http.Handler) http.Handler
+	adminMiddleware func(next http.Handler) http.Handler
 }
 
 func New(cache cache.Cache, logger logger.Logger) *Router {
 	router := chi.NewRouter()
 
+	router.Use(middleware.RequestID)
+	router.Use(middlewares.ContextMiddleware)
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 
 	jwtMiddleware := middlewares.JWTMiddleware(logger)
 	cacheMiddleware := middlewares.CacheMiddleware(cache)
+	adminMiddleware := middlewares.RoleMiddleware(logger, "admin")
 
 	return &Router{
 		router:          router,
 		logger:          logger.Named("router"),
 		cacheMiddleware: cacheMiddleware,
 		jwtMiddleware:   jwtMiddleware,
+		adminMiddleware: adminMiddleware,
 	}
 }
 
 type Handlers interface {
 	Status(w http.ResponseWriter, r *http.Request)
+	Health(w http.ResponseWriter, r *http.Request)
+	Ready(w http.ResponseWriter, r *http.Request)
 	Token(w http.ResponseWriter, r *http.Request)
 	GetFeedback(w http.ResponseWriter, r *http.Request)
 	GetAllFeedback(w http.ResponseWriter, r *http.Request)
+	ExportFeedbacks(w http.ResponseWriter, r *http.Request)
+	ImportFeedbacks(w http.ResponseWriter, r *http.Request)
 	CreateFeedback(w http.ResponseWriter, r *http.Request)
 	GetPageFeedbacks(w http.ResponseWriter, r *http.Request)
+	GetSentimentStats(w http.ResponseWriter, r *http.Request)
+	GetStats(w http.ResponseWriter, r *http.Request)
+	GetLatestFeedbacks(w http.ResponseWriter, r *http.Request)
 	FakeLongWork(w http.ResponseWriter, r *http.Request)
+
+	GetRules(w http.ResponseWriter, r *http.Request)
+	GetRule(w http.ResponseWriter, r *http.Request)
+	CreateRule(w http.ResponseWriter, r *http.Request)
+	UpdateRule(w http.ResponseWriter, r *http.Request)
+	DeleteRule(w http.ResponseWriter, r *http.Request)
+	DryRunRules(w http.ResponseWriter, r *http.Request)
+
+	GetAudit(w http.ResponseWriter, r *http.Request)
+
+	EraseCustomer(w http.ResponseWriter, r *http.Request)
+	ExportCustomer(w http.ResponseWriter, r *http.Request)
+	GetCustomerExport(w http.ResponseWriter, r *http.Request)
+	DownloadCustomerExport(w http.ResponseWriter, r *http.Request)
+
+	GetDeadLetters(w http.ResponseWriter, r *http.Request)
+	ReplayDeadLetters(w http.ResponseWriter, r *http.Request)
+
+	GetWebhooks(w http.ResponseWriter, r *http.Request)
+	CreateWebhook(w http.ResponseWriter, r *http.Request)
+	GetWebhook(w http.ResponseWriter, r *http.Request)
+	UpdateWebhook(w http.ResponseWriter, r *http.Request)
+	DeleteWebhook(w http.ResponseWriter, r *http.Request)
+	GetWebhookDeliveries(w http.ResponseWriter, r *http.Request)
+	TestWebhook(w http.ResponseWriter, r *http.Request)
 }
 
 func (r *Router) Register(handler Handlers) {
@@ -52,12 +90,23 @@ func (r *Router) Register(handler Handlers) {
 
 	// Status checker.
 	r.router.Get("/", handler.Status)
+	// Health of the dependencies and the readiness for the load balancer.
+	r.router.Get("/health", handler.Health)
+	r.router.Get("/ready", handler.Ready)
 
 	// Token generation.
 	r.router.Get("/token", handler.Token)
 
-	// No cache all feedbacks.
+	// Metrics in the Prometheus text format.
+	r.router.Handle("/metrics", promhttp.Handler())
+
+	// No cache all feedbacks, both are streamed.
 	r.router.With(r.jwtMiddleware).Get("/feedbacks", handler.GetAllFeedback)
+	r.router.With(r.jwtMiddleware).Get("/feedbacks/export", handler.ExportFeedbacks)
+	// Counts by dimensions, cached by the stats service with the short TTL.
+	r.router.With(r.jwtMiddleware).Get("/feedbacks/stats", handler.GetStats)
+	// Latest feedbacks of the source host, the projection is already small.
+	r.router.With(r.jwtMiddleware).Get("/feedbacks/latest", handler.GetLatestFeedbacks)
 	r.router.Group(
 		func(router chi.Router) {
 			router.Use(r.cacheMiddleware)
@@ -67,11 +116,51 @@ func (r *Router) Register(handler Handlers) {
 			router.Get("/feedback/{id}", handler.GetFeedback)
 			// Paginated cursor list of feedbacks.
 			router.Get("/p-feedbacks", handler.GetPageFeedbacks)
+			// Daily average sentiment per source.
+			router.Get("/feedbacks/stats/sentiment", handler.GetSentimentStats)
 			// Create feedback.
 			router.Post("/feedback", handler.CreateFeedback)
 		},
 	)
 
+	// Bulk import, routing rules, audit log, erasure of customer data, the dead-letter queue and webhooks,
+	// only for admins and without cache.
+	r.router.Group(
+		func(router chi.Router) {
+			router.Use(r.jwtMiddleware)
+			router.Use(r.adminMiddleware)
+
+			router.Post("/feedbacks/import", handler.ImportFeedbacks)
+
+			router.Get("/rules", handler.GetRules)
+			router.Post("/rules", handler.CreateRule)
+			router.Post("/rules/dry-run", handler.DryRunRules)
+			router.Get("/rules/{id}", handler.GetRule)
+			router.Put("/rules/{id}", handler.UpdateRule)
+			router.Delete("/rules/{id}", handler.DeleteRule)
+
+			router.Get("/audit", handler.GetAudit)
+
+			router.Delete("/customers/by-email/{email}", handler.EraseCustomer)
+			router.Get("/customers/by-email/{email}/export", handler.ExportCustomer)
+			router.Get("/customers/exports/{id}", handler.GetCustomerExport)
+
+			router.Get("/dlq", handler.GetDeadLetters)
+			router.Post("/dlq/replay", handler.ReplayDeadLetters)
+
+			router.Get("/webhooks", handler.GetWebhooks)
+			router.Post("/webhooks", handler.CreateWebhook)
+			router.Get("/webhooks/{id}", handler.GetWebhook)
+			router.Put("/webhooks/{id}", handler.UpdateWebhook)
+			router.Delete("/webhooks/{id}", handler.DeleteWebhook)
+			router.Get("/webhooks/{id}/deliveries", handler.GetWebhookDeliveries)
+			router.Post("/webhooks/{id}/test", handler.TestWebhook)
+		},
+	)
+
+	// The download link of the export is signed, so it's without JWT.
+	r.router.Get("/customers/exports/{id}/download", handler.DownloadCustomerExport)
+
 	// Testing router for checking Graceful Shutdown.
 	r.router.Get("/l", handler.FakeLongWork)
 
@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+)
+
+// Audited entities.
+const (
+	EntityFeedback = "feedback"
+	EntityRule     = "rule"
+	EntityWebhook  = "webhook"
+	// Receipts of the erasures by customer email.
+	EntityErasure = "erasure"
+)
+
+// Actions of the entries.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionErase  = "erase"
+)
+
+// redacted is written instead of the personal data,
+// the audit log is append-only, so it can't be erased later.
+const redacted = "[redacted]"
+
+// Fields with personal data, the change of them is still seen in the diff.
+// The text of the feedback is free text, it could have personal data too.
+var personalFields = map[string][]string{
+	EntityFeedback: {"customer_name", "email", "feedback_text"},
+}
+
+// Sink gets the entries after the commit of the change, e.g. for shipping them out of DB.
+type Sink interface {
+	Write(entries []*models.AuditEntry) error
+}
+
+// NewEntry describes the change of the entity by the actor of the context,
+// nil before means creation and nil after means deletion.
+func NewEntry(
+	ctx context.Context,
+	entity, action string,
+	entityID uuid.UUID,
+	before, after interface{},
+) (*models.AuditEntry, error) {
+	beforeFields, err := snapshot(before)
+	if err != nil {
+		return nil, err
+	}
+
+	afterFields, err := snapshot(after)
+	if err != nil {
+		return nil, err
+	}
+
+	// The diff is taken before the redaction, so changes of personal data are still seen.
+	diffFields := changes(beforeFields, afterFields)
+	redact(entity, beforeFields)
+	redact(entity, afterFields)
+
+	for _, field := range personalFields[entity] {
+		if change, ok := diffFields[field]; ok {
+			diffFields[field] = redactChange(change)
+		}
+	}
+
+	diff, err := json.Marshal(diffFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit diff: %w", err)
+	}
+
+	actor := reqctx.ActorFrom(ctx)
+
+	return &models.AuditEntry{
+		ID:           uuid.New(),
+		Entity:       entity,
+		EntityID:     entityID,
+		Action:       action,
+		ActorSubject: actor.Subject,
+		ActorRole:    actor.Role,
+		ClientIP:     reqctx.ClientIP(ctx),
+		RequestID:    reqctx.RequestID(ctx),
+		Before:       marshalFields(beforeFields),
+		After:        marshalFields(afterFields),
+		Diff:         diff,
+		CreatedAt:    time.Now().UTC(),
+	}, nil
+}
+
+type change struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// snapshot is the JSON representation of the value by fields, the same as the API returns.
+func snapshot(value interface{}) (map[string]json.RawMessage, error) {
+	if value == nil {
+		return nil, nil //nolint:nilnil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit snapshot: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audit snapshot: %w", err)
+	}
+
+	return fields, nil
+}
+
+// changes returns the fields with different values, compacted JSON is compared as is.
+func changes(before, after map[string]json.RawMessage) map[string]change {
+	diff := make(map[string]change)
+
+	for field, value := range before {
+		if !bytes.Equal(value, after[field]) {
+			diff[field] = change{Before: value, After: after[field]}
+		}
+	}
+
+	for field, value := range after {
+		if _, ok := before[field]; !ok {
+			diff[field] = change{Before: nil, After: value}
+		}
+	}
+
+	return diff
+}
+
+// redact hides the non-empty personal fields.
+func redact(entity string, fields map[string]json.RawMessage) {
+	for _, field := range personalFields[entity] {
+		if value, ok := fields[field]; ok {
+			fields[field] = redactValue(value)
+		}
+	}
+}
+
+func redactChange(change change) change {
+	change.Before = redactValue(change.Before)
+	change.After = redactValue(change.After)
+
+	return change
+}
+
+func redactValue(value json.RawMessage) json.RawMessage {
+	if value == nil || bytes.Equal(value, []byte(`""`)) {
+		return value
+	}
+
+	return json.RawMessage(`"` + redacted + `"`)
+}
+
+func marshalFields(fields map[string]json.RawMessage) models.RawJSON {
+	if fields == nil {
+		return nil
+	}
+
+	// Map of raw messages can't fail.
+	data, _ := json.Marshal(fields) //nolint:errchkjson
+
+	return data
+}
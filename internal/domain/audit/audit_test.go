@@ -0,0 +1,173 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+)
+
+const redacted = `"[redacted]"`
+
+func newFeedback() *models.Feedback {
+	//nolint:exhaustivestruct,exhaustruct
+	return &models.Feedback{
+		ID:             uuid.New(),
+		CustomerName:   "Jane Doe",
+		Email:          "jane@example.com",
+		FeedbackText:   "call me at +380501234567",
+		Source:         "https://shop.example.com",
+		SentimentLabel: "neutral",
+		Tags:           []string{"shop"},
+	}
+}
+
+func fields(t *testing.T, data []byte) map[string]json.RawMessage {
+	t.Helper()
+
+	var result map[string]json.RawMessage
+
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+
+	return result
+}
+
+// checkHidden fails if any personal value is left in the entry.
+func checkHidden(t *testing.T, entry *models.AuditEntry, values ...string) {
+	t.Helper()
+
+	for _, data := range [][]byte{entry.Before, entry.After, entry.Diff} {
+		for _, value := range values {
+			if bytes.Contains(data, []byte(value)) {
+				t.Errorf("%q is in %s", value, data)
+			}
+		}
+	}
+}
+
+func TestNewEntryOfCreation(t *testing.T) {
+	t.Parallel()
+
+	ctx := reqctx.WithActor(context.Background(), reqctx.Actor{Subject: "alice", Role: "admin"})
+	ctx = reqctx.WithClientIP(reqctx.WithRequestID(ctx, "host/abc-000001"), "10.0.0.1")
+	feedback := newFeedback()
+
+	entry, err := audit.NewEntry(ctx, audit.EntityFeedback, audit.ActionCreate, feedback.ID, nil, feedback)
+	if err != nil {
+		t.Fatalf("NewEntry: %v", err)
+	}
+
+	if entry.ActorSubject != "alice" || entry.ActorRole != "admin" || entry.ClientIP != "10.0.0.1" ||
+		entry.RequestID != "host/abc-000001" || entry.EntityID != feedback.ID || entry.Before != nil {
+		t.Errorf("entry: got %+v", entry)
+	}
+
+	checkHidden(t, entry, feedback.CustomerName, feedback.Email, feedback.FeedbackText)
+
+	after := fields(t, entry.After)
+	for _, field := range []string{"customer_name", "email", "feedback_text"} {
+		if string(after[field]) != redacted {
+			t.Errorf("after.%s: got %s", field, after[field])
+		}
+	}
+
+	// The rest of the feedback is kept.
+	if string(after["source"]) != `"https://shop.example.com"` || string(after["tags"]) != `["shop"]` {
+		t.Errorf("after: got %s", entry.After)
+	}
+
+	// Every field is the change of the creation.
+	if diff := fields(t, entry.Diff); len(diff) != len(after) {
+		t.Errorf("diff: got %s", entry.Diff)
+	}
+}
+
+func TestNewEntryShowsChangeOfPersonalData(t *testing.T) {
+	t.Parallel()
+
+	before := newFeedback()
+	after := *before
+	after.Email = "jane.doe@example.com"
+	after.Tags = []string{"shop", "vip"}
+
+	entry, err := audit.NewEntry(context.Background(), audit.EntityFeedback, audit.ActionUpdate, before.ID, before, &after)
+	if err != nil {
+		t.Fatalf("NewEntry: %v", err)
+	}
+
+	checkHidden(t, entry, before.Email, after.Email, before.CustomerName)
+
+	// Only the changed fields are in the diff, the changed email is seen but not its values.
+	diff := fields(t, entry.Diff)
+	if len(diff) != 2 {
+		t.Fatalf("diff: got %s", entry.Diff)
+	}
+
+	email := fields(t, diff["email"])
+	if string(email["before"]) != redacted || string(email["after"]) != redacted {
+		t.Errorf("diff.email: got %s", diff["email"])
+	}
+
+	if tags := fields(t, diff["tags"]); string(tags["after"]) != `["shop","vip"]` {
+		t.Errorf("diff.tags: got %s", diff["tags"])
+	}
+
+	// The anonymization is seen as the personal data removed.
+	anonymized := *before
+	anonymized.CustomerName, anonymized.Email = "", ""
+
+	entry, err = audit.NewEntry(context.Background(), audit.EntityFeedback, audit.ActionUpdate, before.ID, before, &anonymized)
+	if err != nil {
+		t.Fatalf("NewEntry: %v", err)
+	}
+
+	name := fields(t, fields(t, entry.Diff)["customer_name"])
+	if string(name["before"]) != redacted || string(name["after"]) != `""` {
+		t.Errorf("diff.customer_name of the anonymization: got %v", name)
+	}
+}
+
+func TestNewEntryOfDeletion(t *testing.T) {
+	t.Parallel()
+
+	feedback := newFeedback()
+
+	entry, err := audit.NewEntry(context.Background(), audit.EntityFeedback, audit.ActionDelete, feedback.ID, feedback, nil)
+	if err != nil {
+		t.Fatalf("NewEntry: %v", err)
+	}
+
+	if entry.After != nil || entry.ActorSubject != "" {
+		t.Errorf("entry: got %+v", entry)
+	}
+
+	checkHidden(t, entry, feedback.CustomerName, feedback.Email, feedback.FeedbackText)
+
+	if before := fields(t, entry.Before); string(before["email"]) != redacted {
+		t.Errorf("before: got %s", entry.Before)
+	}
+}
+
+func TestNewEntryOfOtherEntities(t *testing.T) {
+	t.Parallel()
+
+	// The entities without personal data are kept as is.
+	rule := map[string]string{"name": "vip", "email": "team@example.com"}
+
+	entry, err := audit.NewEntry(context.Background(), audit.EntityRule, audit.ActionCreate, uuid.New(), nil, rule)
+	if err != nil {
+		t.Fatalf("NewEntry: %v", err)
+	}
+
+	if after := fields(t, entry.After); string(after["email"]) != `"team@example.com"` {
+		t.Errorf("after: got %s", entry.After)
+	}
+}
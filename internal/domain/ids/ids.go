@@ -0,0 +1,95 @@
+// Package ids generates the IDs of the saved entities.
+package ids
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator returns a new unique ID for every call.
+type IDGenerator interface {
+	New() uuid.UUID
+}
+
+// Check that actual implementation fits the interface.
+var _ IDGenerator = (*V7)(nil)
+
+const (
+	// rand_a of UUIDv7 is the counter of IDs in one millisecond,
+	// it starts from a random value below the half, so there is room for the increments.
+	counterBits  = 12
+	counterMax   = 1<<counterBits - 1
+	counterStart = 1 << (counterBits - 1)
+)
+
+// V7 generates UUIDv7 (RFC 9562): 48 bits of Unix milliseconds, 12 bits of the counter and 62 random bits.
+// IDs of one generator are strictly increasing, so the primary key index keeps the creation order.
+// The clock going back and the exhausted counter move the timestamp ahead of the clock.
+type V7 struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	lastMS  int64
+	counter uint16
+}
+
+func NewV7() *V7 {
+	return &V7{
+		mu:      sync.Mutex{},
+		now:     time.Now,
+		lastMS:  0,
+		counter: 0,
+	}
+}
+
+// New panics if the random source fails, like uuid.New.
+func (g *V7) New() uuid.UUID {
+	var id uuid.UUID
+
+	_, err := rand.Read(id[:])
+	if err != nil {
+		panic("ids: reading random bytes: " + err.Error())
+	}
+
+	milliseconds, counter := g.next(uint16(id[6])<<8 | uint16(id[7]))
+
+	id[0] = byte(milliseconds >> 40) //nolint:gomnd
+	id[1] = byte(milliseconds >> 32) //nolint:gomnd
+	id[2] = byte(milliseconds >> 24) //nolint:gomnd
+	id[3] = byte(milliseconds >> 16) //nolint:gomnd
+	id[4] = byte(milliseconds >> 8)  //nolint:gomnd
+	id[5] = byte(milliseconds)
+	id[6] = 0x70 | byte(counter>>8) //nolint:gomnd
+	id[7] = byte(counter)
+	id[8] = 0x80 | id[8]&0x3f //nolint:gomnd
+
+	return id
+}
+
+// next returns the timestamp and the counter of the new ID, the random value seeds the counter.
+func (g *V7) next(random uint16) (int64, uint16) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	milliseconds := g.now().UnixMilli()
+
+	switch {
+	case milliseconds > g.lastMS:
+		g.lastMS = milliseconds
+		g.counter = random % counterStart
+	case g.counter < counterMax:
+		g.counter++
+	default:
+		g.lastMS++
+		g.counter = random % counterStart
+	}
+
+	return g.lastMS, g.counter
+}
+
+// IsV7 tells the time-ordered IDs from the random ones (UUIDv4) created before the generator.
+func IsV7(id uuid.UUID) bool {
+	return id.Version() == 7 //nolint:gomnd
+}
@@ -0,0 +1,170 @@
+package ids
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fixedClock is the clock moved only by the test.
+type fixedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fixedClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+}
+
+func newGenerator(start time.Time) (*V7, *fixedClock) {
+	clock := &fixedClock{mu: sync.Mutex{}, now: start}
+	generator := NewV7()
+	generator.now = clock.Now
+
+	return generator, clock
+}
+
+func milliseconds(id uuid.UUID) int64 {
+	var result int64
+	for _, b := range id[:6] {
+		result = result<<8 | int64(b)
+	}
+
+	return result
+}
+
+func checkIncreasing(t *testing.T, generated []uuid.UUID) {
+	t.Helper()
+
+	for i := 1; i < len(generated); i++ {
+		if bytes.Compare(generated[i-1][:], generated[i][:]) >= 0 {
+			t.Errorf("ID %d %s isn't greater than %s", i, generated[i], generated[i-1])
+
+			return
+		}
+	}
+}
+
+func TestV7Format(t *testing.T) {
+	t.Parallel()
+
+	now := time.UnixMilli(1_700_000_000_123)
+	generator, _ := newGenerator(now)
+	id := generator.New()
+
+	if id.Version() != 7 || id.Variant() != uuid.RFC4122 || !IsV7(id) { //nolint:gomnd
+		t.Errorf("got version %d, variant %s", id.Version(), id.Variant())
+	}
+
+	if milliseconds(id) != now.UnixMilli() {
+		t.Errorf("got timestamp %d, want %d", milliseconds(id), now.UnixMilli())
+	}
+
+	if IsV7(uuid.New()) {
+		t.Error("UUIDv4 is taken as UUIDv7")
+	}
+}
+
+func TestV7Monotonic(t *testing.T) {
+	t.Parallel()
+
+	generator := NewV7()
+
+	var (
+		mu        sync.Mutex
+		generated []uuid.UUID
+		wait      sync.WaitGroup
+	)
+
+	// The IDs of concurrent callers are unique, every caller sees them increasing.
+	for worker := 0; worker < 8; worker++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			local := make([]uuid.UUID, 0, 1000)
+			for i := 0; i < 1000; i++ {
+				local = append(local, generator.New())
+			}
+
+			checkIncreasing(t, local)
+
+			mu.Lock()
+			generated = append(generated, local...)
+			mu.Unlock()
+		}()
+	}
+
+	wait.Wait()
+
+	unique := make(map[uuid.UUID]struct{}, len(generated))
+	for _, id := range generated {
+		unique[id] = struct{}{}
+	}
+
+	if len(unique) != len(generated) {
+		t.Errorf("got %d unique IDs of %d", len(unique), len(generated))
+	}
+}
+
+func TestV7CounterOverflow(t *testing.T) {
+	t.Parallel()
+
+	now := time.UnixMilli(1_700_000_000_000)
+	generator, _ := newGenerator(now)
+
+	// More IDs than the counter holds in one millisecond of the clock.
+	generated := make([]uuid.UUID, 0, 2*counterMax)
+	for i := 0; i < 2*counterMax; i++ {
+		generated = append(generated, generator.New())
+	}
+
+	checkIncreasing(t, generated)
+
+	last := milliseconds(generated[len(generated)-1])
+	if last <= now.UnixMilli() {
+		t.Errorf("the timestamp isn't moved ahead after the exhausted counter: %d", last)
+	}
+}
+
+func TestV7ClockRollback(t *testing.T) {
+	t.Parallel()
+
+	now := time.UnixMilli(1_700_000_000_000)
+	generator, clock := newGenerator(now)
+
+	before := generator.New()
+
+	clock.Set(now.Add(-time.Second))
+
+	after := []uuid.UUID{before, generator.New(), generator.New()}
+	checkIncreasing(t, after)
+
+	if milliseconds(after[2]) != now.UnixMilli() {
+		t.Errorf("the timestamp follows the clock back: got %d, want %d", milliseconds(after[2]), now.UnixMilli())
+	}
+
+	// The clock catches up, the IDs use it again.
+	clock.Set(now.Add(time.Second))
+
+	caughtUp := generator.New()
+	checkIncreasing(t, []uuid.UUID{after[2], caughtUp})
+
+	if milliseconds(caughtUp) != now.Add(time.Second).UnixMilli() {
+		t.Errorf("got timestamp %d after the clock caught up", milliseconds(caughtUp))
+	}
+}
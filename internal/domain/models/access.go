@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of the AccessExport.
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+)
+
+// AccessExport is the job of the export of the customer data (data subject access request).
+// DownloadURL is set when the ZIP is ready, it's valid until ExpiresAt.
+type AccessExport struct {
+	ID           uuid.UUID  `json:"id"`
+	Status       string     `json:"status"`
+	Feedbacks    int        `json:"feedbacks"`
+	AuditEntries int        `json:"audit_entries"` //nolint:tagliatelle
+	Error        string     `json:"error,omitempty"`
+	DownloadURL  string     `json:"download_url,omitempty"` //nolint:tagliatelle
+	CreatedAt    time.Time  `json:"created_at"`             //nolint:tagliatelle
+	CompletedAt  *time.Time `json:"completed_at,omitempty"` //nolint:tagliatelle
+	ExpiresAt    time.Time  `json:"expires_at"`             //nolint:tagliatelle
+}
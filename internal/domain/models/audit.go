@@ -0,0 +1,70 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errRawJSONSource = errors.New("unsupported source of JSON")
+
+// AuditEntry is one mutation of the entity, entries are never changed or deleted.
+// Before is missing for creations, After is missing for deletions,
+// Diff has only the changed fields: {"field": {"before": ..., "after": ...}}.
+type AuditEntry struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	Entity       string    `json:"entity"`
+	EntityID     uuid.UUID `json:"entity_id" gorm:"type:uuid"` //nolint:tagliatelle
+	Action       string    `json:"action"`
+	ActorSubject string    `json:"actor_subject"` //nolint:tagliatelle
+	ActorRole    string    `json:"actor_role"`    //nolint:tagliatelle
+	ClientIP     string    `json:"client_ip"`     //nolint:tagliatelle
+	RequestID    string    `json:"request_id"`    //nolint:tagliatelle
+	Before       RawJSON   `json:"before"`
+	After        RawJSON   `json:"after"`
+	Diff         RawJSON   `json:"diff"`
+	CreatedAt    time.Time `json:"created_at"` //nolint:tagliatelle
+}
+
+// RawJSON is the JSON document as is, it's stored as text, so it works with any driver.
+type RawJSON []byte
+
+func (j RawJSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+
+	return j, nil
+}
+
+func (j *RawJSON) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[:0], data...)
+
+	return nil
+}
+
+func (j RawJSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+
+	return string(j), nil
+}
+
+func (j *RawJSON) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append(RawJSON(nil), value...)
+	case string:
+		*j = RawJSON(value)
+	default:
+		return fmt.Errorf("%w: %T", errRawJSONSource, src)
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeadLetter is the event which the broker failed to publish, see the dlq service.
+// It keeps only the feedback ID, the replay publishes the current state of the feedback,
+// so the personal data isn't copied out of the feedbacks.
+type DeadLetter struct {
+	ID uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	// Type of the event, see the Feedback constants of the events.
+	EventType  string    `json:"event_type"`  //nolint:tagliatelle
+	FeedbackID uuid.UUID `json:"feedback_id"` //nolint:tagliatelle
+	Tenant     string    `json:"tenant,omitempty"`
+	// The last error and the number of the failed publishes, the first one included.
+	Error         string    `json:"error"`
+	Attempts      int       `json:"attempts"`
+	CreatedAt     time.Time `json:"created_at"`      //nolint:tagliatelle
+	LastAttemptAt time.Time `json:"last_attempt_at"` //nolint:tagliatelle
+}
+
+// ReplayReport is the result of the replay of the dead letters.
+type ReplayReport struct {
+	// Published and removed from the queue.
+	Replayed int `json:"replayed"`
+	// Failed again, they are kept with the new error.
+	Failed int `json:"failed"`
+	// The feedback is deleted since, the letter is removed without the event.
+	Skipped int `json:"skipped"`
+	// IDs which aren't in the queue.
+	NotFound int `json:"not_found"` //nolint:tagliatelle
+}
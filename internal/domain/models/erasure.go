@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErasureReceipt is the proof of the erasure of the customer data, it's kept in the audit log.
+// The email isn't kept, only its HMAC, so the receipt can be matched with the request later.
+type ErasureReceipt struct {
+	ID             uuid.UUID   `json:"id"`
+	EmailHash      string      `json:"email_hash"`   //nolint:tagliatelle
+	FeedbackIDs    []uuid.UUID `json:"feedback_ids"` //nolint:tagliatelle
+	Erased         int         `json:"erased"`
+	TombstonesSent int         `json:"tombstones_sent"` //nolint:tagliatelle
+	// Records removed from the broker log (BROKER=file), the log keeps the feedbacks in plaintext.
+	LogPurged int `json:"log_purged"` //nolint:tagliatelle
+	// The cache is invalidated fully, so it's always true on success.
+	CacheEvicted bool      `json:"cache_evicted"` //nolint:tagliatelle
+	ActorSubject string    `json:"actor_subject"` //nolint:tagliatelle
+	ActorRole    string    `json:"actor_role"`    //nolint:tagliatelle
+	RequestID    string    `json:"request_id"`    //nolint:tagliatelle
+	CompletedAt  time.Time `json:"completed_at"`  //nolint:tagliatelle
+	// HMAC-SHA256 of the receipt in JSON with empty signature.
+	Signature string `json:"signature"`
+}
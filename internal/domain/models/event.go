@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of FeedbackEvent, one per change the service makes. There are no restored, status_changed
+// and commented types: the deletion is final, feedbacks have no status and no comments.
+const (
+	FeedbackCreated = "feedback.created"
+	// FeedbackUpdated is the change of the feedback without its own type, e.g. the anonymization by retention.
+	FeedbackUpdated = "feedback.updated"
+	FeedbackDeleted = "feedback.deleted"
+	// FeedbackTagged is the change of the tags by the routing rules, the team and the priority could change with them.
+	FeedbackTagged = "feedback.tagged"
+)
+
+// FeedbackEvent is the change of the feedback, it has the whole feedback after the change,
+// so the consumer rebuilds the state from the events alone. Feedback is nil for the deleted one.
+// ID is unique per event, so it can be applied once.
+type FeedbackEvent struct {
+	ID         string
+	Type       string
+	Time       time.Time
+	FeedbackID uuid.UUID
+	Tenant     string
+	Feedback   *Feedback
+}
+
+// NewFeedbackEvent is the event of the changed feedback, the tenant is the one of the feedback.
+func NewFeedbackEvent(eventType string, feedback *Feedback) *FeedbackEvent {
+	return &FeedbackEvent{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		Time:       time.Now().UTC(),
+		FeedbackID: feedback.ID,
+		Tenant:     feedback.Tenant,
+		Feedback:   feedback,
+	}
+}
+
+// NewDeletedEvent is the event of the deleted feedback, the empty tenant is taken from the context by the producers.
+func NewDeletedEvent(feedbackID uuid.UUID, tenant string) *FeedbackEvent {
+	return &FeedbackEvent{
+		ID:         uuid.NewString(),
+		Type:       FeedbackDeleted,
+		Time:       time.Now().UTC(),
+		FeedbackID: feedbackID,
+		Tenant:     tenant,
+		Feedback:   nil,
+	}
+}
+
+// FeedbackEvents makes the events of the same type for the feedbacks.
+func FeedbackEvents(eventType string, feedbacks []*Feedback) []*FeedbackEvent {
+	events := make([]*FeedbackEvent, 0, len(feedbacks))
+	for _, feedback := range feedbacks {
+		events = append(events, NewFeedbackEvent(eventType, feedback))
+	}
+
+	return events
+}
+
+// DeletedEvents makes the events of the deleted feedbacks without the tenant.
+func DeletedEvents(feedbackIDs []uuid.UUID) []*FeedbackEvent {
+	events := make([]*FeedbackEvent, 0, len(feedbackIDs))
+	for _, feedbackID := range feedbackIDs {
+		events = append(events, NewDeletedEvent(feedbackID, ""))
+	}
+
+	return events
+}
@@ -1,11 +1,15 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrFeedbackNotFound is returned by the repositories for the unknown ID.
+var ErrFeedbackNotFound = errors.New("feedback not found")
+
 // ChatGPT's tip for creating separate model for input.
 type FeedbackInput struct {
 	CustomerName string `json:"customer_name"` //nolint:tagliatelle
@@ -20,6 +24,18 @@ type Feedback struct {
 	Email        string    `json:"email"`
 	FeedbackText string    `json:"feedback_text"` //nolint:tagliatelle
 	Source       string    `json:"source"`
-	CreatedAt    time.Time `json:"-" gorm:"created_at"`
-	UpdatedAt    time.Time `json:"-" gorm:"updated_at"`
+	// Tenant of the request which created the feedback, see reqctx.
+	Tenant string `json:"-" gorm:"index"`
+	// Derived fields, they are calculated by the service on creation.
+	SourceHost     string  `json:"-" gorm:"index"`
+	SentimentScore float64 `json:"sentiment_score"`              //nolint:tagliatelle
+	SentimentLabel string  `json:"sentiment_label" gorm:"index"` //nolint:tagliatelle
+	// Blind index of the encrypted email, it's set by the repository.
+	EmailIndex string `json:"-" gorm:"index"`
+	// Routing fields, they are set by the rules.
+	Tags      []string  `json:"tags" gorm:"type:text;serializer:json;not null"`
+	Team      string    `json:"team" gorm:"index"`
+	Priority  string    `json:"priority"`
+	CreatedAt time.Time `json:"-" gorm:"created_at"`
+	UpdatedAt time.Time `json:"-" gorm:"updated_at"`
 }
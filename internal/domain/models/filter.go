@@ -0,0 +1,11 @@
+package models
+
+// FeedbackFilter holds the conditions for the listings of feedbacks.
+// Zero value of a field means "no condition".
+type FeedbackFilter struct {
+	Sentiment string
+	// Words of the feedback text, all of them must be found.
+	Search string
+	// Exact email, case-insensitive.
+	Email string
+}
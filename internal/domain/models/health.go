@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// States of the connection of the broker.
+const (
+	BrokerUp = "up"
+	// BrokerConnecting is the state before the first connection.
+	BrokerConnecting = "connecting"
+	BrokerDown       = "down"
+)
+
+// Statuses of the service in Health.
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+)
+
+// Health is the state of the service by GET /health and GET /ready.
+type Health struct {
+	// Status is degraded while the broker isn't up, the feedbacks are still accepted.
+	Status string        `json:"status"`
+	Broker *BrokerHealth `json:"broker"`
+}
+
+// BrokerHealth is the connection of the producer, the brokers without the connection are always up.
+type BrokerHealth struct {
+	State string `json:"state"`
+	// Events kept in memory until the broker is up and the limit, zero limit means no buffer.
+	Buffered   int    `json:"buffered"`
+	BufferSize int    `json:"buffer_size"` //nolint:tagliatelle
+	Error      string `json:"error,omitempty"`
+	// Since is the time of the last change of the state.
+	Since time.Time `json:"since"`
+}
+
+// Ready tells that the events of the new feedbacks are sent or buffered, not only kept by the dead-letter queue.
+func (h *BrokerHealth) Ready() bool {
+	return h.State == BrokerUp || h.Buffered < h.BufferSize
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// ImportRecord is one row of the imported file, missing timestamps mean "now".
+type ImportRecord struct {
+	FeedbackInput
+	CreatedAt *time.Time `json:"created_at"` //nolint:tagliatelle
+	UpdatedAt *time.Time `json:"updated_at"` //nolint:tagliatelle
+}
+
+// ImportError is the reason why the line of the file is skipped.
+type ImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportReport is the result of the import,
+// with dry run Valid rows are counted, but nothing is imported.
+type ImportReport struct {
+	DryRun   bool `json:"dry_run"` //nolint:tagliatelle
+	Total    int  `json:"total"`
+	Valid    int  `json:"valid"`
+	Imported int  `json:"imported"`
+	Failed   int  `json:"failed"`
+	// Only the first errors are reported, Failed has the count of all of them.
+	Errors          []*ImportError `json:"errors"`
+	ErrorsTruncated bool           `json:"errors_truncated"` //nolint:tagliatelle
+}
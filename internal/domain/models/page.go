@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+)
+
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// PageCursor is the position after the last feedback of the page.
+// Random IDs (UUIDv4) are paged by (created_at, id), so their cursor keeps the creation time:
+// the next page is found even if the feedback of the cursor is deleted in between.
+type PageCursor struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+}
+
+// CursorAfter returns the cursor of the page which ends with the feedback.
+func CursorAfter(feedback *Feedback) PageCursor {
+	if ids.IsV7(feedback.ID) {
+		return PageCursor{ID: feedback.ID, CreatedAt: time.Time{}}
+	}
+
+	return PageCursor{ID: feedback.ID, CreatedAt: feedback.CreatedAt}
+}
+
+// ParsePageCursor parses "<id>" or "<id>.<Unix nanoseconds of created_at>".
+func ParsePageCursor(text string) (PageCursor, error) {
+	var cursor PageCursor
+
+	id, createdAt, found := strings.Cut(text, ".")
+
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+
+	cursor.ID = parsed
+
+	if found {
+		nanoseconds, err := strconv.ParseInt(createdAt, 10, 64)
+		if err != nil {
+			return cursor, ErrInvalidCursor
+		}
+
+		cursor.CreatedAt = time.Unix(0, nanoseconds).UTC()
+	}
+
+	return cursor, nil
+}
+
+// String is the text of ParsePageCursor, the time is omitted for the time-ordered IDs.
+func (c PageCursor) String() string {
+	if c.CreatedAt.IsZero() {
+		return c.ID.String()
+	}
+
+	return c.ID.String() + "." + strconv.FormatInt(c.CreatedAt.UnixNano(), 10)
+}
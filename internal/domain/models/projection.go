@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LatestFeedback is one of the latest feedbacks of the source host, it's read from the projection of the worker.
+type LatestFeedback struct {
+	ID             uuid.UUID `json:"id"`
+	Source         string    `json:"source"`
+	FeedbackText   string    `json:"feedback_text"`   //nolint:tagliatelle
+	SentimentLabel string    `json:"sentiment_label"` //nolint:tagliatelle
+	CreatedAt      time.Time `json:"created_at"`      //nolint:tagliatelle
+}
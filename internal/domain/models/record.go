@@ -0,0 +1,51 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Separator of tags in the CSV column.
+const csvTagSeparator = ";"
+
+// CSVHeader is the order of columns of the exported CSV.
+var CSVHeader = []string{ //nolint:gochecknoglobals
+	"id", "customer_name", "email", "feedback_text", "source",
+	"sentiment_score", "sentiment_label", "tags", "team", "priority",
+	"created_at", "updated_at",
+}
+
+// FeedbackRecord is a feedback with timestamps for the export,
+// the API hides them.
+type FeedbackRecord struct {
+	*Feedback
+	CreatedAt time.Time `json:"created_at"` //nolint:tagliatelle
+	UpdatedAt time.Time `json:"updated_at"` //nolint:tagliatelle
+}
+
+func NewFeedbackRecord(feedback *Feedback) *FeedbackRecord {
+	return &FeedbackRecord{
+		Feedback:  feedback,
+		CreatedAt: feedback.CreatedAt.UTC(),
+		UpdatedAt: feedback.UpdatedAt.UTC(),
+	}
+}
+
+// CSV returns the values in the order of CSVHeader.
+func (r *FeedbackRecord) CSV() []string {
+	return []string{
+		r.ID.String(),
+		r.CustomerName,
+		r.Email,
+		r.FeedbackText,
+		r.Source,
+		strconv.FormatFloat(r.SentimentScore, 'f', -1, 64),
+		r.SentimentLabel,
+		strings.Join(r.Tags, csvTagSeparator),
+		r.Team,
+		r.Priority,
+		r.CreatedAt.Format(time.RFC3339Nano),
+		r.UpdatedAt.Format(time.RFC3339Nano),
+	}
+}
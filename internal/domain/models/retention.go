@@ -0,0 +1,75 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+// Scopes and actions of the RetentionPolicy.
+const (
+	RetentionScopeAll    = "*"
+	RetentionScopeTenant = "tenant"
+	RetentionScopeSource = "source"
+
+	RetentionDelete    = "delete"
+	RetentionAnonymize = "anonymize"
+)
+
+// RetentionPolicy deletes or anonymizes the feedbacks of the scope after MaxAge.
+type RetentionPolicy struct {
+	Scope string
+	// Tenant or source host, empty for the scope "*".
+	Value  string
+	Action string
+	MaxAge time.Duration
+}
+
+// String is the policy as in the config, e.g. "source:shop.example.com=delete:365d".
+func (p *RetentionPolicy) String() string {
+	scope := p.Scope
+	if p.Value != "" {
+		scope += ":" + p.Value
+	}
+
+	return scope + "=" + p.Action + ":" + formatAge(p.MaxAge)
+}
+
+// RetentionFilter selects the expired feedbacks of the policy.
+type RetentionFilter struct {
+	// Empty values mean any tenant or source.
+	Tenant     string
+	SourceHost string
+	// Feedbacks are created before this time.
+	CreatedBefore time.Time
+	// Only feedbacks with name or email, the anonymized ones are skipped.
+	WithPersonalData bool
+}
+
+// RetentionResult is the result of one policy, in dry run Matched is counted, but nothing is changed.
+type RetentionResult struct {
+	Policy        string    `json:"policy"`
+	Action        string    `json:"action"`
+	CreatedBefore time.Time `json:"created_before"` //nolint:tagliatelle
+	Matched       int       `json:"matched"`
+	Processed     int       `json:"processed"`
+}
+
+// RetentionReport is the result of one purge run.
+type RetentionReport struct {
+	DryRun     bool               `json:"dry_run"`    //nolint:tagliatelle
+	StartedAt  time.Time          `json:"started_at"` //nolint:tagliatelle
+	Duration   string             `json:"duration"`
+	Deleted    int                `json:"deleted"`
+	Anonymized int                `json:"anonymized"`
+	Policies   []*RetentionResult `json:"policies"`
+}
+
+const day = 24 * time.Hour
+
+func formatAge(age time.Duration) string {
+	if age%day == 0 {
+		return strconv.Itoa(int(age/day)) + "d"
+	}
+
+	return age.String()
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+// Rule routes incoming feedback: if all conditions match,
+// the feedback gets the tags, team and priority of the rule.
+// Rules are evaluated by Position, for team and priority the first matched rule wins.
+type Rule struct {
+	ID   uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	Name string    `json:"name"`
+	// Conditions.
+	TextPattern string `json:"text_pattern"` //nolint:tagliatelle
+	SourceHost  string `json:"source_host"`  //nolint:tagliatelle
+	// Actions.
+	Tags     []string `json:"tags" gorm:"type:text;serializer:json;not null"`
+	Team     string   `json:"team"`
+	Priority string   `json:"priority"`
+
+	Position  int       `json:"position"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"-" gorm:"created_at"`
+	UpdatedAt time.Time `json:"-" gorm:"updated_at"`
+}
+
+type RuleInput struct {
+	Name        string   `json:"name"`
+	TextPattern string   `json:"text_pattern"` //nolint:tagliatelle
+	SourceHost  string   `json:"source_host"`  //nolint:tagliatelle
+	Tags        []string `json:"tags"`
+	Team        string   `json:"team"`
+	Priority    string   `json:"priority"`
+	Position    int      `json:"position"`
+	// Rule is enabled if it's missing.
+	Enabled *bool `json:"enabled"`
+}
+
+// DryRunResult shows what would happen with feedback on creation.
+type DryRunResult struct {
+	Rules    []*Rule  `json:"rules"`
+	Tags     []string `json:"tags"`
+	Team     string   `json:"team"`
+	Priority string   `json:"priority"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// SentimentStat is an average sentiment of feedbacks
+// for one source host for one day.
+type SentimentStat struct {
+	Day     string  `json:"day"`
+	Source  string  `json:"source"`
+	Average float64 `json:"average"`
+	Count   int64   `json:"count"`
+}
+
+// Dimensions of FeedbackStat, one period (day, week or month) per query.
+const (
+	StatsBySource = "source"
+	StatsByDay    = "day"
+	StatsByWeek   = "week"
+	StatsByMonth  = "month"
+	StatsByTag    = "tag"
+)
+
+// FeedbackStat is the count of feedbacks of one group, the group has the value of every dimension:
+// the source host, the period (day "2006-01-02", week by its Monday "2006-01-02", month "2006-01") and the tag.
+type FeedbackStat struct {
+	Group   map[string]string `json:"group"`
+	Count   int64             `json:"count"`
+	FirstAt time.Time         `json:"first_at"` //nolint:tagliatelle
+	LastAt  time.Time         `json:"last_at"`  //nolint:tagliatelle
+}
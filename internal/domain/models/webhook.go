@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of the webhook deliveries.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+	// DeliverySkipped is the event of the feedback deleted before the delivery.
+	DeliverySkipped = "skipped"
+)
+
+// WebhookTest is the type of the test event, it's sent only by the request of the admin.
+const WebhookTest = "webhook.test"
+
+// Webhook is the subscription of the URL to the feedback events,
+// the feedback is sent if it matches all non-empty filters.
+type Webhook struct {
+	ID  uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	URL string    `json:"url"`
+	// Empty means all types.
+	EventTypes []string `json:"event_types" gorm:"type:text;serializer:json;not null"` //nolint:tagliatelle
+	// HMAC key of the signatures, it's returned only on creation.
+	Secret string `json:"-"`
+	// The name and the email of the customer are sent only with it.
+	IncludePII bool `json:"include_pii" gorm:"column:include_pii"` //nolint:tagliatelle
+	// Filters.
+	Tenant     string `json:"tenant"`
+	SourceHost string `json:"source_host"` //nolint:tagliatelle
+	Sentiment  string `json:"sentiment"`
+	Tag        string `json:"tag"`
+	Team       string `json:"team"`
+
+	Enabled bool `json:"enabled"`
+	// Failures in a row, the webhook is disabled after WEBHOOK_DISABLE_AFTER of them.
+	Failures  int       `json:"failures"`
+	CreatedAt time.Time `json:"-" gorm:"created_at"`
+	UpdatedAt time.Time `json:"-" gorm:"updated_at"`
+}
+
+type WebhookInput struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"` //nolint:tagliatelle
+	// Empty secret is generated on creation and kept on update.
+	Secret     string `json:"secret"`
+	IncludePII bool   `json:"include_pii"` //nolint:tagliatelle
+	Tenant     string `json:"tenant"`
+	SourceHost string `json:"source_host"` //nolint:tagliatelle
+	Sentiment  string `json:"sentiment"`
+	Tag        string `json:"tag"`
+	Team       string `json:"team"`
+	// Webhook is enabled if it's missing, enabling resets the failures.
+	Enabled *bool `json:"enabled"`
+}
+
+// WebhookDelivery is the event for the webhook, it keeps only the feedback ID,
+// the payload is built by the current state of the feedback on every attempt.
+type WebhookDelivery struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	WebhookID  uuid.UUID `json:"webhook_id" gorm:"type:uuid"`  //nolint:tagliatelle
+	EventType  string    `json:"event_type"`                   //nolint:tagliatelle
+	FeedbackID uuid.UUID `json:"feedback_id" gorm:"type:uuid"` //nolint:tagliatelle
+	Tenant     string    `json:"tenant,omitempty"`
+	Status     string    `json:"status"`
+	Attempts   int       `json:"attempts"`
+	// Status of the last response, 0 if there was no response.
+	ResponseStatus int        `json:"response_status,omitempty"` //nolint:tagliatelle
+	Error          string     `json:"error,omitempty"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`        //nolint:tagliatelle
+	CreatedAt      time.Time  `json:"created_at"`             //nolint:tagliatelle
+	CompletedAt    *time.Time `json:"completed_at,omitempty"` //nolint:tagliatelle
+}
@@ -0,0 +1,121 @@
+package reqctx
+
+import (
+	"context"
+	"time"
+)
+
+type contextKey int
+
+const (
+	tenantKey contextKey = iota
+	actorKey
+	requestIDKey
+	clientIPKey
+	lastWriteKey
+	primaryKey
+	traceParentKey
+)
+
+// Actor is the caller of the request, it's taken from JWT.
+type Actor struct {
+	Subject string `json:"subject"`
+	Role    string `json:"role"`
+}
+
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// Tenant returns tenant of the request or empty string.
+func Tenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey).(string)
+
+	return tenant
+}
+
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFrom returns actor of the request or zero Actor.
+func ActorFrom(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey).(Actor)
+
+	return actor
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns ID of the request or empty string.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+
+	return requestID
+}
+
+func WithClientIP(ctx context.Context, clientIP string) context.Context {
+	return context.WithValue(ctx, clientIPKey, clientIP)
+}
+
+// ClientIP returns IP address of the caller or empty string.
+func ClientIP(ctx context.Context) string {
+	clientIP, _ := ctx.Value(clientIPKey).(string)
+
+	return clientIP
+}
+
+// WithLastWrite keeps the time of the last write of the client sent back by the client,
+// so the instance which didn't serve the write sees it too.
+func WithLastWrite(ctx context.Context, wroteAt time.Time) context.Context {
+	return context.WithValue(ctx, lastWriteKey, wroteAt)
+}
+
+// LastWrite returns the time of the last write of the client or zero time.
+func LastWrite(ctx context.Context) time.Time {
+	wroteAt, _ := ctx.Value(lastWriteKey).(time.Time)
+
+	return wroteAt
+}
+
+// WithPrimary marks the reads which must see the latest writes, they skip the replicas.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey, true)
+}
+
+// Primary reports whether the reads must go to the primary.
+func Primary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey).(bool)
+
+	return primary
+}
+
+// WithTraceParent keeps the W3C trace context of the caller, it's passed to the events.
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	return context.WithValue(ctx, traceParentKey, traceParent)
+}
+
+// TraceParent returns the "traceparent" header of the request or empty string.
+func TraceParent(ctx context.Context) string {
+	traceParent, _ := ctx.Value(traceParentKey).(string)
+
+	return traceParent
+}
+
+// Detach returns the context with the values of the request, but without its cancellation,
+// for the work which outlives the request.
+func Detach(ctx context.Context) context.Context {
+	detached := WithTenant(context.Background(), Tenant(ctx))
+	detached = WithActor(detached, ActorFrom(ctx))
+	detached = WithRequestID(detached, RequestID(ctx))
+	detached = WithTraceParent(detached, TraceParent(ctx))
+	detached = WithLastWrite(detached, LastWrite(ctx))
+
+	if Primary(ctx) {
+		detached = WithPrimary(detached)
+	}
+
+	return WithClientIP(detached, ClientIP(ctx))
+}
@@ -0,0 +1,72 @@
+package file
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+const filePermissions = 0o600
+
+// Sink appends audit entries into NDJSON file, one entry per line.
+type Sink struct {
+	mu     sync.Mutex
+	file   *os.File
+	logger logger.Logger
+}
+
+func New(log logger.Logger, path string) (*Sink, error) {
+	log = log.Named("auditFile")
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, filePermissions)
+	if err != nil {
+		log.Error("Can't open the file", logger.M{"err": err, "path": path})
+
+		return nil, fmt.Errorf("can't open the file '%s': %w", path, err)
+	}
+
+	return &Sink{
+		mu:     sync.Mutex{},
+		file:   file,
+		logger: log,
+	}, nil
+}
+
+// Write writes the entries by one write, so the lines of concurrent writes aren't mixed.
+func (s *Sink) Write(entries []*models.AuditEntry) error {
+	var lines []byte
+
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit entry to JSON: %w", err)
+		}
+
+		lines = append(append(lines, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.file.Write(lines)
+	if err != nil {
+		return fmt.Errorf("failed to write audit entries: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("closing error: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package file_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/audit/file"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) logger.Logger { return l }
+func (nopLogger) Debug(string, logger.M)       {}
+func (nopLogger) Info(string, logger.M)        {}
+func (nopLogger) Warn(string, logger.M)        {}
+func (nopLogger) Error(string, logger.M)       {}
+func (nopLogger) Fatal(string, logger.M)       {}
+
+func TestSinkGetsRedactedEntries(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+
+	sink, err := file.New(nopLogger{}, path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	repo := memory.New(memory.NewAuditRepository(sink, nopLogger{}), ids.NewV7(), nopLogger{})
+
+	//nolint:exhaustivestruct,exhaustruct
+	feedback := &models.Feedback{
+		CustomerName: "Jane",
+		Email:        "jane@example.com",
+		FeedbackText: "ok",
+		Source:       "https://shop.example.com",
+	}
+
+	feedbackID, err := repo.Create(ctx, feedback)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err = repo.Delete(ctx, []uuid.UUID{feedbackID}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if err = sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	if strings.Contains(string(data), feedback.Email) {
+		t.Errorf("the email is in the sink: %s", data)
+	}
+
+	// One line per change in the order of the changes.
+	var actions []string
+
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		var entry models.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+
+		if entry.Entity != audit.EntityFeedback || entry.EntityID != feedbackID {
+			t.Errorf("entry: got %+v", entry)
+		}
+
+		actions = append(actions, entry.Action)
+	}
+
+	if strings.Join(actions, ",") != audit.ActionCreate+","+audit.ActionDelete {
+		t.Errorf("actions: got %v", actions)
+	}
+}
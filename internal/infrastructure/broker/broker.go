@@ -0,0 +1,35 @@
+// Package broker has the errors and the serializer shared by the producers of the feedback events.
+package broker
+
+import (
+	"context"
+	"errors"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+)
+
+var (
+	// ErrQueueFull is returned by the producer with the bounded queue, the client could retry later.
+	ErrQueueFull = errors.New("broker queue is full")
+	// ErrClosed is returned after Close of the producer.
+	ErrClosed = errors.New("broker producer is closed")
+	// ErrInvalidEvent is returned for the event which can't be read, retrying doesn't help.
+	ErrInvalidEvent = errors.New("invalid event")
+	// ErrUnavailable is returned while the broker isn't connected, the events of the batch aren't sent.
+	ErrUnavailable = errors.New("broker is unavailable")
+)
+
+// Serializer encodes the feedback in the events, see the schema package for JSON, Avro and Protobuf.
+type Serializer interface {
+	// ContentType is the media type of the data, e.g. application/avro.
+	ContentType() string
+	// DataSchema is the URI of the registered schema, SchemaVersion is its version in the repository.
+	DataSchema() string
+	SchemaVersion() string
+	Serialize(feedback *models.Feedback) ([]byte, error)
+}
+
+// Deserializer reads the feedback written by Serializer, contentType is the one of the serializer.
+type Deserializer interface {
+	Deserialize(ctx context.Context, contentType string, data []byte) (*models.Feedback, error)
+}
@@ -0,0 +1,355 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+const (
+	filePermissions = 0o600
+	// Suffix of the rotated files, they are sorted by the name from the oldest one.
+	rotatedLayout = "20060102T150405.000000000Z"
+)
+
+// Config of the rotation: the file is renamed to "<path>.<UTC time>" after MaxSize bytes
+// and MaxFiles of the rotated files are kept. Zero values mean no rotation and all files.
+type Config struct {
+	MaxSize  int64
+	MaxFiles int
+}
+
+// Producer appends the events into NDJSON file, one Record per line, see ReadRecords.
+// The records keep the feedbacks in plaintext (name, email and text), the erasure of the customer
+// removes them from the file and the rotated files by PurgeCustomer.
+type Producer struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	size   int64
+	config Config
+	logger logger.Logger
+}
+
+func New(log logger.Logger, path string, config Config) (*Producer, error) {
+	log = log.Named("fileBroker")
+
+	producer := &Producer{
+		mu:     sync.Mutex{},
+		path:   path,
+		file:   nil,
+		size:   0,
+		config: config,
+		logger: log,
+	}
+
+	err := producer.open()
+	if err != nil {
+		log.Error("Can't open the file", logger.M{"err": err, "path": path})
+
+		return nil, err
+	}
+
+	return producer, nil
+}
+
+func (p *Producer) Publish(ctx context.Context, event *models.FeedbackEvent) error {
+	return p.PublishBatch(ctx, []*models.FeedbackEvent{event})
+}
+
+// PublishBatch writes the batch by one write, the last line of the feedback ID wins.
+func (p *Producer) PublishBatch(ctx context.Context, events []*models.FeedbackEvent) error {
+	var lines []byte
+
+	for _, event := range events {
+		line, err := json.Marshal(newRecord(ctx, event))
+		if err != nil {
+			p.logger.Error("Failed to marshal event to JSON", logger.M{"err": err, "type": event.Type})
+
+			return fmt.Errorf("failed to marshal event to JSON: %w", err)
+		}
+
+		lines = append(append(lines, line...), '\n')
+	}
+
+	err := p.write(lines)
+	if err != nil {
+		p.logger.Error("Failed to write events", logger.M{"err": err})
+
+		return fmt.Errorf("failed to write events: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.file == nil {
+		return nil
+	}
+
+	if err := p.file.Close(); err != nil {
+		return fmt.Errorf("closing error: %w", err)
+	}
+
+	return nil
+}
+
+// write appends the lines, the file is rotated before the lines which don't fit,
+// so the batch isn't split between the files. The file isn't open after the failed rotation,
+// it's opened again by the next write.
+func (p *Producer) write(lines []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.file == nil {
+		err := p.open()
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.config.MaxSize > 0 && p.size > 0 && p.size+int64(len(lines)) > p.config.MaxSize {
+		err := p.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	written, err := p.file.Write(lines)
+	p.size += int64(written)
+
+	return err //nolint:wrapcheck
+}
+
+func (p *Producer) open() error {
+	file, err := os.OpenFile(p.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, filePermissions)
+	if err != nil {
+		return fmt.Errorf("can't open the file '%s': %w", p.path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("can't stat the file '%s': %w", p.path, err)
+	}
+
+	p.file, p.size = file, info.Size()
+
+	return nil
+}
+
+// rotate renames the full file and opens the new one, the oldest rotated files over MaxFiles are removed.
+// The file isn't renamed if it can't be closed, the failed rename reopens the full file,
+// so the lines are appended to it over MaxSize instead of being lost.
+func (p *Producer) rotate() error {
+	err := p.file.Close()
+	if err != nil {
+		p.logger.Error("Can't close the full file, it isn't rotated", logger.M{"err": err})
+
+		p.file = nil
+
+		return p.open()
+	}
+
+	p.file = nil
+	rotated := p.path + "." + time.Now().UTC().Format(rotatedLayout)
+
+	err = os.Rename(p.path, rotated)
+	if err != nil {
+		p.logger.Error("Can't rotate the file, the lines are appended to it", logger.M{"err": err})
+
+		return p.open()
+	}
+
+	err = p.open()
+	if err != nil {
+		return err
+	}
+
+	p.logger.Info("File is rotated", logger.M{"rotated": rotated})
+
+	if p.config.MaxFiles > 0 {
+		p.removeRotated()
+	}
+
+	return nil
+}
+
+// removeRotated is best effort, the new file is already opened.
+func (p *Producer) removeRotated() {
+	files, err := Rotated(p.path)
+	if err != nil {
+		p.logger.Error("Can't list the rotated files", logger.M{"err": err})
+
+		return
+	}
+
+	for len(files) > p.config.MaxFiles {
+		err = os.Remove(files[0])
+		if err != nil {
+			p.logger.Error("Can't remove the rotated file", logger.M{"err": err, "path": files[0]})
+		}
+
+		files = files[1:]
+	}
+}
+
+// PurgeCustomer rewrites the file and the rotated files without the records of the customer:
+// the records of the feedback IDs and the records with the email. The tombstones are kept,
+// they have only the IDs. The lines which can't be read are kept too. It returns the count of removed records.
+// The writes wait for the purge.
+func (p *Producer) PurgeCustomer(_ context.Context, email string, feedbackIDs []uuid.UUID) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	erased := make(map[uuid.UUID]struct{}, len(feedbackIDs))
+	for _, feedbackID := range feedbackIDs {
+		erased[feedbackID] = struct{}{}
+	}
+
+	match := func(record *Record) bool {
+		if record.Feedback == nil {
+			return false
+		}
+
+		_, ok := erased[record.ID]
+
+		return ok || strings.EqualFold(strings.TrimSpace(record.Feedback.Email), strings.TrimSpace(email))
+	}
+
+	files, err := Rotated(p.path)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+
+	for _, path := range files {
+		count, err := purgeFile(path, match)
+		purged += count
+
+		if err != nil {
+			return purged, err
+		}
+	}
+
+	// The current file is closed for the rewrite and opened again by the next write.
+	if p.file != nil {
+		err = p.file.Close()
+		p.file = nil
+
+		if err != nil {
+			return purged, fmt.Errorf("closing the file '%s': %w", p.path, err)
+		}
+	}
+
+	count, err := purgeFile(p.path, match)
+	purged += count
+
+	if err != nil {
+		return purged, err
+	}
+
+	p.logger.Info("Customer records are purged", logger.M{"purged": purged, "files": len(files) + 1})
+
+	return purged, p.open()
+}
+
+// purgeFile replaces the file by the copy without the matched records, the file without them isn't touched.
+func purgeFile(path string, match func(record *Record) bool) (int, error) {
+	source, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("can't open the file '%s': %w", path, err)
+	}
+	defer source.Close()
+
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".purge-*")
+	if err != nil {
+		return 0, fmt.Errorf("can't create the copy of '%s': %w", path, err)
+	}
+
+	defer os.Remove(temp.Name()) //nolint:errcheck
+
+	purged := 0
+	scanner := bufio.NewScanner(source)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
+	writer := bufio.NewWriter(temp)
+
+	for scanner.Scan() {
+		if record, err := readRecord(scanner.Bytes()); err == nil && match(record) {
+			purged++
+
+			continue
+		}
+
+		_, _ = writer.Write(scanner.Bytes())
+		_ = writer.WriteByte('\n')
+	}
+
+	err = scanner.Err()
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	if err == nil {
+		err = temp.Close()
+	}
+
+	if err != nil {
+		_ = temp.Close()
+
+		return 0, fmt.Errorf("can't copy the file '%s': %w", path, err)
+	}
+
+	if purged == 0 {
+		return 0, nil
+	}
+
+	err = os.Rename(temp.Name(), path)
+	if err != nil {
+		return 0, fmt.Errorf("can't replace the file '%s': %w", path, err)
+	}
+
+	return purged, nil
+}
+
+// Rotated returns the rotated files of the path from the oldest one, without the path itself.
+func Rotated(path string) ([]string, error) {
+	files, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, fmt.Errorf("listing rotated files: %w", err)
+	}
+
+	rotated := make([]string, 0, len(files))
+
+	for _, file := range files {
+		_, err = time.Parse(rotatedLayout, file[len(path)+1:])
+		if err == nil {
+			rotated = append(rotated, file)
+		}
+	}
+
+	sort.Strings(rotated)
+
+	return rotated, nil
+}
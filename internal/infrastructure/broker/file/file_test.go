@@ -0,0 +1,208 @@
+package file_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/file"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) logger.Logger { return l }
+func (nopLogger) Debug(string, logger.M)       {}
+func (nopLogger) Info(string, logger.M)        {}
+func (nopLogger) Warn(string, logger.M)        {}
+func (nopLogger) Error(string, logger.M)       {}
+func (nopLogger) Fatal(string, logger.M)       {}
+
+func newEvent() *models.FeedbackEvent {
+	//nolint:exhaustivestruct,exhaustruct
+	return models.NewFeedbackEvent(models.FeedbackCreated, &models.Feedback{
+		ID:           uuid.New(),
+		FeedbackText: "ok",
+		Source:       "https://shop.example.com",
+	})
+}
+
+func readIDs(t *testing.T, paths ...string) []uuid.UUID {
+	t.Helper()
+
+	var result []uuid.UUID
+
+	for _, path := range paths {
+		reader, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+
+		err = file.ReadRecords(reader, func(_ int, record *file.Record) error {
+			result = append(result, record.ID)
+
+			return nil
+		})
+
+		_ = reader.Close()
+
+		if err != nil {
+			t.Fatalf("ReadRecords: %v", err)
+		}
+	}
+
+	return result
+}
+
+func TestRotation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+
+	// Every event is over the size, so every write after the first one rotates the file.
+	producer, err := file.New(nopLogger{}, path, file.Config{MaxSize: 1, MaxFiles: 2})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	events := []*models.FeedbackEvent{newEvent(), newEvent(), newEvent(), newEvent()}
+
+	for _, event := range events {
+		err = producer.Publish(ctx, event)
+		if err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	err = producer.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rotated, err := file.Rotated(path)
+	if err != nil || len(rotated) != 2 { //nolint:gomnd
+		t.Fatalf("Rotated: got %v, %v", rotated, err)
+	}
+
+	// The oldest rotated file is removed, the rest are read in order.
+	got := readIDs(t, append(rotated, path)...)
+	want := []uuid.UUID{events[1].FeedbackID, events[2].FeedbackID, events[3].FeedbackID}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRotationReopensRemovedFile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.ndjson")
+
+	producer, err := file.New(nopLogger{}, path, file.Config{MaxSize: 1, MaxFiles: 0})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	first := newEvent()
+
+	err = producer.Publish(ctx, first)
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	// The rename fails on the removed file, the lines are appended to the new file of the path.
+	err = os.Remove(path)
+	if err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	second := newEvent()
+
+	err = producer.Publish(ctx, second)
+	if err != nil {
+		t.Fatalf("Publish after the failed rotation: %v", err)
+	}
+
+	err = producer.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	got := readIDs(t, path)
+	if len(got) != 1 || got[0] != second.FeedbackID {
+		t.Errorf("got %v, want [%s]", got, second.FeedbackID)
+	}
+}
+
+func TestPurgeCustomer(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+
+	producer, err := file.New(nopLogger{}, path, file.Config{MaxSize: 1, MaxFiles: 0})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	byID, byEmail, kept := newEvent(), newEvent(), newEvent()
+	byEmail.Feedback.Email = "Ann@Example.com"
+	tombstone := models.NewDeletedEvent(byID.FeedbackID, "")
+
+	// The records are spread over the rotated files and the current one.
+	for _, event := range []*models.FeedbackEvent{byID, byEmail, kept, tombstone} {
+		err = producer.Publish(ctx, event)
+		if err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	purged, err := producer.PurgeCustomer(ctx, "ann@example.com", []uuid.UUID{byID.FeedbackID})
+	if err != nil || purged != 2 { //nolint:gomnd
+		t.Fatalf("PurgeCustomer: got %d, %v", purged, err)
+	}
+
+	// The file is opened again for the next writes.
+	next := newEvent()
+
+	err = producer.Publish(ctx, next)
+	if err != nil {
+		t.Fatalf("Publish after the purge: %v", err)
+	}
+
+	err = producer.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rotated, err := file.Rotated(path)
+	if err != nil {
+		t.Fatalf("Rotated: %v", err)
+	}
+
+	got := readIDs(t, append(rotated, path)...)
+	want := []uuid.UUID{kept.FeedbackID, byID.FeedbackID, next.FeedbackID}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker"
+)
+
+// Lines up to 1 MiB, the feedback text is limited by the service.
+const maxLineSize = 1 << 20
+
+// Record is the line of the file: the event with the feedback or the tombstone.
+// ID is the feedback ID, EventID is empty in the files written before it.
+type Record struct {
+	Type    string    `json:"type"`
+	ID      uuid.UUID `json:"id"`
+	EventID string    `json:"event_id,omitempty"` //nolint:tagliatelle
+	Time    time.Time `json:"time"`
+	Tenant  string    `json:"tenant,omitempty"`
+	Deleted bool      `json:"deleted,omitempty"`
+	// Feedback is nil for the tombstone.
+	Feedback *models.Feedback `json:"feedback,omitempty"`
+}
+
+func newRecord(ctx context.Context, event *models.FeedbackEvent) *Record {
+	tenant := event.Tenant
+	if tenant == "" {
+		tenant = reqctx.Tenant(ctx)
+	}
+
+	return &Record{
+		Type:     event.Type,
+		ID:       event.FeedbackID,
+		EventID:  event.ID,
+		Time:     event.Time,
+		Tenant:   tenant,
+		Deleted:  event.Type == models.FeedbackDeleted,
+		Feedback: event.Feedback,
+	}
+}
+
+// Event is the event of the record, the line of the old format gets the new ID and the current time.
+func (r *Record) Event() *models.FeedbackEvent {
+	eventID, eventTime := r.EventID, r.Time
+	if eventID == "" {
+		eventID = uuid.NewString()
+	}
+
+	if eventTime.IsZero() {
+		eventTime = time.Now().UTC()
+	}
+
+	return &models.FeedbackEvent{
+		ID:         eventID,
+		Type:       r.Type,
+		Time:       eventTime,
+		FeedbackID: r.ID,
+		Tenant:     r.Tenant,
+		Feedback:   r.Feedback,
+	}
+}
+
+// ReadRecords calls the callback for every line of the file in order and stops at its first error.
+// The lines of the old format (the feedback or {"id": ..., "deleted": true}) are read as created and deleted events.
+func ReadRecords(reader io.Reader, callback func(line int, record *Record) error) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		record, err := readRecord(scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		err = callback(line, record)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading the file: %w", err)
+	}
+
+	return nil
+}
+
+func readRecord(data []byte) (*Record, error) {
+	var record Record
+
+	err := json.Unmarshal(data, &record)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err.Error(), broker.ErrInvalidEvent)
+	}
+
+	switch {
+	case record.Type == "" && record.Deleted:
+		record.Type = models.FeedbackDeleted
+	case record.Type == "":
+		var feedback models.Feedback
+
+		// The line is the feedback itself.
+		_ = json.Unmarshal(data, &feedback)
+		record.Type, record.Feedback = models.FeedbackCreated, &feedback
+	}
+
+	switch {
+	case record.Type != models.FeedbackDeleted && record.Feedback == nil:
+		return nil, fmt.Errorf("event '%s' without feedback: %w", record.Type, broker.ErrInvalidEvent)
+	case record.Feedback != nil:
+		record.ID = record.Feedback.ID
+		record.Feedback.Tenant = record.Tenant
+	}
+
+	if record.ID == uuid.Nil {
+		return nil, fmt.Errorf("event without ID: %w", broker.ErrInvalidEvent)
+	}
+
+	return &record, nil
+}
@@ -0,0 +1,460 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// Policies of AsyncProducer for the message of the full queue.
+const (
+	PolicyBlock  = "block"
+	PolicyDrop   = "drop"
+	PolicyReject = "reject"
+)
+
+var errUnknownPolicy = errors.New("unknown queue policy, expected block, drop or reject")
+
+// Metrics of the async producer.
+//
+//nolint:gochecknoglobals,exhaustivestruct,exhaustruct
+var (
+	messagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_total",
+		Help: "Messages of the async Kafka producer by result: sent, failed, dropped, rejected.",
+	}, []string{"result"})
+	queueSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "kafka_queue_messages",
+		Help: "Messages in the queue of the async Kafka producer, they aren't acknowledged yet.",
+	})
+)
+
+// DeliveryFunc gets the result of every message of the async producer, nil error means the message is acknowledged.
+// The value is nil for tombstones. It's called from the goroutine of the results, so it must not block.
+type DeliveryFunc func(key, value []byte, err error)
+
+// UndeliveredFunc gets the event of Publish which isn't delivered or is dropped by the policy,
+// the batches return the errors to their callers. It's called from the goroutine of the results,
+// the slot of the message is taken until it returns.
+type UndeliveredFunc func(event *models.FeedbackEvent, err error)
+
+// AsyncConfig is the queue and the batches of AsyncProducer, zero values mean the defaults of Sarama.
+type AsyncConfig struct {
+	// Messages sent, but not acknowledged by Kafka yet.
+	QueueSize int
+	// What to do with the message of the full queue: block, drop or reject, see the Policy constants.
+	Policy string
+	// Messages per batch and the time of waiting for the batch to be filled.
+	BatchSize int
+	Linger    time.Duration
+	// Optional callbacks of the results.
+	OnDelivery    DeliveryFunc
+	OnUndelivered UndeliveredFunc
+}
+
+// AsyncProducer batches the messages in the background, Publish returns when the message is queued.
+// The policy is applied only to Publish: the batches of PublishBatch wait for the room in the queue
+// and for the delivery, their callers rely on it (e.g. the erasure).
+type AsyncProducer struct {
+	logger        logger.Logger
+	client        sarama.Client
+	producer      sarama.AsyncProducer
+	topic         string
+	encoder       *encoder
+	timeout       time.Duration
+	policy        string
+	onDelivery    DeliveryFunc
+	onUndelivered UndeliveredFunc
+
+	// slots is the bounded queue: a message takes a slot until its result.
+	slots chan struct{}
+
+	// mu guards closed and the input of the producer, it's closed under the write lock.
+	mu      sync.RWMutex
+	closed  bool
+	pending sync.Mutex
+	count   int
+	waiters []chan struct{}
+	done    chan struct{}
+}
+
+// batch is the metadata of the messages sent by PublishBatch.
+type batch struct {
+	wg  sync.WaitGroup
+	mu  sync.Mutex
+	err error
+}
+
+func NewAsync(
+	log logger.Logger,
+	addr string,
+	topicName string,
+	timeout time.Duration,
+	events *EventConfig,
+	asyncConfig *AsyncConfig,
+) (*AsyncProducer, error) {
+	log = log.Named("kafka")
+
+	encoder, err := newEncoder(topicName, events)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkPolicy(asyncConfig.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	config := newConfig(timeout)
+	config.Producer.Return.Errors = true
+
+	if asyncConfig.QueueSize > 0 {
+		config.ChannelBufferSize = asyncConfig.QueueSize
+	}
+
+	if asyncConfig.BatchSize > 0 {
+		config.Producer.Flush.Messages = asyncConfig.BatchSize
+	}
+
+	config.Producer.Flush.Frequency = asyncConfig.Linger
+	if config.Producer.Flush.Frequency <= 0 {
+		config.Producer.Flush.Frequency = frequency * time.Millisecond
+	}
+
+	client, err := newClient(log, []string{addr}, config)
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := sarama.NewAsyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+
+		log.Error("Failed to create async Kafka producer", logger.M{
+			"err":   err,
+			"addr":  addr,
+			"topic": topicName,
+		})
+
+		return nil, fmt.Errorf("can't setting up async Kafka Producer: %w", err)
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	async := &AsyncProducer{
+		logger:        log,
+		client:        client,
+		producer:      producer,
+		topic:         topicName,
+		encoder:       encoder,
+		timeout:       timeout,
+		policy:        asyncConfig.Policy,
+		onDelivery:    asyncConfig.OnDelivery,
+		onUndelivered: asyncConfig.OnUndelivered,
+		slots:         make(chan struct{}, config.ChannelBufferSize),
+		done:          make(chan struct{}),
+	}
+
+	go async.results()
+
+	return async, nil
+}
+
+// Publish queues the event, the delivery is reported by the metrics, the log and the callback.
+func (a *AsyncProducer) Publish(ctx context.Context, event *models.FeedbackEvent) error {
+	message, err := a.encoder.message(ctx, event)
+	if err != nil {
+		a.logger.Error("Failed to serialize event", logger.M{"err": err, "type": event.Type})
+
+		return err
+	}
+
+	err = a.acquire(ctx, a.policy)
+	if errors.Is(err, errDropped) {
+		a.logger.Warn("Queue is full, the message is dropped", logger.M{"feedbackID": event.FeedbackID, "type": event.Type})
+
+		if a.onUndelivered != nil {
+			a.onUndelivered(event, err)
+		}
+
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to queue Kafka message: %w", err)
+	}
+
+	message.Metadata = event
+
+	return a.input(message)
+}
+
+// PublishBatch waits until all events are delivered.
+func (a *AsyncProducer) PublishBatch(ctx context.Context, events []*models.FeedbackEvent) error {
+	messages, err := a.encoder.messages(ctx, events)
+	if err != nil {
+		a.logger.Error("Failed to serialize events", logger.M{"err": err})
+
+		return err
+	}
+
+	err = a.sendBatch(ctx, messages)
+	if err != nil {
+		a.logger.Error("Failed to send Kafka messages", logger.M{"err": err, "count": len(messages)})
+
+		return fmt.Errorf("failed to send Kafka messages: %w", err)
+	}
+
+	a.logger.Info("Sent Kafka messages", logger.M{"count": len(messages)})
+
+	return nil
+}
+
+// Rejects tells that the next Publish would be rejected, so the caller can stop before the changes.
+func (a *AsyncProducer) Rejects() bool {
+	return a.policy == PolicyReject && len(a.slots) == cap(a.slots)
+}
+
+// Flush waits until all queued messages get their results or the context is done.
+func (a *AsyncProducer) Flush(ctx context.Context) error {
+	a.pending.Lock()
+	if a.count == 0 {
+		a.pending.Unlock()
+
+		return nil
+	}
+
+	drained := make(chan struct{})
+	a.waiters = append(a.waiters, drained)
+	a.pending.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("flushing Kafka messages: %w", ctx.Err())
+	}
+}
+
+// Ping refreshes the metadata of the topic, the error means that Kafka isn't reachable.
+func (a *AsyncProducer) Ping() error {
+	return ping(a.client, a.topic)
+}
+
+// Close stops accepting messages, drains the queue within the timeout and closes the producer.
+func (a *AsyncProducer) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+
+		return nil
+	}
+
+	a.closed = true
+	a.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+
+	err := a.Flush(ctx)
+	if err != nil {
+		a.logger.Error("Queue isn't drained", logger.M{"err": err, "left": len(a.slots)})
+	}
+
+	a.producer.AsyncClose()
+	<-a.done
+	a.client.Close()
+
+	a.logger.Info("Async producer is closed", nil)
+
+	return err
+}
+
+var errDropped = errors.New("message is dropped")
+
+func checkPolicy(policy string) error {
+	switch policy {
+	case PolicyBlock, PolicyDrop, PolicyReject:
+		return nil
+	default:
+		return fmt.Errorf("'%s': %w", policy, errUnknownPolicy)
+	}
+}
+
+// acquire takes the slot of the queue by the policy.
+func (a *AsyncProducer) acquire(ctx context.Context, policy string) error {
+	select {
+	case a.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	switch policy {
+	case PolicyDrop:
+		messagesTotal.WithLabelValues("dropped").Inc()
+
+		return errDropped
+	case PolicyReject:
+		messagesTotal.WithLabelValues("rejected").Inc()
+
+		return broker.ErrQueueFull
+	}
+
+	select {
+	case a.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for the queue: %w", ctx.Err())
+	}
+}
+
+// input passes the message with the taken slot to Sarama.
+func (a *AsyncProducer) input(message *sarama.ProducerMessage) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		<-a.slots
+
+		return broker.ErrClosed
+	}
+
+	a.pending.Lock()
+	a.count++
+	a.pending.Unlock()
+	queueSize.Add(1)
+
+	a.producer.Input() <- message
+
+	return nil
+}
+
+// sendBatch queues the messages and waits for their results until the timeout.
+func (a *AsyncProducer) sendBatch(ctx context.Context, messages []*sarama.ProducerMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	//nolint:exhaustivestruct,exhaustruct
+	result := &batch{}
+
+	for _, message := range messages {
+		err := a.acquire(ctx, PolicyBlock)
+		if err != nil {
+			return err
+		}
+
+		message.Metadata = result
+
+		result.wg.Add(1)
+
+		err = a.input(message)
+		if err != nil {
+			result.wg.Done()
+
+			return err
+		}
+	}
+
+	delivered := make(chan struct{})
+
+	go func() {
+		result.wg.Wait()
+		close(delivered)
+	}()
+
+	select {
+	case <-delivered:
+		return result.err
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for the delivery: %w", ctx.Err())
+	}
+}
+
+// results reads the acknowledgements and the errors until the producer is closed.
+func (a *AsyncProducer) results() {
+	defer close(a.done)
+
+	successes, errs := a.producer.Successes(), a.producer.Errors()
+
+	for successes != nil || errs != nil {
+		select {
+		case message, ok := <-successes:
+			if !ok {
+				successes = nil
+
+				continue
+			}
+
+			a.complete(message, nil)
+		case failed, ok := <-errs:
+			if !ok {
+				errs = nil
+
+				continue
+			}
+
+			a.complete(failed.Msg, failed.Err)
+		}
+	}
+}
+
+func (a *AsyncProducer) complete(message *sarama.ProducerMessage, err error) {
+	if err != nil {
+		messagesTotal.WithLabelValues("failed").Inc()
+		a.logger.Error("Failed to deliver Kafka message", logger.M{"err": err})
+	} else {
+		messagesTotal.WithLabelValues("sent").Inc()
+	}
+
+	if result, ok := message.Metadata.(*batch); ok {
+		if err != nil {
+			result.mu.Lock()
+			result.err = err
+			result.mu.Unlock()
+		}
+
+		result.wg.Done()
+	}
+
+	if a.onDelivery != nil {
+		a.onDelivery(encoded(message.Key), encoded(message.Value), err)
+	}
+
+	if event, ok := message.Metadata.(*models.FeedbackEvent); ok && err != nil && a.onUndelivered != nil {
+		a.onUndelivered(event, err)
+	}
+
+	<-a.slots
+	queueSize.Add(-1)
+
+	a.pending.Lock()
+	a.count--
+
+	if a.count == 0 {
+		for _, waiter := range a.waiters {
+			close(waiter)
+		}
+
+		a.waiters = nil
+	}
+	a.pending.Unlock()
+}
+
+func encoded(encoder sarama.Encoder) []byte {
+	if encoder == nil {
+		return nil
+	}
+
+	data, _ := encoder.Encode()
+
+	return data
+}
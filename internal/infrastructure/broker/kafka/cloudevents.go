@@ -0,0 +1,360 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker"
+)
+
+// Modes of the CloudEvents Kafka binding: the structured one keeps the whole event in the value,
+// the binary one keeps the feedback in the value and the attributes in "ce_" headers.
+const (
+	ModeStructured = "structured"
+	ModeBinary     = "binary"
+)
+
+// Types of the events.
+const (
+	TypeCreated = models.FeedbackCreated
+	TypeUpdated = models.FeedbackUpdated
+	TypeDeleted = models.FeedbackDeleted
+	TypeTagged  = models.FeedbackTagged
+)
+
+const (
+	specVersion = "1.0"
+	// SchemaVersion is the version of the feedback in the data without the serializer.
+	SchemaVersion = "1"
+
+	contentTypeJSON       = "application/json"
+	contentTypeCloudEvent = "application/cloudevents+json"
+
+	// Headers of the context of the request, they are set in both modes.
+	headerTraceParent = "traceparent"
+	headerRequestID   = "x-request-id"
+)
+
+var errUnknownMode = errors.New("unknown event mode, expected structured or binary")
+
+// EventConfig is the envelope of the messages, empty values mean structured mode,
+// "/feedback-service" as the source and the feedback as JSON without schema.
+type EventConfig struct {
+	Mode       string
+	Source     string
+	Serializer broker.Serializer
+}
+
+// cloudEvent is the structured mode event.
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	Subject         string          `json:"subject"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	SchemaVersion   string          `json:"schemaversion"`
+	Tenant          string          `json:"tenant,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	// DataBase64 is the binary data (Avro or Protobuf) of the structured mode.
+	DataBase64 []byte `json:"data_base64,omitempty"` //nolint:tagliatelle
+}
+
+// plainJSON is the serializer without schema.
+type plainJSON struct{}
+
+func (plainJSON) ContentType() string   { return contentTypeJSON }
+func (plainJSON) DataSchema() string    { return "" }
+func (plainJSON) SchemaVersion() string { return SchemaVersion }
+
+func (plainJSON) Serialize(feedback *models.Feedback) ([]byte, error) {
+	data, err := json.Marshal(feedback)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Feedback to JSON: %w", err)
+	}
+
+	return data, nil
+}
+
+// encoder builds the messages of the topic by the config.
+type encoder struct {
+	topicName  string
+	mode       string
+	source     string
+	serializer broker.Serializer
+}
+
+func newEncoder(topicName string, config *EventConfig) (*encoder, error) {
+	//nolint:exhaustivestruct,exhaustruct
+	if config == nil {
+		config = &EventConfig{}
+	}
+
+	result := &encoder{
+		topicName:  topicName,
+		mode:       config.Mode,
+		source:     config.Source,
+		serializer: config.Serializer,
+	}
+
+	if result.serializer == nil {
+		result.serializer = plainJSON{}
+	}
+
+	switch result.mode {
+	case "":
+		result.mode = ModeStructured
+	case ModeStructured, ModeBinary:
+	default:
+		return nil, fmt.Errorf("'%s': %w", config.Mode, errUnknownMode)
+	}
+
+	if result.source == "" {
+		result.source = "/feedback-service"
+	}
+
+	return result, nil
+}
+
+// message is keyed by the feedback ID, so the compacted topic keeps the last event of every feedback.
+// The deleted feedback is the tombstone: it has no value in both modes, the attributes of the event are in the headers.
+func (e *encoder) message(ctx context.Context, event *models.FeedbackEvent) (*sarama.ProducerMessage, error) {
+	envelope := e.event(ctx, event)
+
+	//nolint:exhaustivestruct,exhaustruct
+	message := &sarama.ProducerMessage{
+		Topic:   e.topicName,
+		Key:     sarama.StringEncoder(event.FeedbackID.String()),
+		Headers: contextHeaders(ctx),
+	}
+
+	if event.Type == TypeDeleted {
+		envelope.DataSchema = ""
+		message.Headers = append(message.Headers, binaryHeaders(envelope, "")...)
+
+		return message, nil
+	}
+
+	if event.Feedback == nil {
+		return nil, fmt.Errorf("event '%s' of type '%s' without feedback: %w", event.ID, event.Type, broker.ErrInvalidEvent)
+	}
+
+	data, err := e.serializer.Serialize(event.Feedback)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if e.mode == ModeBinary {
+		message.Headers = append(message.Headers, binaryHeaders(envelope, envelope.DataContentType)...)
+		message.Value = sarama.ByteEncoder(data)
+
+		return message, nil
+	}
+
+	if envelope.DataContentType == contentTypeJSON {
+		envelope.Data = data
+	} else {
+		envelope.DataBase64 = data
+	}
+
+	value, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal CloudEvent to JSON: %w", err)
+	}
+
+	message.Headers = append(message.Headers, header("content-type", contentTypeCloudEvent))
+	message.Value = sarama.ByteEncoder(value)
+
+	return message, nil
+}
+
+func (e *encoder) messages(ctx context.Context, events []*models.FeedbackEvent) ([]*sarama.ProducerMessage, error) {
+	messages := make([]*sarama.ProducerMessage, 0, len(events))
+
+	for _, event := range events {
+		message, err := e.message(ctx, event)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// event is the envelope of the event, the tenant of the context is used for the event without it.
+func (e *encoder) event(ctx context.Context, event *models.FeedbackEvent) *cloudEvent {
+	tenant := event.Tenant
+	if tenant == "" {
+		tenant = reqctx.Tenant(ctx)
+	}
+
+	return &cloudEvent{
+		SpecVersion:     specVersion,
+		ID:              event.ID,
+		Type:            event.Type,
+		Source:          e.source,
+		Subject:         event.FeedbackID.String(),
+		Time:            event.Time.UTC().Format(time.RFC3339Nano),
+		DataContentType: e.serializer.ContentType(),
+		DataSchema:      e.serializer.DataSchema(),
+		SchemaVersion:   e.serializer.SchemaVersion(),
+		Tenant:          tenant,
+		Data:            nil,
+		DataBase64:      nil,
+	}
+}
+
+// binaryHeaders are the attributes of the binary mode, empty content type is for the event without data.
+func binaryHeaders(event *cloudEvent, contentType string) []sarama.RecordHeader {
+	headers := []sarama.RecordHeader{
+		header("ce_specversion", event.SpecVersion),
+		header("ce_id", event.ID),
+		header("ce_type", event.Type),
+		header("ce_source", event.Source),
+		header("ce_subject", event.Subject),
+		header("ce_time", event.Time),
+		header("ce_schemaversion", event.SchemaVersion),
+	}
+
+	if event.DataSchema != "" {
+		headers = append(headers, header("ce_dataschema", event.DataSchema))
+	}
+
+	if event.Tenant != "" {
+		headers = append(headers, header("ce_tenant", event.Tenant))
+	}
+
+	if contentType != "" {
+		headers = append(headers, header("content-type", contentType))
+	}
+
+	return headers
+}
+
+func contextHeaders(ctx context.Context) []sarama.RecordHeader {
+	headers := make([]sarama.RecordHeader, 0, 2) //nolint:gomnd
+
+	if traceParent := reqctx.TraceParent(ctx); traceParent != "" {
+		headers = append(headers, header(headerTraceParent, traceParent))
+	}
+
+	if requestID := reqctx.RequestID(ctx); requestID != "" {
+		headers = append(headers, header(headerRequestID, requestID))
+	}
+
+	return headers
+}
+
+func header(key, value string) sarama.RecordHeader {
+	return sarama.RecordHeader{Key: []byte(key), Value: []byte(value)}
+}
+
+// decodeEvent reads the message of both modes, the tombstone has no feedback.
+// The message without the envelope is the feedback written before CloudEvents, it's the created one.
+func decodeEvent(
+	ctx context.Context,
+	deserializer broker.Deserializer,
+	message *sarama.ConsumerMessage,
+) (*models.FeedbackEvent, error) {
+	headers := make(map[string]string, len(message.Headers))
+	for _, record := range message.Headers {
+		headers[strings.ToLower(string(record.Key))] = string(record.Value)
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	event := &cloudEvent{
+		ID:              headers["ce_id"],
+		Type:            headers["ce_type"],
+		Subject:         headers["ce_subject"],
+		Time:            headers["ce_time"],
+		DataContentType: headers["content-type"],
+		Tenant:          headers["ce_tenant"],
+		Data:            message.Value,
+	}
+
+	switch {
+	case message.Value == nil:
+		if event.Type == "" {
+			event.Type = TypeDeleted
+		}
+
+		if event.Subject == "" {
+			event.Subject = string(message.Key)
+		}
+	case event.Type == "" && strings.HasPrefix(event.DataContentType, contentTypeCloudEvent):
+		event.Data = nil
+
+		err := json.Unmarshal(message.Value, event)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", err.Error(), broker.ErrInvalidEvent)
+		}
+
+		if event.DataBase64 != nil {
+			event.Data = event.DataBase64
+		}
+	case event.Type == "":
+		event.Type = TypeCreated
+		event.DataContentType = contentTypeJSON
+	}
+
+	if event.ID == "" {
+		event.ID = fmt.Sprintf("%s/%d/%d", message.Topic, message.Partition, message.Offset)
+	}
+
+	return readEvent(ctx, deserializer, event, message.Timestamp)
+}
+
+func readEvent(
+	ctx context.Context,
+	deserializer broker.Deserializer,
+	event *cloudEvent,
+	timestamp time.Time,
+) (*models.FeedbackEvent, error) {
+	result := &models.FeedbackEvent{
+		ID:         event.ID,
+		Type:       event.Type,
+		Time:       timestamp.UTC(),
+		FeedbackID: uuid.Nil,
+		Tenant:     event.Tenant,
+		Feedback:   nil,
+	}
+
+	if eventTime, err := time.Parse(time.RFC3339Nano, event.Time); err == nil {
+		result.Time = eventTime.UTC()
+	}
+
+	if event.Type != TypeDeleted {
+		feedback, err := deserializer.Deserialize(ctx, event.DataContentType, event.Data)
+		if err != nil {
+			return nil, fmt.Errorf("event '%s': %w", event.ID, err)
+		}
+
+		feedback.Tenant = event.Tenant
+		result.Feedback = feedback
+		result.FeedbackID = feedback.ID
+
+		return result, nil
+	}
+
+	feedbackID, err := uuid.Parse(event.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("subject '%s' of event '%s': %w", event.Subject, event.ID, broker.ErrInvalidEvent)
+	}
+
+	result.FeedbackID = feedbackID
+
+	return result, nil
+}
@@ -0,0 +1,229 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+const (
+	// Offsets are committed after this number of messages or when the partition has no more messages.
+	commitEvery = 100
+
+	// Backoff of the handler failures, the message is retried until the context is done.
+	retryMin = 500 * time.Millisecond
+	retryMax = 30 * time.Second
+)
+
+// Metrics of the consumer.
+//
+//nolint:gochecknoglobals,exhaustivestruct,exhaustruct
+var consumedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "kafka_consumed_total",
+	Help: "Messages of the Kafka consumer by result: handled, invalid, retried.",
+}, []string{"result"})
+
+// Handler applies the event, the offset is committed only after it returns nil.
+// The events are delivered at least once, so the handler must skip the applied ones by the event ID.
+type Handler interface {
+	Handle(ctx context.Context, event *models.FeedbackEvent) error
+}
+
+// Consumer reads the feedback events by the consumer group, the partitions are split between the members.
+type Consumer struct {
+	logger       logger.Logger
+	group        sarama.ConsumerGroup
+	topics       []string
+	deserializer broker.Deserializer
+	handler      Handler
+}
+
+// Check that actual implementation fits the interface.
+var _ sarama.ConsumerGroupHandler = (*Consumer)(nil)
+
+// NewConsumer starts from the oldest offset for the new group, so the handler gets the whole topic.
+func NewConsumer(
+	log logger.Logger,
+	addr string,
+	topicName string,
+	groupID string,
+	timeout time.Duration,
+	deserializer broker.Deserializer,
+	handler Handler,
+) (*Consumer, error) {
+	log = log.Named("kafka.consumer")
+
+	config := sarama.NewConfig()
+	config.Net.DialTimeout = timeout
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Offsets.AutoCommit.Enable = false
+
+	group, err := sarama.NewConsumerGroup([]string{addr}, groupID, config)
+	if err != nil {
+		log.Error("Failed to create Kafka consumer group", logger.M{
+			"err":   err,
+			"addr":  addr,
+			"topic": topicName,
+			"group": groupID,
+		})
+
+		return nil, fmt.Errorf("can't setting up Kafka consumer group: %w", err)
+	}
+
+	return &Consumer{
+		logger:       log,
+		group:        group,
+		topics:       []string{topicName},
+		deserializer: deserializer,
+		handler:      handler,
+	}, nil
+}
+
+// Run consumes until the context is done, every rebalance starts the new session.
+func (c *Consumer) Run(ctx context.Context) error {
+	go func() {
+		for err := range c.group.Errors() {
+			c.logger.Error("Consumer group error", logger.M{"err": err})
+		}
+	}()
+
+	for ctx.Err() == nil {
+		err := c.group.Consume(ctx, c.topics, c)
+
+		switch {
+		case errors.Is(err, sarama.ErrClosedConsumerGroup):
+			return nil
+		case err != nil:
+			c.logger.Error("Consumer session failed", logger.M{"err": err})
+
+			if !sleep(ctx, retryMin) {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close leaves the group, the marked offsets are committed in Cleanup of the session.
+func (c *Consumer) Close() error {
+	if err := c.group.Close(); err != nil {
+		return fmt.Errorf("closing consumer group: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Consumer) Setup(session sarama.ConsumerGroupSession) error {
+	c.logger.Info("Partitions are assigned", logger.M{
+		"claims":       session.Claims(),
+		"memberID":     session.MemberID(),
+		"generationID": session.GenerationID(),
+	})
+
+	return nil
+}
+
+// Cleanup commits the handled messages before the partitions are given to other members.
+func (c *Consumer) Cleanup(session sarama.ConsumerGroupSession) error {
+	session.Commit()
+
+	c.logger.Info("Partitions are revoked", logger.M{"claims": session.Claims()})
+
+	return nil
+}
+
+func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	uncommitted := 0
+
+	for {
+		select {
+		case <-session.Context().Done():
+			return nil
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+
+			if !c.handle(session.Context(), message) {
+				// The session is over, the message goes to the next owner of the partition.
+				return nil
+			}
+
+			session.MarkMessage(message, "")
+			uncommitted++
+
+			if uncommitted >= commitEvery || len(claim.Messages()) == 0 {
+				session.Commit()
+
+				uncommitted = 0
+			}
+		}
+	}
+}
+
+// handle retries the failed message with the backoff, the invalid message is skipped.
+// It returns false if the context is done before the message is handled.
+func (c *Consumer) handle(ctx context.Context, message *sarama.ConsumerMessage) bool {
+	for delay := retryMin; ; delay *= 2 {
+		if delay > retryMax {
+			delay = retryMax
+		}
+
+		event, err := decodeEvent(ctx, c.deserializer, message)
+		if err == nil {
+			err = c.handler.Handle(ctx, event)
+		}
+
+		switch {
+		case err == nil:
+			consumedTotal.WithLabelValues("handled").Inc()
+
+			return true
+		case errors.Is(err, broker.ErrInvalidEvent):
+			consumedTotal.WithLabelValues("invalid").Inc()
+			c.logger.Error("Invalid message is skipped", logger.M{
+				"err":       err,
+				"partition": message.Partition,
+				"offset":    message.Offset,
+			})
+
+			return true
+		}
+
+		consumedTotal.WithLabelValues("retried").Inc()
+		c.logger.Error("Failed to handle message", logger.M{
+			"err":       err,
+			"partition": message.Partition,
+			"offset":    message.Offset,
+			"retryIn":   delay.String(),
+		})
+
+		if !sleep(ctx, delay) {
+			return false
+		}
+	}
+}
+
+// sleep returns false if the context is done first.
+func sleep(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
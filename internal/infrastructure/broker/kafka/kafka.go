@@ -1,7 +1,7 @@
 package kafka
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"time"
 
@@ -14,41 +14,41 @@ import (
 const frequency = 500
 
 type Producer struct {
-	logger    logger.Logger
-	producer  sarama.SyncProducer
-	topicName string
+	logger   logger.Logger
+	client   sarama.Client
+	producer sarama.SyncProducer
+	topic    string
+	encoder  *encoder
+	timeout  time.Duration
 }
 
-func New(log logger.Logger, addr string, topicName string) (*Producer, error) {
+// New publishes the events in the envelope of CloudEvents, see EventConfig.
+func New(
+	log logger.Logger,
+	addr string,
+	topicName string,
+	timeout time.Duration,
+	events *EventConfig,
+) (*Producer, error) {
 	log = log.Named("kafka")
 
-	config := sarama.NewConfig()
-	config.Producer.Return.Successes = true
-	config.Producer.RequiredAcks = sarama.WaitForAll
-	config.Producer.Compression = sarama.CompressionSnappy
-	config.Producer.Flush.Frequency = frequency * time.Millisecond
-
-	brokers := []string{addr}
-
-	// ChatGPT's generated code for displaying available Topics.
-	// Check list of topics which exist.
-	client, err := sarama.NewClient(brokers, config)
+	encoder, err := newEncoder(topicName, events)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create client: %w", err)
+		return nil, err
 	}
 
-	topics, err := client.Topics()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get topics: %w", err)
-	}
+	config := newConfig(timeout)
+	config.Producer.Flush.Frequency = frequency * time.Millisecond
 
-	for _, topic := range topics {
-		log.Info("Topic", logger.M{"topic": topic})
+	client, err := newClient(log, []string{addr}, config)
+	if err != nil {
+		return nil, err
 	}
-	// End generated code.
 
-	producer, err := sarama.NewSyncProducer(brokers, config)
+	producer, err := sarama.NewSyncProducerFromClient(client)
 	if err != nil {
+		client.Close()
+
 		log.Error("Failed to create Kafka producer", logger.M{
 			"err":   err,
 			"addr":  addr,
@@ -59,34 +59,48 @@ func New(log logger.Logger, addr string, topicName string) (*Producer, error) {
 	}
 
 	return &Producer{
-		logger:    log,
-		producer:  producer,
-		topicName: topicName,
+		logger:   log,
+		client:   client,
+		producer: producer,
+		topic:    topicName,
+		encoder:  encoder,
+		timeout:  timeout,
 	}, nil
 }
 
-func (a *Producer) SendMessage(feedback *models.Feedback) error {
-	feedbackJSON, err := json.Marshal(feedback)
+// CheckConfig returns the error of the configuration without the connection to Kafka,
+// asyncConfig is nil for the sync producer. It's for the producers created later, see ResilientProducer.
+func CheckConfig(events *EventConfig, asyncConfig *AsyncConfig) error {
+	_, err := newEncoder("", events)
 	if err != nil {
-		a.logger.Error("Failed to marshal Feedback to JSON", logger.M{"err": err})
+		return err
+	}
 
-		return fmt.Errorf("failed to marshal Feedback to JSON: %w", err)
+	if asyncConfig != nil {
+		return checkPolicy(asyncConfig.Policy)
 	}
 
-	//nolint:exhaustivestruct,exhaustruct
-	message := &sarama.ProducerMessage{
-		Topic: a.topicName,
-		Value: sarama.StringEncoder(feedbackJSON),
+	return nil
+}
+
+// Publish sends the event and waits for the acknowledgement.
+func (a *Producer) Publish(ctx context.Context, event *models.FeedbackEvent) error {
+	message, err := a.encoder.message(ctx, event)
+	if err != nil {
+		a.logger.Error("Failed to serialize event", logger.M{"err": err, "type": event.Type})
+
+		return err
 	}
 
-	partition, offset, err := a.producer.SendMessage(message)
+	partition, offset, err := a.send(ctx, message)
 	if err != nil {
-		a.logger.Error("Failed to send Kafka message", logger.M{"err": err})
+		a.logger.Error("Failed to send Kafka message", logger.M{"err": err, "type": event.Type})
 
 		return fmt.Errorf("failed to send Kafka message: %w", err)
 	}
 
 	a.logger.Info("Sent Kafka message", logger.M{
+		"type":      event.Type,
 		"partition": partition,
 		"offset":    offset,
 	})
@@ -94,10 +108,123 @@ func (a *Producer) SendMessage(feedback *models.Feedback) error {
 	return nil
 }
 
+// PublishBatch sends the events by one request.
+func (a *Producer) PublishBatch(ctx context.Context, events []*models.FeedbackEvent) error {
+	messages, err := a.encoder.messages(ctx, events)
+	if err != nil {
+		a.logger.Error("Failed to serialize events", logger.M{"err": err})
+
+		return err
+	}
+
+	err = a.wait(ctx, func() error {
+		return a.producer.SendMessages(messages) //nolint:wrapcheck
+	})
+	if err != nil {
+		a.logger.Error("Failed to send Kafka messages", logger.M{"err": err, "count": len(messages)})
+
+		return fmt.Errorf("failed to send Kafka messages: %w", err)
+	}
+
+	a.logger.Info("Sent Kafka messages", logger.M{"count": len(messages)})
+
+	return nil
+}
+
+// send waits for the result of the sync producer until the context is done.
+// Sarama doesn't support context, so the message still can be delivered after that.
+func (a *Producer) send(ctx context.Context, message *sarama.ProducerMessage) (int32, int64, error) {
+	var (
+		partition int32
+		offset    int64
+	)
+
+	err := a.wait(ctx, func() (err error) {
+		partition, offset, err = a.producer.SendMessage(message)
+
+		return err //nolint:wrapcheck
+	})
+
+	return partition, offset, err
+}
+
+// wait runs the call of the sync producer in goroutine and waits until the context is done.
+func (a *Producer) wait(ctx context.Context, call func() error) error {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- call()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("context is done: %w", ctx.Err())
+	}
+}
+
+// Ping refreshes the metadata of the topic, the error means that Kafka isn't reachable.
+func (a *Producer) Ping() error {
+	return ping(a.client, a.topic)
+}
+
+// Close closes the producer and its client.
 func (a *Producer) Close() error {
 	if err := a.producer.Close(); err != nil {
+		a.client.Close()
+
 		return fmt.Errorf("closing error: %w", err)
 	}
 
+	if err := a.client.Close(); err != nil {
+		return fmt.Errorf("closing client error: %w", err)
+	}
+
+	return nil
+}
+
+func newConfig(timeout time.Duration) *sarama.Config {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Compression = sarama.CompressionSnappy
+	config.Producer.Timeout = timeout
+	config.Net.DialTimeout = timeout
+
+	return config
+}
+
+// newClient connects to Kafka, the producer is created from the client and checks the connection by it.
+// ChatGPT's generated code for displaying available Topics.
+// Check list of topics which exist.
+func newClient(log logger.Logger, brokers []string, config *sarama.Config) (sarama.Client, error) { //nolint:ireturn
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	topics, err := client.Topics()
+	if err != nil {
+		client.Close()
+
+		return nil, fmt.Errorf("failed to get topics: %w", err)
+	}
+
+	for _, topic := range topics {
+		log.Info("Topic", logger.M{"topic": topic})
+	}
+
+	return client, nil
+}
+
+func ping(client sarama.Client, topic string) error {
+	if err := client.RefreshMetadata(topic); err != nil {
+		return fmt.Errorf("refreshing metadata of '%s': %w", topic, err)
+	}
+
 	return nil
 }
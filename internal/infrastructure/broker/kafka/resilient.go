@@ -0,0 +1,584 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// Defaults of ResilientConfig.
+const (
+	defaultReconnectBackoff = time.Second
+	defaultCheckInterval    = 10 * time.Second
+)
+
+// Metrics of the connection.
+//
+//nolint:gochecknoglobals,exhaustivestruct,exhaustruct
+var (
+	connectedGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "kafka_connected",
+		Help: "1 while the Kafka producer is connected, 0 while the events are buffered.",
+	})
+	reconnectsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_reconnects_total",
+		Help: "Attempts to connect to Kafka by result: connected, failed, lost.",
+	}, []string{"result"})
+	bufferedGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "kafka_buffered_events",
+		Help: "Events kept in memory until Kafka is connected.",
+	})
+)
+
+// Publisher is the connected producer, Producer or AsyncProducer.
+type Publisher interface {
+	Publish(ctx context.Context, event *models.FeedbackEvent) error
+	PublishBatch(ctx context.Context, events []*models.FeedbackEvent) error
+	Ping() error
+	Close() error
+}
+
+// Check if the actual implementation fits the interface.
+var (
+	_ Publisher = (*Producer)(nil)
+	_ Publisher = (*AsyncProducer)(nil)
+)
+
+// ConnectFunc creates the producer, it's called until it succeeds.
+type ConnectFunc func() (Publisher, error)
+
+// Journal keeps the buffered events out of the process, e.g. as the letters of the dead-letter queue,
+// so the events of the killed process aren't lost with the buffer.
+type Journal interface {
+	// Keep saves the event before it's buffered and returns its key, the event isn't buffered if it fails.
+	Keep(ctx context.Context, event *models.FeedbackEvent, cause error) (uuid.UUID, error)
+	// Sent removes the event sent from the buffer.
+	Sent(ctx context.Context, key uuid.UUID) error
+}
+
+// ResilientConfig is the reconnects and the buffer of ResilientProducer.
+type ResilientConfig struct {
+	// Exponential backoff of the attempts to connect, zero values mean 1s and Backoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Events of Publish kept in memory while Kafka is down, zero means no buffer.
+	BufferSize int
+	// Interval of the checks of the connection, zero means 10s.
+	CheckInterval time.Duration
+	// Timeout of PublishBatch waiting for the connection and of the last flush on Close.
+	Timeout time.Duration
+	// OnUndelivered gets the buffered events which can't be sent, e.g. left on Close.
+	// The events kept by the journal stay in it instead.
+	OnUndelivered UndeliveredFunc
+	// Journal keeps the buffered events until they are sent, nil keeps them only in memory.
+	Journal Journal
+}
+
+// ResilientProducer connects to Kafka in the background, so the service starts while Kafka is down.
+// While it's down, Publish keeps the events in memory and the buffered events are sent in order
+// when the connection is back. The failed sends and the periodic checks find the lost connection,
+// then the producer is closed and created again with the backoff.
+// PublishBatch isn't buffered, its callers rely on the delivery: it waits for the connection and the flush
+// within the timeout, then fails with broker.ErrUnavailable.
+type ResilientProducer struct {
+	logger  logger.Logger
+	connect ConnectFunc
+	config  ResilientConfig
+
+	// sending is held by the sends of the callers, the producer is closed under the write lock.
+	sending sync.RWMutex
+
+	mu sync.Mutex
+	// producer is nil while Kafka is down.
+	producer Publisher
+	buffer   []*bufferedEvent
+	state    string
+	lastErr  error
+	since    time.Time
+	closed   bool
+	// changed is closed and replaced when the producer is set or the buffer is flushed.
+	changed chan struct{}
+
+	// check wakes up the loop after the failed send or the buffered event.
+	check    chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	closeErr error
+}
+
+// bufferedEvent keeps the values of the request context for the headers, not its deadline.
+// key is the key of the journal, uuid.Nil without it.
+type bufferedEvent struct {
+	ctx   context.Context //nolint:containedctx
+	event *models.FeedbackEvent
+	key   uuid.UUID
+}
+
+// NewResilient doesn't wait for the connection, see ResilientProducer.
+func NewResilient(log logger.Logger, connect ConnectFunc, config ResilientConfig) *ResilientProducer {
+	if config.Backoff <= 0 {
+		config.Backoff = defaultReconnectBackoff
+	}
+
+	if config.MaxBackoff < config.Backoff {
+		config.MaxBackoff = config.Backoff
+	}
+
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = defaultCheckInterval
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	resilient := &ResilientProducer{
+		logger:  log.Named("kafka"),
+		connect: connect,
+		config:  config,
+		state:   models.BrokerConnecting,
+		since:   time.Now().UTC(),
+		changed: make(chan struct{}),
+		check:   make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	connectedGauge.Set(0)
+
+	go resilient.run()
+
+	return resilient
+}
+
+// Publish sends the event by the connected producer or buffers it. The buffer keeps the order:
+// while it isn't empty, the new events are buffered too. The full buffer returns broker.ErrQueueFull.
+func (r *ResilientProducer) Publish(ctx context.Context, event *models.FeedbackEvent) error {
+	r.sending.RLock()
+	defer r.sending.RUnlock()
+
+	r.mu.Lock()
+	producer, buffered := r.producer, len(r.buffer)
+	r.mu.Unlock()
+
+	if producer == nil || buffered > 0 {
+		return r.push(ctx, event, broker.ErrUnavailable)
+	}
+
+	err := producer.Publish(ctx, event)
+	if err == nil || errors.Is(err, broker.ErrInvalidEvent) || errors.Is(err, broker.ErrQueueFull) {
+		return err //nolint:wrapcheck
+	}
+
+	// Kafka may be down: the event waits for the check of the connection.
+	if r.push(ctx, event, err) != nil {
+		return err //nolint:wrapcheck
+	}
+
+	r.logger.Warn("Send failed, the event is buffered", logger.M{"err": err, "type": event.Type})
+
+	return nil
+}
+
+// PublishBatch waits until the producer is connected and the buffer is flushed, so the batch goes after it.
+func (r *ResilientProducer) PublishBatch(ctx context.Context, events []*models.FeedbackEvent) error {
+	producer, err := r.ready(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.sending.RUnlock()
+
+	err = producer.PublishBatch(ctx, events)
+	if err != nil && !errors.Is(err, broker.ErrInvalidEvent) {
+		r.wake()
+	}
+
+	return err //nolint:wrapcheck
+}
+
+// Rejects passes the backpressure of the connected async producer,
+// the events are buffered while Kafka is down.
+func (r *ResilientProducer) Rejects() bool {
+	r.mu.Lock()
+	producer, ok := r.producer.(interface{ Rejects() bool })
+	r.mu.Unlock()
+
+	return ok && producer.Rejects()
+}
+
+// Health is the state of the connection and the buffer.
+func (r *ResilientProducer) Health() *models.BrokerHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	health := &models.BrokerHealth{
+		State:      r.state,
+		Buffered:   len(r.buffer),
+		BufferSize: r.config.BufferSize,
+		Error:      "",
+		Since:      r.since,
+	}
+
+	if r.lastErr != nil && r.state != models.BrokerUp {
+		health.Error = r.lastErr.Error()
+	}
+
+	return health
+}
+
+// Close stops the reconnects, flushes the buffer if Kafka is connected and closes the producer.
+// The events left in the buffer are passed to OnUndelivered, the ones kept by the journal stay in it.
+func (r *ResilientProducer) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+
+		return nil
+	}
+
+	r.closed = true
+	r.mu.Unlock()
+
+	close(r.stop)
+	<-r.done
+
+	r.mu.Lock()
+	left := r.buffer
+	r.buffer = nil
+	r.mu.Unlock()
+
+	bufferedGauge.Set(0)
+
+	if len(left) > 0 {
+		r.logger.Warn("Buffered events aren't sent", logger.M{"count": len(left)})
+	}
+
+	for _, item := range left {
+		if item.key == uuid.Nil {
+			r.undelivered(item.event, broker.ErrUnavailable)
+		}
+	}
+
+	r.logger.Info("Resilient producer is closed", nil)
+
+	return r.closeErr
+}
+
+// push keeps the event in the journal and adds it to the buffer, cause is the reason of the buffering.
+// The journal is written out of the lock, the event is removed from it if the buffer became full meanwhile.
+func (r *ResilientProducer) push(ctx context.Context, event *models.FeedbackEvent, cause error) error {
+	r.mu.Lock()
+	err := r.room()
+	r.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	ctx = reqctx.Detach(ctx)
+	key := uuid.Nil
+
+	if r.config.Journal != nil {
+		key, err = r.config.Journal.Keep(ctx, event, cause)
+		if err != nil {
+			return fmt.Errorf("keeping the buffered event: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err = r.room()
+	if err != nil {
+		r.sent(key)
+
+		return err
+	}
+
+	r.buffer = append(r.buffer, &bufferedEvent{ctx: ctx, event: event, key: key})
+	bufferedGauge.Set(float64(len(r.buffer)))
+	r.wake()
+
+	return nil
+}
+
+// room checks that the event can be buffered, it's called under the lock.
+func (r *ResilientProducer) room() error {
+	if r.closed {
+		return broker.ErrClosed
+	}
+
+	if r.config.BufferSize == 0 {
+		return broker.ErrUnavailable
+	}
+
+	if len(r.buffer) >= r.config.BufferSize {
+		return fmt.Errorf("%d events are buffered: %w", len(r.buffer), broker.ErrQueueFull)
+	}
+
+	return nil
+}
+
+// sent removes the event from the journal, the failure leaves the letter for the replay.
+func (r *ResilientProducer) sent(key uuid.UUID) {
+	if key == uuid.Nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.config.Timeout)
+	defer cancel()
+
+	err := r.config.Journal.Sent(ctx, key)
+	if err != nil {
+		r.logger.Error("Sent event isn't removed from the journal", logger.M{"err": err, "key": key})
+	}
+}
+
+func (r *ResilientProducer) wake() {
+	select {
+	case r.check <- struct{}{}:
+	default:
+	}
+}
+
+// ready returns the connected producer with the empty buffer, the read lock of sending is held then.
+func (r *ResilientProducer) ready(ctx context.Context) (Publisher, error) { //nolint:ireturn
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+
+	for {
+		r.sending.RLock()
+		r.mu.Lock()
+		producer, changed, closed, lastErr := r.producer, r.changed, r.closed, r.lastErr
+		buffered := len(r.buffer)
+		r.mu.Unlock()
+
+		if producer != nil && buffered == 0 && !closed {
+			return producer, nil
+		}
+
+		r.sending.RUnlock()
+
+		if closed {
+			return nil, broker.ErrClosed
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			if lastErr == nil {
+				lastErr = ctx.Err()
+			}
+
+			return nil, fmt.Errorf("%w, %d events are buffered: %s", broker.ErrUnavailable, buffered, lastErr)
+		}
+	}
+}
+
+// run connects with the backoff and serves the connection until it's lost or Close is called.
+func (r *ResilientProducer) run() {
+	defer close(r.done)
+
+	for attempt := 1; ; attempt++ {
+		producer, err := r.connect()
+		if err != nil {
+			reconnectsTotal.WithLabelValues("failed").Inc()
+
+			delay := r.backoff(attempt)
+			r.down(err)
+			r.logger.Warn("Kafka isn't reachable, the events are buffered", logger.M{
+				"err":     err,
+				"attempt": attempt,
+				"retryIn": delay.String(),
+			})
+
+			select {
+			case <-time.After(delay):
+				continue
+			case <-r.stop:
+				return
+			}
+		}
+
+		reconnectsTotal.WithLabelValues("connected").Inc()
+		r.up(producer)
+
+		err = r.serve(producer)
+		r.mu.Lock()
+		r.producer = nil
+		r.mu.Unlock()
+
+		r.sending.Lock()
+		closeErr := producer.Close()
+		r.sending.Unlock()
+
+		select {
+		case <-r.stop:
+			if err != nil {
+				r.logger.Error("Buffered events aren't flushed", logger.M{"err": err})
+			}
+
+			r.closeErr = closeErr
+
+			return
+		default:
+		}
+
+		reconnectsTotal.WithLabelValues("lost").Inc()
+		r.down(err)
+		r.logger.Error("Kafka connection is lost", logger.M{"err": err})
+
+		attempt = 0
+	}
+}
+
+// serve flushes the buffer and checks the connection until it's lost or Close is called.
+func (r *ResilientProducer) serve(producer Publisher) error {
+	ticker := time.NewTicker(r.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		err := r.flush(producer)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-r.check:
+		case <-ticker.C:
+		case <-r.stop:
+			// The last flush is bounded by the timeout of every send.
+			return r.flush(producer)
+		}
+
+		err = producer.Ping()
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+}
+
+// flush sends the buffered events one by one with their contexts. If the send fails while Kafka is reachable,
+// the event is passed to OnUndelivered or left in the journal, so one bad event doesn't block the others.
+func (r *ResilientProducer) flush(producer Publisher) error {
+	sent := 0
+
+	for {
+		r.mu.Lock()
+		if len(r.buffer) == 0 {
+			r.broadcast()
+			r.mu.Unlock()
+
+			if sent > 0 {
+				r.logger.Info("Buffered events are sent", logger.M{"count": sent})
+			}
+
+			return nil
+		}
+
+		item := r.buffer[0]
+		r.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(item.ctx, r.config.Timeout)
+		err := producer.Publish(ctx, item.event)
+
+		cancel()
+
+		if err != nil {
+			pingErr := producer.Ping()
+			if pingErr != nil {
+				return pingErr //nolint:wrapcheck
+			}
+
+			if item.key == uuid.Nil {
+				r.undelivered(item.event, err)
+			} else {
+				r.logger.Error("Buffered event isn't sent, it's left in the journal", logger.M{
+					"err":        err,
+					"feedbackID": item.event.FeedbackID,
+					"type":       item.event.Type,
+				})
+			}
+		} else {
+			r.sent(item.key)
+		}
+
+		r.mu.Lock()
+		r.buffer[0] = nil
+		r.buffer = r.buffer[1:]
+		bufferedGauge.Set(float64(len(r.buffer)))
+		r.mu.Unlock()
+
+		sent++
+	}
+}
+
+func (r *ResilientProducer) up(producer Publisher) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.producer = producer
+	r.state = models.BrokerUp
+	r.lastErr = nil
+	r.since = time.Now().UTC()
+	r.broadcast()
+
+	connectedGauge.Set(1)
+	r.logger.Info("Kafka is connected", logger.M{"buffered": len(r.buffer)})
+}
+
+func (r *ResilientProducer) down(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state == models.BrokerUp {
+		r.state = models.BrokerDown
+		r.since = time.Now().UTC()
+	}
+
+	r.lastErr = err
+
+	connectedGauge.Set(0)
+}
+
+// broadcast wakes up the waiters of PublishBatch, it's called under the lock.
+func (r *ResilientProducer) broadcast() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+func (r *ResilientProducer) undelivered(event *models.FeedbackEvent, err error) {
+	if r.config.OnUndelivered != nil {
+		r.config.OnUndelivered(event, err)
+
+		return
+	}
+
+	r.logger.Error("Event is lost", logger.M{"err": err, "feedbackID": event.FeedbackID, "type": event.Type})
+}
+
+// backoff is exponential with the equal jitter, so the instances don't reconnect at the same time.
+func (r *ResilientProducer) backoff(attempt int) time.Duration {
+	delay := r.config.Backoff
+
+	for i := 1; i < attempt && delay < r.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > r.config.MaxBackoff {
+		delay = r.config.MaxBackoff
+	}
+
+	half := delay / 2 //nolint:gomnd
+
+	return half + time.Duration(rand.Int63n(int64(half)+1)) //nolint:gosec
+}
@@ -0,0 +1,203 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) logger.Logger { return l }
+func (nopLogger) Debug(string, logger.M)       {}
+func (nopLogger) Info(string, logger.M)        {}
+func (nopLogger) Warn(string, logger.M)        {}
+func (nopLogger) Error(string, logger.M)       {}
+func (nopLogger) Fatal(string, logger.M)       {}
+
+var errDown = errors.New("kafka is down")
+
+// fakePublisher records the sent events.
+type fakePublisher struct {
+	mu   sync.Mutex
+	sent []*models.FeedbackEvent
+}
+
+func (p *fakePublisher) Publish(_ context.Context, event *models.FeedbackEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.sent = append(p.sent, event)
+
+	return nil
+}
+
+func (p *fakePublisher) PublishBatch(ctx context.Context, events []*models.FeedbackEvent) error {
+	for _, event := range events {
+		_ = p.Publish(ctx, event)
+	}
+
+	return nil
+}
+
+func (p *fakePublisher) Ping() error  { return nil }
+func (p *fakePublisher) Close() error { return nil }
+
+func (p *fakePublisher) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.sent)
+}
+
+// fakeJournal keeps the events by the keys like the dead-letter queue.
+type fakeJournal struct {
+	mu     sync.Mutex
+	kept   map[uuid.UUID]*models.FeedbackEvent
+	failed bool
+}
+
+func (j *fakeJournal) Keep(_ context.Context, event *models.FeedbackEvent, _ error) (uuid.UUID, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.failed {
+		return uuid.Nil, errDown
+	}
+
+	key := uuid.New()
+	j.kept[key] = event
+
+	return key, nil
+}
+
+func (j *fakeJournal) Sent(_ context.Context, key uuid.UUID) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	delete(j.kept, key)
+
+	return nil
+}
+
+func (j *fakeJournal) count() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return len(j.kept)
+}
+
+func newEvent() *models.FeedbackEvent {
+	//nolint:exhaustivestruct,exhaustruct
+	return models.NewFeedbackEvent(models.FeedbackCreated, &models.Feedback{ID: uuid.New(), FeedbackText: "ok"})
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second) //nolint:gomnd
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+
+		time.Sleep(10 * time.Millisecond) //nolint:gomnd
+	}
+}
+
+func TestResilientJournal(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu        sync.Mutex
+		available bool
+	)
+
+	publisher := &fakePublisher{mu: sync.Mutex{}, sent: nil}
+	journal := &fakeJournal{mu: sync.Mutex{}, kept: make(map[uuid.UUID]*models.FeedbackEvent), failed: false}
+
+	connect := func() (Publisher, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !available {
+			return nil, errDown
+		}
+
+		return publisher, nil
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	producer := NewResilient(nopLogger{}, connect, ResilientConfig{
+		Backoff:    10 * time.Millisecond, //nolint:gomnd
+		MaxBackoff: 10 * time.Millisecond, //nolint:gomnd
+		BufferSize: 2,                     //nolint:gomnd
+		Timeout:    time.Second,
+		Journal:    journal,
+	})
+
+	ctx := context.Background()
+
+	// The buffered events are kept by the journal while Kafka is down.
+	for i := 0; i < 2; i++ {
+		err := producer.Publish(ctx, newEvent())
+		if err != nil {
+			t.Fatalf("Publish %d: %v", i, err)
+		}
+	}
+
+	if journal.count() != 2 { //nolint:gomnd
+		t.Errorf("journal: got %d events, want 2", journal.count())
+	}
+
+	// The full buffer doesn't leave the event in the journal.
+	err := producer.Publish(ctx, newEvent())
+	if !errors.Is(err, broker.ErrQueueFull) || journal.count() != 2 {
+		t.Errorf("full buffer: got %v, %d events in the journal", err, journal.count())
+	}
+
+	mu.Lock()
+	available = true
+	mu.Unlock()
+
+	// The sent events are removed from the journal.
+	waitFor(t, func() bool { return publisher.count() == 2 && journal.count() == 0 })
+
+	err = producer.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestResilientJournalFailure(t *testing.T) {
+	t.Parallel()
+
+	journal := &fakeJournal{mu: sync.Mutex{}, kept: make(map[uuid.UUID]*models.FeedbackEvent), failed: true}
+
+	//nolint:exhaustivestruct,exhaustruct
+	producer := NewResilient(nopLogger{}, func() (Publisher, error) { return nil, errDown }, ResilientConfig{
+		Backoff:    time.Hour,
+		BufferSize: 10, //nolint:gomnd
+		Timeout:    time.Second,
+		Journal:    journal,
+	})
+
+	// The event isn't buffered without the journal, the caller gets the error.
+	err := producer.Publish(context.Background(), newEvent())
+	if !errors.Is(err, errDown) || producer.Health().Buffered != 0 {
+		t.Errorf("got %v, %d buffered", err, producer.Health().Buffered)
+	}
+
+	err = producer.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Package memory has the producer which keeps the events in memory, it's for tests and local runs.
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// Message is the received event, the feedback is the copy at the time of the send and nil for the tombstone.
+type Message struct {
+	EventID    string
+	Type       string
+	FeedbackID uuid.UUID
+	Tenant     string
+	RequestID  string
+	Time       time.Time
+	Feedback   *models.Feedback
+}
+
+// Recorder keeps all received messages until Reset, SetError makes the sends fail.
+type Recorder struct {
+	mu       sync.Mutex
+	messages []*Message
+	err      error
+	closed   bool
+	logger   logger.Logger
+}
+
+func New(log logger.Logger) *Recorder {
+	return &Recorder{
+		mu:       sync.Mutex{},
+		messages: nil,
+		err:      nil,
+		closed:   false,
+		logger:   log.Named("memoryBroker"),
+	}
+}
+
+func (r *Recorder) Publish(ctx context.Context, event *models.FeedbackEvent) error {
+	return r.PublishBatch(ctx, []*models.FeedbackEvent{event})
+}
+
+// Messages returns the received messages in order.
+func (r *Recorder) Messages() []*Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	messages := make([]*Message, len(r.messages))
+	copy(messages, r.messages)
+
+	return messages
+}
+
+// Reset forgets the messages and the error.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages, r.err = nil, nil
+}
+
+// SetError makes the next sends fail with the error until it's set to nil, the failed messages aren't kept.
+func (r *Recorder) SetError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.err = err
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+
+	return nil
+}
+
+// PublishBatch keeps the whole batch or nothing, like one write of the file producer.
+func (r *Recorder) PublishBatch(ctx context.Context, events []*models.FeedbackEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case r.closed:
+		return broker.ErrClosed
+	case r.err != nil:
+		return r.err
+	}
+
+	for _, event := range events {
+		r.messages = append(r.messages, r.message(ctx, event))
+	}
+
+	r.logger.Debug("Recorded messages", logger.M{"count": len(events)})
+
+	return nil
+}
+
+func (r *Recorder) message(ctx context.Context, event *models.FeedbackEvent) *Message {
+	tenant := event.Tenant
+	if tenant == "" {
+		tenant = reqctx.Tenant(ctx)
+	}
+
+	var feedback *models.Feedback
+
+	if event.Feedback != nil {
+		feedbackCopy := *event.Feedback
+		feedbackCopy.Tags = append([]string(nil), event.Feedback.Tags...)
+		feedback = &feedbackCopy
+	}
+
+	return &Message{
+		EventID:    event.ID,
+		Type:       event.Type,
+		FeedbackID: event.FeedbackID,
+		Tenant:     tenant,
+		RequestID:  reqctx.RequestID(ctx),
+		Time:       event.Time,
+		Feedback:   feedback,
+	}
+}
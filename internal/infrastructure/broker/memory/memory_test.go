@@ -0,0 +1,130 @@
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/memory"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) logger.Logger { return l }
+func (nopLogger) Debug(string, logger.M)       {}
+func (nopLogger) Info(string, logger.M)        {}
+func (nopLogger) Warn(string, logger.M)        {}
+func (nopLogger) Error(string, logger.M)       {}
+func (nopLogger) Fatal(string, logger.M)       {}
+
+func newFeedback() *models.Feedback {
+	//nolint:exhaustivestruct,exhaustruct
+	return &models.Feedback{
+		ID:           uuid.New(),
+		FeedbackText: "ok",
+		Source:       "https://shop.example.com",
+		Tags:         []string{"shop"},
+	}
+}
+
+func TestRecorderKeepsCopies(t *testing.T) {
+	t.Parallel()
+
+	ctx := reqctx.WithRequestID(reqctx.WithTenant(context.Background(), "acme"), "request-1")
+	recorder := memory.New(nopLogger{})
+	feedback := newFeedback()
+	deleted := uuid.New()
+
+	err := recorder.PublishBatch(ctx, []*models.FeedbackEvent{
+		models.NewFeedbackEvent(models.FeedbackCreated, feedback),
+		models.NewDeletedEvent(deleted, ""),
+	})
+	if err != nil {
+		t.Fatalf("PublishBatch: %v", err)
+	}
+
+	// The feedback changed after the send doesn't change the message.
+	feedback.FeedbackText = "changed"
+	feedback.Tags[0] = "changed"
+
+	messages := recorder.Messages()
+	if len(messages) != 2 { //nolint:gomnd
+		t.Fatalf("got %d messages", len(messages))
+	}
+
+	created, tombstone := messages[0], messages[1]
+
+	if created.Type != models.FeedbackCreated || created.FeedbackID != feedback.ID || created.EventID == "" {
+		t.Errorf("created: got %+v", created)
+	}
+
+	if created.Feedback.FeedbackText != "ok" || created.Feedback.Tags[0] != "shop" {
+		t.Errorf("created: the feedback isn't copied: %+v", created.Feedback)
+	}
+
+	if created.Tenant != "acme" || created.RequestID != "request-1" {
+		t.Errorf("created: got tenant %q, request ID %q", created.Tenant, created.RequestID)
+	}
+
+	if tombstone.Type != models.FeedbackDeleted || tombstone.FeedbackID != deleted || tombstone.Feedback != nil {
+		t.Errorf("tombstone: got %+v", tombstone)
+	}
+
+	// The returned slice is a copy.
+	messages[0] = nil
+
+	if recorder.Messages()[0] == nil {
+		t.Error("messages are changed by the caller")
+	}
+
+	recorder.Reset()
+
+	if len(recorder.Messages()) != 0 {
+		t.Errorf("after Reset: got %d messages", len(recorder.Messages()))
+	}
+}
+
+func TestRecorderErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	recorder := memory.New(nopLogger{})
+	errSend := errors.New("send failed") //nolint:goerr113
+
+	recorder.SetError(errSend)
+
+	err := recorder.PublishBatch(ctx, []*models.FeedbackEvent{
+		models.NewFeedbackEvent(models.FeedbackCreated, newFeedback()),
+		models.NewFeedbackEvent(models.FeedbackCreated, newFeedback()),
+	})
+	if !errors.Is(err, errSend) {
+		t.Errorf("PublishBatch: got %v, want %v", err, errSend)
+	}
+
+	if len(recorder.Messages()) != 0 {
+		t.Errorf("failed batch: got %d messages", len(recorder.Messages()))
+	}
+
+	recorder.SetError(nil)
+
+	err = recorder.Publish(ctx, models.NewFeedbackEvent(models.FeedbackCreated, newFeedback()))
+	if err != nil || len(recorder.Messages()) != 1 {
+		t.Errorf("Publish: got %v, %d messages", err, len(recorder.Messages()))
+	}
+
+	err = recorder.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	err = recorder.Publish(ctx, models.NewFeedbackEvent(models.FeedbackCreated, newFeedback()))
+	if !errors.Is(err, broker.ErrClosed) {
+		t.Errorf("after Close: got %v, want %v", err, broker.ErrClosed)
+	}
+}
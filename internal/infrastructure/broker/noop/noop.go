@@ -0,0 +1,35 @@
+package noop
+
+import (
+	"context"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// Producer drops all messages, it's used when there is no broker.
+type Producer struct {
+	logger logger.Logger
+}
+
+func New(log logger.Logger) *Producer {
+	return &Producer{
+		logger: log.Named("noopBroker"),
+	}
+}
+
+func (p *Producer) Publish(_ context.Context, event *models.FeedbackEvent) error {
+	p.logger.Debug("Dropped event", logger.M{"feedbackID": event.FeedbackID, "type": event.Type})
+
+	return nil
+}
+
+func (p *Producer) PublishBatch(_ context.Context, events []*models.FeedbackEvent) error {
+	p.logger.Debug("Dropped events", logger.M{"count": len(events)})
+
+	return nil
+}
+
+func (p *Producer) Close() error {
+	return nil
+}
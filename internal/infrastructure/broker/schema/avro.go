@@ -0,0 +1,540 @@
+package schema
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// avroType is the parsed Avro type: a primitive, a record, an array or a union.
+type avroType struct {
+	Type   string
+	Name   string
+	Items  *avroType
+	Fields []*avroField
+	Union  []*avroType
+}
+
+type avroField struct {
+	Name    string
+	Type    *avroType
+	Default json.RawMessage
+}
+
+// avroPromotions are the writer types readable by the reader type.
+//
+//nolint:gochecknoglobals
+var avroPromotions = map[string][]string{
+	"long":   {"int"},
+	"float":  {"int", "long"},
+	"double": {"int", "long", "float"},
+	"string": {"bytes"},
+	"bytes":  {"string"},
+}
+
+func parseAvro(text string) (*avroType, error) {
+	parsed, err := parseAvroType(json.RawMessage(text))
+	if err != nil {
+		return nil, err
+	}
+
+	if parsed.Type != "record" {
+		return nil, fmt.Errorf("top level type '%s': %w", parsed.Type, errInvalidSchema)
+	}
+
+	return parsed, nil
+}
+
+func parseAvroType(raw json.RawMessage) (*avroType, error) {
+	var name string
+	if json.Unmarshal(raw, &name) == nil {
+		return primitiveAvroType(name)
+	}
+
+	var union []json.RawMessage
+	if json.Unmarshal(raw, &union) == nil {
+		result := &avroType{Type: "union", Name: "", Items: nil, Fields: nil, Union: make([]*avroType, 0, len(union))}
+
+		for _, branch := range union {
+			parsed, err := parseAvroType(branch)
+			if err != nil {
+				return nil, err
+			}
+
+			result.Union = append(result.Union, parsed)
+		}
+
+		return result, nil
+	}
+
+	var complexType struct {
+		Type   json.RawMessage `json:"type"`
+		Name   string          `json:"name"`
+		Items  json.RawMessage `json:"items"`
+		Fields []struct {
+			Name    string          `json:"name"`
+			Type    json.RawMessage `json:"type"`
+			Default json.RawMessage `json:"default"`
+		} `json:"fields"`
+	}
+
+	err := json.Unmarshal(raw, &complexType)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err.Error(), errInvalidSchema)
+	}
+
+	if json.Unmarshal(complexType.Type, &name) != nil {
+		// {"type": {"type": "string"}} is the same as {"type": "string"}.
+		return parseAvroType(complexType.Type)
+	}
+
+	switch name {
+	case "record":
+		result := &avroType{Type: name, Name: complexType.Name, Items: nil, Fields: nil, Union: nil}
+
+		for _, field := range complexType.Fields {
+			fieldType, err := parseAvroType(field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("field '%s': %w", field.Name, err)
+			}
+
+			result.Fields = append(result.Fields, &avroField{Name: field.Name, Type: fieldType, Default: field.Default})
+		}
+
+		return result, nil
+	case "array":
+		items, err := parseAvroType(complexType.Items)
+		if err != nil {
+			return nil, err
+		}
+
+		return &avroType{Type: name, Name: "", Items: items, Fields: nil, Union: nil}, nil
+	default:
+		// Logical types (e.g. uuid) are written as their underlying type.
+		return primitiveAvroType(name)
+	}
+}
+
+func primitiveAvroType(name string) (*avroType, error) {
+	switch name {
+	case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+		return &avroType{Type: name, Name: "", Items: nil, Fields: nil, Union: nil}, nil
+	default:
+		return nil, fmt.Errorf("avro type '%s': %w", name, errUnsupportedType)
+	}
+}
+
+func (t *avroType) encode(values map[string]any) ([]byte, error) {
+	return t.write(nil, values)
+}
+
+// write appends the value in the Avro binary encoding.
+func (t *avroType) write(buf []byte, value any) ([]byte, error) {
+	switch t.Type {
+	case "null":
+		return buf, nil
+	case "boolean":
+		flag, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%v isn't boolean: %w", value, errInvalidValue)
+		}
+
+		if flag {
+			return append(buf, 1), nil
+		}
+
+		return append(buf, 0), nil
+	case "int", "long":
+		number, ok := toInt64(value)
+		if !ok {
+			return nil, fmt.Errorf("%v isn't integer: %w", value, errInvalidValue)
+		}
+
+		return binary.AppendVarint(buf, number), nil
+	case "float", "double":
+		number, ok := toFloat64(value)
+		if !ok {
+			return nil, fmt.Errorf("%v isn't number: %w", value, errInvalidValue)
+		}
+
+		if t.Type == "float" {
+			return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(number))), nil
+		}
+
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(number)), nil
+	case "string", "bytes":
+		data, ok := toBytes(value)
+		if !ok {
+			return nil, fmt.Errorf("%v isn't string: %w", value, errInvalidValue)
+		}
+
+		buf = binary.AppendVarint(buf, int64(len(data)))
+
+		return append(buf, data...), nil
+	case "array":
+		return t.writeArray(buf, value)
+	case "union":
+		return t.writeUnion(buf, value)
+	default:
+		return t.writeRecord(buf, value)
+	}
+}
+
+// writeArray writes all items by one block and the empty block of the end.
+func (t *avroType) writeArray(buf []byte, value any) ([]byte, error) {
+	items, ok := toSlice(value)
+	if !ok {
+		return nil, fmt.Errorf("%v isn't array: %w", value, errInvalidValue)
+	}
+
+	var err error
+
+	if len(items) > 0 {
+		buf = binary.AppendVarint(buf, int64(len(items)))
+
+		for _, item := range items {
+			buf, err = t.Items.write(buf, item)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return append(buf, 0), nil
+}
+
+// writeUnion writes nil as the null branch and other values as the first non-null branch.
+func (t *avroType) writeUnion(buf []byte, value any) ([]byte, error) {
+	for index, branch := range t.Union {
+		if (value == nil) != (branch.Type == "null") {
+			continue
+		}
+
+		buf = binary.AppendVarint(buf, int64(index))
+
+		return branch.write(buf, value)
+	}
+
+	return nil, fmt.Errorf("%v doesn't match the union: %w", value, errInvalidValue)
+}
+
+func (t *avroType) writeRecord(buf []byte, value any) ([]byte, error) {
+	values, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%v isn't record: %w", value, errInvalidValue)
+	}
+
+	var err error
+
+	for _, field := range t.Fields {
+		fieldValue, ok := values[field.Name]
+		if !ok {
+			if field.Default == nil {
+				return nil, fmt.Errorf("field '%s' has no value and default: %w", field.Name, errInvalidValue)
+			}
+
+			err = json.Unmarshal(field.Default, &fieldValue)
+			if err != nil {
+				return nil, fmt.Errorf("default of field '%s': %w", field.Name, err)
+			}
+		}
+
+		buf, err = field.Type.write(buf, fieldValue)
+		if err != nil {
+			return nil, fmt.Errorf("field '%s': %w", field.Name, err)
+		}
+	}
+
+	return buf, nil
+}
+
+func (t *avroType) backward(old codec) error {
+	writer, ok := old.(*avroType)
+	if !ok {
+		return ErrIncompatible
+	}
+
+	return t.reads(writer, "")
+}
+
+// reads checks the resolution of the writer type by the reader type (this one), see the Avro specification.
+func (t *avroType) reads(writer *avroType, path string) error {
+	switch {
+	case writer.Type == "union":
+		for _, branch := range writer.Union {
+			err := t.reads(branch, path)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case t.Type == "union":
+		for _, branch := range t.Union {
+			if branch.reads(writer, path) == nil {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("%s: union doesn't have %s: %w", path, writer.Type, ErrIncompatible)
+	case t.Type != writer.Type:
+		for _, promoted := range avroPromotions[t.Type] {
+			if promoted == writer.Type {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("%s: %s can't be read as %s: %w", path, writer.Type, t.Type, ErrIncompatible)
+	case t.Type == "array":
+		return t.Items.reads(writer.Items, path+"[]")
+	case t.Type == "record":
+		return t.readsRecord(writer, path)
+	default:
+		return nil
+	}
+}
+
+// readsRecord allows the removed fields and the added fields with the default.
+func (t *avroType) readsRecord(writer *avroType, path string) error {
+	if t.Name != writer.Name {
+		return fmt.Errorf("%s: record '%s' was '%s': %w", path, t.Name, writer.Name, ErrIncompatible)
+	}
+
+	written := make(map[string]*avroField, len(writer.Fields))
+	for _, field := range writer.Fields {
+		written[field.Name] = field
+	}
+
+	for _, field := range t.Fields {
+		name := field.Name
+		if path != "" {
+			name = path + "." + field.Name
+		}
+
+		old, ok := written[field.Name]
+		if !ok {
+			if field.Default == nil {
+				return fmt.Errorf("added field '%s' has no default: %w", name, ErrIncompatible)
+			}
+
+			continue
+		}
+
+		err := field.Type.reads(old.Type, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func toInt64(value any) (int64, bool) {
+	switch number := value.(type) {
+	case int:
+		return int64(number), true
+	case int32:
+		return int64(number), true
+	case int64:
+		return number, true
+	case float64: // numbers of the JSON defaults.
+		return int64(number), number == math.Trunc(number)
+	default:
+		return 0, false
+	}
+}
+
+func toFloat64(value any) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case float32:
+		return float64(number), true
+	default:
+		integer, ok := toInt64(value)
+
+		return float64(integer), ok
+	}
+}
+
+func toBytes(value any) ([]byte, bool) {
+	switch data := value.(type) {
+	case string:
+		return []byte(data), true
+	case []byte:
+		return data, true
+	default:
+		return nil, false
+	}
+}
+
+func toSlice(value any) ([]any, bool) {
+	switch items := value.(type) {
+	case []any:
+		return items, true
+	case []string:
+		result := make([]any, 0, len(items))
+		for _, item := range items {
+			result = append(result, item)
+		}
+
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+func (t *avroType) decode(data []byte) (map[string]any, error) {
+	reader := &avroReader{data: data, offset: 0}
+
+	value, err := reader.read(t)
+	if err != nil {
+		return nil, err
+	}
+
+	values, _ := value.(map[string]any)
+
+	return values, nil
+}
+
+// avroReader reads the values of the Avro binary encoding.
+type avroReader struct {
+	data   []byte
+	offset int
+}
+
+func (r *avroReader) read(t *avroType) (any, error) {
+	switch t.Type {
+	case "null":
+		return nil, nil
+	case "boolean":
+		data, err := r.next(1)
+		if err != nil {
+			return nil, err
+		}
+
+		return data[0] != 0, nil
+	case "int", "long":
+		return r.varint()
+	case "float":
+		data, err := r.next(4) //nolint:gomnd
+		if err != nil {
+			return nil, err
+		}
+
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), nil
+	case "double":
+		data, err := r.next(8) //nolint:gomnd
+		if err != nil {
+			return nil, err
+		}
+
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case "string", "bytes":
+		data, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+
+		if t.Type == "string" {
+			return string(data), nil
+		}
+
+		return append([]byte(nil), data...), nil
+	case "array":
+		return r.readArray(t)
+	case "union":
+		index, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+
+		if index < 0 || int(index) >= len(t.Union) {
+			return nil, fmt.Errorf("union index %d: %w", index, errInvalidData)
+		}
+
+		return r.read(t.Union[index])
+	default:
+		values := make(map[string]any, len(t.Fields))
+
+		for _, field := range t.Fields {
+			value, err := r.read(field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("field '%s': %w", field.Name, err)
+			}
+
+			values[field.Name] = value
+		}
+
+		return values, nil
+	}
+}
+
+// readArray reads the blocks until the empty one, the negative count is followed by the size of the block.
+func (r *avroReader) readArray(t *avroType) (any, error) {
+	items := make([]any, 0)
+
+	for {
+		count, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+
+		if count == 0 {
+			return items, nil
+		}
+
+		if count < 0 {
+			count = -count
+
+			if _, err = r.varint(); err != nil {
+				return nil, err
+			}
+		}
+
+		for ; count > 0; count-- {
+			item, err := r.read(t.Items)
+			if err != nil {
+				return nil, err
+			}
+
+			items = append(items, item)
+		}
+	}
+}
+
+func (r *avroReader) varint() (int64, error) {
+	value, size := binary.Varint(r.data[r.offset:])
+	if size <= 0 {
+		return 0, fmt.Errorf("varint at %d: %w", r.offset, errInvalidData)
+	}
+
+	r.offset += size
+
+	return value, nil
+}
+
+func (r *avroReader) bytes() ([]byte, error) {
+	size, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+
+	if size < 0 {
+		return nil, fmt.Errorf("length %d: %w", size, errInvalidData)
+	}
+
+	return r.next(int(size))
+}
+
+func (r *avroReader) next(size int) ([]byte, error) {
+	if size > len(r.data)-r.offset {
+		return nil, fmt.Errorf("%d bytes at %d: %w", size, r.offset, errInvalidData)
+	}
+
+	data := r.data[r.offset : r.offset+size]
+	r.offset += size
+
+	return data, nil
+}
@@ -0,0 +1,116 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonSchema is the object schema of JSON Schema, only the top level properties are checked.
+type jsonSchema struct {
+	Properties map[string]jsonProperty `json:"properties"`
+	Required   []string                `json:"required"`
+}
+
+type jsonProperty struct {
+	Type    json.RawMessage `json:"type"`
+	Default json.RawMessage `json:"default"`
+}
+
+func parseJSONSchema(text string) (*jsonSchema, error) {
+	var schema jsonSchema
+
+	err := json.Unmarshal([]byte(text), &schema)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err.Error(), errInvalidSchema)
+	}
+
+	if len(schema.Properties) == 0 {
+		return nil, fmt.Errorf("no properties: %w", errInvalidSchema)
+	}
+
+	for _, name := range schema.Required {
+		if _, ok := schema.Properties[name]; !ok {
+			return nil, fmt.Errorf("required '%s' isn't in properties: %w", name, errInvalidSchema)
+		}
+	}
+
+	return &schema, nil
+}
+
+// encode writes only the properties of the schema, so the data has nothing unknown to the consumers.
+func (s *jsonSchema) encode(values map[string]any) ([]byte, error) {
+	result := make(map[string]any, len(s.Properties))
+
+	for name, property := range s.Properties {
+		value, ok := values[name]
+		if !ok && property.Default != nil {
+			value, ok = property.Default, true
+		}
+
+		if ok {
+			result[name] = value
+		}
+	}
+
+	for _, name := range s.Required {
+		if _, ok := result[name]; !ok {
+			return nil, fmt.Errorf("required '%s' has no value: %w", name, errInvalidValue)
+		}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling JSON: %w", err)
+	}
+
+	return data, nil
+}
+
+// backward allows the added optional properties, the new required property must have been required before.
+func (s *jsonSchema) backward(old codec) error {
+	writer, ok := old.(*jsonSchema)
+	if !ok {
+		return ErrIncompatible
+	}
+
+	required := make(map[string]bool, len(writer.Required))
+	for _, name := range writer.Required {
+		required[name] = true
+	}
+
+	for _, name := range s.Required {
+		if !required[name] {
+			return fmt.Errorf("property '%s' became required: %w", name, ErrIncompatible)
+		}
+	}
+
+	for name, property := range s.Properties {
+		written, ok := writer.Properties[name]
+		if ok && !bytes.Equal(compact(written.Type), compact(property.Type)) {
+			return fmt.Errorf("property '%s' changed the type: %w", name, ErrIncompatible)
+		}
+	}
+
+	return nil
+}
+
+func compact(data json.RawMessage) []byte {
+	var buf bytes.Buffer
+	if json.Compact(&buf, data) != nil {
+		return data
+	}
+
+	return buf.Bytes()
+}
+
+func (s *jsonSchema) decode(data []byte) (map[string]any, error) {
+	var values map[string]any
+
+	err := json.Unmarshal(data, &values)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err.Error(), errInvalidData)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,435 @@
+package schema
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Wire types of Protobuf.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+//nolint:gochecknoglobals
+var (
+	// message Feedback {.
+	messageRegex = regexp.MustCompile(`^message\s+(\w+)\s*\{$`)
+	// repeated string tags = 8;.
+	protoFieldRegex = regexp.MustCompile(`^(repeated\s+|optional\s+)?(\w+)\s+(\w+)\s*=\s*(\d+)\s*(\[.*\])?\s*;$`)
+)
+
+// protoMessage is the parsed proto3 file with one message of the scalar fields.
+type protoMessage struct {
+	Name   string
+	Fields []*protoField
+}
+
+type protoField struct {
+	Name     string
+	Type     string
+	Number   int
+	Repeated bool
+}
+
+func parseProto(text string) (*protoMessage, error) {
+	var message *protoMessage
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if index := strings.Index(line, "//"); index >= 0 {
+			line = strings.TrimSpace(line[:index])
+		}
+
+		switch {
+		case line == "", strings.HasPrefix(line, "syntax"), strings.HasPrefix(line, "package"),
+			strings.HasPrefix(line, "option"), strings.HasPrefix(line, "reserved"):
+		case message == nil:
+			match := messageRegex.FindStringSubmatch(line)
+			if match == nil {
+				return nil, fmt.Errorf("'%s': %w", line, errInvalidSchema)
+			}
+
+			message = &protoMessage{Name: match[1], Fields: nil}
+		case line == "}":
+			return message, nil
+		default:
+			field, err := parseProtoField(line)
+			if err != nil {
+				return nil, err
+			}
+
+			message.Fields = append(message.Fields, field)
+		}
+	}
+
+	return nil, fmt.Errorf("message isn't closed: %w", errInvalidSchema)
+}
+
+func parseProtoField(line string) (*protoField, error) {
+	match := protoFieldRegex.FindStringSubmatch(line)
+	if match == nil {
+		return nil, fmt.Errorf("'%s': %w", line, errInvalidSchema)
+	}
+
+	if protoWireType(match[2]) < 0 {
+		return nil, fmt.Errorf("protobuf type '%s': %w", match[2], errUnsupportedType)
+	}
+
+	number, _ := strconv.Atoi(match[4])
+
+	return &protoField{
+		Name:     match[3],
+		Type:     match[2],
+		Number:   number,
+		Repeated: strings.TrimSpace(match[1]) == "repeated",
+	}, nil
+}
+
+func protoWireType(name string) int {
+	switch name {
+	case "int32", "int64", "uint32", "uint64", "sint32", "sint64", "bool":
+		return wireVarint
+	case "double":
+		return wireFixed64
+	case "float":
+		return wireFixed32
+	case "string", "bytes":
+		return wireBytes
+	default:
+		return -1
+	}
+}
+
+// encode skips the zero values like proto3 does, repeated numbers are packed.
+func (m *protoMessage) encode(values map[string]any) ([]byte, error) {
+	var (
+		buf []byte
+		err error
+	)
+
+	for _, field := range m.Fields {
+		value, ok := values[field.Name]
+		if !ok || value == nil {
+			continue
+		}
+
+		buf, err = field.write(buf, value)
+		if err != nil {
+			return nil, fmt.Errorf("field '%s': %w", field.Name, err)
+		}
+	}
+
+	return buf, nil
+}
+
+func (f *protoField) write(buf []byte, value any) ([]byte, error) {
+	if !f.Repeated {
+		data, err := f.scalar(nil, value)
+		if err != nil || len(data) == 0 {
+			return buf, err
+		}
+
+		return f.append(buf, protoWireType(f.Type), data), nil
+	}
+
+	items, ok := toSlice(value)
+	if !ok {
+		return nil, fmt.Errorf("%v isn't array: %w", value, errInvalidValue)
+	}
+
+	if protoWireType(f.Type) == wireBytes {
+		for _, item := range items {
+			data, err := toProtoBytes(item)
+			if err != nil {
+				return nil, err
+			}
+
+			buf = f.append(buf, wireBytes, binary.AppendUvarint(nil, uint64(len(data))))
+			buf = append(buf, data...)
+		}
+
+		return buf, nil
+	}
+
+	var packed []byte
+
+	for _, item := range items {
+		data, err := f.scalar(nil, item)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(data) == 0 {
+			data = protoZero(protoWireType(f.Type))
+		}
+
+		packed = append(packed, data...)
+	}
+
+	if len(packed) == 0 {
+		return buf, nil
+	}
+
+	buf = f.append(buf, wireBytes, binary.AppendUvarint(nil, uint64(len(packed))))
+
+	return append(buf, packed...), nil
+}
+
+// append writes the tag and the data of the field.
+func (f *protoField) append(buf []byte, wireType int, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(f.Number)<<3|uint64(wireType)) //nolint:gomnd
+
+	return append(buf, data...)
+}
+
+// scalar returns nothing for the zero value, the bytes have the length prefix.
+func (f *protoField) scalar(buf []byte, value any) ([]byte, error) {
+	switch f.Type {
+	case "bool":
+		flag, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%v isn't boolean: %w", value, errInvalidValue)
+		}
+
+		if !flag {
+			return buf, nil
+		}
+
+		return append(buf, 1), nil
+	case "double", "float":
+		number, ok := toFloat64(value)
+		if !ok {
+			return nil, fmt.Errorf("%v isn't number: %w", value, errInvalidValue)
+		}
+
+		switch {
+		case number == 0:
+			return buf, nil
+		case f.Type == "float":
+			return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(number))), nil
+		default:
+			return binary.LittleEndian.AppendUint64(buf, math.Float64bits(number)), nil
+		}
+	case "string", "bytes":
+		data, err := toProtoBytes(value)
+		if err != nil || len(data) == 0 {
+			return buf, err
+		}
+
+		buf = binary.AppendUvarint(buf, uint64(len(data)))
+
+		return append(buf, data...), nil
+	default:
+		number, ok := toInt64(value)
+		if !ok {
+			return nil, fmt.Errorf("%v isn't integer: %w", value, errInvalidValue)
+		}
+
+		switch {
+		case number == 0:
+			return buf, nil
+		case strings.HasPrefix(f.Type, "sint"):
+			return binary.AppendVarint(buf, number), nil
+		default:
+			return binary.AppendUvarint(buf, uint64(number)), nil
+		}
+	}
+}
+
+// protoZero is the zero value of the packed field, it can't be skipped there.
+func protoZero(wireType int) []byte {
+	switch wireType {
+	case wireFixed64:
+		return make([]byte, 8) //nolint:gomnd
+	case wireFixed32:
+		return make([]byte, 4) //nolint:gomnd
+	default:
+		return []byte{0}
+	}
+}
+
+func toProtoBytes(value any) ([]byte, error) {
+	data, ok := toBytes(value)
+	if !ok {
+		return nil, fmt.Errorf("%v isn't string: %w", value, errInvalidValue)
+	}
+
+	return data, nil
+}
+
+// backward allows the added and the removed fields, the kept numbers must have the same type.
+func (m *protoMessage) backward(old codec) error {
+	writer, ok := old.(*protoMessage)
+	if !ok {
+		return ErrIncompatible
+	}
+
+	if m.Name != writer.Name {
+		return fmt.Errorf("message '%s' was '%s': %w", m.Name, writer.Name, ErrIncompatible)
+	}
+
+	written := make(map[int]*protoField, len(writer.Fields))
+	for _, field := range writer.Fields {
+		written[field.Number] = field
+	}
+
+	for _, field := range m.Fields {
+		old, ok := written[field.Number]
+		if !ok {
+			continue
+		}
+
+		if old.Type != field.Type || old.Repeated != field.Repeated {
+			return fmt.Errorf("field %d '%s' changed the type: %w", field.Number, field.Name, ErrIncompatible)
+		}
+	}
+
+	return nil
+}
+
+// decode skips the unknown fields, the missing fields are the zero values of proto3.
+func (m *protoMessage) decode(data []byte) (map[string]any, error) {
+	fields := make(map[int]*protoField, len(m.Fields))
+	values := make(map[string]any, len(m.Fields))
+
+	for _, field := range m.Fields {
+		fields[field.Number] = field
+
+		if field.Repeated {
+			values[field.Name] = []any{}
+		}
+	}
+
+	for offset := 0; offset < len(data); {
+		tag, size := binary.Uvarint(data[offset:])
+		if size <= 0 {
+			return nil, fmt.Errorf("tag at %d: %w", offset, errInvalidData)
+		}
+
+		offset += size
+		wireType := int(tag & 7) //nolint:gomnd
+
+		value, size, err := protoValue(data[offset:], wireType)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %w", tag>>3, err) //nolint:gomnd
+		}
+
+		offset += size
+
+		field, ok := fields[int(tag>>3)] //nolint:gomnd
+		if !ok {
+			continue
+		}
+
+		err = field.read(values, wireType, value)
+		if err != nil {
+			return nil, fmt.Errorf("field '%s': %w", field.Name, err)
+		}
+	}
+
+	return values, nil
+}
+
+// protoValue returns the raw value of the wire type and its size.
+func protoValue(data []byte, wireType int) ([]byte, int, error) {
+	switch wireType {
+	case wireVarint:
+		_, size := binary.Uvarint(data)
+		if size <= 0 {
+			return nil, 0, errInvalidData
+		}
+
+		return data[:size], size, nil
+	case wireFixed64, wireFixed32:
+		size := 8
+		if wireType == wireFixed32 {
+			size = 4
+		}
+
+		if len(data) < size {
+			return nil, 0, errInvalidData
+		}
+
+		return data[:size], size, nil
+	case wireBytes:
+		length, size := binary.Uvarint(data)
+		if size <= 0 || length > uint64(len(data)-size) {
+			return nil, 0, errInvalidData
+		}
+
+		return data[size : size+int(length)], size + int(length), nil
+	default:
+		return nil, 0, fmt.Errorf("wire type %d: %w", wireType, errUnsupportedType)
+	}
+}
+
+// read sets the value of the field, the packed repeated numbers are split.
+func (f *protoField) read(values map[string]any, wireType int, data []byte) error {
+	fieldType := protoWireType(f.Type)
+
+	if f.Repeated && wireType == wireBytes && fieldType != wireBytes {
+		for len(data) > 0 {
+			item, size, err := protoValue(data, fieldType)
+			if err != nil {
+				return err
+			}
+
+			value, err := f.scalarValue(item)
+			if err != nil {
+				return err
+			}
+
+			values[f.Name] = append(values[f.Name].([]any), value) //nolint:forcetypeassert
+			data = data[size:]
+		}
+
+		return nil
+	}
+
+	if wireType != fieldType {
+		return fmt.Errorf("wire type %d: %w", wireType, errInvalidData)
+	}
+
+	value, err := f.scalarValue(data)
+	if err != nil {
+		return err
+	}
+
+	if f.Repeated {
+		values[f.Name] = append(values[f.Name].([]any), value) //nolint:forcetypeassert
+	} else {
+		values[f.Name] = value
+	}
+
+	return nil
+}
+
+func (f *protoField) scalarValue(data []byte) (any, error) {
+	switch f.Type {
+	case "string":
+		return string(data), nil
+	case "bytes":
+		return append([]byte(nil), data...), nil
+	case "double":
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case "float":
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), nil
+	case "bool":
+		return data[0] != 0, nil
+	case "sint32", "sint64":
+		value, _ := binary.Varint(data)
+
+		return value, nil
+	default:
+		value, _ := binary.Uvarint(data)
+
+		return int64(value), nil
+	}
+}
@@ -0,0 +1,186 @@
+// Package schema serializes the feedback of the events by the schemas versioned in the feedback directory,
+// registers them in the schema registry and reads the events back by the registered schemas.
+package schema
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker"
+)
+
+// Formats of the serialized feedback.
+const (
+	FormatJSON     = "json"
+	FormatAvro     = "avro"
+	FormatProtobuf = "protobuf"
+)
+
+var (
+	// ErrIncompatible is returned for the schema which can't read the data of the previous version.
+	ErrIncompatible = errors.New("schema isn't backward compatible")
+	// ErrUnknownFormat is returned for the format other than json, avro or protobuf.
+	ErrUnknownFormat = errors.New("unknown schema format, expected json, avro or protobuf")
+
+	errWrongFileName   = errors.New("wrong schema file name")
+	errMissingVersion  = errors.New("missing schema version")
+	errInvalidSchema   = errors.New("invalid schema")
+	errUnsupportedType = errors.New("unsupported schema type")
+	errInvalidValue    = errors.New("value doesn't match the schema")
+	errInvalidData     = fmt.Errorf("data doesn't match the schema: %w", broker.ErrInvalidEvent)
+
+	// v1.avsc.
+	fileNameRegex = regexp.MustCompile(`^v(\d+)\.(avsc|proto|json)$`)
+
+	//go:embed feedback/*
+	files embed.FS
+
+	extensions = map[string]string{ //nolint:gochecknoglobals
+		FormatJSON:     "json",
+		FormatAvro:     "avsc",
+		FormatProtobuf: "proto",
+	}
+)
+
+// Schema is one version of the feedback schema.
+type Schema struct {
+	Format  string
+	Version int
+	Text    string
+	codec   codec
+}
+
+// codec is the parsed schema of the format.
+type codec interface {
+	// encode writes the values of the fields, the missing values are the defaults of the schema.
+	encode(values map[string]any) ([]byte, error)
+	// decode reads the values of the fields written by this schema.
+	decode(data []byte) (map[string]any, error)
+	// backward checks that the data written by the old schema is readable by this one.
+	backward(old codec) error
+}
+
+// Load returns all versions of the format from the oldest one,
+// every version is checked to be backward compatible with the previous one.
+func Load(format string) ([]*Schema, error) {
+	extension, ok := extensions[format]
+	if !ok {
+		return nil, fmt.Errorf("'%s': %w", format, ErrUnknownFormat)
+	}
+
+	entries, err := fs.ReadDir(files, "feedback")
+	if err != nil {
+		return nil, fmt.Errorf("reading schemas: %w", err)
+	}
+
+	schemas := make([]*Schema, 0, len(entries))
+
+	for _, entry := range entries {
+		match := fileNameRegex.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("'%s': %w", entry.Name(), errWrongFileName)
+		}
+
+		if match[2] != extension {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+
+		text, err := fs.ReadFile(files, "feedback/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("reading schema '%s': %w", entry.Name(), err)
+		}
+
+		schema, err := Parse(format, version, string(text))
+		if err != nil {
+			return nil, fmt.Errorf("schema '%s': %w", entry.Name(), err)
+		}
+
+		schemas = append(schemas, schema)
+	}
+
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Version < schemas[j].Version })
+
+	for i, schema := range schemas {
+		if schema.Version != i+1 {
+			return nil, fmt.Errorf("%s v%d: %w", format, i+1, errMissingVersion)
+		}
+
+		if i > 0 {
+			err = Backward(schemas[i-1], schema)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(schemas) == 0 {
+		return nil, fmt.Errorf("%s v1: %w", format, errMissingVersion)
+	}
+
+	return schemas, nil
+}
+
+// Parse reads the schema text of the format, e.g. the latest version from the registry.
+func Parse(format string, version int, text string) (*Schema, error) {
+	var (
+		parsed codec
+		err    error
+	)
+
+	switch format {
+	case FormatJSON:
+		parsed, err = parseJSONSchema(text)
+	case FormatAvro:
+		parsed, err = parseAvro(text)
+	case FormatProtobuf:
+		parsed, err = parseProto(text)
+	default:
+		return nil, fmt.Errorf("'%s': %w", format, ErrUnknownFormat)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Schema{
+		Format:  format,
+		Version: version,
+		Text:    text,
+		codec:   parsed,
+	}, nil
+}
+
+// Backward checks that the new schema reads the data written by the old one:
+// the added fields must be optional (a default in Avro, not required in JSON Schema),
+// the types of the kept fields can't be changed except the Avro promotions (e.g. int to long).
+func Backward(old, schema *Schema) error {
+	if old.Format != schema.Format {
+		return fmt.Errorf("%s v%d after %s v%d: %w", schema.Format, schema.Version, old.Format, old.Version, ErrIncompatible)
+	}
+
+	err := schema.codec.backward(old.codec)
+	if err != nil {
+		return fmt.Errorf("%s v%d after v%d: %w", schema.Format, schema.Version, old.Version, err)
+	}
+
+	return nil
+}
+
+// registryType is the schemaType of the Confluent schema registry.
+func (s *Schema) registryType() string {
+	switch s.Format {
+	case FormatAvro:
+		return "AVRO"
+	case FormatProtobuf:
+		return "PROTOBUF"
+	default:
+		return "JSON"
+	}
+}
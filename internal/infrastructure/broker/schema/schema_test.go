@@ -0,0 +1,354 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+)
+
+const testSubject = "feedbacks-value"
+
+var formats = []string{FormatJSON, FormatAvro, FormatProtobuf} //nolint:gochecknoglobals
+
+func testFeedback() *models.Feedback {
+	//nolint:exhaustivestruct,exhaustruct
+	return &models.Feedback{
+		ID:             uuid.MustParse("01890a5d-ac96-774b-bcce-b302099a8057"),
+		CustomerName:   "Jane Doe",
+		Email:          "jane@example.com",
+		FeedbackText:   "The delivery was late, ünïcödé 👍",
+		Source:         "https://shop.example.com/orders/1",
+		SentimentScore: -0.25,
+		SentimentLabel: "negative",
+		Tags:           []string{"delivery", "late"},
+		Team:           "logistics",
+		Priority:       "high",
+		SourceHost:     "shop.example.com",
+		CreatedAt:      time.Date(2024, 5, 1, 10, 30, 0, 123456000, time.UTC),
+		UpdatedAt:      time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC),
+	}
+}
+
+// routed is the part of the feedback written to the events.
+func routed(feedback *models.Feedback) *models.Feedback {
+	//nolint:exhaustivestruct,exhaustruct
+	return &models.Feedback{
+		ID:             feedback.ID,
+		CustomerName:   feedback.CustomerName,
+		Email:          feedback.Email,
+		FeedbackText:   feedback.FeedbackText,
+		Source:         feedback.Source,
+		SentimentScore: feedback.SentimentScore,
+		SentimentLabel: feedback.SentimentLabel,
+		Tags:           feedback.Tags,
+		Team:           feedback.Team,
+		Priority:       feedback.Priority,
+		SourceHost:     feedback.SourceHost,
+		CreatedAt:      feedback.CreatedAt,
+		UpdatedAt:      feedback.UpdatedAt,
+	}
+}
+
+// routedV1 is the part of the feedback written by the first version of the schemas.
+func routedV1(feedback *models.Feedback) *models.Feedback {
+	result := routed(feedback)
+	result.SourceHost = ""
+	result.CreatedAt = time.Time{}
+	result.UpdatedAt = time.Time{}
+
+	return result
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range formats {
+		format := format
+
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			registry := NewMemory()
+
+			serializer, err := New(ctx, format, registry, testSubject)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			for name, feedback := range map[string]*models.Feedback{
+				"full":  testFeedback(),
+				"empty": {ID: uuid.New(), FeedbackText: "ok", Source: "https://example.com", Tags: []string{}},
+			} {
+				data, err := serializer.Serialize(feedback)
+				if err != nil {
+					t.Fatalf("%s: Serialize: %v", name, err)
+				}
+
+				got, err := NewDeserializer(registry).Deserialize(ctx, serializer.ContentType(), data)
+				if err != nil {
+					t.Fatalf("%s: Deserialize: %v", name, err)
+				}
+
+				want := routed(feedback)
+				if len(got.Tags) == 0 && len(want.Tags) == 0 {
+					got.Tags, want.Tags = nil, nil
+				}
+
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s: got %+v, want %+v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range formats {
+		schemas, err := Load(format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		for i, schema := range schemas {
+			if schema.Version != i+1 || schema.Format != format {
+				t.Errorf("%s: schema %d is %s v%d", format, i, schema.Format, schema.Version)
+			}
+		}
+	}
+
+	_, err := Load("xml")
+	if !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("xml: got %v, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func TestBackward(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		format     string
+		old        string
+		new        string
+		compatible bool
+	}{
+		{
+			name:       "avro added field with default",
+			format:     FormatAvro,
+			old:        `{"type":"record","name":"Feedback","fields":[{"name":"id","type":"string"}]}`,
+			new:        `{"type":"record","name":"Feedback","fields":[{"name":"id","type":"string"},{"name":"team","type":"string","default":""}]}`,
+			compatible: true,
+		},
+		{
+			name:       "avro added field without default",
+			format:     FormatAvro,
+			old:        `{"type":"record","name":"Feedback","fields":[{"name":"id","type":"string"}]}`,
+			new:        `{"type":"record","name":"Feedback","fields":[{"name":"id","type":"string"},{"name":"team","type":"string"}]}`,
+			compatible: false,
+		},
+		{
+			name:       "avro removed field",
+			format:     FormatAvro,
+			old:        `{"type":"record","name":"Feedback","fields":[{"name":"id","type":"string"},{"name":"team","type":"string"}]}`,
+			new:        `{"type":"record","name":"Feedback","fields":[{"name":"id","type":"string"}]}`,
+			compatible: true,
+		},
+		{
+			name:       "avro promoted type",
+			format:     FormatAvro,
+			old:        `{"type":"record","name":"Feedback","fields":[{"name":"score","type":"int"}]}`,
+			new:        `{"type":"record","name":"Feedback","fields":[{"name":"score","type":"long"}]}`,
+			compatible: true,
+		},
+		{
+			name:       "avro changed type",
+			format:     FormatAvro,
+			old:        `{"type":"record","name":"Feedback","fields":[{"name":"score","type":"double"}]}`,
+			new:        `{"type":"record","name":"Feedback","fields":[{"name":"score","type":"string"}]}`,
+			compatible: false,
+		},
+		{
+			name:       "avro optional field",
+			format:     FormatAvro,
+			old:        `{"type":"record","name":"Feedback","fields":[{"name":"id","type":"string"}]}`,
+			new:        `{"type":"record","name":"Feedback","fields":[{"name":"id","type":"string"},{"name":"team","type":["null","string"],"default":null}]}`,
+			compatible: true,
+		},
+		{
+			name:       "avro renamed record",
+			format:     FormatAvro,
+			old:        `{"type":"record","name":"Feedback","fields":[{"name":"id","type":"string"}]}`,
+			new:        `{"type":"record","name":"Review","fields":[{"name":"id","type":"string"}]}`,
+			compatible: false,
+		},
+		{
+			name:       "protobuf added field",
+			format:     FormatProtobuf,
+			old:        "syntax = \"proto3\";\nmessage Feedback {\n  string id = 1;\n}\n",
+			new:        "syntax = \"proto3\";\nmessage Feedback {\n  string id = 1;\n  string team = 2;\n}\n",
+			compatible: true,
+		},
+		{
+			name:       "protobuf changed type",
+			format:     FormatProtobuf,
+			old:        "syntax = \"proto3\";\nmessage Feedback {\n  string id = 1;\n  double score = 2;\n}\n",
+			new:        "syntax = \"proto3\";\nmessage Feedback {\n  string id = 1;\n  string score = 2;\n}\n",
+			compatible: false,
+		},
+		{
+			name:       "protobuf repeated field",
+			format:     FormatProtobuf,
+			old:        "syntax = \"proto3\";\nmessage Feedback {\n  string tag = 1;\n}\n",
+			new:        "syntax = \"proto3\";\nmessage Feedback {\n  repeated string tag = 1;\n}\n",
+			compatible: false,
+		},
+		{
+			name:       "json added optional property",
+			format:     FormatJSON,
+			old:        `{"type":"object","properties":{"id":{"type":"string"}},"required":["id"]}`,
+			new:        `{"type":"object","properties":{"id":{"type":"string"},"team":{"type":"string"}},"required":["id"]}`,
+			compatible: true,
+		},
+		{
+			name:       "json added required property",
+			format:     FormatJSON,
+			old:        `{"type":"object","properties":{"id":{"type":"string"}},"required":["id"]}`,
+			new:        `{"type":"object","properties":{"id":{"type":"string"},"team":{"type":"string"}},"required":["id","team"]}`,
+			compatible: false,
+		},
+		{
+			name:       "json changed type",
+			format:     FormatJSON,
+			old:        `{"type":"object","properties":{"score":{"type":"number"}}}`,
+			new:        `{"type":"object","properties":{"score":{"type":"string"}}}`,
+			compatible: false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			old, err := Parse(test.format, 1, test.old)
+			if err != nil {
+				t.Fatalf("old: %v", err)
+			}
+
+			schema, err := Parse(test.format, 2, test.new) //nolint:gomnd
+			if err != nil {
+				t.Fatalf("new: %v", err)
+			}
+
+			err = Backward(old, schema)
+			if test.compatible && err != nil {
+				t.Errorf("got %v, want compatible", err)
+			}
+
+			if !test.compatible && !errors.Is(err, ErrIncompatible) {
+				t.Errorf("got %v, want %v", err, ErrIncompatible)
+			}
+
+			// The registry refuses the incompatible version of the subject.
+			registry := NewMemory()
+
+			_, err = registry.Register(context.Background(), testSubject, old)
+			if err != nil {
+				t.Fatalf("registering old: %v", err)
+			}
+
+			_, err = registry.Register(context.Background(), testSubject, schema)
+			if test.compatible != (err == nil) {
+				t.Errorf("registering new: got %v, compatible %t", err, test.compatible)
+			}
+		})
+	}
+}
+
+// TestReadOldData checks that the consumer reads the events written by every previous version of the schema,
+// the data keeps the ID of its own schema.
+func TestReadOldData(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	for _, format := range formats {
+		schemas, err := Load(format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		registry := NewMemory()
+		feedback := testFeedback()
+
+		for _, schema := range schemas {
+			id, err := registry.Register(ctx, testSubject, schema)
+			if err != nil {
+				t.Fatalf("%s v%d: registering: %v", format, schema.Version, err)
+			}
+
+			// The serializer of the old version, like the one of the previous release.
+			serializer := &Serializer{schema: schema, id: id, dataSchema: registry.URL(id)}
+
+			data, err := serializer.Serialize(feedback)
+			if err != nil {
+				t.Fatalf("%s v%d: Serialize: %v", format, schema.Version, err)
+			}
+
+			got, err := NewDeserializer(registry).Deserialize(ctx, serializer.ContentType(), data)
+			if err != nil {
+				t.Fatalf("%s v%d: Deserialize: %v", format, schema.Version, err)
+			}
+
+			want := routed(feedback)
+			if schema.Version == 1 {
+				want = routedV1(feedback)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s v%d: got %+v, want %+v", format, schema.Version, got, want)
+			}
+		}
+	}
+}
+
+func TestDeserializeInvalid(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	registry := NewMemory()
+
+	serializer, err := New(ctx, FormatAvro, registry, testSubject)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	data, err := serializer.Serialize(testFeedback())
+	if err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+
+	unknown := append([]byte{magicByte, 0, 0, 0, 99}, data[headerSize:]...) //nolint:gomnd
+
+	for name, data := range map[string][]byte{
+		"short":     {magicByte, 0},
+		"magic":     append([]byte{1}, data[1:]...),
+		"unknown":   unknown,
+		"truncated": data[:len(data)-3],
+	} {
+		_, err := NewDeserializer(registry).Deserialize(ctx, serializer.ContentType(), data)
+		if err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package schema
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker"
+)
+
+// Check that actual implementations fit the interfaces.
+var (
+	_ broker.Serializer   = (*Serializer)(nil)
+	_ broker.Deserializer = (*Deserializer)(nil)
+)
+
+// The Confluent wire format: the magic byte, 4 bytes of the schema ID and the data.
+const (
+	magicByte  = 0
+	headerSize = 5
+)
+
+var errWireFormat = fmt.Errorf("data isn't in the Confluent wire format: %w", broker.ErrInvalidEvent)
+
+// Serializer encodes the feedback by the latest schema of the format.
+// Avro and Protobuf data is in the Confluent wire format, JSON is plain, its schema is in the dataschema of the event.
+type Serializer struct {
+	schema     *Schema
+	id         int
+	dataSchema string
+}
+
+// Subject is the subject of the topic values by the topic name strategy of Confluent.
+func Subject(topicName string) string {
+	return topicName + "-value"
+}
+
+// New loads the schemas of the format, checks the latest one against the registry and registers it,
+// so the incompatible schema fails the start before anything is published.
+func New(ctx context.Context, format string, registry Registry, subject string) (*Serializer, error) {
+	schemas, err := Load(format)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := schemas[len(schemas)-1]
+
+	err = registry.Compatible(ctx, subject, latest)
+	if err != nil {
+		return nil, fmt.Errorf("checking %s v%d: %w", format, latest.Version, err)
+	}
+
+	id, err := registry.Register(ctx, subject, latest)
+	if err != nil {
+		return nil, fmt.Errorf("registering %s v%d: %w", format, latest.Version, err)
+	}
+
+	return &Serializer{
+		schema:     latest,
+		id:         id,
+		dataSchema: registry.URL(id),
+	}, nil
+}
+
+func (s *Serializer) ContentType() string {
+	switch s.schema.Format {
+	case FormatAvro:
+		return "application/avro"
+	case FormatProtobuf:
+		return "application/protobuf"
+	default:
+		return "application/json"
+	}
+}
+
+func (s *Serializer) DataSchema() string {
+	return s.dataSchema
+}
+
+func (s *Serializer) SchemaVersion() string {
+	return strconv.Itoa(s.schema.Version)
+}
+
+func (s *Serializer) Serialize(feedback *models.Feedback) ([]byte, error) {
+	data, err := s.schema.codec.encode(values(feedback))
+	if err != nil {
+		return nil, fmt.Errorf("serializing feedback to %s: %w", s.schema.Format, err)
+	}
+
+	if s.schema.Format == FormatJSON {
+		return data, nil
+	}
+
+	buf := make([]byte, 0, len(data)+6) //nolint:gomnd
+	buf = append(buf, magicByte)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(s.id))
+
+	if s.schema.Format == FormatProtobuf {
+		// Indexes of the message in the file, [0] (the first message) is written as one zero.
+		buf = append(buf, 0)
+	}
+
+	return append(buf, data...), nil
+}
+
+// values are the fields of the schemas, the field added to the schema must be added here.
+func values(feedback *models.Feedback) map[string]any {
+	tags := feedback.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return map[string]any{
+		"id":              feedback.ID.String(),
+		"customer_name":   feedback.CustomerName,
+		"email":           feedback.Email,
+		"feedback_text":   feedback.FeedbackText,
+		"source":          feedback.Source,
+		"sentiment_score": feedback.SentimentScore,
+		"sentiment_label": feedback.SentimentLabel,
+		"tags":            tags,
+		"team":            feedback.Team,
+		"priority":        feedback.Priority,
+		"source_host":     feedback.SourceHost,
+		"created_at":      micros(feedback.CreatedAt),
+		"updated_at":      micros(feedback.UpdatedAt),
+	}
+}
+
+// micros is the time of the schemas (Unix microseconds), the zero time is 0.
+func micros(value time.Time) int64 {
+	if value.IsZero() {
+		return 0
+	}
+
+	return value.UnixMicro()
+}
+
+// fromMicros is the reverse of micros, 0 and the missing value of the old data are the zero time.
+func fromMicros(value any) time.Time {
+	number, _ := toInt64(value)
+	if number == 0 {
+		return time.Time{}
+	}
+
+	return time.UnixMicro(number).UTC()
+}
+
+// Deserializer reads the feedback of any format by the schema of the data,
+// the schemas are fetched from the registry by the ID once.
+type Deserializer struct {
+	registry Registry
+	mu       sync.Mutex
+	schemas  map[int]*Schema
+}
+
+func NewDeserializer(registry Registry) *Deserializer {
+	return &Deserializer{
+		registry: registry,
+		mu:       sync.Mutex{},
+		schemas:  make(map[int]*Schema),
+	}
+}
+
+// Deserialize reads the data of the content type (datacontenttype of the event): plain JSON,
+// Avro or Protobuf in the wire format. The fields unknown to the feedback are skipped.
+func (d *Deserializer) Deserialize(ctx context.Context, contentType string, data []byte) (*models.Feedback, error) {
+	if contentType == "" || strings.HasPrefix(contentType, "application/json") {
+		var values map[string]any
+
+		err := json.Unmarshal(data, &values)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", err.Error(), errInvalidData)
+		}
+
+		return feedbackFrom(values)
+	}
+
+	if len(data) < headerSize || data[0] != magicByte {
+		return nil, errWireFormat
+	}
+
+	schema, err := d.schema(ctx, int(binary.BigEndian.Uint32(data[1:headerSize])))
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[headerSize:]
+
+	if schema.Format == FormatProtobuf {
+		// Only the first message is written, its indexes are one zero.
+		if len(data) == 0 || data[0] != 0 {
+			return nil, fmt.Errorf("message indexes: %w", errWireFormat)
+		}
+
+		data = data[1:]
+	}
+
+	values, err := schema.codec.decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("deserializing feedback from %s: %w", schema.Format, err)
+	}
+
+	return feedbackFrom(values)
+}
+
+func (d *Deserializer) schema(ctx context.Context, id int) (*Schema, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if schema, ok := d.schemas[id]; ok {
+		return schema, nil
+	}
+
+	schema, err := d.registry.Schema(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("getting schema %d: %w", id, err)
+	}
+
+	d.schemas[id] = schema
+
+	return schema, nil
+}
+
+// feedbackFrom is the reverse of values.
+func feedbackFrom(values map[string]any) (*models.Feedback, error) {
+	text := func(name string) string {
+		value, _ := values[name].(string)
+
+		return value
+	}
+
+	feedbackID, err := uuid.Parse(text("id"))
+	if err != nil {
+		return nil, fmt.Errorf("id: %w", errInvalidData)
+	}
+
+	score, _ := toFloat64(values["sentiment_score"])
+	items, _ := toSlice(values["tags"])
+	tags := make([]string, 0, len(items))
+
+	for _, item := range items {
+		if tag, ok := item.(string); ok {
+			tags = append(tags, tag)
+		}
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	return &models.Feedback{
+		ID:             feedbackID,
+		CustomerName:   text("customer_name"),
+		Email:          text("email"),
+		FeedbackText:   text("feedback_text"),
+		Source:         text("source"),
+		SentimentScore: score,
+		SentimentLabel: text("sentiment_label"),
+		Tags:           tags,
+		Team:           text("team"),
+		Priority:       text("priority"),
+		SourceHost:     text("source_host"),
+		CreatedAt:      fromMicros(values["created_at"]),
+		UpdatedAt:      fromMicros(values["updated_at"]),
+	}, nil
+}
@@ -1,8 +1,56 @@
 package cache
 
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// generationKey keeps the generation of the cached responses, it's a part of every key.
+const generationKey = "cache-generation"
+
 // Cache interface is used in
 // controller and cache-middleware.
 type Cache interface {
-	Get(key string) ([]byte, bool, error)
-	Set(key string, value []byte) error
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Clearer is the cache which can drop all items at once. The old generations are never read again,
+// so the cache without the expiration (the memory one) is cleared by Invalidate.
+type Clearer interface {
+	Clear(ctx context.Context) error
+}
+
+// Key returns the key of the response by its URL in the current generation.
+func Key(ctx context.Context, cache Cache, url string) (string, error) {
+	generation, _, err := cache.Get(ctx, generationKey)
+	if err != nil {
+		return "", fmt.Errorf("getting cache generation: %w", err)
+	}
+
+	return string(generation) + ":" + url, nil
+}
+
+// Invalidate starts the new generation, so all responses cached before are missed.
+// Memcached can't list the keys, so the lists with the changed entity
+// can't be found and deleted one by one. The Clearer is cleared before, it doesn't keep the old generations.
+func Invalidate(ctx context.Context, cache Cache) error {
+	if clearer, ok := cache.(Clearer); ok {
+		err := clearer.Clear(ctx)
+		if err != nil {
+			return fmt.Errorf("clearing cache: %w", err)
+		}
+	}
+
+	generation := strconv.FormatInt(time.Now().UnixNano(), 36) //nolint:gomnd
+
+	err := cache.Set(ctx, generationKey, []byte(generation))
+	if err != nil {
+		return fmt.Errorf("setting cache generation: %w", err)
+	}
+
+	return nil
 }
@@ -1,8 +1,10 @@
 package memcached
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
 
@@ -19,16 +21,26 @@ type Memcached struct {
 // Check that actual implementation fits the interface.
 var _ cache.Cache = (*Memcached)(nil)
 
-func New(host string, secondsToLive int32, logger logger.Logger) *Memcached {
+func New(host string, secondsToLive int32, timeout time.Duration, logger logger.Logger) *Memcached {
+	client := memcache.New(host)
+	client.Timeout = timeout
+
 	return &Memcached{
 		logger:        logger.Named("memcached"),
-		client:        memcache.New(host),
+		client:        client,
 		secondsToLive: secondsToLive,
 	}
 }
 
-func (c *Memcached) Get(key string) ([]byte, bool, error) {
-	item, err := c.client.Get(key)
+func (c *Memcached) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var item *memcache.Item
+
+	err := do(ctx, func() error {
+		var err error
+		item, err = c.client.Get(key)
+
+		return err //nolint:wrapcheck
+	})
 	if err != nil {
 		if errors.Is(err, memcache.ErrCacheMiss) {
 			c.logger.Info("Cache miss", logger.M{"key": key})
@@ -46,12 +58,14 @@ func (c *Memcached) Get(key string) ([]byte, bool, error) {
 	return item.Value, true, nil
 }
 
-func (c *Memcached) Set(key string, value []byte) error {
-	//nolint:exhaustivestruct,exhaustruct
-	err := c.client.Set(&memcache.Item{
-		Key:        key,
-		Value:      value,
-		Expiration: c.secondsToLive,
+func (c *Memcached) Set(ctx context.Context, key string, value []byte) error {
+	err := do(ctx, func() error {
+		//nolint:exhaustivestruct,exhaustruct,wrapcheck
+		return c.client.Set(&memcache.Item{
+			Key:        key,
+			Value:      value,
+			Expiration: c.secondsToLive,
+		})
 	})
 	if err != nil {
 		c.logger.Error("setting error", logger.M{"err": err})
@@ -63,3 +77,38 @@ func (c *Memcached) Set(key string, value []byte) error {
 
 	return nil
 }
+
+// Delete removes the item, missing item isn't an error.
+func (c *Memcached) Delete(ctx context.Context, key string) error {
+	err := do(ctx, func() error {
+		return c.client.Delete(key) //nolint:wrapcheck
+	})
+	if err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+		c.logger.Error("deleting error", logger.M{"err": err})
+
+		return fmt.Errorf("deleting cache: %w", err)
+	}
+
+	return nil
+}
+
+// do runs the call, but returns earlier if the context is done.
+// The client doesn't support context, the call itself is limited by the client timeout.
+func do(ctx context.Context, call func() error) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context is done: %w", err)
+	}
+
+	result := make(chan error, 1)
+
+	go func() {
+		result <- call()
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("context is done: %w", ctx.Err())
+	}
+}
@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"context"
 	"sync"
 
 	c "github.com/andrsj/feedback-service/internal/infrastructure/cache"
@@ -15,7 +16,10 @@ type Cache struct {
 	logger logger.Logger
 }
 
-var _ c.Cache = (*Cache)(nil)
+var (
+	_ c.Cache   = (*Cache)(nil)
+	_ c.Clearer = (*Cache)(nil)
+)
 
 func New(logger logger.Logger) *Cache {
 	return &Cache{
@@ -26,7 +30,7 @@ func New(logger logger.Logger) *Cache {
 }
 
 // Set adds a new item to the cache.
-func (c *Cache) Set(key string, value []byte) error {
+func (c *Cache) Set(_ context.Context, key string, value []byte) error {
 	c.logger.Info("Setting values", logger.M{
 		"key":   key,
 		"value": string(value),
@@ -41,7 +45,7 @@ func (c *Cache) Set(key string, value []byte) error {
 }
 
 // Get retrieves an item from the cache.
-func (c *Cache) Get(key string) ([]byte, bool, error) {
+func (c *Cache) Get(_ context.Context, key string) ([]byte, bool, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -54,3 +58,25 @@ func (c *Cache) Get(key string) ([]byte, bool, error) {
 
 	return value, keyExists, nil
 }
+
+// Delete removes the item, missing item isn't an error.
+func (c *Cache) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, key)
+
+	return nil
+}
+
+// Clear removes all items, the items don't expire, so the old generations are dropped by it.
+func (c *Cache) Clear(_ context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.logger.Info("Clearing values", logger.M{"count": len(c.items)})
+
+	c.items = make(map[string][]byte)
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andrsj/feedback-service/internal/infrastructure/cache"
+	"github.com/andrsj/feedback-service/internal/infrastructure/cache/memory"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) logger.Logger { return l }
+func (nopLogger) Debug(string, logger.M)       {}
+func (nopLogger) Info(string, logger.M)        {}
+func (nopLogger) Warn(string, logger.M)        {}
+func (nopLogger) Error(string, logger.M)       {}
+func (nopLogger) Fatal(string, logger.M)       {}
+
+func TestInvalidateDropsOldGenerations(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	responses := memory.New(nopLogger{})
+
+	key, err := cache.Key(ctx, responses, "/feedbacks")
+	if err != nil {
+		t.Fatalf("Key: %v", err)
+	}
+
+	err = responses.Set(ctx, key, []byte("[]"))
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	err = cache.Invalidate(ctx, responses)
+	if err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+
+	// The response of the old generation is removed, not only missed by the new key.
+	if _, ok, _ := responses.Get(ctx, key); ok {
+		t.Error("the response of the old generation is kept")
+	}
+
+	newKey, _ := cache.Key(ctx, responses, "/feedbacks")
+	if newKey == key {
+		t.Errorf("the generation isn't changed: %q", newKey)
+	}
+
+	if _, ok, _ := responses.Get(ctx, newKey); ok {
+		t.Error("the response is found by the new key")
+	}
+}
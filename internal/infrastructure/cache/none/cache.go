@@ -0,0 +1,28 @@
+package none
+
+import (
+	"context"
+
+	"github.com/andrsj/feedback-service/internal/infrastructure/cache"
+)
+
+// Cache doesn't cache anything, every Get is a miss.
+type Cache struct{}
+
+// Check that actual implementation fits the interface.
+var _ cache.Cache = (*Cache)(nil)
+
+func New() *Cache {
+	return &Cache{}
+}
+
+func (c *Cache) Get(_ context.Context, _ string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (c *Cache) Set(_ context.Context, _ string, _ []byte) error {
+	return nil
+}
+func (c *Cache) Delete(_ context.Context, _ string) error {
+	return nil
+}
@@ -0,0 +1,141 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// AuditRepository reads the audit log, the entries are written by the other repositories
+// in the transactions of their changes, see record.
+type AuditRepository struct {
+	db      *gorm.DB
+	sink    audit.Sink
+	timeout time.Duration
+	logger  log.Logger
+}
+
+// NewAuditRepository gets the optional sink, it gets the entries after the commit.
+//
+//nolint:varnamelen
+func NewAuditRepository(db *gorm.DB, sink audit.Sink, timeout time.Duration, logger log.Logger) *AuditRepository {
+	return &AuditRepository{
+		db:      db,
+		sink:    sink,
+		timeout: timeout,
+		logger:  logger.Named("gormAudit"),
+	}
+}
+
+func (r *AuditRepository) GetByEntity(
+	ctx context.Context,
+	entity string,
+	entityID uuid.UUID,
+) ([]*models.AuditEntry, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var entries []*models.AuditEntry
+
+	err := db.Where("entity = ? AND entity_id = ?", entity, entityID).
+		Order("created_at").
+		Order("id").
+		Find(&entries).Error
+	if err != nil {
+		r.logger.Error("Failed to get audit entries from DB", log.M{"entity": entity, "id": entityID, "error": err.Error()})
+
+		return nil, fmt.Errorf("failed to get audit entries from DB: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetByEntities returns the history of the entities from the oldest entry, the unknown IDs are skipped.
+func (r *AuditRepository) GetByEntities(
+	ctx context.Context,
+	entity string,
+	entityIDs []uuid.UUID,
+) ([]*models.AuditEntry, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	entries := make([]*models.AuditEntry, 0)
+
+	for _, chunk := range chunks(entityIDs, maxInList) {
+		var found []*models.AuditEntry
+
+		err := db.Where("entity = ? AND entity_id IN ?", entity, chunk).
+			Order("created_at").
+			Order("id").
+			Find(&found).Error
+		if err != nil {
+			r.logger.Error("Failed to get audit entries from DB", log.M{"entity": entity, "error": err.Error()})
+
+			return nil, fmt.Errorf("failed to get audit entries from DB: %w", err)
+		}
+
+		entries = append(entries, found...)
+	}
+
+	return entries, nil
+}
+
+// Record saves the entry which isn't a part of a change of other repositories,
+// e.g. the receipt of the erasure.
+func (r *AuditRepository) Record(ctx context.Context, entry *models.AuditEntry) error {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	err := r.record(db, entry)
+	if err != nil {
+		r.logger.Error("Failed to write audit entry", log.M{"entity": entry.Entity, "id": entry.EntityID, "err": err})
+
+		return err
+	}
+
+	r.committed(entry)
+
+	return nil
+}
+
+// record saves the entries in the transaction of the change,
+// nil repository means that audit is off.
+func (r *AuditRepository) record(tx *gorm.DB, entries ...*models.AuditEntry) error {
+	if r == nil || len(entries) == 0 {
+		return nil
+	}
+
+	err := tx.CreateInBatches(entries, createBatchSize).Error
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	return nil
+}
+
+// committed passes the entries to the sink, the change is already saved,
+// so the failure of the sink is only logged.
+func (r *AuditRepository) committed(entries ...*models.AuditEntry) {
+	if r == nil || r.sink == nil || len(entries) == 0 {
+		return
+	}
+
+	err := r.sink.Write(entries)
+	if err != nil {
+		r.logger.Error("Failed to write audit entries to the sink", log.M{"count": len(entries), "err": err})
+	}
+}
+
+// conn returns DB session bound to the context with the query timeout.
+func (r *AuditRepository) conn(ctx context.Context) (*gorm.DB, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+
+	return r.db.WithContext(ctx), cancel
+}
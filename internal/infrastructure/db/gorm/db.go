@@ -1,81 +1,306 @@
 package gorm
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/ids"
 	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/envelope"
 	log "github.com/andrsj/feedback-service/pkg/logger"
 )
 
+// Rows per INSERT of CreateBatch, Postgres allows 65535 parameters per query.
+const createBatchSize = 100
+
 type FeedbackRepository struct {
-	db     *gorm.DB
-	logger log.Logger
+	db       *gorm.DB
+	replicas *Replicas
+	audit    *AuditRepository
+	cipher   *envelope.Cipher
+	ids      ids.IDGenerator
+	timeout  time.Duration
+	logger   log.Logger
 }
 
+// NewFeedbackRepository doesn't touch the schema, it's managed by the migrations.
+// GetByID, GetPage and Iterate go to the replicas, nil replicas mean the primary only.
+// Changes are written to the audit log in the same transaction.
+// The personal data is encrypted by the cipher, nil cipher means plaintext.
+// IDs of the new feedbacks are made by the generator, see GetPage.
+//
 //nolint:varnamelen
-func NewFeedbackRepository(db *gorm.DB, logger log.Logger) (*FeedbackRepository, error) {
-	logger = logger.Named("gormORM")
-
-	//nolint:exhaustivestruct,exhaustruct
-	err := db.AutoMigrate(models.Feedback{})
-	if err != nil {
-		logger.Error("Can't Auto Migrate the 'Feedback' model", log.M{"err": err})
-
-		return nil, fmt.Errorf("can't Auto Migrate the 'Feedback' model: %w", err)
-	}
-
-	logger.Info("Successfully migrated", nil)
-
+func NewFeedbackRepository(
+	db *gorm.DB,
+	replicas *Replicas,
+	auditLog *AuditRepository,
+	cipher *envelope.Cipher,
+	generator ids.IDGenerator,
+	timeout time.Duration,
+	logger log.Logger,
+) *FeedbackRepository {
 	return &FeedbackRepository{
-		db:     db,
-		logger: logger,
-	}, nil
+		db:       db,
+		replicas: replicas,
+		audit:    auditLog,
+		cipher:   cipher,
+		ids:      generator,
+		timeout:  timeout,
+		logger:   logger.Named("gormORM"),
+	}
 }
 
-func (r *FeedbackRepository) Create(feedbackInput *models.FeedbackInput) (uuid.UUID, error) {
+func (r *FeedbackRepository) Create(ctx context.Context, feedback *models.Feedback) (uuid.UUID, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
 	r.logger.Info("Creating 'Feedback'", nil)
 
-	var (
-		feedback *models.Feedback
-		// Potential mistake: WE CAN'T BE SURE THAT DB does not have the same ID.
-		// But for test task I ignore it for simplify
-		feedbackID = uuid.New()
-	)
+	feedbackID := r.ids.New()
+
+	feedback.ID = feedbackID
+	feedback.CreatedAt = now()
+	feedback.UpdatedAt = now()
+
+	entry, err := audit.NewEntry(ctx, audit.EntityFeedback, audit.ActionCreate, feedbackID, nil, feedback)
+	if err != nil {
+		return uuid.Nil, err //nolint:wrapcheck
+	}
 
-	feedback = &models.Feedback{
-		ID:           feedbackID,
-		CustomerName: feedbackInput.CustomerName,
-		Email:        feedbackInput.Email,
-		FeedbackText: feedbackInput.FeedbackText,
-		Source:       feedbackInput.Source,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+	sealed, err := r.seal(feedback)
+	if err != nil {
+		return uuid.Nil, err
 	}
 
-	err := r.db.Create(feedback).Error
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(sealed).Error
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return r.audit.record(tx, entry)
+	})
 	if err != nil {
 		r.logger.Error("Failed to create feedback into DB", log.M{"err": err})
 
 		return uuid.Nil, fmt.Errorf("failed to create feedback into DB: %w", err)
 	}
 
+	r.replicas.Wrote(ctx)
+	r.audit.committed(entry)
+
 	r.logger.Info("Feedback created successfully", log.M{"id": feedbackID})
 
 	return feedbackID, nil
 }
 
-func (r *FeedbackRepository) GetByID(feedbackID uuid.UUID) (*models.Feedback, error) {
+// CreateBatch inserts the feedbacks in one transaction,
+// the timestamps are set only if they are zero.
+func (r *FeedbackRepository) CreateBatch(ctx context.Context, feedbacks []*models.Feedback) error {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	r.logger.Info("Creating batch of 'Feedback's", log.M{"count": len(feedbacks)})
+
+	createdAt := now()
+	entries := make([]*models.AuditEntry, 0, len(feedbacks))
+
+	for _, feedback := range feedbacks {
+		feedback.ID = r.ids.New()
+
+		if feedback.CreatedAt.IsZero() {
+			feedback.CreatedAt = createdAt
+		}
+
+		if feedback.UpdatedAt.IsZero() {
+			feedback.UpdatedAt = feedback.CreatedAt
+		}
+
+		entry, err := audit.NewEntry(ctx, audit.EntityFeedback, audit.ActionCreate, feedback.ID, nil, feedback)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sealed, err := r.sealAll(feedbacks)
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.CreateInBatches(sealed, createBatchSize).Error
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return r.audit.record(tx, entries...)
+	})
+	if err != nil {
+		r.logger.Error("Failed to create batch of feedbacks into DB", log.M{"err": err})
+
+		return fmt.Errorf("failed to create batch of feedbacks into DB: %w", err)
+	}
+
+	r.replicas.Wrote(ctx)
+	r.audit.committed(entries...)
+
+	return nil
+}
+
+func (r *FeedbackRepository) Update(ctx context.Context, feedback *models.Feedback) error {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	r.logger.Info("Updating 'Feedback'", log.M{"feedbackID": feedback.ID})
+
+	feedback.UpdatedAt = now()
+
+	var entry *models.AuditEntry
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// The row is locked, so the snapshot is the state right before this change.
+		var before models.Feedback
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&before, feedback.ID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.ErrFeedbackNotFound
+		}
+
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		err = r.open(&before)
+		if err != nil {
+			return err
+		}
+
+		sealed, err := r.seal(feedback)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Save(sealed).Error
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		entry, err = audit.NewEntry(ctx, audit.EntityFeedback, audit.ActionUpdate, feedback.ID, &before, feedback)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return r.audit.record(tx, entry)
+	})
+	if err != nil {
+		r.logger.Error("Failed to update feedback in DB", log.M{
+			"feedbackID": feedback.ID,
+			"error":      err.Error(),
+		})
+
+		return fmt.Errorf("failed to update feedback in DB: %w", err)
+	}
+
+	r.replicas.Wrote(ctx)
+	r.audit.committed(entry)
+
+	return nil
+}
+
+// Delete removes the feedbacks with their audit entries in one transaction,
+// unknown IDs are skipped. It returns the count of deleted feedbacks.
+// The personal data isn't decrypted, it's redacted in the audit log anyway,
+// so the rows with lost keys can be deleted too.
+func (r *FeedbackRepository) Delete(ctx context.Context, feedbackIDs []uuid.UUID) (int, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	r.logger.Info("Deleting 'Feedback's", log.M{"count": len(feedbackIDs)})
+
+	var entries []*models.AuditEntry
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var feedbacks []*models.Feedback
+
+		for _, chunk := range chunks(feedbackIDs, maxInList) {
+			var found []*models.Feedback
+
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", chunk).Find(&found).Error
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			feedbacks = append(feedbacks, found...)
+		}
+
+		if len(feedbacks) == 0 {
+			return nil
+		}
+
+		deleted := make([]uuid.UUID, 0, len(feedbacks))
+
+		for _, feedback := range feedbacks {
+			entry, err := audit.NewEntry(ctx, audit.EntityFeedback, audit.ActionDelete, feedback.ID, feedback, nil)
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			entries = append(entries, entry)
+			deleted = append(deleted, feedback.ID)
+		}
+
+		for _, chunk := range chunks(deleted, maxInList) {
+			//nolint:exhaustivestruct,exhaustruct
+			err := tx.Where("id IN ?", chunk).Delete(&models.Feedback{}).Error
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+		}
+
+		return r.audit.record(tx, entries...)
+	})
+	if err != nil {
+		r.logger.Error("Failed to delete feedbacks from DB", log.M{"err": err})
+
+		return 0, fmt.Errorf("failed to delete feedbacks from DB: %w", err)
+	}
+
+	r.replicas.Wrote(ctx)
+	r.audit.committed(entries...)
+
+	r.logger.Info("Feedbacks deleted", log.M{"count": len(entries)})
+
+	return len(entries), nil
+}
+
+func (r *FeedbackRepository) GetByID(ctx context.Context, feedbackID uuid.UUID) (*models.Feedback, error) {
 	var feedback models.Feedback
 
 	r.logger.Info("Getting 'Feedback' by ID", log.M{
 		"feedbackID": feedbackID,
 	})
 
-	err := r.db.First(&feedback, feedbackID).Error
+	err := r.replicas.read(ctx, r.db, r.timeout, func(db *gorm.DB) error {
+		return db.First(&feedback, feedbackID).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = models.ErrFeedbackNotFound
+	}
+
+	if err == nil {
+		// Decryption isn't a part of the read, the failed one doesn't mark the replica as down.
+		err = r.open(&feedback)
+	}
+
 	if err != nil {
 		r.logger.Error("Failed to get feedback from DB", log.M{
 			"feedbackID": feedbackID,
@@ -90,61 +315,207 @@ func (r *FeedbackRepository) GetByID(feedbackID uuid.UUID) (*models.Feedback, er
 	return &feedback, nil
 }
 
-func (r *FeedbackRepository) GetPage(limit int, next uuid.UUID) ([]*models.Feedback, uuid.UUID, error) {
+// GetPage is the keyset pagination by the primary key: the IDs are time-ordered (UUIDv7),
+// so the pages are in the order of creation. Random IDs (UUIDv4) of the rows created before
+// the generator are paged first by (created_at, id), then the pages continue by ID.
+func (r *FeedbackRepository) GetPage(
+	ctx context.Context,
+	limit int,
+	next models.PageCursor,
+	filter *models.FeedbackFilter,
+) ([]*models.Feedback, models.PageCursor, error) {
 	var (
 		feedbacks []*models.Feedback
-		cursor    uuid.UUID
+		cursor    models.PageCursor
 	)
 
-	r.logger.Info("Get page of 'Feedback's", log.M{"limit": limit, "next": next})
+	r.logger.Info("Get page of 'Feedback's", log.M{"limit": limit, "next": next.String()})
 
-	if next != uuid.Nil {
-		query := r.db.
-			Where("created_at > (SELECT created_at FROM feedbacks WHERE id = ?)", next).
-			Order("created_at").
-			Limit(limit).
-			Find(&feedbacks)
+	err := r.replicas.read(ctx, r.db, r.timeout, func(db *gorm.DB) error {
+		var legacy, ordered []*models.Feedback
 
-		if err := query.Error; err != nil {
-			r.logger.Error("Failed to get feedback page from DB", log.M{"error": err.Error()})
+		after := next.ID
 
-			return nil, uuid.Nil, fmt.Errorf("failed to get feedback page from DB: %w", err)
-		}
-	} else {
-		query := r.db.
-			Order("created_at").
-			Limit(limit).
-			Find(&feedbacks)
+		if after == uuid.Nil || !ids.IsV7(after) {
+			query := r.applyFilter(db, filter).Where(idVersion(db) + " <> '7'")
+
+			switch {
+			case !next.CreatedAt.IsZero():
+				// The feedback of the cursor could be deleted, so its time is taken from the cursor.
+				query = query.Where("(created_at, id) > (?, ?)", next.CreatedAt, after)
+			case after != uuid.Nil:
+				query = query.Where("(created_at, id) > ((SELECT created_at FROM feedbacks WHERE id = ?), ?)", after, after)
+			}
 
-		if err := query.Error; err != nil {
-			r.logger.Error("Failed to get feedback page from DB", log.M{"error": err.Error()})
+			err := query.Order("created_at").Order("id").Limit(limit).Find(&legacy).Error
+			if err != nil || len(legacy) == limit {
+				feedbacks = legacy
 
-			return nil, uuid.Nil, fmt.Errorf("failed to get feedback page from DB: %w", err)
+				return err //nolint:wrapcheck
+			}
+
+			// The legacy rows are over, the page continues from the first time-ordered ID.
+			after = uuid.Nil
+		}
+
+		query := r.applyFilter(db, filter).Where(idVersion(db) + " = '7'")
+		if after != uuid.Nil {
+			query = query.Where("id > ?", after)
 		}
+
+		err := query.Order("id").Limit(limit - len(legacy)).Find(&ordered).Error
+		feedbacks = legacy
+		feedbacks = append(feedbacks, ordered...)
+
+		return err //nolint:wrapcheck
+	})
+	if err != nil {
+		r.logger.Error("Failed to get feedback page from DB", log.M{"error": err.Error()})
+
+		return nil, cursor, fmt.Errorf("failed to get feedback page from DB: %w", err)
 	}
 
+	r.openAll(feedbacks)
+
 	if len(feedbacks) > 0 {
-		cursor = feedbacks[len(feedbacks)-1].ID
+		cursor = models.CursorAfter(feedbacks[len(feedbacks)-1])
 	}
 
-	r.logger.Info("Got page of 'Feedback's", log.M{"count": len(feedbacks), "cursor": cursor})
+	r.logger.Info("Got page of 'Feedback's", log.M{"count": len(feedbacks), "cursor": cursor.String()})
 
 	return feedbacks, cursor, nil
 }
 
-func (r *FeedbackRepository) GetAll() ([]*models.Feedback, error) {
-	var feedbacks []*models.Feedback
+// Iterate reads feedbacks ordered by creation in chunks and passes them to the callback,
+// every chunk is a separate query, so the memory doesn't depend on the table size.
+func (r *FeedbackRepository) Iterate(
+	ctx context.Context,
+	filter *models.FeedbackFilter,
+	chunkSize int,
+	callback func(feedbacks []*models.Feedback) error,
+) error {
+	var (
+		last  *models.Feedback
+		total int
+	)
+
+	r.logger.Info("Iterating 'Feedback's", log.M{"filter": filter, "chunkSize": chunkSize})
+
+	for {
+		var feedbacks []*models.Feedback
+
+		err := r.replicas.read(ctx, r.db, r.timeout, func(db *gorm.DB) error {
+			query := r.applyFilter(db, filter)
+			if last != nil {
+				// Keyset by (created_at, id), the timestamps could be equal.
+				query = query.Where("(created_at, id) > (?, ?)", last.CreatedAt, last.ID)
+			}
+
+			return query.Order("created_at").Order("id").Limit(chunkSize).Find(&feedbacks).Error
+		})
+		if err != nil {
+			r.logger.Error("Failed to iterate feedbacks from DB", log.M{"error": err.Error(), "read": total})
+
+			return fmt.Errorf("failed to iterate feedbacks from DB: %w", err)
+		}
+
+		if len(feedbacks) == 0 {
+			break
+		}
+
+		r.openAll(feedbacks)
+
+		total += len(feedbacks)
+
+		err = callback(feedbacks)
+		if err != nil {
+			return err
+		}
+
+		if len(feedbacks) < chunkSize {
+			break
+		}
+
+		last = feedbacks[len(feedbacks)-1]
+	}
+
+	r.logger.Info("Iterated 'Feedback's", log.M{"count": total})
+
+	return nil
+}
+
+func (r *FeedbackRepository) GetSentimentStats(ctx context.Context) ([]*models.SentimentStat, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var stats []*models.SentimentStat
 
-	r.logger.Info("Get all 'Feedback's", nil)
+	r.logger.Info("Get daily sentiment stats", nil)
 
-	err := r.db.Order("created_at").Find(&feedbacks).Error
+	//nolint:exhaustivestruct,exhaustruct
+	err := db.Model(&models.Feedback{}).
+		Select(
+			dayColumn(db, "created_at") + " AS day, " +
+				"source_host AS source, " +
+				"AVG(sentiment_score) AS average, " +
+				"COUNT(*) AS count",
+		).
+		Group("day, source").
+		Order("day, source").
+		Scan(&stats).Error
 	if err != nil {
-		r.logger.Error("Failed to get feedback from DB", log.M{"error": err.Error()})
+		r.logger.Error("Failed to get sentiment stats from DB", log.M{"error": err.Error()})
+
+		return nil, fmt.Errorf("failed to get sentiment stats from DB: %w", err)
+	}
+
+	r.logger.Info("Got daily sentiment stats", log.M{"count": len(stats)})
+
+	return stats, nil
+}
+
+func (r *FeedbackRepository) applyFilter(query *gorm.DB, filter *models.FeedbackFilter) *gorm.DB {
+	if filter == nil {
+		return query
+	}
+
+	if filter.Sentiment != "" {
+		query = query.Where("sentiment_label = ?", filter.Sentiment)
+	}
+
+	if filter.Search != "" {
+		query = search(query, "feedback_text", filter.Search)
+	}
 
-		return nil, fmt.Errorf("failed to get feedbacks from DB: %w", err)
+	if filter.Email != "" {
+		query = r.whereEmail(query, filter.Email)
 	}
 
-	r.logger.Info("Got all 'Feedback's", log.M{"count": len(feedbacks)})
+	return query
+}
+
+// conn returns DB session bound to the context with the query timeout.
+func (r *FeedbackRepository) conn(ctx context.Context) (*gorm.DB, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+
+	return r.db.WithContext(ctx), cancel
+}
+
+// maxInList bounds the IDs of one "IN" list, Postgres allows only 65535 bind parameters per query.
+const maxInList = 1000
+
+// chunks splits the IDs into the parts of at most size IDs.
+func chunks(ids []uuid.UUID, size int) [][]uuid.UUID {
+	parts := make([][]uuid.UUID, 0, (len(ids)+size-1)/size)
+
+	for len(ids) > size {
+		parts = append(parts, ids[:size])
+		ids = ids[size:]
+	}
+
+	if len(ids) > 0 {
+		parts = append(parts, ids)
+	}
 
-	return feedbacks, nil
+	return parts
 }
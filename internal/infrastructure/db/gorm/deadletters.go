@@ -0,0 +1,181 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// DeadLetterRepository is the queue of the events which the broker failed to publish.
+type DeadLetterRepository struct {
+	db      *gorm.DB
+	ids     ids.IDGenerator
+	timeout time.Duration
+	logger  log.Logger
+}
+
+// NewDeadLetterRepository makes the IDs by the generator, so the letters are listed by the ID in the order of failures.
+//
+//nolint:varnamelen
+func NewDeadLetterRepository(
+	db *gorm.DB,
+	generator ids.IDGenerator,
+	timeout time.Duration,
+	logger log.Logger,
+) *DeadLetterRepository {
+	return &DeadLetterRepository{
+		db:      db,
+		ids:     generator,
+		timeout: timeout,
+		logger:  logger.Named("gormDeadLetters"),
+	}
+}
+
+// Add sets the IDs and the time of the letters.
+func (r *DeadLetterRepository) Add(ctx context.Context, letters []*models.DeadLetter) error {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	for _, letter := range letters {
+		letter.ID = r.ids.New()
+		letter.CreatedAt = now()
+		letter.LastAttemptAt = letter.CreatedAt
+	}
+
+	err := db.CreateInBatches(letters, createBatchSize).Error
+	if err != nil {
+		r.logger.Error("Failed to add dead letters into DB", log.M{"err": err, "count": len(letters)})
+
+		return fmt.Errorf("failed to add dead letters into DB: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the letters after the ID, uuid.Nil is the start of the queue.
+func (r *DeadLetterRepository) List(ctx context.Context, limit int, after uuid.UUID) ([]*models.DeadLetter, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	letters := make([]*models.DeadLetter, 0, limit)
+
+	query := db.Order("id").Limit(limit)
+	if after != uuid.Nil {
+		query = query.Where("id > ?", after)
+	}
+
+	err := query.Find(&letters).Error
+	if err != nil {
+		r.logger.Error("Failed to list dead letters from DB", log.M{"error": err.Error()})
+
+		return nil, fmt.Errorf("failed to list dead letters from DB: %w", err)
+	}
+
+	return letters, nil
+}
+
+// GetByIDs skips the unknown IDs.
+func (r *DeadLetterRepository) GetByIDs(ctx context.Context, letterIDs []uuid.UUID) ([]*models.DeadLetter, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	letters := make([]*models.DeadLetter, 0, len(letterIDs))
+
+	err := db.Where("id IN ?", letterIDs).Order("id").Find(&letters).Error
+	if err != nil {
+		r.logger.Error("Failed to get dead letters from DB", log.M{"error": err.Error()})
+
+		return nil, fmt.Errorf("failed to get dead letters from DB: %w", err)
+	}
+
+	return letters, nil
+}
+
+// Retried saves the error of the failed replay and counts the attempt.
+func (r *DeadLetterRepository) Retried(ctx context.Context, letterID uuid.UUID, reason string) error {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	//nolint:exhaustivestruct,exhaustruct
+	err := db.Model(&models.DeadLetter{}).Where("id = ?", letterID).Updates(map[string]interface{}{
+		"error":           reason,
+		"attempts":        gorm.Expr("attempts + 1"),
+		"last_attempt_at": now(),
+	}).Error
+	if err != nil {
+		r.logger.Error("Failed to update dead letter in DB", log.M{"letterID": letterID, "error": err.Error()})
+
+		return fmt.Errorf("failed to update dead letter in DB: %w", err)
+	}
+
+	return nil
+}
+
+// Delete skips the unknown IDs and returns the count of the deleted letters.
+func (r *DeadLetterRepository) Delete(ctx context.Context, letterIDs []uuid.UUID) (int, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	//nolint:exhaustivestruct,exhaustruct
+	result := db.Where("id IN ?", letterIDs).Delete(&models.DeadLetter{})
+	if result.Error != nil {
+		r.logger.Error("Failed to delete dead letters from DB", log.M{"error": result.Error.Error()})
+
+		return 0, fmt.Errorf("failed to delete dead letters from DB: %w", result.Error)
+	}
+
+	return int(result.RowsAffected), nil
+}
+
+// DeleteBefore deletes the letters added before the time, the zero time means all of them.
+func (r *DeadLetterRepository) DeleteBefore(ctx context.Context, before time.Time) (int, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	query := db.Where("1 = 1")
+	if !before.IsZero() {
+		query = db.Where("created_at < ?", before.UTC())
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	result := query.Delete(&models.DeadLetter{})
+	if result.Error != nil {
+		r.logger.Error("Failed to purge dead letters from DB", log.M{"error": result.Error.Error()})
+
+		return 0, fmt.Errorf("failed to purge dead letters from DB: %w", result.Error)
+	}
+
+	return int(result.RowsAffected), nil
+}
+
+// Count is the depth of the queue.
+func (r *DeadLetterRepository) Count(ctx context.Context) (int, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var count int64
+
+	//nolint:exhaustivestruct,exhaustruct
+	err := db.Model(&models.DeadLetter{}).Count(&count).Error
+	if err != nil {
+		r.logger.Error("Failed to count dead letters in DB", log.M{"error": err.Error()})
+
+		return 0, fmt.Errorf("failed to count dead letters in DB: %w", err)
+	}
+
+	return int(count), nil
+}
+
+// conn returns DB session bound to the context with the query timeout.
+func (r *DeadLetterRepository) conn(ctx context.Context) (*gorm.DB, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+
+	return r.db.WithContext(ctx), cancel
+}
@@ -0,0 +1,162 @@
+package gorm
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Name of the SQLite dialector, the repositories are shared with db/sqlite.
+const sqliteDialect = "sqlite"
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// now returns the time in UTC, so text timestamps of SQLite stay comparable.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// dayColumn formats the timestamp column as YYYY-MM-DD of the UTC day.
+func dayColumn(db *gorm.DB, column string) string {
+	if db.Dialector.Name() == sqliteDialect {
+		return "strftime('%Y-%m-%d', " + column + ")"
+	}
+
+	return "to_char(date_trunc('day', " + utc(column) + "), 'YYYY-MM-DD')"
+}
+
+// utc converts the timestamptz column of Postgres to UTC,
+// so the periods don't depend on the time zone of the session.
+// SQLite keeps the timestamps in UTC, see now.
+func utc(column string) string {
+	return "(" + column + " AT TIME ZONE 'UTC')"
+}
+
+// dayStart is the start of the UTC day of the date column as timestamptz,
+// so the date columns are formatted by the same functions as the timestamps.
+func dayStart(db *gorm.DB, column string) string {
+	if db.Dialector.Name() == sqliteDialect {
+		return column
+	}
+
+	return "(" + column + "::timestamp AT TIME ZONE 'UTC')"
+}
+
+// idVersion is the version digit of the UUID in the id column, see FeedbackRepository.GetPage.
+// "<> '7'" is the same as in the partial index of the random IDs, so the index is used.
+func idVersion(db *gorm.DB) string {
+	if db.Dialector.Name() == sqliteDialect {
+		return "substr(id, 15, 1)"
+	}
+
+	return "substr(id::text, 15, 1)"
+}
+
+// weekColumn formats the timestamp column as YYYY-MM-DD of the Monday of the UTC week.
+func weekColumn(db *gorm.DB, column string) string {
+	if db.Dialector.Name() == sqliteDialect {
+		// The next Sunday (or the same day) minus 6 days.
+		return "date(" + column + ", 'weekday 0', '-6 days')"
+	}
+
+	return "to_char(date_trunc('week', " + utc(column) + "), 'YYYY-MM-DD')"
+}
+
+// monthColumn formats the timestamp column as YYYY-MM of the UTC month.
+func monthColumn(db *gorm.DB, column string) string {
+	if db.Dialector.Name() == sqliteDialect {
+		return "strftime('%Y-%m', " + column + ")"
+	}
+
+	return "to_char(date_trunc('month', " + utc(column) + "), 'YYYY-MM')"
+}
+
+// joinTags joins every tag of the JSON column as the row "tag" with the column "value",
+// the rows without tags are joined with NULL.
+func joinTags(db *gorm.DB, column string) string {
+	// The column is '' or 'null' for the rows without tags.
+	tags := "CASE WHEN " + column + " LIKE '[%' THEN " + column + " ELSE '[]' END"
+
+	if db.Dialector.Name() == sqliteDialect {
+		return "LEFT JOIN json_each(" + tags + ") AS tag ON true"
+	}
+
+	return "LEFT JOIN LATERAL jsonb_array_elements_text((" + tags + ")::jsonb) AS tag(value) ON true"
+}
+
+// scannedTime scans the timestamp of the aggregate: SQLite returns it as text,
+// the type of the column is lost by MIN and MAX.
+type scannedTime struct {
+	time.Time
+}
+
+// Layouts of the timestamps written by the SQLite driver.
+var sqliteTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
+func (t *scannedTime) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case time.Time:
+		t.Time = value
+
+		return nil
+	case nil:
+		t.Time = time.Time{}
+
+		return nil
+	case []byte:
+		return t.parse(string(value))
+	case string:
+		return t.parse(value)
+	default:
+		return fmt.Errorf("can't scan %T as time", value) //nolint:goerr113
+	}
+}
+
+// Value is needed by Gorm to detect the type of the field.
+func (t scannedTime) Value() (driver.Value, error) {
+	return t.Time, nil
+}
+
+func (t *scannedTime) parse(value string) error {
+	for _, layout := range sqliteTimeLayouts {
+		parsed, err := time.Parse(layout, value)
+		if err == nil {
+			t.Time = parsed.UTC()
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("can't parse time '%s'", value) //nolint:goerr113
+}
+
+// search uses full-text search of Postgres,
+// other dialects fall back to LIKE for every word of the text.
+func search(query *gorm.DB, column, text string) *gorm.DB {
+	if query.Dialector.Name() != sqliteDialect {
+		return query.Where("to_tsvector('simple', "+column+") @@ plainto_tsquery('simple', ?)", text)
+	}
+
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		query = query.Where("LOWER("+column+`) LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(word)+"%")
+	}
+
+	return query
+}
+
+// minMaxFunctions are the scalar functions of the lesser and the greater of two values.
+func minMaxFunctions(db *gorm.DB) (string, string) {
+	if db.Dialector.Name() == sqliteDialect {
+		return "MIN", "MAX"
+	}
+
+	return "LEAST", "GREATEST"
+}
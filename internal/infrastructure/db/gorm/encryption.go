@@ -0,0 +1,229 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+var ErrNoEncryption = errors.New("encryption keys aren't configured")
+
+// Encrypted columns, the names are the associated data with the row ID,
+// so the value can't be moved to another column or row.
+const (
+	customerNameColumn = "customer_name"
+	emailColumn        = "email"
+)
+
+// seal returns the copy of the feedback for saving: with encrypted personal data
+// and the blind index of the email. The feedback of the caller stays plaintext.
+// Nil cipher keeps the values, but it refuses the values with the prefix of the encrypted ones too.
+func (r *FeedbackRepository) seal(feedback *models.Feedback) (*models.Feedback, error) {
+	var err error
+
+	sealed := *feedback
+
+	sealed.CustomerName, err = r.cipher.Encrypt(feedback.CustomerName, associatedData(customerNameColumn, feedback))
+	if err != nil {
+		return nil, fmt.Errorf("can't encrypt %s: %w", customerNameColumn, err)
+	}
+
+	sealed.Email, err = r.cipher.Encrypt(feedback.Email, associatedData(emailColumn, feedback))
+	if err != nil {
+		return nil, fmt.Errorf("can't encrypt %s: %w", emailColumn, err)
+	}
+
+	sealed.EmailIndex = r.cipher.BlindIndex(feedback.Email)
+
+	return &sealed, nil
+}
+
+func (r *FeedbackRepository) sealAll(feedbacks []*models.Feedback) ([]*models.Feedback, error) {
+	sealed := make([]*models.Feedback, 0, len(feedbacks))
+
+	for _, feedback := range feedbacks {
+		sealedFeedback, err := r.seal(feedback)
+		if err != nil {
+			return nil, err
+		}
+
+		sealed = append(sealed, sealedFeedback)
+	}
+
+	return sealed, nil
+}
+
+// open decrypts the read feedbacks in place, plaintext values are kept as is,
+// they are written before the encryption was on.
+func (r *FeedbackRepository) open(feedbacks ...*models.Feedback) error {
+	for _, feedback := range feedbacks {
+		var err error
+
+		feedback.CustomerName, err = r.cipher.Decrypt(feedback.CustomerName, associatedData(customerNameColumn, feedback))
+		if err != nil {
+			return fmt.Errorf("can't decrypt %s of feedback '%s': %w", customerNameColumn, feedback.ID, err)
+		}
+
+		feedback.Email, err = r.cipher.Decrypt(feedback.Email, associatedData(emailColumn, feedback))
+		if err != nil {
+			return fmt.Errorf("can't decrypt %s of feedback '%s': %w", emailColumn, feedback.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// openAll decrypts the feedbacks of the lists and the exports in place. The value which can't be decrypted
+// is logged and replaced by empty string, so one broken row doesn't fail the whole page.
+func (r *FeedbackRepository) openAll(feedbacks []*models.Feedback) {
+	for _, feedback := range feedbacks {
+		for column, value := range map[string]*string{
+			customerNameColumn: &feedback.CustomerName,
+			emailColumn:        &feedback.Email,
+		} {
+			plaintext, err := r.cipher.Decrypt(*value, associatedData(column, feedback))
+			if err != nil {
+				r.logger.Error("Can't decrypt, the value is left empty", log.M{
+					"feedbackID": feedback.ID,
+					"column":     column,
+					"err":        err,
+				})
+			}
+
+			*value = plaintext
+		}
+	}
+}
+
+// whereEmail matches the email by the blind index, the rows without index
+// (saved before the encryption was on) are matched by the plaintext.
+func (r *FeedbackRepository) whereEmail(query *gorm.DB, email string) *gorm.DB {
+	if r.cipher == nil {
+		return query.Where("LOWER(email) = LOWER(?)", email)
+	}
+
+	return query.Where(
+		"(email_index = ? OR (email_index = '' AND LOWER(email) = LOWER(?)))",
+		r.cipher.BlindIndex(email), email,
+	)
+}
+
+// RotateKeys re-encrypts the personal data of the rows which aren't encrypted by the active key
+// or have outdated blind index, by batches in separate transactions. It isn't a change of data,
+// so nothing is written to the audit log and updated_at is kept.
+// It returns the count of re-encrypted rows.
+func (r *FeedbackRepository) RotateKeys(ctx context.Context, batchSize int) (int, error) {
+	if r.cipher == nil {
+		return 0, ErrNoEncryption
+	}
+
+	var (
+		last    *models.Feedback
+		rotated int
+	)
+
+	r.logger.Info("Rotating encryption keys", log.M{"activeKey": r.cipher.ActiveKeyID(), "batchSize": batchSize})
+
+	for {
+		var feedbacks []*models.Feedback
+
+		err := withTimeout(ctx, r.db, r.timeout, func(db *gorm.DB) error {
+			query := db.Select("id", "customer_name", "email", "email_index", "created_at")
+			if last != nil {
+				query = query.Where("(created_at, id) > (?, ?)", last.CreatedAt, last.ID)
+			}
+
+			return query.Order("created_at").Order("id").Limit(batchSize).Find(&feedbacks).Error
+		})
+		if err != nil {
+			return rotated, fmt.Errorf("failed to read feedbacks for key rotation: %w", err)
+		}
+
+		if len(feedbacks) == 0 {
+			break
+		}
+
+		count, err := r.rotateBatch(ctx, feedbacks)
+		rotated += count
+
+		if err != nil {
+			return rotated, err
+		}
+
+		r.logger.Info("Rotated batch", log.M{"rotated": rotated})
+
+		if len(feedbacks) < batchSize {
+			break
+		}
+
+		last = feedbacks[len(feedbacks)-1]
+	}
+
+	r.logger.Info("Encryption keys are rotated", log.M{"rotated": rotated})
+
+	return rotated, nil
+}
+
+func (r *FeedbackRepository) rotateBatch(ctx context.Context, feedbacks []*models.Feedback) (int, error) {
+	rotated := 0
+
+	err := withTimeout(ctx, r.db, r.timeout, func(db *gorm.DB) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			for _, stored := range feedbacks {
+				plain := *stored
+
+				// The broken row is skipped, it can't be re-encrypted, but it doesn't stop the rotation.
+				err := r.open(&plain)
+				if err != nil {
+					r.logger.Error("Can't decrypt, the feedback is skipped", log.M{"feedbackID": stored.ID, "err": err})
+
+					continue
+				}
+
+				if r.cipher.Current(stored.CustomerName) && r.cipher.Current(stored.Email) &&
+					stored.EmailIndex == r.cipher.BlindIndex(plain.Email) {
+					continue
+				}
+
+				sealed, err := r.seal(&plain)
+				if err != nil {
+					return err
+				}
+
+				// The row is updated only if it isn't changed since the read,
+				// otherwise it's already saved with the active key.
+				//nolint:exhaustivestruct,exhaustruct
+				result := tx.Model(&models.Feedback{}).
+					Where("id = ? AND customer_name = ? AND email = ?", stored.ID, stored.CustomerName, stored.Email).
+					UpdateColumns(map[string]interface{}{
+						customerNameColumn: sealed.CustomerName,
+						emailColumn:        sealed.Email,
+						"email_index":      sealed.EmailIndex,
+					})
+				if result.Error != nil {
+					return fmt.Errorf("failed to save re-encrypted feedback '%s': %w", stored.ID, result.Error)
+				}
+
+				rotated += int(result.RowsAffected)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		r.logger.Error("Failed to rotate batch", log.M{"err": err})
+
+		return 0, err
+	}
+
+	return rotated, nil
+}
+
+func associatedData(column string, feedback *models.Feedback) string {
+	return "feedbacks." + column + ":" + feedback.ID.String()
+}
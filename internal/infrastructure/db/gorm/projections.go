@@ -0,0 +1,388 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// ProjectionRepository keeps the read models built by the worker from the feedback events:
+// the daily counters per source host, the search index and the latest feedbacks per source host.
+type ProjectionRepository struct {
+	db       *gorm.DB
+	replicas *Replicas
+	timeout  time.Duration
+	logger   log.Logger
+}
+
+// NewProjectionRepository reads from the replicas like FeedbackRepository, nil replicas mean the primary only.
+//
+//nolint:varnamelen
+func NewProjectionRepository(db *gorm.DB, replicas *Replicas, timeout time.Duration, logger log.Logger) *ProjectionRepository {
+	return &ProjectionRepository{
+		db:       db,
+		replicas: replicas,
+		timeout:  timeout,
+		logger:   logger.Named("projections"),
+	}
+}
+
+// searchRow is the row of projection_search, it keeps the group of the feedback in the counters.
+type searchRow struct {
+	FeedbackID     uuid.UUID
+	Day            string
+	SourceHost     string
+	SentimentLabel string
+	SentimentScore float64
+	CreatedAt      time.Time
+	Document       string
+}
+
+// Apply writes the event to all projections by one transaction, it returns false for the applied event.
+// The feedback of the event must have SourceHost. latest is the number of the feedbacks kept per source host.
+func (r *ProjectionRepository) Apply(ctx context.Context, event *models.FeedbackEvent, latest int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	applied := true
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec(
+			"INSERT INTO projection_events (event_id, applied_at) VALUES (?, ?) ON CONFLICT (event_id) DO NOTHING",
+			event.ID, now(),
+		)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			applied = false
+
+			return nil
+		}
+
+		old, err := r.searchRow(tx, event.FeedbackID)
+		if err != nil {
+			return err
+		}
+
+		if old != nil {
+			err = r.count(tx, old, -1)
+			if err != nil {
+				return err
+			}
+		}
+
+		if event.Type == models.FeedbackDeleted {
+			return r.delete(tx, event.FeedbackID)
+		}
+
+		return r.save(tx, event, old, latest)
+	})
+	if err != nil {
+		r.logger.Error("Failed to apply event", log.M{"err": err, "eventID": event.ID, "type": event.Type})
+
+		return false, fmt.Errorf("failed to apply event to projections: %w", err)
+	}
+
+	return applied, nil
+}
+
+// DeleteEventsBefore removes the IDs of the events applied before the time, the event redelivered after it
+// is applied again.
+func (r *ProjectionRepository) DeleteEventsBefore(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result := r.db.WithContext(ctx).Exec("DELETE FROM projection_events WHERE applied_at < ?", before.UTC())
+	if result.Error != nil {
+		r.logger.Error("Failed to delete old projection events", log.M{"error": result.Error.Error()})
+
+		return 0, fmt.Errorf("failed to delete old projection events: %w", result.Error)
+	}
+
+	return int(result.RowsAffected), nil
+}
+
+func (r *ProjectionRepository) searchRow(tx *gorm.DB, feedbackID uuid.UUID) (*searchRow, error) {
+	var row searchRow
+
+	err := tx.Table("projection_search").
+		Select(
+			"feedback_id, "+dayColumn(tx, dayStart(tx, "day"))+" AS day, source_host, sentiment_label, sentiment_score, created_at, document",
+		).
+		Where("feedback_id = ?", feedbackID).
+		Take(&row).Error
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, nil //nolint:nilnil
+	case err != nil:
+		return nil, err //nolint:wrapcheck
+	default:
+		return &row, nil
+	}
+}
+
+// save writes the feedback to the search index, the counters and the latest feedbacks,
+// the changed feedback keeps its day and creation time. The feedback is bucketed by its creation time,
+// the time of the event is used for the events without it (the schemas before v2).
+func (r *ProjectionRepository) save(tx *gorm.DB, event *models.FeedbackEvent, old *searchRow, latest int) error {
+	feedback := event.Feedback
+
+	createdAt := feedback.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = event.Time
+	}
+
+	createdAt = createdAt.UTC()
+
+	row := &searchRow{
+		FeedbackID:     event.FeedbackID,
+		Day:            createdAt.Format("2006-01-02"),
+		SourceHost:     feedback.SourceHost,
+		SentimentLabel: feedback.SentimentLabel,
+		SentimentScore: feedback.SentimentScore,
+		CreatedAt:      createdAt,
+		Document:       feedback.FeedbackText,
+	}
+
+	if old != nil {
+		row.Day, row.CreatedAt = old.Day, old.CreatedAt
+	}
+
+	err := r.count(tx, row, 1)
+	if err != nil {
+		return err
+	}
+
+	err = r.delete(tx, row.FeedbackID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Exec(
+		"INSERT INTO projection_search "+
+			"(feedback_id, day, source_host, sentiment_label, sentiment_score, created_at, document) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?)",
+		row.FeedbackID, row.Day, row.SourceHost, row.SentimentLabel, row.SentimentScore, row.CreatedAt, row.Document,
+	).Error
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	err = tx.Exec(
+		"INSERT INTO projection_latest_feedbacks "+
+			"(feedback_id, source_host, feedback_text, sentiment_label, created_at) VALUES (?, ?, ?, ?, ?)",
+		row.FeedbackID, row.SourceHost, row.Document, row.SentimentLabel, row.CreatedAt,
+	).Error
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	// The feedbacks older than the latest ones of the source host are trimmed.
+	return tx.Exec(
+		"DELETE FROM projection_latest_feedbacks WHERE source_host = ? AND feedback_id NOT IN ("+
+			"SELECT feedback_id FROM projection_latest_feedbacks WHERE source_host = ? "+
+			"ORDER BY created_at DESC, feedback_id DESC LIMIT ?)",
+		row.SourceHost, row.SourceHost, latest,
+	).Error
+}
+
+func (r *ProjectionRepository) delete(tx *gorm.DB, feedbackID uuid.UUID) error {
+	err := tx.Exec("DELETE FROM projection_search WHERE feedback_id = ?", feedbackID).Error
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return tx.Exec("DELETE FROM projection_latest_feedbacks WHERE feedback_id = ?", feedbackID).Error
+}
+
+// count adds the feedback to the counters of its group or removes it by the negative delta.
+// first_at and last_at aren't narrowed by the removal, they are the bounds of the group.
+func (r *ProjectionRepository) count(tx *gorm.DB, row *searchRow, delta int) error {
+	if delta < 0 {
+		err := tx.Exec(
+			"UPDATE projection_daily_sources SET count = count - 1, score_sum = score_sum - ? "+
+				"WHERE day = ? AND source_host = ? AND sentiment_label = ?",
+			row.SentimentScore, row.Day, row.SourceHost, row.SentimentLabel,
+		).Error
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return tx.Exec(
+			"DELETE FROM projection_daily_sources WHERE day = ? AND source_host = ? AND sentiment_label = ? AND count <= 0",
+			row.Day, row.SourceHost, row.SentimentLabel,
+		).Error
+	}
+
+	least, greatest := minMaxFunctions(tx)
+
+	return tx.Exec(
+		"INSERT INTO projection_daily_sources "+
+			"(day, source_host, sentiment_label, count, score_sum, first_at, last_at) VALUES (?, ?, ?, 1, ?, ?, ?) "+
+			"ON CONFLICT (day, source_host, sentiment_label) DO UPDATE SET "+
+			"count = projection_daily_sources.count + 1, "+
+			"score_sum = projection_daily_sources.score_sum + excluded.score_sum, "+
+			"first_at = "+least+"(projection_daily_sources.first_at, excluded.first_at), "+
+			"last_at = "+greatest+"(projection_daily_sources.last_at, excluded.last_at)",
+		row.Day, row.SourceHost, row.SentimentLabel, row.SentimentScore, row.CreatedAt, row.CreatedAt,
+	).Error
+}
+
+// GetStats answers the stats by the source host and the period with the sentiment and search filters:
+// the counters are read without the search, the search index is scanned with it. See stats.Service.
+func (r *ProjectionRepository) GetStats(
+	ctx context.Context,
+	groupBy []string,
+	filter *models.FeedbackFilter,
+) ([]*models.FeedbackStat, error) {
+	var rows []*struct {
+		Source  string
+		Period  string
+		Count   int64
+		FirstAt scannedTime
+		LastAt  scannedTime
+	}
+
+	r.logger.Info("Get projected stats", log.M{"groupBy": groupBy, "filter": filter})
+
+	err := r.replicas.read(ctx, r.db, r.timeout, func(db *gorm.DB) error {
+		columns := []string{
+			"COALESCE(SUM(count), 0) AS count",
+			"MIN(first_at) AS first_at",
+			"MAX(last_at) AS last_at",
+		}
+		groups := make([]string, 0, len(groupBy))
+		query := db.Table("projection_daily_sources")
+
+		if filter != nil && filter.Search != "" {
+			columns = []string{"COUNT(*) AS count", "MIN(created_at) AS first_at", "MAX(created_at) AS last_at"}
+			query = search(db.Table("projection_search"), "document", filter.Search)
+		}
+
+		if filter != nil && filter.Sentiment != "" {
+			query = query.Where("sentiment_label = ?", filter.Sentiment)
+		}
+
+		for _, dimension := range groupBy {
+			var column, alias string
+
+			switch dimension {
+			case models.StatsBySource:
+				column, alias = "source_host", "source"
+			case models.StatsByDay:
+				column, alias = dayColumn(db, dayStart(db, "day")), "period"
+			case models.StatsByWeek:
+				column, alias = weekColumn(db, dayStart(db, "day")), "period"
+			case models.StatsByMonth:
+				column, alias = monthColumn(db, dayStart(db, "day")), "period"
+			default:
+				return fmt.Errorf("dimension '%s' isn't projected", dimension) //nolint:goerr113
+			}
+
+			columns = append(columns, column+" AS "+alias)
+			groups = append(groups, alias)
+		}
+
+		query = query.Select(columns)
+
+		for _, group := range groups {
+			query = query.Group(group).Order(group)
+		}
+
+		return query.Scan(&rows).Error
+	})
+	if err != nil {
+		r.logger.Error("Failed to get projected stats from DB", log.M{"error": err.Error()})
+
+		return nil, fmt.Errorf("failed to get projected stats from DB: %w", err)
+	}
+
+	stats := make([]*models.FeedbackStat, 0, len(rows))
+
+	for _, row := range rows {
+		group := make(map[string]string, len(groupBy))
+
+		for _, dimension := range groupBy {
+			if dimension == models.StatsBySource {
+				group[dimension] = row.Source
+			} else {
+				group[dimension] = row.Period
+			}
+		}
+
+		stats = append(stats, &models.FeedbackStat{
+			Group:   group,
+			Count:   row.Count,
+			FirstAt: row.FirstAt.UTC(),
+			LastAt:  row.LastAt.UTC(),
+		})
+	}
+
+	r.logger.Info("Got projected stats", log.M{"count": len(stats)})
+
+	return stats, nil
+}
+
+// GetSentimentStats is FeedbackRepository.GetSentimentStats by the counters.
+func (r *ProjectionRepository) GetSentimentStats(ctx context.Context) ([]*models.SentimentStat, error) {
+	var stats []*models.SentimentStat
+
+	err := r.replicas.read(ctx, r.db, r.timeout, func(db *gorm.DB) error {
+		return db.Table("projection_daily_sources").
+			Select(
+				dayColumn(db, dayStart(db, "day")) + " AS day, " +
+					"source_host AS source, " +
+					"SUM(score_sum) / SUM(count) AS average, " +
+					"SUM(count) AS count",
+			).
+			Group("1, 2").
+			Order("1, 2").
+			Scan(&stats).Error
+	})
+	if err != nil {
+		r.logger.Error("Failed to get projected sentiment stats from DB", log.M{"error": err.Error()})
+
+		return nil, fmt.Errorf("failed to get projected sentiment stats from DB: %w", err)
+	}
+
+	return stats, nil
+}
+
+// GetLatest returns the latest feedbacks of the source host from the newest one.
+func (r *ProjectionRepository) GetLatest(
+	ctx context.Context,
+	sourceHost string,
+	limit int,
+) ([]*models.LatestFeedback, error) {
+	latest := make([]*models.LatestFeedback, 0, limit)
+
+	err := r.replicas.read(ctx, r.db, r.timeout, func(db *gorm.DB) error {
+		return db.Table("projection_latest_feedbacks").
+			Select("feedback_id AS id, source_host AS source, feedback_text, sentiment_label, created_at").
+			Where("source_host = ?", sourceHost).
+			Order("created_at DESC, feedback_id DESC").
+			Limit(limit).
+			Scan(&latest).Error
+	})
+	if err != nil {
+		r.logger.Error("Failed to get latest feedbacks from DB", log.M{"error": err.Error()})
+
+		return nil, fmt.Errorf("failed to get latest feedbacks from DB: %w", err)
+	}
+
+	for _, feedback := range latest {
+		feedback.CreatedAt = feedback.CreatedAt.UTC()
+	}
+
+	return latest, nil
+}
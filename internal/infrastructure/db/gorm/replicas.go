@@ -0,0 +1,244 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type replica struct {
+	name    string
+	db      *gorm.DB
+	healthy atomic.Bool
+}
+
+// Replicas routes reads to the healthy replicas by round robin.
+// The primary is used when all replicas are down and for the clients
+// that wrote something during the read-your-writes window,
+// replicas could still not have their writes. The writes served by this instance are remembered here,
+// the writes served by other instances are known from the time sent back by the client, see reqctx.LastWrite.
+type Replicas struct {
+	replicas []*replica
+	next     atomic.Uint32
+	window   time.Duration
+	timeout  time.Duration
+
+	mu     sync.Mutex
+	writes map[string]time.Time // Client → time of the last write.
+
+	stop   chan struct{}
+	logger log.Logger
+}
+
+// NewReplicas starts the health checks of replicas with the interval,
+// names are used only in logs, so they must not contain passwords.
+func NewReplicas(
+	names []string,
+	dbs []*gorm.DB,
+	window, interval, timeout time.Duration,
+	logger log.Logger,
+) *Replicas {
+	replicas := &Replicas{
+		replicas: make([]*replica, 0, len(dbs)),
+		window:   window,
+		timeout:  timeout,
+		writes:   make(map[string]time.Time),
+		stop:     make(chan struct{}),
+		logger:   logger.Named("replicas"),
+	}
+
+	for i, db := range dbs {
+		//nolint:exhaustivestruct,exhaustruct
+		replicas.replicas = append(replicas.replicas, &replica{name: names[i], db: db})
+	}
+
+	replicas.check()
+
+	go replicas.run(interval)
+
+	return replicas
+}
+
+// Close stops the health checks and closes the connections to the replicas.
+func (r *Replicas) Close() error {
+	close(r.stop)
+
+	for _, replica := range r.replicas {
+		sqlDB, err := replica.db.DB()
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		err = sqlDB.Close()
+		if err != nil {
+			return fmt.Errorf("can't close replica '%s': %w", replica.name, err)
+		}
+	}
+
+	return nil
+}
+
+// Wrote remembers the write of the client from the context.
+func (r *Replicas) Wrote(ctx context.Context) {
+	if r == nil {
+		return
+	}
+
+	client := clientKey(ctx)
+	if client == "" {
+		return
+	}
+
+	r.mu.Lock()
+	r.writes[client] = time.Now()
+	r.mu.Unlock()
+}
+
+// pick returns the replica for the read or nil for the primary.
+func (r *Replicas) pick(ctx context.Context) *replica {
+	if r == nil || len(r.replicas) == 0 || reqctx.Primary(ctx) || r.recentlyWrote(ctx) {
+		return nil
+	}
+
+	start := r.next.Add(1)
+
+	for i := range r.replicas {
+		replica := r.replicas[(int(start)+i)%len(r.replicas)]
+		if replica.healthy.Load() {
+			return replica
+		}
+	}
+
+	return nil
+}
+
+func (r *Replicas) recentlyWrote(ctx context.Context) bool {
+	// The time in the future is from the client, it doesn't keep the reads on the primary longer than the window.
+	if wroteAt := reqctx.LastWrite(ctx); !wroteAt.IsZero() {
+		if since := time.Since(wroteAt); since < r.window && since > -r.window {
+			return true
+		}
+	}
+
+	client := clientKey(ctx)
+	if client == "" {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wroteAt, ok := r.writes[client]
+
+	return ok && time.Since(wroteAt) < r.window
+}
+
+// failed marks the replica as down until the next successful health check.
+func (r *Replicas) failed(replica *replica, err error) {
+	if replica.healthy.Swap(false) {
+		r.logger.Warn("Replica is down, reading from the primary", log.M{"replica": replica.name, "err": err})
+	}
+}
+
+func (r *Replicas) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.check()
+			r.forgetWrites()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *Replicas) check() {
+	for _, replica := range r.replicas {
+		err := r.ping(replica)
+
+		switch {
+		case err != nil && replica.healthy.Swap(false):
+			r.logger.Warn("Replica is down", log.M{"replica": replica.name, "err": err})
+		case err != nil:
+			r.logger.Debug("Replica is still down", log.M{"replica": replica.name, "err": err})
+		case !replica.healthy.Swap(true):
+			r.logger.Info("Replica is up", log.M{"replica": replica.name})
+		}
+	}
+}
+
+func (r *Replicas) ping(replica *replica) error {
+	sqlDB, err := replica.db.DB()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	return sqlDB.PingContext(ctx) //nolint:wrapcheck
+}
+
+// forgetWrites removes the writes older than the window.
+func (r *Replicas) forgetWrites() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for client, wroteAt := range r.writes {
+		if time.Since(wroteAt) >= r.window {
+			delete(r.writes, client)
+		}
+	}
+}
+
+// clientKey identifies the client by JWT subject or by IP address.
+func clientKey(ctx context.Context) string {
+	if subject := reqctx.ActorFrom(ctx).Subject; subject != "" {
+		return reqctx.Tenant(ctx) + "/sub:" + subject
+	}
+
+	if clientIP := reqctx.ClientIP(ctx); clientIP != "" {
+		return reqctx.Tenant(ctx) + "/ip:" + clientIP
+	}
+
+	return ""
+}
+
+// read runs the query on a replica and retries it on the primary
+// if the replica fails with something other than "not found".
+func (r *Replicas) read(
+	ctx context.Context,
+	primary *gorm.DB,
+	timeout time.Duration,
+	query func(db *gorm.DB) error,
+) error {
+	replica := r.pick(ctx)
+	if replica != nil {
+		err := withTimeout(ctx, replica.db, timeout, query)
+		if err == nil || errors.Is(err, gorm.ErrRecordNotFound) || ctx.Err() != nil {
+			return err
+		}
+
+		r.failed(replica, err)
+	}
+
+	return withTimeout(ctx, primary, timeout, query)
+}
+
+func withTimeout(ctx context.Context, db *gorm.DB, timeout time.Duration, query func(db *gorm.DB) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return query(db.WithContext(ctx))
+}
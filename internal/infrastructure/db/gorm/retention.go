@@ -0,0 +1,163 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// CountExpired counts the feedbacks selected by the retention filter.
+// Retention reads go to the primary, the lagging replica would return the processed rows again.
+func (r *FeedbackRepository) CountExpired(ctx context.Context, filter *models.RetentionFilter) (int, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var count int64
+
+	//nolint:exhaustivestruct,exhaustruct
+	err := whereExpired(db.Model(&models.Feedback{}), filter).Count(&count).Error
+	if err != nil {
+		r.logger.Error("Failed to count expired feedbacks", log.M{"filter": filter, "error": err.Error()})
+
+		return 0, fmt.Errorf("failed to count expired feedbacks: %w", err)
+	}
+
+	return int(count), nil
+}
+
+// GetExpired returns IDs of the oldest feedbacks selected by the retention filter.
+func (r *FeedbackRepository) GetExpired(
+	ctx context.Context,
+	filter *models.RetentionFilter,
+	limit int,
+) ([]uuid.UUID, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var feedbackIDs []uuid.UUID
+
+	//nolint:exhaustivestruct,exhaustruct
+	err := whereExpired(db.Model(&models.Feedback{}), filter).
+		Order("created_at").
+		Order("id").
+		Limit(limit).
+		Pluck("id", &feedbackIDs).Error
+	if err != nil {
+		r.logger.Error("Failed to get expired feedbacks", log.M{"filter": filter, "error": err.Error()})
+
+		return nil, fmt.Errorf("failed to get expired feedbacks: %w", err)
+	}
+
+	return feedbackIDs, nil
+}
+
+// Anonymize erases the name and the email of the feedbacks, the text and the derived fields are kept.
+// Unknown and already anonymized feedbacks are skipped. It returns the anonymized feedbacks.
+func (r *FeedbackRepository) Anonymize(ctx context.Context, feedbackIDs []uuid.UUID) ([]*models.Feedback, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	r.logger.Info("Anonymizing 'Feedback's", log.M{"count": len(feedbackIDs)})
+
+	var (
+		anonymized []*models.Feedback
+		entries    []*models.AuditEntry
+	)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var feedbacks []*models.Feedback
+
+		for _, chunk := range chunks(feedbackIDs, maxInList) {
+			var found []*models.Feedback
+
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id IN ?", chunk).
+				Where(withPersonalData).
+				Find(&found).Error
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			feedbacks = append(feedbacks, found...)
+		}
+
+		if len(feedbacks) == 0 {
+			return nil
+		}
+
+		updatedAt := now()
+		changed := make([]uuid.UUID, 0, len(feedbacks))
+
+		// The personal data isn't decrypted, it's redacted in the audit log anyway.
+		for _, feedback := range feedbacks {
+			after := *feedback
+			after.CustomerName = ""
+			after.Email = ""
+			after.EmailIndex = ""
+			after.UpdatedAt = updatedAt
+
+			entry, err := audit.NewEntry(ctx, audit.EntityFeedback, audit.ActionUpdate, feedback.ID, feedback, &after)
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			entries = append(entries, entry)
+			anonymized = append(anonymized, &after)
+			changed = append(changed, feedback.ID)
+		}
+
+		for _, chunk := range chunks(changed, maxInList) {
+			//nolint:exhaustivestruct,exhaustruct
+			err := tx.Model(&models.Feedback{}).Where("id IN ?", chunk).UpdateColumns(map[string]interface{}{
+				customerNameColumn: "",
+				emailColumn:        "",
+				"email_index":      "",
+				"updated_at":       updatedAt,
+			}).Error
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+		}
+
+		return r.audit.record(tx, entries...)
+	})
+	if err != nil {
+		r.logger.Error("Failed to anonymize feedbacks", log.M{"err": err})
+
+		return nil, fmt.Errorf("failed to anonymize feedbacks: %w", err)
+	}
+
+	r.replicas.Wrote(ctx)
+	r.audit.committed(entries...)
+
+	r.logger.Info("Feedbacks anonymized", log.M{"count": len(anonymized)})
+
+	return anonymized, nil
+}
+
+const withPersonalData = "(customer_name <> '' OR email <> '')"
+
+func whereExpired(query *gorm.DB, filter *models.RetentionFilter) *gorm.DB {
+	query = query.Where("created_at < ?", filter.CreatedBefore)
+
+	if filter.Tenant != "" {
+		query = query.Where("tenant = ?", filter.Tenant)
+	}
+
+	if filter.SourceHost != "" {
+		query = query.Where("source_host = ?", filter.SourceHost)
+	}
+
+	if filter.WithPersonalData {
+		query = query.Where(withPersonalData)
+	}
+
+	return query
+}
@@ -0,0 +1,198 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type RuleRepository struct {
+	db      *gorm.DB
+	audit   *AuditRepository
+	timeout time.Duration
+	logger  log.Logger
+}
+
+// NewRuleRepository doesn't touch the schema, it's managed by the migrations.
+// Changes are written to the audit log in the same transaction.
+//
+//nolint:varnamelen
+func NewRuleRepository(
+	db *gorm.DB,
+	auditLog *AuditRepository,
+	timeout time.Duration,
+	logger log.Logger,
+) *RuleRepository {
+	return &RuleRepository{
+		db:      db,
+		audit:   auditLog,
+		timeout: timeout,
+		logger:  logger.Named("gormRules"),
+	}
+}
+
+func (r *RuleRepository) Create(ctx context.Context, rule *models.Rule) (uuid.UUID, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	rule.ID = uuid.New()
+	rule.CreatedAt = now()
+	rule.UpdatedAt = now()
+
+	entry, err := audit.NewEntry(ctx, audit.EntityRule, audit.ActionCreate, rule.ID, nil, rule)
+	if err != nil {
+		return uuid.Nil, err //nolint:wrapcheck
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(rule).Error
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return r.audit.record(tx, entry)
+	})
+	if err != nil {
+		r.logger.Error("Failed to create rule into DB", log.M{"err": err})
+
+		return uuid.Nil, fmt.Errorf("failed to create rule into DB: %w", err)
+	}
+
+	r.audit.committed(entry)
+
+	r.logger.Info("Rule created successfully", log.M{"id": rule.ID})
+
+	return rule.ID, nil
+}
+
+func (r *RuleRepository) GetByID(ctx context.Context, ruleID uuid.UUID) (*models.Rule, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var rule models.Rule
+
+	err := db.First(&rule, ruleID).Error
+	if err != nil {
+		r.logger.Error("Failed to get rule from DB", log.M{"ruleID": ruleID, "error": err.Error()})
+
+		return nil, fmt.Errorf("failed to get rule from DB: %w", err)
+	}
+
+	return &rule, nil
+}
+
+func (r *RuleRepository) GetAll(ctx context.Context) ([]*models.Rule, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var rules []*models.Rule
+
+	err := db.Order("position").Order("created_at").Find(&rules).Error
+	if err != nil {
+		r.logger.Error("Failed to get rules from DB", log.M{"error": err.Error()})
+
+		return nil, fmt.Errorf("failed to get rules from DB: %w", err)
+	}
+
+	return rules, nil
+}
+
+func (r *RuleRepository) Update(ctx context.Context, rule *models.Rule) error {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	rule.UpdatedAt = now()
+
+	var entry *models.AuditEntry
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		before, err := lockRule(tx, rule.ID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Save(rule).Error
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		entry, err = audit.NewEntry(ctx, audit.EntityRule, audit.ActionUpdate, rule.ID, before, rule)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return r.audit.record(tx, entry)
+	})
+	if err != nil {
+		r.logger.Error("Failed to update rule in DB", log.M{"ruleID": rule.ID, "error": err.Error()})
+
+		return fmt.Errorf("failed to update rule in DB: %w", err)
+	}
+
+	r.audit.committed(entry)
+
+	return nil
+}
+
+func (r *RuleRepository) Delete(ctx context.Context, ruleID uuid.UUID) error {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var entry *models.AuditEntry
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		before, err := lockRule(tx, ruleID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Delete(before).Error
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		entry, err = audit.NewEntry(ctx, audit.EntityRule, audit.ActionDelete, ruleID, before, nil)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return r.audit.record(tx, entry)
+	})
+	if err != nil {
+		r.logger.Error("Failed to delete rule from DB", log.M{"ruleID": ruleID, "error": err.Error()})
+
+		return fmt.Errorf("failed to delete rule from DB: %w", err)
+	}
+
+	r.audit.committed(entry)
+
+	return nil
+}
+
+// lockRule reads the rule for the change, the row is locked till the end of the transaction,
+// so the snapshot is the state right before the change.
+func lockRule(tx *gorm.DB, ruleID uuid.UUID) (*models.Rule, error) {
+	var rule models.Rule
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&rule, ruleID).Error
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return &rule, nil
+}
+
+// conn returns DB session bound to the context with the query timeout.
+func (r *RuleRepository) conn(ctx context.Context) (*gorm.DB, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+
+	return r.db.WithContext(ctx), cancel
+}
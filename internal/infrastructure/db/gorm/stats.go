@@ -0,0 +1,107 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// GetStats counts the feedbacks matched by the filter by the groups of the dimensions,
+// see models.FeedbackStat. The dimensions are validated by the service.
+// The feedback with several tags is counted in the group of every tag.
+func (r *FeedbackRepository) GetStats(
+	ctx context.Context,
+	groupBy []string,
+	filter *models.FeedbackFilter,
+) ([]*models.FeedbackStat, error) {
+	var rows []*struct {
+		Source  string
+		Period  string
+		Tag     string
+		Count   int64
+		FirstAt scannedTime
+		LastAt  scannedTime
+	}
+
+	r.logger.Info("Get stats of 'Feedback's", log.M{"groupBy": groupBy, "filter": filter})
+
+	err := r.replicas.read(ctx, r.db, r.timeout, func(db *gorm.DB) error {
+		columns := []string{
+			"COUNT(*) AS count",
+			"MIN(feedbacks.created_at) AS first_at",
+			"MAX(feedbacks.created_at) AS last_at",
+		}
+		groups := make([]string, 0, len(groupBy))
+
+		//nolint:exhaustivestruct,exhaustruct
+		query := r.applyFilter(db.Model(&models.Feedback{}), filter)
+
+		for _, dimension := range groupBy {
+			var column, alias string
+
+			switch dimension {
+			case models.StatsBySource:
+				column, alias = "feedbacks.source_host", "source"
+			case models.StatsByDay:
+				column, alias = dayColumn(db, "feedbacks.created_at"), "period"
+			case models.StatsByWeek:
+				column, alias = weekColumn(db, "feedbacks.created_at"), "period"
+			case models.StatsByMonth:
+				column, alias = monthColumn(db, "feedbacks.created_at"), "period"
+			case models.StatsByTag:
+				column, alias = "COALESCE(tag.value, '')", "tag"
+				query = query.Joins(joinTags(db, "feedbacks.tags"))
+			default:
+				return fmt.Errorf("unknown dimension '%s'", dimension) //nolint:goerr113
+			}
+
+			columns = append(columns, column+" AS "+alias)
+			groups = append(groups, alias)
+		}
+
+		query = query.Select(columns)
+
+		for _, group := range groups {
+			query = query.Group(group).Order(group)
+		}
+
+		return query.Scan(&rows).Error
+	})
+	if err != nil {
+		r.logger.Error("Failed to get stats from DB", log.M{"error": err.Error()})
+
+		return nil, fmt.Errorf("failed to get stats from DB: %w", err)
+	}
+
+	stats := make([]*models.FeedbackStat, 0, len(rows))
+
+	for _, row := range rows {
+		group := make(map[string]string, len(groupBy))
+
+		for _, dimension := range groupBy {
+			switch dimension {
+			case models.StatsBySource:
+				group[dimension] = row.Source
+			case models.StatsByTag:
+				group[dimension] = row.Tag
+			default:
+				group[dimension] = row.Period
+			}
+		}
+
+		stats = append(stats, &models.FeedbackStat{
+			Group:   group,
+			Count:   row.Count,
+			FirstAt: row.FirstAt.UTC(),
+			LastAt:  row.LastAt.UTC(),
+		})
+	}
+
+	r.logger.Info("Got stats of 'Feedback's", log.M{"count": len(stats)})
+
+	return stats, nil
+}
@@ -0,0 +1,432 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/envelope"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// The column of the secret, it's the associated data of the encrypted secret with the webhook ID.
+const secretColumn = "secret"
+
+// WebhookRepository keeps the subscriptions and the log of their deliveries.
+type WebhookRepository struct {
+	db      *gorm.DB
+	audit   *AuditRepository
+	cipher  *envelope.Cipher
+	ids     ids.IDGenerator
+	timeout time.Duration
+	logger  log.Logger
+}
+
+// NewWebhookRepository writes the changes of the subscriptions to the audit log like RuleRepository,
+// the IDs of the deliveries are made by the generator, so the log is ordered by the ID.
+// The secrets are encrypted by the cipher like the personal data of the feedbacks, nil cipher means plaintext.
+//
+//nolint:varnamelen
+func NewWebhookRepository(
+	db *gorm.DB,
+	auditLog *AuditRepository,
+	cipher *envelope.Cipher,
+	generator ids.IDGenerator,
+	timeout time.Duration,
+	logger log.Logger,
+) *WebhookRepository {
+	return &WebhookRepository{
+		db:      db,
+		audit:   auditLog,
+		cipher:  cipher,
+		ids:     generator,
+		timeout: timeout,
+		logger:  logger.Named("gormWebhooks"),
+	}
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, webhook *models.Webhook) (uuid.UUID, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	webhook.ID = uuid.New()
+	webhook.CreatedAt = now()
+	webhook.UpdatedAt = now()
+
+	entry, err := audit.NewEntry(ctx, audit.EntityWebhook, audit.ActionCreate, webhook.ID, nil, webhook)
+	if err != nil {
+		return uuid.Nil, err //nolint:wrapcheck
+	}
+
+	sealed, err := r.seal(webhook)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(sealed).Error
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return r.audit.record(tx, entry)
+	})
+	if err != nil {
+		r.logger.Error("Failed to create webhook into DB", log.M{"err": err})
+
+		return uuid.Nil, fmt.Errorf("failed to create webhook into DB: %w", err)
+	}
+
+	r.audit.committed(entry)
+
+	r.logger.Info("Webhook created successfully", log.M{"id": webhook.ID})
+
+	return webhook.ID, nil
+}
+
+func (r *WebhookRepository) GetByID(ctx context.Context, webhookID uuid.UUID) (*models.Webhook, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var webhook models.Webhook
+
+	err := db.First(&webhook, webhookID).Error
+	if err != nil {
+		r.logger.Error("Failed to get webhook from DB", log.M{"webhookID": webhookID, "error": err.Error()})
+
+		return nil, fmt.Errorf("failed to get webhook from DB: %w", err)
+	}
+
+	err = r.open(&webhook)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+func (r *WebhookRepository) GetAll(ctx context.Context) ([]*models.Webhook, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var webhooks []*models.Webhook
+
+	err := db.Order("created_at").Find(&webhooks).Error
+	if err != nil {
+		r.logger.Error("Failed to get webhooks from DB", log.M{"error": err.Error()})
+
+		return nil, fmt.Errorf("failed to get webhooks from DB: %w", err)
+	}
+
+	err = r.open(webhooks...)
+	if err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (r *WebhookRepository) Update(ctx context.Context, webhook *models.Webhook) error {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	webhook.UpdatedAt = now()
+
+	sealed, err := r.seal(webhook)
+	if err != nil {
+		return err
+	}
+
+	var entry *models.AuditEntry
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		before, err := lockWebhook(tx, webhook.ID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Save(sealed).Error
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		entry, err = audit.NewEntry(ctx, audit.EntityWebhook, audit.ActionUpdate, webhook.ID, before, webhook)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return r.audit.record(tx, entry)
+	})
+	if err != nil {
+		r.logger.Error("Failed to update webhook in DB", log.M{"webhookID": webhook.ID, "error": err.Error()})
+
+		return fmt.Errorf("failed to update webhook in DB: %w", err)
+	}
+
+	r.audit.committed(entry)
+
+	return nil
+}
+
+// Delete removes the webhook with its deliveries.
+func (r *WebhookRepository) Delete(ctx context.Context, webhookID uuid.UUID) error {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	var entry *models.AuditEntry
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		before, err := lockWebhook(tx, webhookID)
+		if err != nil {
+			return err
+		}
+
+		// SQLite doesn't cascade without the pragma of the connection.
+		//nolint:exhaustivestruct,exhaustruct
+		err = tx.Where("webhook_id = ?", webhookID).Delete(&models.WebhookDelivery{}).Error
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		err = tx.Delete(before).Error
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		entry, err = audit.NewEntry(ctx, audit.EntityWebhook, audit.ActionDelete, webhookID, before, nil)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return r.audit.record(tx, entry)
+	})
+	if err != nil {
+		r.logger.Error("Failed to delete webhook from DB", log.M{"webhookID": webhookID, "error": err.Error()})
+
+		return fmt.Errorf("failed to delete webhook from DB: %w", err)
+	}
+
+	r.audit.committed(entry)
+
+	return nil
+}
+
+// Counted changes the failures in a row of the webhook: the success resets them,
+// the failure adds one and disables the webhook after disableAfter of them. It returns true if it's disabled now.
+func (r *WebhookRepository) Counted(
+	ctx context.Context,
+	webhookID uuid.UUID,
+	failed bool,
+	disableAfter int,
+) (bool, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	if !failed {
+		//nolint:exhaustivestruct,exhaustruct
+		err := db.Model(&models.Webhook{}).Where("id = ? AND failures > 0", webhookID).Update("failures", 0).Error
+		if err != nil {
+			return false, fmt.Errorf("failed to reset failures of webhook: %w", err)
+		}
+
+		return false, nil
+	}
+
+	disabled := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		webhook, err := lockWebhook(tx, webhookID)
+		if err != nil {
+			return err
+		}
+
+		webhook.Failures++
+		disabled = webhook.Enabled && disableAfter > 0 && webhook.Failures >= disableAfter
+
+		return tx.Model(webhook).Updates(map[string]interface{}{
+			"failures": webhook.Failures,
+			"enabled":  webhook.Enabled && !disabled,
+		}).Error
+	})
+	if err != nil {
+		r.logger.Error("Failed to count webhook failure", log.M{"webhookID": webhookID, "error": err.Error()})
+
+		return false, fmt.Errorf("failed to count webhook failure: %w", err)
+	}
+
+	return disabled, nil
+}
+
+// AddDeliveries sets the IDs and the creation time of the deliveries.
+func (r *WebhookRepository) AddDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	for _, delivery := range deliveries {
+		delivery.ID = r.ids.New()
+		delivery.CreatedAt = now()
+	}
+
+	err := db.CreateInBatches(deliveries, createBatchSize).Error
+	if err != nil {
+		r.logger.Error("Failed to add webhook deliveries into DB", log.M{"err": err, "count": len(deliveries)})
+
+		return fmt.Errorf("failed to add webhook deliveries into DB: %w", err)
+	}
+
+	return nil
+}
+
+// Due returns the pending deliveries with the time of the attempt before the moment, the oldest ones first.
+func (r *WebhookRepository) Due(ctx context.Context, moment time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	deliveries := make([]*models.WebhookDelivery, 0, limit)
+
+	err := db.Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, moment.UTC()).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&deliveries).Error
+	if err != nil {
+		r.logger.Error("Failed to get due webhook deliveries from DB", log.M{"error": err.Error()})
+
+		return nil, fmt.Errorf("failed to get due webhook deliveries from DB: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// Claim moves the next attempt of the due delivery to the time, so other instances don't take it,
+// false means it's taken already.
+func (r *WebhookRepository) Claim(ctx context.Context, deliveryID uuid.UUID, moment, until time.Time) (bool, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	//nolint:exhaustivestruct,exhaustruct
+	result := db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", deliveryID, models.DeliveryPending, moment.UTC()).
+		Update("next_attempt_at", until.UTC())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim webhook delivery: %w", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// SaveDelivery saves the result of the attempt.
+func (r *WebhookRepository) SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	err := db.Save(delivery).Error
+	if err != nil {
+		r.logger.Error("Failed to save webhook delivery", log.M{"deliveryID": delivery.ID, "error": err.Error()})
+
+		return fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// Deliveries returns the log of the webhook from the newest delivery, before is the last ID of the previous page.
+func (r *WebhookRepository) Deliveries(
+	ctx context.Context,
+	webhookID uuid.UUID,
+	limit int,
+	before uuid.UUID,
+) ([]*models.WebhookDelivery, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	deliveries := make([]*models.WebhookDelivery, 0, limit)
+
+	query := db.Where("webhook_id = ?", webhookID).Order("id DESC").Limit(limit)
+	if before != uuid.Nil {
+		query = query.Where("id < ?", before)
+	}
+
+	err := query.Find(&deliveries).Error
+	if err != nil {
+		r.logger.Error("Failed to get webhook deliveries from DB", log.M{"webhookID": webhookID, "error": err.Error()})
+
+		return nil, fmt.Errorf("failed to get webhook deliveries from DB: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// DeleteDeliveriesBefore removes the completed deliveries created before the time.
+func (r *WebhookRepository) DeleteDeliveriesBefore(ctx context.Context, before time.Time) (int, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	//nolint:exhaustivestruct,exhaustruct
+	result := db.Where("status <> ? AND created_at < ?", models.DeliveryPending, before.UTC()).
+		Delete(&models.WebhookDelivery{})
+	if result.Error != nil {
+		r.logger.Error("Failed to delete old webhook deliveries", log.M{"error": result.Error.Error()})
+
+		return 0, fmt.Errorf("failed to delete old webhook deliveries: %w", result.Error)
+	}
+
+	return int(result.RowsAffected), nil
+}
+
+// seal returns the copy of the webhook for saving with the encrypted secret.
+func (r *WebhookRepository) seal(webhook *models.Webhook) (*models.Webhook, error) {
+	sealed := *webhook
+
+	var err error
+
+	sealed.Secret, err = r.cipher.Encrypt(webhook.Secret, webhookData(secretColumn, webhook))
+	if err != nil {
+		return nil, fmt.Errorf("can't encrypt %s: %w", secretColumn, err)
+	}
+
+	return &sealed, nil
+}
+
+// open decrypts the secrets in place, the plaintext secrets are saved before the encryption was on.
+func (r *WebhookRepository) open(webhooks ...*models.Webhook) error {
+	for _, webhook := range webhooks {
+		var err error
+
+		webhook.Secret, err = r.cipher.Decrypt(webhook.Secret, webhookData(secretColumn, webhook))
+		if err != nil {
+			return fmt.Errorf("can't decrypt %s of webhook '%s': %w", secretColumn, webhook.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func webhookData(column string, webhook *models.Webhook) string {
+	return "webhooks." + column + ":" + webhook.ID.String()
+}
+
+// lockWebhook reads the webhook for the change like lockRule.
+func lockWebhook(tx *gorm.DB, webhookID uuid.UUID) (*models.Webhook, error) {
+	var webhook models.Webhook
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&webhook, webhookID).Error
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return &webhook, nil
+}
+
+// conn returns DB session bound to the context with the query timeout.
+func (r *WebhookRepository) conn(ctx context.Context) (*gorm.DB, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+
+	return r.db.WithContext(ctx), cancel
+}
@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// AuditRepository keeps the audit log in the order of writes,
+// the other repositories record their changes under their own locks
+// and flush them to the sink after the locks are released.
+type AuditRepository struct {
+	mu      sync.RWMutex
+	entries []*models.AuditEntry
+	// pending entries aren't written to the sink yet.
+	pending []*models.AuditEntry
+
+	// sinkMu keeps the order of the entries in the sink.
+	sinkMu sync.Mutex
+	sink   audit.Sink
+	logger logger.Logger
+}
+
+// NewAuditRepository gets the optional sink, it gets the entries after they are recorded.
+func NewAuditRepository(sink audit.Sink, logger logger.Logger) *AuditRepository {
+	return &AuditRepository{
+		mu:      sync.RWMutex{},
+		entries: make([]*models.AuditEntry, 0),
+		pending: nil,
+		sinkMu:  sync.Mutex{},
+		sink:    sink,
+		logger:  logger.Named("memoryAudit"),
+	}
+}
+
+func (r *AuditRepository) GetByEntity(
+	_ context.Context,
+	entity string,
+	entityID uuid.UUID,
+) ([]*models.AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]*models.AuditEntry, 0)
+
+	for _, entry := range r.entries {
+		if entry.Entity == entity && entry.EntityID == entityID {
+			entryCopy := *entry
+			entries = append(entries, &entryCopy)
+		}
+	}
+
+	return entries, nil
+}
+
+// GetByEntities returns the history of the entities in the order of writes.
+func (r *AuditRepository) GetByEntities(
+	_ context.Context,
+	entity string,
+	entityIDs []uuid.UUID,
+) ([]*models.AuditEntry, error) {
+	wanted := make(map[uuid.UUID]struct{}, len(entityIDs))
+	for _, entityID := range entityIDs {
+		wanted[entityID] = struct{}{}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]*models.AuditEntry, 0)
+
+	for _, entry := range r.entries {
+		if _, ok := wanted[entry.EntityID]; ok && entry.Entity == entity {
+			entryCopy := *entry
+			entries = append(entries, &entryCopy)
+		}
+	}
+
+	return entries, nil
+}
+
+// Record saves the entry which isn't a part of a change of other repositories.
+func (r *AuditRepository) Record(_ context.Context, entry *models.AuditEntry) error {
+	r.record(entry)
+	r.flush()
+
+	return nil
+}
+
+// record appends the entries, the sink gets them by flush.
+func (r *AuditRepository) record(entries ...*models.AuditEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, entries...)
+
+	if r.sink != nil {
+		r.pending = append(r.pending, entries...)
+	}
+}
+
+// flush writes the pending entries to the sink, it's called after the lock of the changed repository
+// is released, so the slow sink doesn't block the other calls of the repository.
+func (r *AuditRepository) flush() {
+	if r.sink == nil {
+		return
+	}
+
+	r.sinkMu.Lock()
+	defer r.sinkMu.Unlock()
+
+	r.mu.Lock()
+	entries := r.pending
+	r.pending = nil
+	r.mu.Unlock()
+
+	if len(entries) == 0 {
+		return
+	}
+
+	err := r.sink.Write(entries)
+	if err != nil {
+		r.logger.Error("Failed to write audit entries to the sink", logger.M{"count": len(entries), "err": err})
+	}
+}
@@ -1,81 +1,408 @@
 package memory
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/ids"
 	"github.com/andrsj/feedback-service/internal/domain/models"
 	"github.com/andrsj/feedback-service/pkg/logger"
 )
 
+const dayLayout = "2006-01-02"
+
+// FeedbackRepository keeps feedbacks in the creation order,
+// so listings and pages are ordered like in the DB repository.
 type FeedbackRepository struct {
-	mu        sync.Mutex
-	feedbacks map[string]*models.Feedback
-	logger    logger.Logger
+	mu        sync.RWMutex
+	feedbacks []*models.Feedback
+	index     map[uuid.UUID]int
+	// byID is the IDs in the ascending order, the pages are read from it.
+	byID   []uuid.UUID
+	audit  *AuditRepository
+	ids    ids.IDGenerator
+	logger logger.Logger
 }
 
-func New(logger logger.Logger) *FeedbackRepository {
+// New records the changes to the audit log under the lock of the change.
+// IDs of the new feedbacks are made by the generator.
+func New(auditLog *AuditRepository, generator ids.IDGenerator, logger logger.Logger) *FeedbackRepository {
 	return &FeedbackRepository{
-		mu:        sync.Mutex{},
-		feedbacks: make(map[string]*models.Feedback),
+		mu:        sync.RWMutex{},
+		feedbacks: make([]*models.Feedback, 0),
+		index:     make(map[uuid.UUID]int),
+		byID:      make([]uuid.UUID, 0),
+		audit:     auditLog,
+		ids:       generator,
 		logger:    logger.Named("memoryDB"),
 	}
 }
 
-func (r *FeedbackRepository) Create(feedback *models.FeedbackInput) (uuid.UUID, error) {
-	feedbackID := uuid.New()
+func (r *FeedbackRepository) Create(ctx context.Context, feedback *models.Feedback) (uuid.UUID, error) {
+	feedbackID := r.ids.New()
 
 	r.logger.Info("Creating feedback", logger.M{"feedbackID": feedbackID})
 
-	feedbackOutput := &models.Feedback{
-		ID:           feedbackID,
-		CustomerName: feedback.CustomerName,
-		Email:        feedback.Email,
-		FeedbackText: feedback.FeedbackText,
-		Source:       feedback.Source,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
-	}
+	defer r.audit.flush()
 
 	r.mu.Lock()
-	r.logger.Info("Saving feedback", logger.M{"feedbackID": feedbackID})
-	r.feedbacks[feedbackID.String()] = feedbackOutput
-	r.mu.Unlock()
+	defer r.mu.Unlock()
+
+	// Time is taken under the lock, so the order of the slice is the order of creation time.
+	feedback.ID = feedbackID
+	feedback.CreatedAt = time.Now()
+	feedback.UpdatedAt = feedback.CreatedAt
+
+	entry, err := audit.NewEntry(ctx, audit.EntityFeedback, audit.ActionCreate, feedbackID, nil, feedback)
+	if err != nil {
+		return uuid.Nil, err //nolint:wrapcheck
+	}
+
+	r.index[feedbackID] = len(r.feedbacks)
+	r.feedbacks = append(r.feedbacks, clone(feedback))
+	r.addID(feedbackID)
+	r.audit.record(entry)
 
 	r.logger.Info("Returning feedbackID for successfully saved feedback", logger.M{"feedbackID": feedbackID})
 
 	return feedbackID, nil
 }
 
-func (r *FeedbackRepository) GetByID(feedbackID uuid.UUID) (*models.Feedback, error) {
+// CreateBatch inserts the feedbacks by their creation time,
+// imported feedbacks could be older than the saved ones.
+func (r *FeedbackRepository) CreateBatch(ctx context.Context, feedbacks []*models.Feedback) error {
+	defer r.audit.flush()
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.logger.Info("Getting feedback from map", logger.M{"feedbackID": feedbackID})
+	now := time.Now()
+	first := len(r.feedbacks)
+	entries := make([]*models.AuditEntry, 0, len(feedbacks))
+
+	// Entries are built first, so nothing is inserted if one of them fails.
+	for _, feedback := range feedbacks {
+		feedback.ID = r.ids.New()
+
+		if feedback.CreatedAt.IsZero() {
+			feedback.CreatedAt = now
+		}
+
+		if feedback.UpdatedAt.IsZero() {
+			feedback.UpdatedAt = feedback.CreatedAt
+		}
+
+		entry, err := audit.NewEntry(ctx, audit.EntityFeedback, audit.ActionCreate, feedback.ID, nil, feedback)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		entries = append(entries, entry)
+	}
+
+	for _, feedback := range feedbacks {
+		position := sort.Search(len(r.feedbacks), func(i int) bool {
+			return r.feedbacks[i].CreatedAt.After(feedback.CreatedAt)
+		})
+
+		r.feedbacks = append(r.feedbacks, nil)
+		copy(r.feedbacks[position+1:], r.feedbacks[position:])
+		r.feedbacks[position] = clone(feedback)
+		r.addID(feedback.ID)
+
+		if position < first {
+			first = position
+		}
+	}
+
+	for position := first; position < len(r.feedbacks); position++ {
+		r.index[r.feedbacks[position].ID] = position
+	}
+
+	r.audit.record(entries...)
+
+	r.logger.Info("Feedbacks created", logger.M{"count": len(feedbacks)})
+
+	return nil
+}
+
+func (r *FeedbackRepository) Update(ctx context.Context, feedback *models.Feedback) error {
+	defer r.audit.flush()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	feedbackOutput, ok := r.feedbacks[feedbackID.String()]
+	position, ok := r.index[feedback.ID]
 	if !ok {
-		r.logger.Error("Feedback not found for ID", logger.M{"feedbackID": feedbackID})
+		return fmt.Errorf("%w for ID '%s'", models.ErrFeedbackNotFound, feedback.ID)
+	}
 
-		return nil, fmt.Errorf("feedback not found for ID '%s'", feedbackID) //nolint:goerr113
+	feedback.UpdatedAt = time.Now()
+
+	entry, err := audit.NewEntry(ctx, audit.EntityFeedback, audit.ActionUpdate, feedback.ID, r.feedbacks[position], feedback)
+	if err != nil {
+		return err //nolint:wrapcheck
 	}
 
-	r.logger.Info("Getting feedback from map successfully", logger.M{"feedbackID": feedbackID})
+	r.feedbacks[position] = clone(feedback)
+	r.audit.record(entry)
+
+	r.logger.Info("Feedback updated", logger.M{"feedbackID": feedback.ID})
 
-	return feedbackOutput, nil
+	return nil
 }
 
-func (r *FeedbackRepository) GetAll() ([]*models.Feedback, error) {
+// Delete removes the feedbacks, unknown IDs are skipped.
+func (r *FeedbackRepository) Delete(ctx context.Context, feedbackIDs []uuid.UUID) (int, error) {
+	defer r.audit.flush()
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	var feedbacks = make([]*models.Feedback, 0, len(r.feedbacks))
+	var (
+		entries = make([]*models.AuditEntry, 0, len(feedbackIDs))
+		deleted = make(map[uuid.UUID]struct{}, len(feedbackIDs))
+	)
+
+	for _, feedbackID := range feedbackIDs {
+		position, ok := r.index[feedbackID]
+		if !ok {
+			continue
+		}
+
+		if _, ok := deleted[feedbackID]; ok {
+			continue
+		}
+
+		entry, err := audit.NewEntry(ctx, audit.EntityFeedback, audit.ActionDelete, feedbackID, r.feedbacks[position], nil)
+		if err != nil {
+			return 0, err //nolint:wrapcheck
+		}
+
+		entries = append(entries, entry)
+		deleted[feedbackID] = struct{}{}
+	}
+
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+
+	kept := r.feedbacks[:0]
+
+	for _, feedback := range r.feedbacks {
+		if _, ok := deleted[feedback.ID]; ok {
+			delete(r.index, feedback.ID)
+
+			continue
+		}
+
+		r.index[feedback.ID] = len(kept)
+		kept = append(kept, feedback)
+	}
+
+	// The tail keeps the pointers to the deleted feedbacks.
+	for position := len(kept); position < len(r.feedbacks); position++ {
+		r.feedbacks[position] = nil
+	}
+
+	r.feedbacks = kept
+
+	keptIDs := r.byID[:0]
+
+	for _, feedbackID := range r.byID {
+		if _, ok := deleted[feedbackID]; !ok {
+			keptIDs = append(keptIDs, feedbackID)
+		}
+	}
+
+	r.byID = keptIDs
+	r.audit.record(entries...)
+
+	r.logger.Info("Feedbacks deleted", logger.M{"count": len(deleted)})
+
+	return len(deleted), nil
+}
+
+func (r *FeedbackRepository) GetByID(_ context.Context, feedbackID uuid.UUID) (*models.Feedback, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	r.logger.Info("Getting feedback from memory", logger.M{"feedbackID": feedbackID})
+
+	position, ok := r.index[feedbackID]
+	if !ok {
+		r.logger.Error("Feedback not found for ID", logger.M{"feedbackID": feedbackID})
+
+		return nil, fmt.Errorf("%w for ID '%s'", models.ErrFeedbackNotFound, feedbackID)
+	}
+
+	r.logger.Info("Getting feedback from memory successfully", logger.M{"feedbackID": feedbackID})
+
+	return clone(r.feedbacks[position]), nil
+}
+
+// GetPage is the keyset pagination by ID like in the DB repository, the IDs are time-ordered.
+func (r *FeedbackRepository) GetPage(
+	_ context.Context,
+	limit int,
+	next models.PageCursor,
+	filter *models.FeedbackFilter,
+) ([]*models.Feedback, models.PageCursor, error) {
+	var (
+		feedbacks = make([]*models.Feedback, 0, limit)
+		cursor    models.PageCursor
+	)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// The first ID after the cursor, the feedback of the cursor could be deleted.
+	position := sort.Search(len(r.byID), func(i int) bool {
+		return bytes.Compare(r.byID[i][:], next.ID[:]) > 0
+	})
+
+	for ; position < len(r.byID) && len(feedbacks) < limit; position++ {
+		feedback := r.feedbacks[r.index[r.byID[position]]]
+		if matchFilter(feedback, filter) {
+			feedbacks = append(feedbacks, clone(feedback))
+		}
+	}
+
+	if len(feedbacks) > 0 {
+		cursor = models.CursorAfter(feedbacks[len(feedbacks)-1])
+	}
+
+	r.logger.Info("Got page of feedbacks", logger.M{"count": len(feedbacks), "cursor": cursor.String()})
+
+	return feedbacks, cursor, nil
+}
+
+// addID inserts the ID into byID, the new IDs are usually the greatest ones.
+func (r *FeedbackRepository) addID(feedbackID uuid.UUID) {
+	position := sort.Search(len(r.byID), func(i int) bool {
+		return bytes.Compare(r.byID[i][:], feedbackID[:]) > 0
+	})
+
+	r.byID = append(r.byID, uuid.Nil)
+	copy(r.byID[position+1:], r.byID[position:])
+	r.byID[position] = feedbackID
+}
+
+// Iterate passes copies of feedbacks in chunks, the lock isn't held during the callback.
+func (r *FeedbackRepository) Iterate(
+	_ context.Context,
+	filter *models.FeedbackFilter,
+	chunkSize int,
+	callback func(feedbacks []*models.Feedback) error,
+) error {
+	position := 0
+
+	for {
+		feedbacks := make([]*models.Feedback, 0, chunkSize)
+
+		r.mu.RLock()
+		for ; position < len(r.feedbacks) && len(feedbacks) < chunkSize; position++ {
+			if matchFilter(r.feedbacks[position], filter) {
+				feedbacks = append(feedbacks, clone(r.feedbacks[position]))
+			}
+		}
+		r.mu.RUnlock()
+
+		if len(feedbacks) == 0 {
+			return nil
+		}
+
+		err := callback(feedbacks)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (r *FeedbackRepository) GetSentimentStats(_ context.Context) ([]*models.SentimentStat, error) {
+	type statKey struct {
+		day    string
+		source string
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var (
+		keys  []statKey
+		stats = make(map[statKey]*models.SentimentStat)
+	)
+
 	for _, feedback := range r.feedbacks {
-		feedbacks = append(feedbacks, feedback)
+		key := statKey{
+			day:    feedback.CreatedAt.UTC().Format(dayLayout),
+			source: feedback.SourceHost,
+		}
+
+		stat, ok := stats[key]
+		if !ok {
+			stat = &models.SentimentStat{Day: key.day, Source: key.source, Average: 0, Count: 0}
+			stats[key] = stat
+			keys = append(keys, key)
+		}
+
+		// Running average.
+		stat.Count++
+		stat.Average += (feedback.SentimentScore - stat.Average) / float64(stat.Count)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].day != keys[j].day {
+			return keys[i].day < keys[j].day
+		}
+
+		return keys[i].source < keys[j].source
+	})
+
+	result := make([]*models.SentimentStat, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, stats[key])
 	}
 
-	return feedbacks, nil
+	return result, nil
+}
+
+func matchFilter(feedback *models.Feedback, filter *models.FeedbackFilter) bool {
+	if filter == nil {
+		return true
+	}
+
+	if filter.Sentiment != "" && feedback.SentimentLabel != filter.Sentiment {
+		return false
+	}
+
+	if filter.Email != "" && !strings.EqualFold(feedback.Email, filter.Email) {
+		return false
+	}
+
+	// The same as LIKE fallback of SQL repositories: every word must be in the text.
+	text := strings.ToLower(feedback.FeedbackText)
+	for _, word := range strings.Fields(strings.ToLower(filter.Search)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// clone copies the feedback, so callers can't change the saved one.
+func clone(feedback *models.Feedback) *models.Feedback {
+	feedbackCopy := *feedback
+	feedbackCopy.Tags = append([]string(nil), feedback.Tags...)
+
+	return &feedbackCopy
 }
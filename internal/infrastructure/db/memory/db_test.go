@@ -0,0 +1,252 @@
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) logger.Logger { return l }
+func (nopLogger) Debug(string, logger.M)       {}
+func (nopLogger) Info(string, logger.M)        {}
+func (nopLogger) Warn(string, logger.M)        {}
+func (nopLogger) Error(string, logger.M)       {}
+func (nopLogger) Fatal(string, logger.M)       {}
+
+func newRepository() (*memory.FeedbackRepository, *memory.AuditRepository) {
+	auditLog := memory.NewAuditRepository(nil, nopLogger{})
+
+	return memory.New(auditLog, ids.NewV7(), nopLogger{}), auditLog
+}
+
+func newFeedback(text string) *models.Feedback {
+	//nolint:exhaustivestruct,exhaustruct
+	return &models.Feedback{
+		CustomerName:   "Jane",
+		Email:          "Jane@Example.com",
+		FeedbackText:   text,
+		Source:         "https://shop.example.com",
+		SentimentLabel: "neutral",
+		Tags:           []string{"shop"},
+	}
+}
+
+func create(t *testing.T, repo *memory.FeedbackRepository, texts ...string) []uuid.UUID {
+	t.Helper()
+
+	created := make([]uuid.UUID, 0, len(texts))
+
+	for _, text := range texts {
+		feedbackID, err := repo.Create(context.Background(), newFeedback(text))
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		created = append(created, feedbackID)
+	}
+
+	return created
+}
+
+func texts(feedbacks []*models.Feedback) []string {
+	result := make([]string, 0, len(feedbacks))
+	for _, feedback := range feedbacks {
+		result = append(result, feedback.FeedbackText)
+	}
+
+	return result
+}
+
+func TestCreateAndGet(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo, auditLog := newRepository()
+	feedbackID := create(t, repo, "first")[0]
+
+	feedback, err := repo.GetByID(ctx, feedbackID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+
+	if feedback.FeedbackText != "first" || feedback.CreatedAt.IsZero() || !ids.IsV7(feedback.ID) {
+		t.Errorf("got %+v", feedback)
+	}
+
+	// The returned feedback is a copy.
+	feedback.Tags[0] = "changed"
+
+	saved, _ := repo.GetByID(ctx, feedbackID)
+	if saved.Tags[0] != "shop" {
+		t.Errorf("saved feedback is changed by the caller: %v", saved.Tags)
+	}
+
+	_, err = repo.GetByID(ctx, uuid.New())
+	if !errors.Is(err, models.ErrFeedbackNotFound) {
+		t.Errorf("unknown ID: got %v, want %v", err, models.ErrFeedbackNotFound)
+	}
+
+	entries, _ := auditLog.GetByEntity(ctx, audit.EntityFeedback, feedbackID)
+	if len(entries) != 1 || entries[0].Action != audit.ActionCreate {
+		t.Errorf("audit log: got %d entries", len(entries))
+	}
+}
+
+func TestUpdateAndDelete(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo, auditLog := newRepository()
+	created := create(t, repo, "first", "second", "third")
+
+	feedback, _ := repo.GetByID(ctx, created[1])
+	feedback.Team = "support"
+
+	err := repo.Update(ctx, feedback)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	updated, _ := repo.GetByID(ctx, created[1])
+	if updated.Team != "support" {
+		t.Errorf("Update: got team %q", updated.Team)
+	}
+
+	deleted, err := repo.Delete(ctx, []uuid.UUID{created[0], created[1], created[1], uuid.New()})
+	if err != nil || deleted != 2 {
+		t.Fatalf("Delete: got %d, %v", deleted, err)
+	}
+
+	_, err = repo.GetByID(ctx, created[0])
+	if !errors.Is(err, models.ErrFeedbackNotFound) {
+		t.Errorf("deleted feedback: got %v", err)
+	}
+
+	// The index of the kept feedback is moved.
+	kept, err := repo.GetByID(ctx, created[2])
+	if err != nil || kept.FeedbackText != "third" {
+		t.Errorf("kept feedback: got %+v, %v", kept, err)
+	}
+
+	err = repo.Update(ctx, updated)
+	if !errors.Is(err, models.ErrFeedbackNotFound) {
+		t.Errorf("Update of deleted: got %v", err)
+	}
+
+	entries, _ := auditLog.GetByEntity(ctx, audit.EntityFeedback, created[1])
+	if len(entries) != 3 || entries[2].Action != audit.ActionDelete { //nolint:gomnd
+		t.Errorf("audit log: got %d entries", len(entries))
+	}
+}
+
+func TestGetPage(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo, _ := newRepository()
+	all := make([]string, 0)
+
+	for i := 0; i < 7; i++ {
+		all = append(all, fmt.Sprintf("feedback %d", i))
+	}
+
+	created := create(t, repo, all...)
+
+	var (
+		next models.PageCursor
+		got  []string
+	)
+
+	for {
+		feedbacks, cursor, err := repo.GetPage(ctx, 3, next, nil) //nolint:gomnd
+		if err != nil {
+			t.Fatalf("GetPage: %v", err)
+		}
+
+		if len(feedbacks) == 0 {
+			break
+		}
+
+		got = append(got, texts(feedbacks)...)
+		next = cursor
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(all) {
+		t.Errorf("pages: got %v, want %v", got, all)
+	}
+
+	// The page continues after the deleted feedback of the cursor.
+	_, cursor, _ := repo.GetPage(ctx, 2, models.PageCursor{}, nil) //nolint:gomnd
+
+	_, err := repo.Delete(ctx, []uuid.UUID{cursor.ID})
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	feedbacks, _, _ := repo.GetPage(ctx, 1, cursor, nil)
+	if len(feedbacks) != 1 || feedbacks[0].ID != created[2] {
+		t.Errorf("after deleted cursor: got %v", texts(feedbacks))
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	feedbacks, _, _ = repo.GetPage(ctx, 10, models.PageCursor{}, &models.FeedbackFilter{Search: "FEEDBACK 6"})
+	if fmt.Sprint(texts(feedbacks)) != "[feedback 6]" {
+		t.Errorf("filtered page: got %v", texts(feedbacks))
+	}
+}
+
+func TestCreateBatchKeepsCreationOrder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo, _ := newRepository()
+	create(t, repo, "new")
+
+	old := newFeedback("old")
+	old.CreatedAt = time.Now().Add(-time.Hour)
+
+	err := repo.CreateBatch(ctx, []*models.Feedback{newFeedback("newest"), old})
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+
+	var got []string
+
+	err = repo.Iterate(ctx, nil, 2, func(feedbacks []*models.Feedback) error { //nolint:gomnd
+		got = append(got, texts(feedbacks)...)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Iterate: %v", err)
+	}
+
+	if fmt.Sprint(got) != "[old new newest]" {
+		t.Errorf("Iterate: got %v", got)
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	filter := &models.FeedbackFilter{Email: "jane@example.com", Sentiment: "neutral"}
+	count := 0
+
+	err = repo.Iterate(ctx, filter, 10, func(feedbacks []*models.Feedback) error { //nolint:gomnd
+		count += len(feedbacks)
+
+		return nil
+	})
+	if err != nil || count != 3 { //nolint:gomnd
+		t.Errorf("Iterate by email: got %d, %v", count, err)
+	}
+}
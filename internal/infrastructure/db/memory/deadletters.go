@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// DeadLetterRepository keeps the letters until the restart, the IDs are time-ordered like in the DB repository.
+type DeadLetterRepository struct {
+	mu      sync.Mutex
+	letters map[uuid.UUID]*models.DeadLetter
+	ids     ids.IDGenerator
+	logger  logger.Logger
+}
+
+func NewDeadLetterRepository(generator ids.IDGenerator, logger logger.Logger) *DeadLetterRepository {
+	return &DeadLetterRepository{
+		mu:      sync.Mutex{},
+		letters: make(map[uuid.UUID]*models.DeadLetter),
+		ids:     generator,
+		logger:  logger.Named("memoryDeadLetters"),
+	}
+}
+
+func (r *DeadLetterRepository) Add(_ context.Context, letters []*models.DeadLetter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, letter := range letters {
+		letter.ID = r.ids.New()
+		letter.CreatedAt = time.Now().UTC()
+		letter.LastAttemptAt = letter.CreatedAt
+
+		letterCopy := *letter
+		r.letters[letter.ID] = &letterCopy
+	}
+
+	r.logger.Info("Dead letters added", logger.M{"count": len(letters)})
+
+	return nil
+}
+
+func (r *DeadLetterRepository) List(_ context.Context, limit int, after uuid.UUID) ([]*models.DeadLetter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	letters := make([]*models.DeadLetter, 0, len(r.letters))
+
+	for _, letter := range r.letters {
+		if after == uuid.Nil || bytes.Compare(letter.ID[:], after[:]) > 0 {
+			letterCopy := *letter
+			letters = append(letters, &letterCopy)
+		}
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		return bytes.Compare(letters[i].ID[:], letters[j].ID[:]) < 0
+	})
+
+	if len(letters) > limit {
+		letters = letters[:limit]
+	}
+
+	return letters, nil
+}
+
+func (r *DeadLetterRepository) GetByIDs(_ context.Context, letterIDs []uuid.UUID) ([]*models.DeadLetter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	letters := make([]*models.DeadLetter, 0, len(letterIDs))
+
+	for _, letterID := range letterIDs {
+		if letter, ok := r.letters[letterID]; ok {
+			letterCopy := *letter
+			letters = append(letters, &letterCopy)
+		}
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		return bytes.Compare(letters[i].ID[:], letters[j].ID[:]) < 0
+	})
+
+	return letters, nil
+}
+
+func (r *DeadLetterRepository) Retried(_ context.Context, letterID uuid.UUID, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if letter, ok := r.letters[letterID]; ok {
+		letter.Error = reason
+		letter.Attempts++
+		letter.LastAttemptAt = time.Now().UTC()
+	}
+
+	return nil
+}
+
+func (r *DeadLetterRepository) Delete(_ context.Context, letterIDs []uuid.UUID) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+
+	for _, letterID := range letterIDs {
+		if _, ok := r.letters[letterID]; ok {
+			delete(r.letters, letterID)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (r *DeadLetterRepository) DeleteBefore(_ context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+
+	for letterID, letter := range r.letters {
+		if before.IsZero() || letter.CreatedAt.Before(before) {
+			delete(r.letters, letterID)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (r *DeadLetterRepository) Count(_ context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.letters), nil
+}
@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+func (r *FeedbackRepository) CountExpired(_ context.Context, filter *models.RetentionFilter) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+
+	for _, feedback := range r.feedbacks {
+		if matchExpired(feedback, filter) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// GetExpired returns IDs of the oldest feedbacks selected by the retention filter.
+func (r *FeedbackRepository) GetExpired(
+	_ context.Context,
+	filter *models.RetentionFilter,
+	limit int,
+) ([]uuid.UUID, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	feedbackIDs := make([]uuid.UUID, 0, limit)
+
+	// The slice is ordered by creation, so the first ones are the oldest.
+	for _, feedback := range r.feedbacks {
+		if len(feedbackIDs) == limit || !feedback.CreatedAt.Before(filter.CreatedBefore) {
+			break
+		}
+
+		if matchExpired(feedback, filter) {
+			feedbackIDs = append(feedbackIDs, feedback.ID)
+		}
+	}
+
+	return feedbackIDs, nil
+}
+
+// Anonymize erases the name and the email of the feedbacks, the text and the derived fields are kept.
+// Unknown and already anonymized feedbacks are skipped. It returns the anonymized feedbacks.
+func (r *FeedbackRepository) Anonymize(ctx context.Context, feedbackIDs []uuid.UUID) ([]*models.Feedback, error) {
+	defer r.audit.flush()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var (
+		updatedAt  = time.Now()
+		entries    = make([]*models.AuditEntry, 0, len(feedbackIDs))
+		anonymized = make([]*models.Feedback, 0, len(feedbackIDs))
+	)
+
+	// Entries are built first, so nothing is changed if one of them fails.
+	for _, feedbackID := range feedbackIDs {
+		position, ok := r.index[feedbackID]
+		if !ok || !hasPersonalData(r.feedbacks[position]) {
+			continue
+		}
+
+		after := clone(r.feedbacks[position])
+		after.CustomerName = ""
+		after.Email = ""
+		after.UpdatedAt = updatedAt
+
+		entry, err := audit.NewEntry(ctx, audit.EntityFeedback, audit.ActionUpdate, feedbackID, r.feedbacks[position], after)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		entries = append(entries, entry)
+		anonymized = append(anonymized, after)
+	}
+
+	for _, feedback := range anonymized {
+		r.feedbacks[r.index[feedback.ID]] = clone(feedback)
+	}
+
+	r.audit.record(entries...)
+
+	r.logger.Info("Feedbacks anonymized", logger.M{"count": len(anonymized)})
+
+	return anonymized, nil
+}
+
+func matchExpired(feedback *models.Feedback, filter *models.RetentionFilter) bool {
+	switch {
+	case !feedback.CreatedAt.Before(filter.CreatedBefore):
+		return false
+	case filter.Tenant != "" && feedback.Tenant != filter.Tenant:
+		return false
+	case filter.SourceHost != "" && feedback.SourceHost != filter.SourceHost:
+		return false
+	case filter.WithPersonalData && !hasPersonalData(feedback):
+		return false
+	default:
+		return true
+	}
+}
+
+func hasPersonalData(feedback *models.Feedback) bool {
+	return feedback.CustomerName != "" || feedback.Email != ""
+}
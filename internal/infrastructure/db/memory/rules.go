@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type RuleRepository struct {
+	mu     sync.Mutex
+	rules  map[uuid.UUID]*models.Rule
+	audit  *AuditRepository
+	logger logger.Logger
+}
+
+// NewRuleRepository records the changes to the audit log under the lock of the change.
+func NewRuleRepository(auditLog *AuditRepository, logger logger.Logger) *RuleRepository {
+	return &RuleRepository{
+		mu:     sync.Mutex{},
+		rules:  make(map[uuid.UUID]*models.Rule),
+		audit:  auditLog,
+		logger: logger.Named("memoryRules"),
+	}
+}
+
+func (r *RuleRepository) Create(ctx context.Context, rule *models.Rule) (uuid.UUID, error) {
+	rule.ID = uuid.New()
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = time.Now()
+
+	entry, err := audit.NewEntry(ctx, audit.EntityRule, audit.ActionCreate, rule.ID, nil, rule)
+	if err != nil {
+		return uuid.Nil, err //nolint:wrapcheck
+	}
+
+	ruleCopy := *rule
+
+	defer r.audit.flush()
+
+	r.mu.Lock()
+	r.rules[rule.ID] = &ruleCopy
+	r.audit.record(entry)
+	r.mu.Unlock()
+
+	r.logger.Info("Rule created", logger.M{"ruleID": rule.ID})
+
+	return rule.ID, nil
+}
+
+func (r *RuleRepository) GetByID(_ context.Context, ruleID uuid.UUID) (*models.Rule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rule, ok := r.rules[ruleID]
+	if !ok {
+		return nil, fmt.Errorf("rule not found for ID '%s'", ruleID) //nolint:goerr113
+	}
+
+	ruleCopy := *rule
+
+	return &ruleCopy, nil
+}
+
+func (r *RuleRepository) GetAll(_ context.Context) ([]*models.Rule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rules := make([]*models.Rule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		ruleCopy := *rule
+		rules = append(rules, &ruleCopy)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Position != rules[j].Position {
+			return rules[i].Position < rules[j].Position
+		}
+
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+
+	return rules, nil
+}
+
+func (r *RuleRepository) Update(ctx context.Context, rule *models.Rule) error {
+	defer r.audit.flush()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.rules[rule.ID]
+	if !ok {
+		return fmt.Errorf("rule not found for ID '%s'", rule.ID) //nolint:goerr113
+	}
+
+	rule.UpdatedAt = time.Now()
+
+	entry, err := audit.NewEntry(ctx, audit.EntityRule, audit.ActionUpdate, rule.ID, before, rule)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	ruleCopy := *rule
+	r.rules[rule.ID] = &ruleCopy
+	r.audit.record(entry)
+
+	return nil
+}
+
+func (r *RuleRepository) Delete(ctx context.Context, ruleID uuid.UUID) error {
+	defer r.audit.flush()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.rules[ruleID]
+	if !ok {
+		return fmt.Errorf("rule not found for ID '%s'", ruleID) //nolint:goerr113
+	}
+
+	entry, err := audit.NewEntry(ctx, audit.EntityRule, audit.ActionDelete, ruleID, before, nil)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	delete(r.rules, ruleID)
+	r.audit.record(entry)
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+)
+
+const monthLayout = "2006-01"
+
+// GetStats counts the feedbacks like the SQL repositories: the groups are ordered by the dimensions,
+// the feedback with several tags is counted in the group of every tag.
+func (r *FeedbackRepository) GetStats(
+	_ context.Context,
+	groupBy []string,
+	filter *models.FeedbackFilter,
+) ([]*models.FeedbackStat, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var (
+		keys  [][]string
+		stats = make(map[string]*models.FeedbackStat)
+	)
+
+	for _, feedback := range r.feedbacks {
+		if !matchFilter(feedback, filter) {
+			continue
+		}
+
+		tags := feedback.Tags
+		if len(tags) == 0 {
+			tags = []string{""}
+		}
+
+		for _, tag := range tags {
+			key := statsKey(feedback, tag, groupBy)
+			joined := strings.Join(key, "\xff")
+
+			stat, ok := stats[joined]
+			if !ok {
+				stat = &models.FeedbackStat{
+					Group:   make(map[string]string, len(groupBy)),
+					Count:   0,
+					FirstAt: feedback.CreatedAt.UTC(),
+					LastAt:  feedback.CreatedAt.UTC(),
+				}
+
+				for i, dimension := range groupBy {
+					stat.Group[dimension] = key[i]
+				}
+
+				stats[joined] = stat
+				keys = append(keys, key)
+			}
+
+			stat.Count++
+
+			if feedback.CreatedAt.Before(stat.FirstAt) {
+				stat.FirstAt = feedback.CreatedAt.UTC()
+			}
+
+			if feedback.CreatedAt.After(stat.LastAt) {
+				stat.LastAt = feedback.CreatedAt.UTC()
+			}
+
+			if !containsString(groupBy, models.StatsByTag) {
+				break
+			}
+		}
+	}
+
+	// Without dimensions SQL returns one row even for no feedbacks.
+	if len(groupBy) == 0 && len(keys) == 0 {
+		//nolint:exhaustivestruct,exhaustruct
+		return []*models.FeedbackStat{{Group: map[string]string{}}}, nil
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		for position := range keys[i] {
+			if keys[i][position] != keys[j][position] {
+				return keys[i][position] < keys[j][position]
+			}
+		}
+
+		return false
+	})
+
+	result := make([]*models.FeedbackStat, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, stats[strings.Join(key, "\xff")])
+	}
+
+	return result, nil
+}
+
+func statsKey(feedback *models.Feedback, tag string, groupBy []string) []string {
+	createdAt := feedback.CreatedAt.UTC()
+	key := make([]string, 0, len(groupBy))
+
+	for _, dimension := range groupBy {
+		switch dimension {
+		case models.StatsBySource:
+			key = append(key, feedback.SourceHost)
+		case models.StatsByDay:
+			key = append(key, createdAt.Format(dayLayout))
+		case models.StatsByWeek:
+			// Monday of the week.
+			offset := (int(createdAt.Weekday()) + 6) % 7 //nolint:gomnd
+			key = append(key, createdAt.AddDate(0, 0, -offset).Format(dayLayout))
+		case models.StatsByMonth:
+			key = append(key, createdAt.Format(monthLayout))
+		case models.StatsByTag:
+			key = append(key, tag)
+		default:
+			key = append(key, "")
+		}
+	}
+
+	return key
+}
+
+func containsString(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,287 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type WebhookRepository struct {
+	mu         sync.Mutex
+	webhooks   map[uuid.UUID]*models.Webhook
+	deliveries map[uuid.UUID]*models.WebhookDelivery
+	audit      *AuditRepository
+	ids        ids.IDGenerator
+	logger     logger.Logger
+}
+
+// NewWebhookRepository records the changes to the audit log under the lock of the change like RuleRepository.
+func NewWebhookRepository(auditLog *AuditRepository, generator ids.IDGenerator, logger logger.Logger) *WebhookRepository {
+	return &WebhookRepository{
+		mu:         sync.Mutex{},
+		webhooks:   make(map[uuid.UUID]*models.Webhook),
+		deliveries: make(map[uuid.UUID]*models.WebhookDelivery),
+		audit:      auditLog,
+		ids:        generator,
+		logger:     logger.Named("memoryWebhooks"),
+	}
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, webhook *models.Webhook) (uuid.UUID, error) {
+	webhook.ID = uuid.New()
+	webhook.CreatedAt = time.Now()
+	webhook.UpdatedAt = time.Now()
+
+	entry, err := audit.NewEntry(ctx, audit.EntityWebhook, audit.ActionCreate, webhook.ID, nil, webhook)
+	if err != nil {
+		return uuid.Nil, err //nolint:wrapcheck
+	}
+
+	defer r.audit.flush()
+
+	r.mu.Lock()
+	r.webhooks[webhook.ID] = copyWebhook(webhook)
+	r.audit.record(entry)
+	r.mu.Unlock()
+
+	r.logger.Info("Webhook created", logger.M{"webhookID": webhook.ID})
+
+	return webhook.ID, nil
+}
+
+func (r *WebhookRepository) GetByID(_ context.Context, webhookID uuid.UUID) (*models.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	webhook, ok := r.webhooks[webhookID]
+	if !ok {
+		return nil, fmt.Errorf("webhook not found for ID '%s'", webhookID) //nolint:goerr113
+	}
+
+	return copyWebhook(webhook), nil
+}
+
+func (r *WebhookRepository) GetAll(_ context.Context) ([]*models.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	webhooks := make([]*models.Webhook, 0, len(r.webhooks))
+	for _, webhook := range r.webhooks {
+		webhooks = append(webhooks, copyWebhook(webhook))
+	}
+
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+
+	return webhooks, nil
+}
+
+func (r *WebhookRepository) Update(ctx context.Context, webhook *models.Webhook) error {
+	defer r.audit.flush()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.webhooks[webhook.ID]
+	if !ok {
+		return fmt.Errorf("webhook not found for ID '%s'", webhook.ID) //nolint:goerr113
+	}
+
+	webhook.UpdatedAt = time.Now()
+
+	entry, err := audit.NewEntry(ctx, audit.EntityWebhook, audit.ActionUpdate, webhook.ID, before, webhook)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	r.webhooks[webhook.ID] = copyWebhook(webhook)
+	r.audit.record(entry)
+
+	return nil
+}
+
+func (r *WebhookRepository) Delete(ctx context.Context, webhookID uuid.UUID) error {
+	defer r.audit.flush()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.webhooks[webhookID]
+	if !ok {
+		return fmt.Errorf("webhook not found for ID '%s'", webhookID) //nolint:goerr113
+	}
+
+	entry, err := audit.NewEntry(ctx, audit.EntityWebhook, audit.ActionDelete, webhookID, before, nil)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	delete(r.webhooks, webhookID)
+
+	for deliveryID, delivery := range r.deliveries {
+		if delivery.WebhookID == webhookID {
+			delete(r.deliveries, deliveryID)
+		}
+	}
+
+	r.audit.record(entry)
+
+	return nil
+}
+
+func (r *WebhookRepository) Counted(_ context.Context, webhookID uuid.UUID, failed bool, disableAfter int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	webhook, ok := r.webhooks[webhookID]
+	if !ok {
+		return false, fmt.Errorf("webhook not found for ID '%s'", webhookID) //nolint:goerr113
+	}
+
+	if !failed {
+		webhook.Failures = 0
+
+		return false, nil
+	}
+
+	webhook.Failures++
+	disabled := webhook.Enabled && disableAfter > 0 && webhook.Failures >= disableAfter
+	webhook.Enabled = webhook.Enabled && !disabled
+
+	return disabled, nil
+}
+
+func (r *WebhookRepository) AddDeliveries(_ context.Context, deliveries []*models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, delivery := range deliveries {
+		delivery.ID = r.ids.New()
+		delivery.CreatedAt = time.Now().UTC()
+		r.deliveries[delivery.ID] = copyDelivery(delivery)
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) Due(_ context.Context, moment time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deliveries := make([]*models.WebhookDelivery, 0, limit)
+
+	for _, delivery := range r.deliveries {
+		if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(moment) {
+			deliveries = append(deliveries, copyDelivery(delivery))
+		}
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].NextAttemptAt.Before(deliveries[j].NextAttemptAt)
+	})
+
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) Claim(_ context.Context, deliveryID uuid.UUID, moment, until time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delivery, ok := r.deliveries[deliveryID]
+	if !ok || delivery.Status != models.DeliveryPending || delivery.NextAttemptAt.After(moment) {
+		return false, nil
+	}
+
+	delivery.NextAttemptAt = until
+
+	return true, nil
+}
+
+func (r *WebhookRepository) SaveDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The delivery of the deleted webhook is gone.
+	if _, ok := r.deliveries[delivery.ID]; ok {
+		r.deliveries[delivery.ID] = copyDelivery(delivery)
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) Deliveries(
+	_ context.Context,
+	webhookID uuid.UUID,
+	limit int,
+	before uuid.UUID,
+) ([]*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deliveries := make([]*models.WebhookDelivery, 0, limit)
+
+	for _, delivery := range r.deliveries {
+		if delivery.WebhookID == webhookID && (before == uuid.Nil || bytes.Compare(delivery.ID[:], before[:]) < 0) {
+			deliveries = append(deliveries, copyDelivery(delivery))
+		}
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return bytes.Compare(deliveries[i].ID[:], deliveries[j].ID[:]) > 0
+	})
+
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) DeleteDeliveriesBefore(_ context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+
+	for deliveryID, delivery := range r.deliveries {
+		if delivery.Status != models.DeliveryPending && delivery.CreatedAt.Before(before) {
+			delete(r.deliveries, deliveryID)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func copyWebhook(webhook *models.Webhook) *models.Webhook {
+	webhookCopy := *webhook
+	webhookCopy.EventTypes = append([]string(nil), webhook.EventTypes...)
+
+	return &webhookCopy
+}
+
+func copyDelivery(delivery *models.WebhookDelivery) *models.WebhookDelivery {
+	deliveryCopy := *delivery
+
+	if delivery.CompletedAt != nil {
+		completedAt := *delivery.CompletedAt
+		deliveryCopy.CompletedAt = &completedAt
+	}
+
+	return &deliveryCopy
+}
@@ -0,0 +1,492 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+
+	// Random constant key for pg_advisory_lock,
+	// only one replica runs the migrations at the same time.
+	advisoryLockKey = 7_203_117_522
+
+	createTableTemplate = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    bigint PRIMARY KEY,
+    name       text NOT NULL,
+    applied_at %s NOT NULL
+)`
+)
+
+var (
+	ErrSchemaBehind   = errors.New("database schema is behind")
+	ErrUnknownVersion = errors.New("unknown migration version")
+	errWrongFileName  = errors.New("wrong migration file name")
+	errMissingFile    = errors.New("missing up or down migration")
+	errUnknownDialect = errors.New("unknown SQL dialect")
+
+	// 0001_create_feedbacks.up.sql.
+	fileNameRegex = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+	//go:embed postgres/*.sql
+	postgresFiles embed.FS
+
+	//go:embed sqlite/*.sql
+	sqliteFiles embed.FS
+)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status of one known migration.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies embedded SQL migrations and
+// records them into the 'schema_migrations' table.
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []*Migration
+	logger     logger.Logger
+}
+
+func New(db *sql.DB, dialect string, log logger.Logger) (*Migrator, error) {
+	var files fs.FS
+
+	switch dialect {
+	case Postgres:
+		files = postgresFiles
+	case SQLite:
+		files = sqliteFiles
+	default:
+		return nil, fmt.Errorf("dialect '%s': %w", dialect, errUnknownDialect)
+	}
+
+	migrations, err := load(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("can't load migrations: %w", err)
+	}
+
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+		logger:     log.Named("migrate"),
+	}, nil
+}
+
+// Latest returns version of the last known migration.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the current version of the database schema.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return 0, err
+	}
+
+	var current int64
+
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+
+	return current, nil
+}
+
+// Check returns ErrSchemaBehind if some migrations are not applied.
+func (m *Migrator) Check(ctx context.Context) error {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	if current < m.Latest() {
+		return fmt.Errorf(
+			"current version %d, latest %d, run 'migrate up': %w",
+			current, m.Latest(), ErrSchemaBehind,
+		)
+	}
+
+	if current > m.Latest() {
+		m.logger.Warn("Database schema is ahead of the application", logger.M{
+			"current": current,
+			"latest":  m.Latest(),
+		})
+	}
+
+	return nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*Status, 0, len(m.migrations))
+
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, &Status{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+
+	return statuses, nil
+}
+
+// Up applies all not applied migrations.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rolls back the last applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	var previous int64
+
+	for _, migration := range m.migrations {
+		if migration.Version < current {
+			previous = migration.Version
+		}
+	}
+
+	return m.To(ctx, previous)
+}
+
+// To migrates up or down to the version, 0 rolls back everything.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("version %d: %w", version, ErrUnknownVersion)
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("can't get DB connection: %w", err)
+	}
+	defer conn.Close()
+
+	err = m.lock(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	defer m.unlock(conn)
+
+	_, err = conn.ExecContext(ctx, m.createTableSQL())
+	if err != nil {
+		return fmt.Errorf("can't create schema_migrations: %w", err)
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	// Up in ascending order.
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > version {
+			continue
+		}
+
+		err = m.apply(ctx, conn, migration, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Down in descending order.
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+			continue
+		}
+
+		err = m.apply(ctx, conn, migration, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration *Migration, up bool) error {
+	direction, query := "up", migration.Up
+	if !up {
+		direction, query = "down", migration.Down
+	}
+
+	m.logger.Info("Applying migration", logger.M{
+		"version":   migration.Version,
+		"name":      migration.Name,
+		"direction": direction,
+	})
+
+	//nolint:exhaustivestruct,exhaustruct
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("can't begin transaction: %w", err)
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	_, err = tx.ExecContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("migration %d %s: %w", migration.Version, direction, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx,
+			m.rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+			migration.Version, migration.Name, time.Now().UTC(),
+		)
+	} else {
+		_, err = tx.ExecContext(ctx,
+			m.rebind("DELETE FROM schema_migrations WHERE version = ?"),
+			migration.Version,
+		)
+	}
+
+	if err != nil {
+		return fmt.Errorf("migration %d: can't update schema_migrations: %w", migration.Version, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("migration %d: can't commit: %w", migration.Version, err)
+	}
+
+	return nil
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// applied returns versions with the time of applying.
+func (m *Migrator) applied(ctx context.Context, db querier) (map[int64]time.Time, error) {
+	applied := make(map[int64]time.Time)
+
+	exists, err := m.tableExists(ctx, db)
+	if err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("can't read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("can't scan schema_migrations: %w", err)
+		}
+
+		applied[version] = appliedAt
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't read schema_migrations: %w", err)
+	}
+
+	return applied, nil
+}
+
+func (m *Migrator) tableExists(ctx context.Context, db querier) (bool, error) {
+	var exists bool
+
+	query := "SELECT to_regclass('schema_migrations') IS NOT NULL"
+	if m.dialect == SQLite {
+		query = "SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'"
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return false, fmt.Errorf("can't check schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		if err = rows.Scan(&exists); err != nil {
+			return false, fmt.Errorf("can't check schema_migrations: %w", err)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return false, fmt.Errorf("can't check schema_migrations: %w", err)
+	}
+
+	return exists, nil
+}
+
+// createTableSQL returns DDL of 'schema_migrations' for the dialect.
+func (m *Migrator) createTableSQL() string {
+	// SQLite driver parses only DATE, DATETIME and TIMESTAMP columns as time.
+	if m.dialect == SQLite {
+		return fmt.Sprintf(createTableTemplate, "datetime")
+	}
+
+	return fmt.Sprintf(createTableTemplate, "timestamptz")
+}
+
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) error {
+	// SQLite has only one writer, the transactions of migrations
+	// are serialized by the database file lock.
+	if m.dialect == SQLite {
+		return nil
+	}
+
+	m.logger.Info("Waiting for the migration lock", nil)
+
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockKey)
+	if err != nil {
+		return fmt.Errorf("can't get the migration lock: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Migrator) unlock(conn *sql.Conn) {
+	if m.dialect == SQLite {
+		return
+	}
+
+	// The lock must be released even if the context is cancelled.
+	_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", advisoryLockKey)
+	if err != nil {
+		m.logger.Error("Can't release the migration lock", logger.M{"err": err})
+	}
+}
+
+// rebind replaces '?' placeholders for the dialect.
+func (m *Migrator) rebind(query string) string {
+	if m.dialect != Postgres {
+		return query
+	}
+
+	var (
+		result = make([]byte, 0, len(query))
+		index  = 0
+	)
+
+	for i := 0; i < len(query); i++ {
+		if query[i] != '?' {
+			result = append(result, query[i])
+
+			continue
+		}
+
+		index++
+		result = append(result, '$')
+		result = strconv.AppendInt(result, int64(index), 10) //nolint:gomnd
+	}
+
+	return string(result)
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+
+	return nil
+}
+
+// load reads '<version>_<name>.(up|down).sql' files from the dialect directory.
+func load(files fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, fmt.Errorf("can't read migrations directory: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		parts := fileNameRegex.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("file '%s': %w", entry.Name(), errWrongFileName)
+		}
+
+		version, _ := strconv.ParseInt(parts[1], 10, 64) //nolint:gomnd
+
+		content, err := fs.ReadFile(files, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("can't read '%s': %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			//nolint:exhaustivestruct,exhaustruct
+			migration = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = migration
+		}
+
+		if parts[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("version %d: %w", migration.Version, errMissingFile)
+		}
+
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,10 @@
+-- IF NOT EXISTS: the table could be already created by AutoMigrate before the migrations.
+CREATE TABLE IF NOT EXISTS feedbacks (
+    id            uuid PRIMARY KEY,
+    customer_name text,
+    email         text,
+    feedback_text text,
+    source        text,
+    created_at    timestamptz,
+    updated_at    timestamptz
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_feedbacks_sentiment_label;
+DROP INDEX IF EXISTS idx_feedbacks_source_host;
+
+ALTER TABLE feedbacks DROP COLUMN IF EXISTS sentiment_label;
+ALTER TABLE feedbacks DROP COLUMN IF EXISTS sentiment_score;
+ALTER TABLE feedbacks DROP COLUMN IF EXISTS source_host;
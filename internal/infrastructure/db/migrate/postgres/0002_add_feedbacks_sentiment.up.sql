@@ -0,0 +1,11 @@
+ALTER TABLE feedbacks ADD COLUMN IF NOT EXISTS source_host text NOT NULL DEFAULT '';
+ALTER TABLE feedbacks ADD COLUMN IF NOT EXISTS sentiment_score double precision NOT NULL DEFAULT 0;
+ALTER TABLE feedbacks ADD COLUMN IF NOT EXISTS sentiment_label text NOT NULL DEFAULT '';
+
+-- Old rows don't have the host.
+UPDATE feedbacks
+SET source_host = lower(substring(source FROM '^[a-zA-Z]+://([^/:?#]+)'))
+WHERE source_host = '' AND source ~ '^[a-zA-Z]+://[^/:?#]+';
+
+CREATE INDEX IF NOT EXISTS idx_feedbacks_source_host ON feedbacks (source_host);
+CREATE INDEX IF NOT EXISTS idx_feedbacks_sentiment_label ON feedbacks (sentiment_label);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_feedbacks_team;
+
+ALTER TABLE feedbacks DROP COLUMN IF EXISTS priority;
+ALTER TABLE feedbacks DROP COLUMN IF EXISTS team;
+ALTER TABLE feedbacks DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,6 @@
+-- Tags are JSON array of strings.
+ALTER TABLE feedbacks ADD COLUMN IF NOT EXISTS tags text NOT NULL DEFAULT '';
+ALTER TABLE feedbacks ADD COLUMN IF NOT EXISTS team text NOT NULL DEFAULT '';
+ALTER TABLE feedbacks ADD COLUMN IF NOT EXISTS priority text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_feedbacks_team ON feedbacks (team);
@@ -0,0 +1,2 @@
+-- Full-text search over the feedback text, see the 'q' filter.
+CREATE INDEX IF NOT EXISTS idx_feedbacks_search ON feedbacks USING gin (to_tsvector('simple', feedback_text));
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS audit_entries (
+    id            uuid PRIMARY KEY,
+    entity        text NOT NULL,
+    entity_id     uuid NOT NULL,
+    action        text NOT NULL,
+    actor_subject text NOT NULL DEFAULT '',
+    actor_role    text NOT NULL DEFAULT '',
+    client_ip     text NOT NULL DEFAULT '',
+    request_id    text NOT NULL DEFAULT '',
+    before        jsonb,
+    after         jsonb,
+    diff          jsonb,
+    created_at    timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_entries_entity ON audit_entries (entity, entity_id, created_at);
+
+-- The audit log is append-only, the rows can't be changed even by the service.
+CREATE OR REPLACE FUNCTION audit_entries_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_entries_append_only
+    BEFORE UPDATE OR DELETE ON audit_entries
+    FOR EACH ROW EXECUTE FUNCTION audit_entries_append_only();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_feedbacks_email_index;
+
+ALTER TABLE feedbacks DROP COLUMN IF EXISTS email_index;
@@ -0,0 +1,5 @@
+-- Tenant of the request which created the feedback (X-Tenant-ID), empty for the rows before it.
+-- Retention policies select the expired rows by tenant, source host and created_at.
+ALTER TABLE feedbacks ADD COLUMN IF NOT EXISTS tenant text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_feedbacks_tenant_created_at ON feedbacks (tenant, created_at);
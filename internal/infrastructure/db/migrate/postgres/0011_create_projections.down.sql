@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS projection_latest_feedbacks;
+DROP TABLE IF EXISTS projection_search;
+DROP TABLE IF EXISTS projection_daily_sources;
+DROP TABLE IF EXISTS projection_events;
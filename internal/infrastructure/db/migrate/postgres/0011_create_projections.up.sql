@@ -0,0 +1,45 @@
+-- Read models of the 'worker' command, they are built from the feedback events.
+-- Every event is applied once, the consumer delivers them at least once.
+CREATE TABLE IF NOT EXISTS projection_events (
+    event_id   text PRIMARY KEY,
+    applied_at timestamptz NOT NULL
+);
+
+-- The IDs of the applied events are pruned by the time, see PROJECTION_EVENT_TTL.
+CREATE INDEX IF NOT EXISTS idx_projection_events_applied_at ON projection_events (applied_at);
+
+-- Counters per source host and day, the sentiment label is kept for the 'sentiment' filter.
+CREATE TABLE IF NOT EXISTS projection_daily_sources (
+    day             date NOT NULL,
+    source_host     text NOT NULL,
+    sentiment_label text NOT NULL,
+    count           bigint NOT NULL,
+    score_sum       double precision NOT NULL,
+    first_at        timestamptz NOT NULL,
+    last_at         timestamptz NOT NULL,
+    PRIMARY KEY (day, source_host, sentiment_label)
+);
+
+-- Search index: one row per feedback, the counters are decremented by it on deletion.
+CREATE TABLE IF NOT EXISTS projection_search (
+    feedback_id     uuid PRIMARY KEY,
+    day             date NOT NULL,
+    source_host     text NOT NULL,
+    sentiment_label text NOT NULL,
+    sentiment_score double precision NOT NULL,
+    created_at      timestamptz NOT NULL,
+    document        text NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_projection_search_document ON projection_search USING gin (to_tsvector('simple', document));
+
+-- The latest feedbacks of every source host, the older ones are trimmed by the worker.
+CREATE TABLE IF NOT EXISTS projection_latest_feedbacks (
+    feedback_id     uuid PRIMARY KEY,
+    source_host     text NOT NULL,
+    feedback_text   text NOT NULL,
+    sentiment_label text NOT NULL,
+    created_at      timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_projection_latest_feedbacks_source ON projection_latest_feedbacks (source_host, created_at);
@@ -0,0 +1,14 @@
+-- Events which the broker failed to publish, they are replayed by 'dlq replay' or POST /dlq/replay.
+-- Only the feedback ID is kept, the replay publishes the current state of the feedback.
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id              uuid PRIMARY KEY,
+    event_type      text NOT NULL,
+    feedback_id     uuid NOT NULL,
+    tenant          text NOT NULL DEFAULT '',
+    error           text NOT NULL,
+    attempts        integer NOT NULL,
+    created_at      timestamptz NOT NULL,
+    last_attempt_at timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_dead_letters_created_at ON dead_letters (created_at);
@@ -0,0 +1,36 @@
+-- Subscriptions of the webhooks and the log of their deliveries, see the webhooks service.
+CREATE TABLE IF NOT EXISTS webhooks (
+    id          uuid PRIMARY KEY,
+    url         text NOT NULL,
+    event_types text NOT NULL DEFAULT '[]',
+    secret      text NOT NULL,
+    include_pii boolean NOT NULL DEFAULT false,
+    tenant      text NOT NULL DEFAULT '',
+    source_host text NOT NULL DEFAULT '',
+    sentiment   text NOT NULL DEFAULT '',
+    tag         text NOT NULL DEFAULT '',
+    team        text NOT NULL DEFAULT '',
+    enabled     boolean NOT NULL DEFAULT true,
+    failures    integer NOT NULL DEFAULT 0,
+    created_at  timestamptz,
+    updated_at  timestamptz
+);
+
+-- Only the feedback ID is kept, the payload is built by the current state of the feedback.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              uuid PRIMARY KEY,
+    webhook_id      uuid NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_type      text NOT NULL,
+    feedback_id     uuid NOT NULL,
+    tenant          text NOT NULL DEFAULT '',
+    status          text NOT NULL,
+    attempts        integer NOT NULL DEFAULT 0,
+    response_status integer NOT NULL DEFAULT 0,
+    error           text NOT NULL DEFAULT '',
+    next_attempt_at timestamptz NOT NULL,
+    created_at      timestamptz NOT NULL,
+    completed_at    timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS feedbacks (
+    id            text PRIMARY KEY,
+    customer_name text,
+    email         text,
+    feedback_text text,
+    source        text,
+    created_at    datetime,
+    updated_at    datetime
+);
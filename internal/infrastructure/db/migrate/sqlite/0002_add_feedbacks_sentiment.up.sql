@@ -0,0 +1,6 @@
+ALTER TABLE feedbacks ADD COLUMN source_host text NOT NULL DEFAULT '';
+ALTER TABLE feedbacks ADD COLUMN sentiment_score real NOT NULL DEFAULT 0;
+ALTER TABLE feedbacks ADD COLUMN sentiment_label text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_feedbacks_source_host ON feedbacks (source_host);
+CREATE INDEX IF NOT EXISTS idx_feedbacks_sentiment_label ON feedbacks (sentiment_label);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_feedbacks_team;
+
+ALTER TABLE feedbacks DROP COLUMN priority;
+ALTER TABLE feedbacks DROP COLUMN team;
+ALTER TABLE feedbacks DROP COLUMN tags;
@@ -0,0 +1,6 @@
+-- Tags are JSON array of strings.
+ALTER TABLE feedbacks ADD COLUMN tags text NOT NULL DEFAULT '';
+ALTER TABLE feedbacks ADD COLUMN team text NOT NULL DEFAULT '';
+ALTER TABLE feedbacks ADD COLUMN priority text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_feedbacks_team ON feedbacks (team);
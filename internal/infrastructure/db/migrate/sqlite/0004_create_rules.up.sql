@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS rules (
+    id           text PRIMARY KEY,
+    name         text NOT NULL DEFAULT '',
+    text_pattern text NOT NULL DEFAULT '',
+    source_host  text NOT NULL DEFAULT '',
+    tags         text NOT NULL DEFAULT '',
+    team         text NOT NULL DEFAULT '',
+    priority     text NOT NULL DEFAULT '',
+    position     integer NOT NULL DEFAULT 0,
+    enabled      boolean NOT NULL DEFAULT true,
+    created_at   datetime,
+    updated_at   datetime
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_feedbacks_created_at;
@@ -0,0 +1,2 @@
+-- Listings and pagination are ordered by created_at.
+CREATE INDEX IF NOT EXISTS idx_feedbacks_created_at ON feedbacks (created_at);
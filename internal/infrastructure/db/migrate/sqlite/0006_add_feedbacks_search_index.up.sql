@@ -0,0 +1,3 @@
+-- SQLite falls back to LIKE for the 'q' filter, there is nothing to index.
+-- The version is kept to have the same numbers as Postgres.
+SELECT 1;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS audit_entries (
+    id            text PRIMARY KEY,
+    entity        text NOT NULL,
+    entity_id     text NOT NULL,
+    action        text NOT NULL,
+    actor_subject text NOT NULL DEFAULT '',
+    actor_role    text NOT NULL DEFAULT '',
+    client_ip     text NOT NULL DEFAULT '',
+    request_id    text NOT NULL DEFAULT '',
+    before        text,
+    after         text,
+    diff          text,
+    created_at    datetime NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_entries_entity ON audit_entries (entity, entity_id, created_at);
+
+-- The audit log is append-only, the rows can't be changed even by the service.
+CREATE TRIGGER IF NOT EXISTS audit_entries_no_update BEFORE UPDATE ON audit_entries
+BEGIN
+    SELECT RAISE(ABORT, 'audit_entries is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_entries_no_delete BEFORE DELETE ON audit_entries
+BEGIN
+    SELECT RAISE(ABORT, 'audit_entries is append-only');
+END;
@@ -0,0 +1,5 @@
+-- HMAC of the normalized email, the email itself is encrypted, see the envelope package.
+-- Empty index: the row is saved before the encryption was on, 'rotate-keys' fills it.
+ALTER TABLE feedbacks ADD COLUMN email_index text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_feedbacks_email_index ON feedbacks (email_index);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_feedbacks_tenant_created_at;
+
+ALTER TABLE feedbacks DROP COLUMN tenant;
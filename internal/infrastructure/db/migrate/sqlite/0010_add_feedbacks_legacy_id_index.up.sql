@@ -0,0 +1,3 @@
+-- New IDs are time-ordered (UUIDv7), pages go by the primary key.
+-- The random IDs (UUIDv4) created before are paged by creation time, the partial index keeps only them.
+CREATE INDEX IF NOT EXISTS idx_feedbacks_legacy_id ON feedbacks (created_at, id) WHERE substr(id, 15, 1) <> '7';
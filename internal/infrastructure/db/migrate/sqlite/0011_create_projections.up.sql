@@ -0,0 +1,44 @@
+-- Read models of the 'worker' command, they are built from the feedback events.
+-- Every event is applied once, the consumer delivers them at least once.
+CREATE TABLE IF NOT EXISTS projection_events (
+    event_id   text PRIMARY KEY,
+    applied_at datetime NOT NULL
+);
+
+-- The IDs of the applied events are pruned by the time, see PROJECTION_EVENT_TTL.
+CREATE INDEX IF NOT EXISTS idx_projection_events_applied_at ON projection_events (applied_at);
+
+-- Counters per source host and day (YYYY-MM-DD), the sentiment label is kept for the 'sentiment' filter.
+CREATE TABLE IF NOT EXISTS projection_daily_sources (
+    day             text NOT NULL,
+    source_host     text NOT NULL,
+    sentiment_label text NOT NULL,
+    count           integer NOT NULL,
+    score_sum       real NOT NULL,
+    first_at        datetime NOT NULL,
+    last_at         datetime NOT NULL,
+    PRIMARY KEY (day, source_host, sentiment_label)
+);
+
+-- Search index: one row per feedback, the counters are decremented by it on deletion.
+-- SQLite falls back to LIKE, there is nothing to index for the document.
+CREATE TABLE IF NOT EXISTS projection_search (
+    feedback_id     text PRIMARY KEY,
+    day             text NOT NULL,
+    source_host     text NOT NULL,
+    sentiment_label text NOT NULL,
+    sentiment_score real NOT NULL,
+    created_at      datetime NOT NULL,
+    document        text NOT NULL
+);
+
+-- The latest feedbacks of every source host, the older ones are trimmed by the worker.
+CREATE TABLE IF NOT EXISTS projection_latest_feedbacks (
+    feedback_id     text PRIMARY KEY,
+    source_host     text NOT NULL,
+    feedback_text   text NOT NULL,
+    sentiment_label text NOT NULL,
+    created_at      datetime NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_projection_latest_feedbacks_source ON projection_latest_feedbacks (source_host, created_at);
@@ -0,0 +1,31 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	repo "github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// AuditRepository is the Gorm repository with writes through the single writer of DB,
+// the entries of the other repositories are written in their transactions.
+type AuditRepository struct {
+	*repo.AuditRepository
+	db *DB
+}
+
+func NewAuditRepository(db *DB, sink audit.Sink, timeout time.Duration, logger log.Logger) *AuditRepository {
+	return &AuditRepository{
+		AuditRepository: repo.NewAuditRepository(db.gorm, sink, timeout, logger.Named("sqlite")),
+		db:              db,
+	}
+}
+
+func (r *AuditRepository) Record(ctx context.Context, entry *models.AuditEntry) error {
+	return r.db.do(ctx, func() error {
+		return r.AuditRepository.Record(ctx, entry)
+	})
+}
@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	repo "github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// DeadLetterRepository is the Gorm repository with writes through the single writer of DB.
+type DeadLetterRepository struct {
+	*repo.DeadLetterRepository
+	db *DB
+}
+
+func NewDeadLetterRepository(
+	db *DB,
+	generator ids.IDGenerator,
+	timeout time.Duration,
+	logger log.Logger,
+) *DeadLetterRepository {
+	return &DeadLetterRepository{
+		DeadLetterRepository: repo.NewDeadLetterRepository(db.gorm, generator, timeout, logger.Named("sqlite")),
+		db:                   db,
+	}
+}
+
+func (r *DeadLetterRepository) Add(ctx context.Context, letters []*models.DeadLetter) error {
+	return r.db.do(ctx, func() error {
+		return r.DeadLetterRepository.Add(ctx, letters)
+	})
+}
+
+func (r *DeadLetterRepository) Retried(ctx context.Context, letterID uuid.UUID, reason string) error {
+	return r.db.do(ctx, func() error {
+		return r.DeadLetterRepository.Retried(ctx, letterID, reason)
+	})
+}
+
+func (r *DeadLetterRepository) Delete(ctx context.Context, letterIDs []uuid.UUID) (int, error) {
+	var deleted int
+
+	err := r.db.do(ctx, func() (err error) {
+		deleted, err = r.DeadLetterRepository.Delete(ctx, letterIDs)
+
+		return err
+	})
+
+	return deleted, err
+}
+
+func (r *DeadLetterRepository) DeleteBefore(ctx context.Context, before time.Time) (int, error) {
+	var deleted int
+
+	err := r.db.do(ctx, func() (err error) {
+		deleted, err = r.DeadLetterRepository.DeleteBefore(ctx, before)
+
+		return err
+	})
+
+	return deleted, err
+}
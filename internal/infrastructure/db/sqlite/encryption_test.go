@@ -0,0 +1,172 @@
+package sqlite_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/sqlite"
+	"github.com/andrsj/feedback-service/internal/infrastructure/envelope"
+)
+
+func key(fill byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(fill), 32)))
+}
+
+func newCipher(t *testing.T, keys, activeID string) *envelope.Cipher {
+	t.Helper()
+
+	cipher, err := envelope.New(keys, activeID, key('i'))
+	if err != nil {
+		t.Fatalf("envelope.New: %v", err)
+	}
+
+	return cipher
+}
+
+func migrated(t *testing.T) *sqlite.DB {
+	t.Helper()
+
+	db, migrator := open(t)
+
+	err := migrator.Up(context.Background())
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	return db
+}
+
+func withCipher(db *sqlite.DB, cipher *envelope.Cipher) *sqlite.FeedbackRepository {
+	auditLog := sqlite.NewAuditRepository(db, nil, time.Second, nopLogger{})
+
+	return sqlite.NewFeedbackRepository(db, auditLog.AuditRepository, cipher, ids.NewV7(), time.Second, nopLogger{})
+}
+
+func storedName(t *testing.T, db *sqlite.DB, feedback *models.Feedback) string {
+	t.Helper()
+
+	var name string
+
+	err := db.Gorm().Raw("SELECT customer_name FROM feedbacks WHERE id = ?", feedback.ID).Scan(&name).Error
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+
+	return name
+}
+
+func TestEncryptionAndKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := migrated(t)
+	repo := withCipher(db, newCipher(t, "k1:"+key('a'), ""))
+
+	feedback := newFeedback("first")
+
+	_, err := repo.Create(ctx, feedback)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if stored := storedName(t, db, feedback); !strings.HasPrefix(stored, "enc:v1:k1:") {
+		t.Errorf("stored name: got %q", stored)
+	}
+
+	// The email is found by the blind index.
+	var found []*models.Feedback
+
+	//nolint:exhaustivestruct,exhaustruct
+	err = repo.Iterate(ctx, &models.FeedbackFilter{Email: "JANE@example.com"}, 10, func(feedbacks []*models.Feedback) error {
+		found = append(found, feedbacks...)
+
+		return nil
+	})
+	if err != nil || len(found) != 1 || found[0].CustomerName != "Jane" {
+		t.Fatalf("Iterate by email: got %v, %v", found, err)
+	}
+
+	rotated := withCipher(db, newCipher(t, "k1:"+key('a')+",k2:"+key('b'), "k2"))
+
+	count, err := rotated.RotateKeys(ctx, 10)
+	if err != nil || count != 1 {
+		t.Fatalf("RotateKeys: got %d, %v", count, err)
+	}
+
+	if stored := storedName(t, db, feedback); !strings.HasPrefix(stored, "enc:v1:k2:") {
+		t.Errorf("stored name after the rotation: got %q", stored)
+	}
+
+	// Nothing is left for the second run.
+	if count, err = rotated.RotateKeys(ctx, 10); err != nil || count != 0 {
+		t.Errorf("second RotateKeys: got %d, %v", count, err)
+	}
+
+	read, err := rotated.GetByID(ctx, feedback.ID)
+	if err != nil || read.CustomerName != "Jane" || read.Email != "jane@example.com" {
+		t.Errorf("GetByID after the rotation: got %+v, %v", read, err)
+	}
+}
+
+func TestReservedPrefixAndBrokenRows(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := migrated(t)
+	repo := withCipher(db, nil)
+
+	reserved := newFeedback("reserved")
+	reserved.CustomerName = "enc:v1:x"
+
+	if _, err := repo.Create(ctx, reserved); !errors.Is(err, envelope.ErrReserved) {
+		t.Errorf("Create with the prefix: got %v, want %v", err, envelope.ErrReserved)
+	}
+
+	good, broken := newFeedback("good"), newFeedback("broken")
+
+	for _, feedback := range []*models.Feedback{good, broken} {
+		if _, err := repo.Create(ctx, feedback); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	// The value written around the repository (or by a lost key) fails only its own row.
+	err := db.Gorm().Exec("UPDATE feedbacks SET customer_name = 'enc:v1:x' WHERE id = ?", broken.ID).Error
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	if _, err = repo.GetByID(ctx, broken.ID); !errors.Is(err, envelope.ErrNoKeys) {
+		t.Errorf("GetByID of the broken row: got %v, want %v", err, envelope.ErrNoKeys)
+	}
+
+	page, _, err := repo.GetPage(ctx, 10, models.PageCursor{}, nil)
+	if err != nil || len(page) != 2 || page[0].CustomerName != "Jane" || page[1].CustomerName != "" {
+		t.Errorf("GetPage: got %v, %v", page, err)
+	}
+
+	count := 0
+
+	err = repo.Iterate(ctx, nil, 1, func(feedbacks []*models.Feedback) error {
+		count += len(feedbacks)
+
+		return nil
+	})
+	if err != nil || count != 2 {
+		t.Errorf("Iterate: got %d, %v", count, err)
+	}
+
+	// The rotation skips the broken row.
+	rotated := withCipher(db, newCipher(t, "k1:"+key('a'), ""))
+
+	rotatedCount, err := rotated.RotateKeys(ctx, 10)
+	if err != nil || rotatedCount != 1 {
+		t.Errorf("RotateKeys: got %d, %v", rotatedCount, err)
+	}
+}
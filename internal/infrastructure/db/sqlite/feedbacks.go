@@ -0,0 +1,87 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	repo "github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/infrastructure/envelope"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// FeedbackRepository reads like the Gorm repository,
+// the dialect differences (stats, search) are handled there.
+// Writes go through the single writer of DB. RotateKeys isn't queued,
+// it's run by the separate command, the busy timeout waits for the writer of the server.
+type FeedbackRepository struct {
+	*repo.FeedbackRepository
+	db *DB
+}
+
+func NewFeedbackRepository(
+	db *DB,
+	auditLog *repo.AuditRepository,
+	cipher *envelope.Cipher,
+	generator ids.IDGenerator,
+	timeout time.Duration,
+	logger log.Logger,
+) *FeedbackRepository {
+	return &FeedbackRepository{
+		FeedbackRepository: repo.NewFeedbackRepository(
+			db.gorm, nil, auditLog, cipher, generator, timeout, logger.Named("sqlite"),
+		),
+		db: db,
+	}
+}
+
+func (r *FeedbackRepository) Create(ctx context.Context, feedback *models.Feedback) (uuid.UUID, error) {
+	var feedbackID uuid.UUID
+
+	err := r.db.do(ctx, func() (err error) {
+		feedbackID, err = r.FeedbackRepository.Create(ctx, feedback)
+
+		return err
+	})
+
+	return feedbackID, err
+}
+
+func (r *FeedbackRepository) CreateBatch(ctx context.Context, feedbacks []*models.Feedback) error {
+	return r.db.do(ctx, func() error {
+		return r.FeedbackRepository.CreateBatch(ctx, feedbacks)
+	})
+}
+
+func (r *FeedbackRepository) Update(ctx context.Context, feedback *models.Feedback) error {
+	return r.db.do(ctx, func() error {
+		return r.FeedbackRepository.Update(ctx, feedback)
+	})
+}
+
+func (r *FeedbackRepository) Delete(ctx context.Context, feedbackIDs []uuid.UUID) (int, error) {
+	var deleted int
+
+	err := r.db.do(ctx, func() (err error) {
+		deleted, err = r.FeedbackRepository.Delete(ctx, feedbackIDs)
+
+		return err
+	})
+
+	return deleted, err
+}
+
+func (r *FeedbackRepository) Anonymize(ctx context.Context, feedbackIDs []uuid.UUID) ([]*models.Feedback, error) {
+	var anonymized []*models.Feedback
+
+	err := r.db.do(ctx, func() (err error) {
+		anonymized, err = r.FeedbackRepository.Anonymize(ctx, feedbackIDs)
+
+		return err
+	})
+
+	return anonymized, err
+}
@@ -0,0 +1,47 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	repo "github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// ProjectionRepository is the Gorm repository with the events applied through the single writer of DB.
+type ProjectionRepository struct {
+	*repo.ProjectionRepository
+	db *DB
+}
+
+func NewProjectionRepository(db *DB, timeout time.Duration, logger log.Logger) *ProjectionRepository {
+	return &ProjectionRepository{
+		ProjectionRepository: repo.NewProjectionRepository(db.gorm, nil, timeout, logger.Named("sqlite")),
+		db:                   db,
+	}
+}
+
+func (r *ProjectionRepository) Apply(ctx context.Context, event *models.FeedbackEvent, latest int) (bool, error) {
+	var applied bool
+
+	err := r.db.do(ctx, func() (err error) {
+		applied, err = r.ProjectionRepository.Apply(ctx, event, latest)
+
+		return err
+	})
+
+	return applied, err
+}
+
+func (r *ProjectionRepository) DeleteEventsBefore(ctx context.Context, before time.Time) (int, error) {
+	var deleted int
+
+	err := r.db.do(ctx, func() (err error) {
+		deleted, err = r.ProjectionRepository.DeleteEventsBefore(ctx, before)
+
+		return err
+	})
+
+	return deleted, err
+}
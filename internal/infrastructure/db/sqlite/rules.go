@@ -0,0 +1,49 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	repo "github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// RuleRepository is the Gorm repository with writes through the single writer of DB.
+type RuleRepository struct {
+	*repo.RuleRepository
+	db *DB
+}
+
+func NewRuleRepository(db *DB, auditLog *repo.AuditRepository, timeout time.Duration, logger log.Logger) *RuleRepository {
+	return &RuleRepository{
+		RuleRepository: repo.NewRuleRepository(db.gorm, auditLog, timeout, logger.Named("sqlite")),
+		db:             db,
+	}
+}
+
+func (r *RuleRepository) Create(ctx context.Context, rule *models.Rule) (uuid.UUID, error) {
+	var ruleID uuid.UUID
+
+	err := r.db.do(ctx, func() (err error) {
+		ruleID, err = r.RuleRepository.Create(ctx, rule)
+
+		return err
+	})
+
+	return ruleID, err
+}
+
+func (r *RuleRepository) Update(ctx context.Context, rule *models.Rule) error {
+	return r.db.do(ctx, func() error {
+		return r.RuleRepository.Update(ctx, rule)
+	})
+}
+
+func (r *RuleRepository) Delete(ctx context.Context, ruleID uuid.UUID) error {
+	return r.db.do(ctx, func() error {
+		return r.RuleRepository.Delete(ctx, ruleID)
+	})
+}
@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	sqliteDriver "github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+const writeQueueSize = 64
+
+var ErrClosed = errors.New("sqlite database is closed")
+
+// DB is SQLite database in one file.
+// Reads go directly to the pool of connections,
+// writes are executed one by one by the single writer,
+// so they never fail with "database is locked".
+type DB struct {
+	gorm    *gorm.DB
+	writes  chan *write
+	closing chan struct{}
+	done    chan struct{}
+	logger  log.Logger
+}
+
+type write struct {
+	ctx    context.Context //nolint:containedctx
+	call   func() error
+	result chan error
+}
+
+func Open(path string, logger log.Logger) (*DB, error) {
+	logger = logger.Named("sqlite")
+
+	//nolint:varnamelen
+	db, err := gorm.Open(
+		sqliteDriver.Open(dsn(path)),
+		//nolint:exhaustivestruct,exhaustruct
+		&gorm.Config{
+			Logger: gormLogger.Default.LogMode(gormLogger.Info),
+		},
+	)
+	if err != nil {
+		logger.Error("Can't open SQLite database", log.M{"err": err, "path": path})
+
+		return nil, fmt.Errorf("can't open SQLite database: %w", err)
+	}
+
+	sqliteDB := &DB{
+		gorm:    db,
+		writes:  make(chan *write, writeQueueSize),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+		logger:  logger,
+	}
+
+	go sqliteDB.run()
+
+	logger.Info("SQLite database is opened", log.M{"path": path})
+
+	return sqliteDB, nil
+}
+
+// dsn is the URI of the file, so the path may have '?' and '%'.
+// WAL lets readers work together with the writer,
+// busy_timeout waits for other processes, e.g. 'migrate' command.
+func dsn(path string) string {
+	query := url.Values{}
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "synchronous(NORMAL)")
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Set("_txlock", "immediate")
+
+	//nolint:exhaustivestruct,exhaustruct
+	file := &url.URL{Path: path}
+
+	return "file:" + file.EscapedPath() + "?" + query.Encode()
+}
+
+// Gorm returns the session for reads and for the migrations.
+func (d *DB) Gorm() *gorm.DB {
+	return d.gorm
+}
+
+// Close waits for the queued writes and closes the database.
+func (d *DB) Close() error {
+	close(d.closing)
+	<-d.done
+
+	sqlDB, err := d.gorm.DB()
+	if err != nil {
+		return fmt.Errorf("can't get SQL DB: %w", err)
+	}
+
+	err = sqlDB.Close()
+	if err != nil {
+		return fmt.Errorf("can't close SQLite database: %w", err)
+	}
+
+	return nil
+}
+
+// do puts the call into the writer queue and waits for the result.
+func (d *DB) do(ctx context.Context, call func() error) error {
+	job := &write{
+		ctx:    ctx,
+		call:   call,
+		result: make(chan error, 1),
+	}
+
+	select {
+	case d.writes <- job:
+	case <-d.closing:
+		return ErrClosed
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for the writer: %w", ctx.Err())
+	}
+
+	select {
+	case err := <-job.result:
+		return err
+	case <-d.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for the writer: %w", ctx.Err())
+	}
+}
+
+func (d *DB) run() {
+	defer close(d.done)
+
+	for {
+		select {
+		case job := <-d.writes:
+			d.exec(job)
+		case <-d.closing:
+			// Finish the queued writes.
+			for {
+				select {
+				case job := <-d.writes:
+					d.exec(job)
+				default:
+					d.logger.Info("SQLite writer is stopped", nil)
+
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *DB) exec(job *write) {
+	// The caller is gone, don't waste the writer time.
+	if err := job.ctx.Err(); err != nil {
+		job.result <- err
+
+		return
+	}
+
+	job.result <- job.call()
+}
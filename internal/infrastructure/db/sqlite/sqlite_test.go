@@ -0,0 +1,261 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/migrate"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/sqlite"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) logger.Logger { return l }
+func (nopLogger) Debug(string, logger.M)       {}
+func (nopLogger) Info(string, logger.M)        {}
+func (nopLogger) Warn(string, logger.M)        {}
+func (nopLogger) Error(string, logger.M)       {}
+func (nopLogger) Fatal(string, logger.M)       {}
+
+func open(t *testing.T) (*sqlite.DB, *migrate.Migrator) {
+	t.Helper()
+
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "feedbacks.db"), nopLogger{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	sqlDB, err := db.Gorm().DB()
+	if err != nil {
+		t.Fatalf("DB: %v", err)
+	}
+
+	migrator, err := migrate.New(sqlDB, migrate.SQLite, nopLogger{})
+	if err != nil {
+		t.Fatalf("migrate.New: %v", err)
+	}
+
+	return db, migrator
+}
+
+// newRepository returns the repository of the migrated database.
+func newRepository(t *testing.T, generator ids.IDGenerator) (*sqlite.FeedbackRepository, *sqlite.AuditRepository) {
+	t.Helper()
+
+	db, migrator := open(t)
+
+	err := migrator.Up(context.Background())
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	auditLog := sqlite.NewAuditRepository(db, nil, time.Second, nopLogger{})
+	repo := sqlite.NewFeedbackRepository(db, auditLog.AuditRepository, nil, generator, time.Second, nopLogger{})
+
+	return repo, auditLog
+}
+
+func newFeedback(text string) *models.Feedback {
+	//nolint:exhaustivestruct,exhaustruct
+	return &models.Feedback{
+		CustomerName:   "Jane",
+		Email:          "jane@example.com",
+		FeedbackText:   text,
+		Source:         "https://shop.example.com",
+		SentimentLabel: "neutral",
+		Tags:           []string{"shop"},
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	_, migrator := open(t)
+
+	version, err := migrator.Version(ctx)
+	if err != nil || version != 0 {
+		t.Fatalf("empty database: got version %d, %v", version, err)
+	}
+
+	if err = migrator.Check(ctx); !errors.Is(err, migrate.ErrSchemaBehind) {
+		t.Errorf("Check of empty database: got %v, want %v", err, migrate.ErrSchemaBehind)
+	}
+
+	// Every migration is applied, rolled back and applied again.
+	for round := 0; round < 2; round++ {
+		err = migrator.Up(ctx)
+		if err != nil {
+			t.Fatalf("Up: %v", err)
+		}
+
+		version, err = migrator.Version(ctx)
+		if err != nil || version != migrator.Latest() {
+			t.Fatalf("after Up: got version %d, %v, want %d", version, err, migrator.Latest())
+		}
+
+		if err = migrator.Check(ctx); err != nil {
+			t.Errorf("Check: %v", err)
+		}
+
+		if round == 0 {
+			err = migrator.To(ctx, 0)
+			if err != nil {
+				t.Fatalf("To 0: %v", err)
+			}
+		}
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil || int64(len(statuses)) != migrator.Latest() {
+		t.Fatalf("Status: got %d migrations, %v", len(statuses), err)
+	}
+
+	for _, status := range statuses {
+		if !status.Applied {
+			t.Errorf("migration %d %s isn't applied", status.Version, status.Name)
+		}
+	}
+
+	if err = migrator.To(ctx, migrator.Latest()+1); !errors.Is(err, migrate.ErrUnknownVersion) {
+		t.Errorf("To unknown version: got %v, want %v", err, migrate.ErrUnknownVersion)
+	}
+}
+
+func TestFeedbackRepository(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo, auditLog := newRepository(t, ids.NewV7())
+
+	feedbackID, err := repo.Create(ctx, newFeedback("first"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	feedback, err := repo.GetByID(ctx, feedbackID)
+	if err != nil || feedback.FeedbackText != "first" || feedback.Tags[0] != "shop" {
+		t.Fatalf("GetByID: got %+v, %v", feedback, err)
+	}
+
+	feedback.Team = "support"
+
+	err = repo.Update(ctx, feedback)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	updated, _ := repo.GetByID(ctx, feedbackID)
+	if updated.Team != "support" {
+		t.Errorf("Update: got team %q", updated.Team)
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	unknown := &models.Feedback{ID: uuid.New(), FeedbackText: "unknown"}
+	if err = repo.Update(ctx, unknown); !errors.Is(err, models.ErrFeedbackNotFound) {
+		t.Errorf("Update of unknown: got %v, want %v", err, models.ErrFeedbackNotFound)
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	found, _, err := repo.GetPage(ctx, 10, models.PageCursor{}, &models.FeedbackFilter{Search: "FIRST"})
+	if err != nil || len(found) != 1 {
+		t.Errorf("search: got %d feedbacks, %v", len(found), err)
+	}
+
+	deleted, err := repo.Delete(ctx, []uuid.UUID{feedbackID, uuid.New()})
+	if err != nil || deleted != 1 {
+		t.Fatalf("Delete: got %d, %v", deleted, err)
+	}
+
+	if _, err = repo.GetByID(ctx, feedbackID); !errors.Is(err, models.ErrFeedbackNotFound) {
+		t.Errorf("deleted feedback: got %v, want %v", err, models.ErrFeedbackNotFound)
+	}
+
+	entries, err := auditLog.GetByEntity(ctx, audit.EntityFeedback, feedbackID)
+	if err != nil || len(entries) != 3 { //nolint:gomnd
+		t.Fatalf("audit log: got %d entries, %v", len(entries), err)
+	}
+
+	for i, action := range []string{audit.ActionCreate, audit.ActionUpdate, audit.ActionDelete} {
+		if entries[i].Action != action {
+			t.Errorf("audit entry %d: got %s, want %s", i, entries[i].Action, action)
+		}
+	}
+}
+
+// legacyGenerator returns random IDs (UUIDv4) first, like the rows created before the UUIDv7 generator.
+type legacyGenerator struct {
+	left int
+	v7   *ids.V7
+}
+
+func (g *legacyGenerator) New() uuid.UUID {
+	if g.left > 0 {
+		g.left--
+
+		return uuid.New()
+	}
+
+	return g.v7.New()
+}
+
+func TestGetPageFromLegacyToOrderedIDs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo, _ := newRepository(t, &legacyGenerator{left: 7, v7: ids.NewV7()})
+
+	var want []string
+
+	for i := 0; i < 10; i++ {
+		text := fmt.Sprintf("feedback %d", i)
+		want = append(want, text)
+
+		_, err := repo.Create(ctx, newFeedback(text))
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		// The legacy rows are ordered by created_at.
+		time.Sleep(time.Millisecond)
+	}
+
+	// Pages of 3 cross the boundary in the third page: 2 legacy rows and 1 ordered.
+	var (
+		next models.PageCursor
+		got  []string
+	)
+
+	for page := 0; ; page++ {
+		feedbacks, cursor, err := repo.GetPage(ctx, 3, next, nil) //nolint:gomnd
+		if err != nil {
+			t.Fatalf("GetPage %d: %v", page, err)
+		}
+
+		if len(feedbacks) == 0 {
+			break
+		}
+
+		for _, feedback := range feedbacks {
+			got = append(got, feedback.FeedbackText)
+		}
+
+		next = cursor
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("pages: got %v, want %v", got, want)
+	}
+}
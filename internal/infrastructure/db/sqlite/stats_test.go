@@ -0,0 +1,111 @@
+package sqlite_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+)
+
+// statsFeedbacks are spread over two hosts, two weeks, two months and the tags, one has no tags.
+func statsFeedbacks() []*models.Feedback {
+	at := func(day, hour int) time.Time {
+		return time.Date(2023, time.March, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	feedback := func(host, label string, createdAt time.Time, tags ...string) *models.Feedback {
+		//nolint:exhaustivestruct,exhaustruct
+		return &models.Feedback{
+			CustomerName:   "Jane",
+			Email:          "jane@example.com",
+			FeedbackText:   "ok",
+			Source:         "https://" + host,
+			SourceHost:     host,
+			SentimentLabel: label,
+			Tags:           tags,
+			CreatedAt:      createdAt,
+			UpdatedAt:      createdAt,
+		}
+	}
+
+	feedbacks := []*models.Feedback{
+		feedback("shop.example.com", "positive", at(19, 23), "shop", "vip"), // Sunday
+		feedback("shop.example.com", "negative", at(20, 1), "shop"),         // Monday
+		feedback("shop.example.com", "positive", at(20, 10)),
+		feedback("blog.example.com", "positive", at(21, 8), "vip"),
+		feedback("blog.example.com", "neutral", time.Date(2023, time.April, 2, 12, 0, 0, 0, time.UTC), "shop"),
+	}
+
+	feedbacks[4].Email = "john@example.com"
+
+	return feedbacks
+}
+
+func TestStatsMatchMemory(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo, _ := newRepository(t, ids.NewV7())
+	memoryRepo := memory.New(memory.NewAuditRepository(nil, nopLogger{}), ids.NewV7(), nopLogger{})
+
+	for _, saver := range []interface {
+		CreateBatch(ctx context.Context, feedbacks []*models.Feedback) error
+	}{repo, memoryRepo} {
+		if err := saver.CreateBatch(ctx, statsFeedbacks()); err != nil {
+			t.Fatalf("CreateBatch: %v", err)
+		}
+	}
+
+	for _, test := range []struct {
+		groupBy []string
+		filter  *models.FeedbackFilter
+		// The counts of the groups in the order of the result.
+		counts []int64
+	}{
+		{nil, nil, []int64{5}},
+		{[]string{models.StatsBySource}, nil, []int64{2, 3}},
+		{[]string{models.StatsByDay}, nil, []int64{1, 2, 1, 1}},
+		{[]string{models.StatsByWeek}, nil, []int64{1, 3, 1}},
+		{[]string{models.StatsByMonth, models.StatsBySource}, nil, []int64{1, 3, 1}},
+		{[]string{models.StatsByTag}, nil, []int64{1, 3, 2}},
+		//nolint:exhaustivestruct,exhaustruct
+		{[]string{models.StatsByTag}, &models.FeedbackFilter{Sentiment: "positive"}, []int64{1, 1, 2}},
+		//nolint:exhaustivestruct,exhaustruct
+		{nil, &models.FeedbackFilter{Email: "JOHN@example.com"}, []int64{1}},
+		//nolint:exhaustivestruct,exhaustruct
+		{nil, &models.FeedbackFilter{Email: "nobody@example.com"}, []int64{0}},
+	} {
+		got, err := repo.GetStats(ctx, test.groupBy, test.filter)
+		if err != nil {
+			t.Fatalf("%v: GetStats: %v", test.groupBy, err)
+		}
+
+		want, err := memoryRepo.GetStats(ctx, test.groupBy, test.filter)
+		if err != nil {
+			t.Fatalf("%v: memory GetStats: %v", test.groupBy, err)
+		}
+
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("%v %+v:\n sqlite %s\n memory %s", test.groupBy, test.filter, gotJSON, wantJSON)
+		}
+
+		if len(got) != len(test.counts) {
+			t.Errorf("%v %+v: got %s, want counts %v", test.groupBy, test.filter, gotJSON, test.counts)
+
+			continue
+		}
+
+		for i, count := range test.counts {
+			if got[i].Count != count {
+				t.Errorf("%v %+v: group %v: got %d, want %d", test.groupBy, test.filter, got[i].Group, got[i].Count, count)
+			}
+		}
+	}
+}
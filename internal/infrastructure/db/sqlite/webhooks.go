@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	repo "github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/infrastructure/envelope"
+	log "github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// WebhookRepository is the Gorm repository with writes through the single writer of DB.
+type WebhookRepository struct {
+	*repo.WebhookRepository
+	db *DB
+}
+
+func NewWebhookRepository(
+	db *DB,
+	auditLog *repo.AuditRepository,
+	cipher *envelope.Cipher,
+	generator ids.IDGenerator,
+	timeout time.Duration,
+	logger log.Logger,
+) *WebhookRepository {
+	return &WebhookRepository{
+		WebhookRepository: repo.NewWebhookRepository(
+			db.gorm, auditLog, cipher, generator, timeout, logger.Named("sqlite"),
+		),
+		db: db,
+	}
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, webhook *models.Webhook) (uuid.UUID, error) {
+	var webhookID uuid.UUID
+
+	err := r.db.do(ctx, func() (err error) {
+		webhookID, err = r.WebhookRepository.Create(ctx, webhook)
+
+		return err
+	})
+
+	return webhookID, err
+}
+
+func (r *WebhookRepository) Update(ctx context.Context, webhook *models.Webhook) error {
+	return r.db.do(ctx, func() error {
+		return r.WebhookRepository.Update(ctx, webhook)
+	})
+}
+
+func (r *WebhookRepository) Delete(ctx context.Context, webhookID uuid.UUID) error {
+	return r.db.do(ctx, func() error {
+		return r.WebhookRepository.Delete(ctx, webhookID)
+	})
+}
+
+func (r *WebhookRepository) Counted(
+	ctx context.Context,
+	webhookID uuid.UUID,
+	failed bool,
+	disableAfter int,
+) (bool, error) {
+	var disabled bool
+
+	err := r.db.do(ctx, func() (err error) {
+		disabled, err = r.WebhookRepository.Counted(ctx, webhookID, failed, disableAfter)
+
+		return err
+	})
+
+	return disabled, err
+}
+
+func (r *WebhookRepository) AddDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	return r.db.do(ctx, func() error {
+		return r.WebhookRepository.AddDeliveries(ctx, deliveries)
+	})
+}
+
+func (r *WebhookRepository) Claim(ctx context.Context, deliveryID uuid.UUID, moment, until time.Time) (bool, error) {
+	var claimed bool
+
+	err := r.db.do(ctx, func() (err error) {
+		claimed, err = r.WebhookRepository.Claim(ctx, deliveryID, moment, until)
+
+		return err
+	})
+
+	return claimed, err
+}
+
+func (r *WebhookRepository) SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return r.db.do(ctx, func() error {
+		return r.WebhookRepository.SaveDelivery(ctx, delivery)
+	})
+}
+
+func (r *WebhookRepository) DeleteDeliveriesBefore(ctx context.Context, before time.Time) (int, error) {
+	var deleted int
+
+	err := r.db.do(ctx, func() (err error) {
+		deleted, err = r.WebhookRepository.DeleteDeliveriesBefore(ctx, before)
+
+		return err
+	})
+
+	return deleted, err
+}
@@ -0,0 +1,255 @@
+// Package envelope encrypts fields by envelope encryption: every value is encrypted
+// by its own random data key with AES-GCM, the data key is encrypted (wrapped)
+// by the key encryption key from the config. The value keeps the ID of the key,
+// so the old values are readable after the new key is added.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Prefix of the encrypted values, the values without it are plaintext.
+	prefix = "enc:v1:"
+
+	keySize     = 32 // AES-256.
+	dataKeySize = 32
+	// Minimal size of the blind index key, it's HMAC-SHA256 key.
+	indexKeySize = 32
+)
+
+var (
+	ErrInvalidKey   = errors.New("invalid encryption key")
+	ErrUnknownKey   = errors.New("unknown encryption key")
+	ErrInvalidValue = errors.New("invalid encrypted value")
+	ErrNoKeys       = errors.New("value is encrypted, but the encryption keys aren't configured")
+	ErrReserved     = errors.New("plaintext value starts with the prefix of the encrypted values '" + prefix + "'")
+)
+
+var encoding = base64.RawURLEncoding
+
+// Cipher encrypts by the active key and decrypts by any known key.
+// Nil Cipher means that encryption is off: the values are stored as is.
+type Cipher struct {
+	keys     map[string]cipher.AEAD
+	activeID string
+	indexKey []byte
+}
+
+// New parses the keys "id1:base64,id2:base64" (32 bytes of every key) and the blind index key (base64).
+// Empty active ID means the last key of the list.
+func New(keys, activeID, indexKey string) (*Cipher, error) {
+	result := &Cipher{
+		keys:     make(map[string]cipher.AEAD),
+		activeID: activeID,
+		indexKey: nil,
+	}
+
+	for _, pair := range strings.Split(keys, ",") {
+		keyID, encoded, found := strings.Cut(strings.TrimSpace(pair), ":")
+		if !found || keyID == "" {
+			// The pair isn't logged, it has the key.
+			return nil, fmt.Errorf("%w: expected 'id:base64'", ErrInvalidKey)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != keySize {
+			return nil, fmt.Errorf("%w: key '%s' must be %d bytes in base64", ErrInvalidKey, keyID, keySize)
+		}
+
+		if _, ok := result.keys[keyID]; ok {
+			return nil, fmt.Errorf("%w: duplicated key '%s'", ErrInvalidKey, keyID)
+		}
+
+		result.keys[keyID], err = newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if activeID == "" {
+			result.activeID = keyID
+		}
+	}
+
+	if activeID != "" {
+		if _, ok := result.keys[activeID]; !ok {
+			return nil, fmt.Errorf("%w: active key '%s'", ErrUnknownKey, activeID)
+		}
+	}
+
+	index, err := base64.StdEncoding.DecodeString(indexKey)
+	if err != nil || len(index) < indexKeySize {
+		return nil, fmt.Errorf("%w: blind index key must be at least %d bytes in base64", ErrInvalidKey, indexKeySize)
+	}
+
+	result.indexKey = index
+
+	return result, nil
+}
+
+// ActiveKeyID is the key of the new values.
+func (c *Cipher) ActiveKeyID() string {
+	if c == nil {
+		return ""
+	}
+
+	return c.activeID
+}
+
+// IsEncrypted reports whether the value has the prefix of the encrypted values.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Encrypt returns "enc:v1:<key ID>:<wrapped data key>:<ciphertext>".
+// The associated data binds the value to its place (e.g. the column and the row ID),
+// so the encrypted value can't be copied to another row. Empty values are kept empty.
+// The plaintext with the prefix is refused even by nil Cipher, it would be read as the encrypted value.
+func (c *Cipher) Encrypt(plaintext, associatedData string) (string, error) {
+	if IsEncrypted(plaintext) {
+		return "", ErrReserved
+	}
+
+	if c == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("can't generate data key: %w", err)
+	}
+
+	wrapped, err := seal(c.keys[c.activeID], dataKey, []byte(c.activeID))
+	if err != nil {
+		return "", err
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := seal(dataAEAD, []byte(plaintext), []byte(associatedData))
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + c.activeID + ":" + encoding.EncodeToString(wrapped) + ":" + encoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt returns plaintext values as is, so the rows written before encryption are still readable.
+func (c *Cipher) Decrypt(value, associatedData string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	if c == nil {
+		return "", ErrNoKeys
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 { //nolint:gomnd
+		return "", ErrInvalidValue
+	}
+
+	keyAEAD, ok := c.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: '%s'", ErrUnknownKey, parts[0])
+	}
+
+	wrapped, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidValue
+	}
+
+	ciphertext, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidValue
+	}
+
+	dataKey, err := open(keyAEAD, wrapped, []byte(parts[0]))
+	if err != nil {
+		return "", err
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(dataAEAD, ciphertext, []byte(associatedData))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// Current reports whether the value doesn't need re-encryption:
+// it's encrypted by the active key or it's empty.
+func (c *Cipher) Current(value string) bool {
+	if c == nil || value == "" {
+		return true
+	}
+
+	return strings.HasPrefix(value, prefix+c.activeID+":")
+}
+
+// BlindIndex is HMAC of the normalized value, it allows exact match lookup
+// without decryption. Nil Cipher returns empty index.
+func (c *Cipher) BlindIndex(value string) string {
+	if c == nil {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(value))))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) { //nolint:ireturn
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+
+	return aead, nil
+}
+
+// seal returns nonce and ciphertext.
+func seal(aead cipher.AEAD, plaintext, associatedData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("can't generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+func open(aead cipher.AEAD, data, associatedData []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrInvalidValue
+	}
+
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidValue, err.Error())
+	}
+
+	return plaintext, nil
+}
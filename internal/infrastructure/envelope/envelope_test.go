@@ -0,0 +1,167 @@
+package envelope_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/andrsj/feedback-service/internal/infrastructure/envelope"
+)
+
+func key(fill byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(fill), 32)))
+}
+
+func newCipher(t *testing.T, keys, activeID string) *envelope.Cipher {
+	t.Helper()
+
+	cipher, err := envelope.New(keys, activeID, key('i'))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return cipher
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	t.Parallel()
+
+	cipher := newCipher(t, "k1:"+key('a'), "")
+
+	encrypted, err := cipher.Encrypt("Jane", "customer_name:1")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	if !strings.HasPrefix(encrypted, "enc:v1:k1:") || strings.Contains(encrypted, "Jane") {
+		t.Fatalf("got %q", encrypted)
+	}
+
+	// Every value has its own data key and nonce.
+	again, _ := cipher.Encrypt("Jane", "customer_name:1")
+	if again == encrypted {
+		t.Error("the same plaintext gives the same value")
+	}
+
+	plaintext, err := cipher.Decrypt(encrypted, "customer_name:1")
+	if err != nil || plaintext != "Jane" {
+		t.Errorf("Decrypt: got %q, %v", plaintext, err)
+	}
+
+	// The value can't be moved to another row or column.
+	if _, err = cipher.Decrypt(encrypted, "customer_name:2"); !errors.Is(err, envelope.ErrInvalidValue) {
+		t.Errorf("another associated data: got %v, want %v", err, envelope.ErrInvalidValue)
+	}
+
+	tampered := encrypted[:len(encrypted)-2] + "AA"
+	if _, err = cipher.Decrypt(tampered, "customer_name:1"); !errors.Is(err, envelope.ErrInvalidValue) {
+		t.Errorf("tampered value: got %v, want %v", err, envelope.ErrInvalidValue)
+	}
+
+	if _, err = cipher.Decrypt("enc:v1:broken", ""); !errors.Is(err, envelope.ErrInvalidValue) {
+		t.Errorf("broken value: got %v, want %v", err, envelope.ErrInvalidValue)
+	}
+
+	// Empty and plaintext values are kept as is.
+	if empty, _ := cipher.Encrypt("", "email:1"); empty != "" {
+		t.Errorf("empty value: got %q", empty)
+	}
+
+	if plain, _ := cipher.Decrypt("jane@example.com", "email:1"); plain != "jane@example.com" {
+		t.Errorf("plaintext value: got %q", plain)
+	}
+}
+
+func TestReservedPrefix(t *testing.T) {
+	t.Parallel()
+
+	var off *envelope.Cipher
+
+	for name, cipher := range map[string]*envelope.Cipher{"on": newCipher(t, "k1:"+key('a'), ""), "off": off} {
+		if _, err := cipher.Encrypt("enc:v1:x", "customer_name:1"); !errors.Is(err, envelope.ErrReserved) {
+			t.Errorf("encryption %s: got %v, want %v", name, err, envelope.ErrReserved)
+		}
+	}
+
+	if !envelope.IsEncrypted("enc:v1:x") || envelope.IsEncrypted("enc:v2:x") {
+		t.Error("IsEncrypted")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	old := newCipher(t, "k1:"+key('a'), "")
+	rotated := newCipher(t, "k1:"+key('a')+",k2:"+key('b'), "k2")
+
+	encrypted, _ := old.Encrypt("jane@example.com", "email:1")
+
+	if !old.Current(encrypted) || rotated.Current(encrypted) {
+		t.Error("the value of the old key is current after the rotation")
+	}
+
+	// The old values are readable by the new list of keys.
+	plaintext, err := rotated.Decrypt(encrypted, "email:1")
+	if err != nil || plaintext != "jane@example.com" {
+		t.Errorf("old value: got %q, %v", plaintext, err)
+	}
+
+	reencrypted, _ := rotated.Encrypt(plaintext, "email:1")
+	if !strings.HasPrefix(reencrypted, "enc:v1:k2:") || !rotated.Current(reencrypted) || rotated.ActiveKeyID() != "k2" {
+		t.Errorf("new value: got %q", reencrypted)
+	}
+
+	// The removed key can't decrypt.
+	if _, err = old.Decrypt(reencrypted, "email:1"); !errors.Is(err, envelope.ErrUnknownKey) {
+		t.Errorf("unknown key: got %v, want %v", err, envelope.ErrUnknownKey)
+	}
+
+	var off *envelope.Cipher
+	if _, err = off.Decrypt(encrypted, "email:1"); !errors.Is(err, envelope.ErrNoKeys) {
+		t.Errorf("no keys: got %v, want %v", err, envelope.ErrNoKeys)
+	}
+}
+
+func TestNewValidatesKeys(t *testing.T) {
+	t.Parallel()
+
+	for name, keys := range map[string][2]string{
+		"no ID":         {":" + key('a'), ""},
+		"short key":     {"k1:" + base64.StdEncoding.EncodeToString([]byte("short")), ""},
+		"duplicate":     {"k1:" + key('a') + ",k1:" + key('b'), ""},
+		"unknown":       {"k1:" + key('a'), "k2"},
+		"not in base64": {"k1:not base64", ""},
+	} {
+		_, err := envelope.New(keys[0], keys[1], key('i'))
+		if err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+
+	if _, err := envelope.New("k1:"+key('a'), "", "c2hvcnQ="); !errors.Is(err, envelope.ErrInvalidKey) {
+		t.Errorf("short index key: got %v, want %v", err, envelope.ErrInvalidKey)
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	t.Parallel()
+
+	cipher := newCipher(t, "k1:"+key('a'), "")
+	other, _ := envelope.New("k1:"+key('a'), "", key('j'))
+
+	index := cipher.BlindIndex("Jane@Example.com ")
+
+	if index == "" || index != cipher.BlindIndex("jane@example.com") {
+		t.Errorf("the index isn't normalized: %q", index)
+	}
+
+	if index == cipher.BlindIndex("john@example.com") || index == other.BlindIndex("jane@example.com") {
+		t.Error("the index doesn't depend on the value and the key")
+	}
+
+	var off *envelope.Cipher
+	if off.BlindIndex("jane@example.com") != "" {
+		t.Error("nil cipher returns the index")
+	}
+}
@@ -0,0 +1,470 @@
+package access
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/sqlite"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+const (
+	iterateChunkSize = 500
+	sweepInterval    = time.Minute
+)
+
+var (
+	ErrInvalidEmail = errors.New("invalid email")
+	ErrNotFound     = errors.New("export not found")
+	ErrInvalidLink  = errors.New("invalid download link")
+	ErrLinkExpired  = errors.New("download link is expired")
+	ErrNotReady     = errors.New("export isn't ready")
+)
+
+type Repository interface {
+	Iterate(
+		ctx context.Context,
+		filter *models.FeedbackFilter,
+		chunkSize int,
+		callback func(feedbacks []*models.Feedback) error,
+	) error
+}
+
+type AuditRepository interface {
+	GetByEntities(ctx context.Context, entity string, entityIDs []uuid.UUID) ([]*models.AuditEntry, error)
+}
+
+// Check that actual implementation fits the interface.
+var (
+	_ Repository = (*gorm.FeedbackRepository)(nil)
+	_ Repository = (*memory.FeedbackRepository)(nil)
+	_ Repository = (*sqlite.FeedbackRepository)(nil)
+
+	_ AuditRepository = (*gorm.AuditRepository)(nil)
+	_ AuditRepository = (*memory.AuditRepository)(nil)
+	_ AuditRepository = (*sqlite.AuditRepository)(nil)
+)
+
+// feedback is the exported feedback with the fields which aren't shown by the API.
+type feedback struct {
+	*models.Feedback
+	SourceHost string    `json:"source_host"` //nolint:tagliatelle
+	CreatedAt  time.Time `json:"created_at"`  //nolint:tagliatelle
+	UpdatedAt  time.Time `json:"updated_at"`  //nolint:tagliatelle
+}
+
+// manifest describes the content of the archive.
+type manifest struct {
+	Email        string    `json:"email"`
+	GeneratedAt  time.Time `json:"generated_at"` //nolint:tagliatelle
+	Feedbacks    int       `json:"feedbacks"`
+	AuditEntries int       `json:"audit_entries"` //nolint:tagliatelle
+	Notes        []string  `json:"notes"`
+}
+
+var manifestNotes = []string{
+	"feedbacks.json has all feedbacks with the email and their derived fields: sentiment, tags, team, priority and source host.",
+	"The service doesn't keep comments, attachments or the language of feedbacks.",
+	"audit.json has the history of the feedbacks, the personal data is redacted in the audit log.",
+}
+
+// Service builds ZIP archives with the data of the customer in the background.
+// The jobs are kept in memory of the instance, so the status and the link
+// have to be requested from the same instance, the jobs are lost on restart.
+type Service struct {
+	logger    logger.Logger
+	repo      Repository
+	auditRepo AuditRepository
+	dir       string
+	linkKey   []byte
+	ttl       time.Duration
+
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*job
+}
+
+type job struct {
+	export models.AccessExport
+	path   string
+}
+
+// New gets the directory of the archives and the HMAC key of the download links,
+// the archives and the links expire after ttl. The archives left by the previous runs
+// are removed once they are older than ttl.
+func New(
+	repo Repository,
+	auditRepo AuditRepository,
+	dir string,
+	linkKey string,
+	ttl time.Duration,
+	logger logger.Logger,
+) (*Service, error) {
+	err := os.MkdirAll(dir, 0o700) //nolint:gomnd
+	if err != nil {
+		return nil, fmt.Errorf("creating directory of exports: %w", err)
+	}
+
+	service := &Service{
+		logger:    logger.Named("access"),
+		repo:      repo,
+		auditRepo: auditRepo,
+		dir:       dir,
+		linkKey:   []byte(linkKey),
+		ttl:       ttl,
+		mu:        sync.Mutex{},
+		jobs:      make(map[uuid.UUID]*job),
+	}
+
+	service.removeStale()
+
+	return service, nil
+}
+
+// Run removes the expired archives until the context is cancelled,
+// so they don't stay on the disk when nobody calls the service.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sweep()
+		}
+	}
+}
+
+// Start creates the job of the export, the archive is built in the background.
+func (s *Service) Start(ctx context.Context, email string) (*models.AccessExport, error) {
+	email = strings.TrimSpace(email)
+
+	if _, err := mail.ParseAddress(email); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEmail, err.Error())
+	}
+
+	s.sweep()
+
+	now := time.Now().UTC()
+	created := &job{
+		//nolint:exhaustivestruct,exhaustruct
+		export: models.AccessExport{
+			ID:        uuid.New(),
+			Status:    models.ExportPending,
+			CreatedAt: now,
+			ExpiresAt: now.Add(s.ttl),
+		},
+		path: "",
+	}
+
+	s.mu.Lock()
+	s.jobs[created.export.ID] = created
+	export := created.export
+	s.mu.Unlock()
+
+	s.logger.Info("starting export", logger.M{"export": export.ID})
+
+	go s.run(reqctx.Detach(ctx), export.ID, email)
+
+	return &export, nil
+}
+
+// Get returns the status of the export.
+func (s *Service) Get(_ context.Context, exportID string) (*models.AccessExport, error) {
+	s.sweep()
+
+	exportUUID, err := uuid.Parse(exportID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found, ok := s.jobs[exportUUID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	export := found.export
+
+	return &export, nil
+}
+
+// Open checks the signed link and opens the archive, the caller closes it.
+func (s *Service) Open(exportID, expires, signature string) (*os.File, error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(s.sign(exportID, expires))) {
+		return nil, ErrInvalidLink
+	}
+
+	if time.Now().Unix() > expiresAt {
+		return nil, ErrLinkExpired
+	}
+
+	s.sweep()
+
+	exportUUID, err := uuid.Parse(exportID)
+	if err != nil {
+		return nil, ErrInvalidLink
+	}
+
+	s.mu.Lock()
+	found, ok := s.jobs[exportUUID]
+	path := ""
+
+	if ok {
+		path = found.path
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if path == "" {
+		return nil, ErrNotReady
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening archive: %w", err)
+	}
+
+	return file, nil
+}
+
+func (s *Service) run(ctx context.Context, exportID uuid.UUID, email string) {
+	s.update(exportID, func(export *models.AccessExport) {
+		export.Status = models.ExportRunning
+	})
+
+	path := filepath.Join(s.dir, exportID.String()+".zip")
+
+	feedbacks, entries, err := s.build(ctx, path, email)
+	if err != nil {
+		s.logger.Error("building export", logger.M{"export": exportID, "err": err})
+
+		_ = os.Remove(path)
+	}
+
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found, ok := s.jobs[exportID]
+	if !ok {
+		// Expired during the build.
+		_ = os.Remove(path)
+
+		return
+	}
+
+	found.export.CompletedAt = &now
+	found.export.Feedbacks = feedbacks
+	found.export.AuditEntries = entries
+
+	if err != nil {
+		found.export.Status = models.ExportFailed
+		found.export.Error = err.Error()
+
+		return
+	}
+
+	found.path = path
+	found.export.Status = models.ExportDone
+	found.export.DownloadURL = s.link(exportID, found.export.ExpiresAt)
+
+	s.logger.Info("export is ready", logger.M{"export": exportID, "feedbacks": feedbacks, "auditEntries": entries})
+}
+
+// build writes the archive and returns the count of the feedbacks and the audit entries.
+func (s *Service) build(ctx context.Context, path, email string) (int, int, error) {
+	var (
+		feedbacks = make([]*feedback, 0)
+		entries   = make([]*models.AuditEntry, 0)
+	)
+
+	//nolint:exhaustivestruct,exhaustruct
+	err := s.repo.Iterate(ctx, &models.FeedbackFilter{Email: email}, iterateChunkSize,
+		func(chunk []*models.Feedback) error {
+			feedbackIDs := make([]uuid.UUID, 0, len(chunk))
+
+			for _, item := range chunk {
+				feedbacks = append(feedbacks, &feedback{
+					Feedback:   item,
+					SourceHost: item.SourceHost,
+					CreatedAt:  item.CreatedAt,
+					UpdatedAt:  item.UpdatedAt,
+				})
+				feedbackIDs = append(feedbackIDs, item.ID)
+			}
+
+			// The history is read for the whole chunk, not for every feedback.
+			history, err := s.auditRepo.GetByEntities(ctx, audit.EntityFeedback, feedbackIDs)
+			if err != nil {
+				return fmt.Errorf("reading audit log: %w", err)
+			}
+
+			entries = append(entries, history...)
+
+			return nil
+		},
+	)
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading feedbacks: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) //nolint:gomnd
+	if err != nil {
+		return 0, 0, fmt.Errorf("creating archive: %w", err)
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+
+	files := []struct {
+		name  string
+		value interface{}
+	}{
+		{"manifest.json", &manifest{
+			Email:        email,
+			GeneratedAt:  time.Now().UTC(),
+			Feedbacks:    len(feedbacks),
+			AuditEntries: len(entries),
+			Notes:        manifestNotes,
+		}},
+		{"feedbacks.json", feedbacks},
+		{"audit.json", entries},
+	}
+
+	for _, item := range files {
+		writer, err := archive.Create(item.name)
+		if err != nil {
+			return 0, 0, fmt.Errorf("writing %s: %w", item.name, err)
+		}
+
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+
+		err = encoder.Encode(item.value)
+		if err != nil {
+			return 0, 0, fmt.Errorf("writing %s: %w", item.name, err)
+		}
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return 0, 0, fmt.Errorf("closing archive: %w", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return 0, 0, fmt.Errorf("closing archive: %w", err)
+	}
+
+	return len(feedbacks), len(entries), nil
+}
+
+func (s *Service) update(exportID uuid.UUID, change func(export *models.AccessExport)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if found, ok := s.jobs[exportID]; ok {
+		change(&found.export)
+	}
+}
+
+// sweep forgets the expired jobs and removes their archives.
+func (s *Service) sweep() {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for exportID, found := range s.jobs {
+		if now.Before(found.export.ExpiresAt) {
+			continue
+		}
+
+		if found.path != "" {
+			err := os.Remove(found.path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				s.logger.Error("removing expired archive", logger.M{"export": exportID, "err": err})
+			}
+		}
+
+		delete(s.jobs, exportID)
+	}
+}
+
+// removeStale removes the archives older than ttl, the jobs of the previous runs are lost,
+// so nothing else removes their archives. The directory could be shared by the instances,
+// the fresh archives could belong to the running ones.
+func (s *Service) removeStale() {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		s.logger.Error("reading directory of exports", logger.M{"err": err})
+
+		return
+	}
+
+	staleBefore := time.Now().Add(-s.ttl)
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".zip" {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil || info.ModTime().After(staleBefore) {
+			continue
+		}
+
+		err = os.Remove(filepath.Join(s.dir, file.Name()))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.logger.Error("removing stale archive", logger.M{"file": file.Name(), "err": err})
+		}
+	}
+}
+
+// link returns the relative download URL, it's signed with its expiration time.
+func (s *Service) link(exportID uuid.UUID, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(exportID.String(), expires))
+
+	return "/customers/exports/" + exportID.String() + "/download?" + query.Encode()
+}
+
+func (s *Service) sign(exportID, expires string) string {
+	mac := hmac.New(sha256.New, s.linkKey)
+	mac.Write([]byte(exportID + "." + expires))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
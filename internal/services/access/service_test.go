@@ -0,0 +1,289 @@
+package access_test
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/internal/services/access"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+const linkKey = "link-key"
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) logger.Logger { return l }
+func (nopLogger) Debug(string, logger.M)       {}
+func (nopLogger) Info(string, logger.M)        {}
+func (nopLogger) Warn(string, logger.M)        {}
+func (nopLogger) Error(string, logger.M)       {}
+func (nopLogger) Fatal(string, logger.M)       {}
+
+func newService(t *testing.T, dir string) (*access.Service, *memory.FeedbackRepository) {
+	t.Helper()
+
+	auditLog := memory.NewAuditRepository(nil, nopLogger{})
+	repo := memory.New(auditLog, ids.NewV7(), nopLogger{})
+
+	service, err := access.New(repo, auditLog, dir, linkKey, time.Hour, nopLogger{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return service, repo
+}
+
+func create(t *testing.T, repo *memory.FeedbackRepository, email string) uuid.UUID {
+	t.Helper()
+
+	//nolint:exhaustivestruct,exhaustruct
+	feedbackID, err := repo.Create(context.Background(), &models.Feedback{
+		CustomerName:   "Ann",
+		Email:          email,
+		FeedbackText:   "great",
+		Source:         "https://shop.example.com/cart",
+		SourceHost:     "shop.example.com",
+		SentimentLabel: "positive",
+		Tags:           []string{"shop"},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	return feedbackID
+}
+
+// sign is the signature of the download link: HMAC-SHA256 of "<id>.<expires>".
+func sign(exportID, expires string) string {
+	mac := hmac.New(sha256.New, []byte(linkKey))
+	mac.Write([]byte(exportID + "." + expires))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// wait returns the finished export.
+func wait(t *testing.T, service *access.Service, exportID uuid.UUID) *models.AccessExport {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		export, err := service.Get(context.Background(), exportID.String())
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+
+		if export.Status == models.ExportDone || export.Status == models.ExportFailed {
+			return export
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("the export isn't finished: %+v", export)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func readArchive(t *testing.T, file *os.File) map[string][]byte {
+	t.Helper()
+
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+
+	archive, err := zip.NewReader(file, info.Size())
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+
+	files := make(map[string][]byte)
+
+	for _, item := range archive.File {
+		reader, err := item.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", item.Name, err)
+		}
+
+		files[item.Name], err = io.ReadAll(reader)
+		_ = reader.Close()
+
+		if err != nil {
+			t.Fatalf("read %s: %v", item.Name, err)
+		}
+	}
+
+	return files
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	service, repo := newService(t, t.TempDir())
+
+	first, second := create(t, repo, "ann@example.com"), create(t, repo, "ANN@example.com")
+	create(t, repo, "bob@example.com")
+
+	started, err := service.Start(ctx, " ann@example.com ")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	export := wait(t, service, started.ID)
+	if export.Status != models.ExportDone || export.Feedbacks != 2 || export.AuditEntries != 2 || export.CompletedAt == nil {
+		t.Fatalf("export: got %+v", export)
+	}
+
+	link, err := url.Parse(export.DownloadURL)
+	if err != nil || link.Path != "/customers/exports/"+export.ID.String()+"/download" {
+		t.Fatalf("download URL: got %q, %v", export.DownloadURL, err)
+	}
+
+	file, err := service.Open(export.ID.String(), link.Query().Get("expires"), link.Query().Get("signature"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer file.Close()
+
+	files := readArchive(t, file)
+
+	var manifest struct {
+		Email        string `json:"email"`
+		Feedbacks    int    `json:"feedbacks"`
+		AuditEntries int    `json:"audit_entries"` //nolint:tagliatelle
+	}
+
+	if err = json.Unmarshal(files["manifest.json"], &manifest); err != nil || manifest.Feedbacks != 2 || manifest.AuditEntries != 2 {
+		t.Errorf("manifest.json: got %s, %v", files["manifest.json"], err)
+	}
+
+	// The derived fields hidden by the API are in the export too.
+	var feedbacks []map[string]any
+	if err = json.Unmarshal(files["feedbacks.json"], &feedbacks); err != nil || len(feedbacks) != 2 {
+		t.Fatalf("feedbacks.json: got %s, %v", files["feedbacks.json"], err)
+	}
+
+	for i, feedbackID := range []uuid.UUID{first, second} {
+		item := feedbacks[i]
+		if item["id"] != feedbackID.String() || item["source_host"] != "shop.example.com" ||
+			item["sentiment_label"] != "positive" || item["created_at"] == nil {
+			t.Errorf("feedback %d: got %v", i, item)
+		}
+	}
+
+	var entries []*models.AuditEntry
+	if err = json.Unmarshal(files["audit.json"], &entries); err != nil || len(entries) != 2 {
+		t.Errorf("audit.json: got %s, %v", files["audit.json"], err)
+	}
+
+	if strings.Contains(string(files["feedbacks.json"]), "bob@example.com") {
+		t.Error("the export has the feedback of the other customer")
+	}
+}
+
+func TestDownloadLink(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	service, _ := newService(t, t.TempDir())
+
+	started, err := service.Start(ctx, "ann@example.com")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	export := wait(t, service, started.ID)
+	link, _ := url.Parse(export.DownloadURL)
+	exportID, expires, signature := export.ID.String(), link.Query().Get("expires"), link.Query().Get("signature")
+
+	later := strconv.FormatInt(time.Now().Add(2*time.Hour).Unix(), 10)
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	unknown := uuid.NewString()
+
+	for name, test := range map[string]struct {
+		exportID, expires, signature string
+		want                         error
+	}{
+		"tampered signature": {exportID, expires, strings.Repeat("0", len(signature)), access.ErrInvalidLink},
+		"extended expiry":    {exportID, later, signature, access.ErrInvalidLink},
+		"other export":       {unknown, expires, signature, access.ErrInvalidLink},
+		"no expiry":          {exportID, "", sign(exportID, ""), access.ErrInvalidLink},
+		"expired":            {exportID, past, sign(exportID, past), access.ErrLinkExpired},
+		"unknown export":     {unknown, expires, sign(unknown, expires), access.ErrNotFound},
+	} {
+		file, err := service.Open(test.exportID, test.expires, test.signature)
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: got %v, want %v", name, err, test.want)
+		}
+
+		if file != nil {
+			_ = file.Close()
+		}
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	service, _ := newService(t, t.TempDir())
+
+	if _, err := service.Start(ctx, "not an email"); !errors.Is(err, access.ErrInvalidEmail) {
+		t.Errorf("Start: got %v, want %v", err, access.ErrInvalidEmail)
+	}
+
+	for _, exportID := range []string{"not an ID", uuid.NewString()} {
+		if _, err := service.Get(ctx, exportID); !errors.Is(err, access.ErrNotFound) {
+			t.Errorf("Get %q: got %v, want %v", exportID, err, access.ErrNotFound)
+		}
+	}
+}
+
+func TestNewRemovesStaleArchives(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	stale, fresh := filepath.Join(dir, "stale.zip"), filepath.Join(dir, "fresh.zip")
+
+	for _, path := range []string{stale, fresh} {
+		if err := os.WriteFile(path, []byte("zip"), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	newService(t, dir)
+
+	if _, err := os.Stat(stale); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the stale archive is kept: %v", err)
+	}
+
+	// The fresh archive could belong to the other instance.
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("the fresh archive is removed: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	domainAudit "github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+var ErrInvalidQuery = errors.New("invalid audit query")
+
+type Repository interface {
+	GetByEntity(ctx context.Context, entity string, entityID uuid.UUID) ([]*models.AuditEntry, error)
+}
+
+// Check that actual implementation fits the interface.
+var (
+	_ Repository = (*gorm.AuditRepository)(nil)
+	_ Repository = (*memory.AuditRepository)(nil)
+)
+
+// Service reads the audit log, it's written by the repositories with their changes.
+type Service struct {
+	logger logger.Logger
+	repo   Repository
+}
+
+func New(auditRepository Repository, logger logger.Logger) *Service {
+	return &Service{
+		logger: logger.Named("audit"),
+		repo:   auditRepository,
+	}
+}
+
+// GetByEntity returns the history of the entity from the oldest entry.
+func (s *Service) GetByEntity(ctx context.Context, entity, entityID string) ([]*models.AuditEntry, error) {
+	switch entity {
+	case domainAudit.EntityFeedback, domainAudit.EntityRule, domainAudit.EntityWebhook, domainAudit.EntityErasure:
+	default:
+		return nil, fmt.Errorf("%w: unknown entity '%s'", ErrInvalidQuery, entity)
+	}
+
+	entityUUID, err := uuid.Parse(entityID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: can't parse the ID: %s", ErrInvalidQuery, err.Error())
+	}
+
+	entries, err := s.repo.GetByEntity(ctx, entity, entityUUID)
+	if err != nil {
+		s.logger.Error("getting audit entries", logger.M{"entity": entity, "id": entityID, "error": err})
+
+		return nil, fmt.Errorf("getting audit entries: %w", err)
+	}
+
+	return entries, nil
+}
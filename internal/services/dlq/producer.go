@@ -0,0 +1,175 @@
+package dlq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// Recorder adds the events failed to be published to the queue.
+type Recorder struct {
+	logger logger.Logger
+	repo   Repository
+}
+
+func NewRecorder(repo Repository, logger logger.Logger) *Recorder {
+	return &Recorder{
+		logger: logger.Named("dlq"),
+		repo:   repo,
+	}
+}
+
+// Record adds the letter per event, the tenant of the context is used for the events without it.
+// It outlives the request, so the letter is saved for the cancelled request too.
+// The letter keeps only the type and the feedback ID, the replay publishes the current state of the feedback.
+func (r *Recorder) Record(ctx context.Context, events []*models.FeedbackEvent, cause error) error {
+	letters := make([]*models.DeadLetter, 0, len(events))
+
+	for _, event := range events {
+		letters = append(letters, r.letter(ctx, event.Type, event.FeedbackID, event.Tenant, cause))
+	}
+
+	return r.add(reqctx.Detach(ctx), letters)
+}
+
+// Undelivered is kafka.UndeliveredFunc, it's called for the messages failed after Publish returned.
+func (r *Recorder) Undelivered(event *models.FeedbackEvent, cause error) {
+	ctx := reqctx.WithTenant(context.Background(), event.Tenant)
+
+	err := r.Record(ctx, []*models.FeedbackEvent{event}, cause)
+	if err != nil {
+		r.logger.Error("event is lost", logger.M{"feedbackID": event.FeedbackID, "type": event.Type, "err": cause})
+	}
+}
+
+// Keep is kafka.Journal: the event buffered while Kafka is down is added as the letter and removed by Sent,
+// so the events buffered by the killed process are replayed from the queue.
+func (r *Recorder) Keep(ctx context.Context, event *models.FeedbackEvent, cause error) (uuid.UUID, error) {
+	letter := r.letter(ctx, event.Type, event.FeedbackID, event.Tenant, cause)
+
+	err := r.add(reqctx.Detach(ctx), []*models.DeadLetter{letter})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return letter.ID, nil
+}
+
+// Sent removes the letter of the buffered event after it's sent.
+func (r *Recorder) Sent(ctx context.Context, letterID uuid.UUID) error {
+	deleted, err := r.repo.Delete(ctx, []uuid.UUID{letterID})
+	if err != nil {
+		return fmt.Errorf("deleting dead letter: %w", err)
+	}
+
+	lettersTotal.WithLabelValues("sent").Add(float64(deleted))
+	depth.Sub(float64(deleted))
+
+	return nil
+}
+
+// Refresh sets the depth metric by the repository.
+func (r *Recorder) Refresh(ctx context.Context) {
+	refresh(ctx, r.repo, r.logger)
+}
+
+func (r *Recorder) letter(
+	ctx context.Context,
+	eventType string,
+	feedbackID uuid.UUID,
+	tenant string,
+	cause error,
+) *models.DeadLetter {
+	if tenant == "" {
+		tenant = reqctx.Tenant(ctx)
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	return &models.DeadLetter{
+		EventType:  eventType,
+		FeedbackID: feedbackID,
+		Tenant:     tenant,
+		Error:      cause.Error(),
+		Attempts:   1,
+	}
+}
+
+func (r *Recorder) add(ctx context.Context, letters []*models.DeadLetter) error {
+	if len(letters) == 0 {
+		return nil
+	}
+
+	err := r.repo.Add(ctx, letters)
+	if err != nil {
+		r.logger.Error("adding dead letters", logger.M{"err": err, "count": len(letters)})
+
+		return fmt.Errorf("adding dead letters: %w", err)
+	}
+
+	lettersTotal.WithLabelValues("added").Add(float64(len(letters)))
+	depth.Add(float64(len(letters)))
+	r.logger.Warn("events are added to the dead-letter queue", logger.M{
+		"count": len(letters),
+		"type":  letters[0].EventType,
+		"err":   letters[0].Error,
+	})
+
+	return nil
+}
+
+// Producer adds the events of the failed sends to the queue, so the writes of the feedbacks don't fail
+// with the broker. The error is returned only if the queue isn't available too.
+type Producer struct {
+	inner    Publisher
+	recorder *Recorder
+}
+
+func NewProducer(inner Publisher, recorder *Recorder) *Producer {
+	return &Producer{
+		inner:    inner,
+		recorder: recorder,
+	}
+}
+
+func (p *Producer) Publish(ctx context.Context, event *models.FeedbackEvent) error {
+	err := p.inner.Publish(ctx, event)
+	if err != nil {
+		return p.record(err, p.recorder.Record(ctx, []*models.FeedbackEvent{event}, err))
+	}
+
+	return nil
+}
+
+func (p *Producer) PublishBatch(ctx context.Context, events []*models.FeedbackEvent) error {
+	err := p.inner.PublishBatch(ctx, events)
+	if err != nil {
+		return p.record(err, p.recorder.Record(ctx, events, err))
+	}
+
+	return nil
+}
+
+// Rejects passes the backpressure of the producer with the bounded queue.
+func (p *Producer) Rejects() bool {
+	producer, ok := p.inner.(interface{ Rejects() bool })
+
+	return ok && producer.Rejects()
+}
+
+func (p *Producer) Close() error {
+	return p.inner.Close() //nolint:wrapcheck
+}
+
+// record returns the error of the send if the letter isn't added.
+func (p *Producer) record(sendErr, recordErr error) error {
+	if recordErr != nil {
+		return sendErr
+	}
+
+	return nil
+}
@@ -0,0 +1,342 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/file"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/kafka"
+	brokerMemory "github.com/andrsj/feedback-service/internal/infrastructure/broker/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/noop"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/sqlite"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid dead-letter request")
+	// ErrReplayRunning is returned while the other replay of the process isn't finished.
+	ErrReplayRunning = errors.New("replay is already running")
+)
+
+const (
+	// Letters per query of ReplayAll.
+	replayChunkSize = 100
+	// IDs per Replay, the replay of the request is paced by the rate.
+	MaxReplayIDs = 1000
+)
+
+type Repository interface {
+	// Add sets the IDs and the time of the letters.
+	Add(ctx context.Context, letters []*models.DeadLetter) error
+	// List returns the letters after the ID in the order of failures, uuid.Nil is the start of the queue.
+	List(ctx context.Context, limit int, after uuid.UUID) ([]*models.DeadLetter, error)
+	GetByIDs(ctx context.Context, letterIDs []uuid.UUID) ([]*models.DeadLetter, error)
+	// Retried saves the error of the failed replay and counts the attempt.
+	Retried(ctx context.Context, letterID uuid.UUID, reason string) error
+	Delete(ctx context.Context, letterIDs []uuid.UUID) (int, error)
+	// DeleteBefore deletes the letters added before the time, the zero time means all of them.
+	DeleteBefore(ctx context.Context, before time.Time) (int, error)
+	Count(ctx context.Context) (int, error)
+}
+
+// FeedbackReader gives the current state of the feedback for the replay.
+type FeedbackReader interface {
+	GetByID(ctx context.Context, feedbackID uuid.UUID) (*models.Feedback, error)
+}
+
+// Publisher is the producer of the broker, the replay waits for the delivery of every event by PublishBatch.
+type Publisher interface {
+	Publish(ctx context.Context, event *models.FeedbackEvent) error
+	PublishBatch(ctx context.Context, events []*models.FeedbackEvent) error
+	Close() error
+}
+
+// Check that actual implementations fit the interfaces.
+var (
+	_ Repository     = (*gorm.DeadLetterRepository)(nil)
+	_ Repository     = (*sqlite.DeadLetterRepository)(nil)
+	_ Repository     = (*memory.DeadLetterRepository)(nil)
+	_ FeedbackReader = (*gorm.FeedbackRepository)(nil)
+	_ FeedbackReader = (*sqlite.FeedbackRepository)(nil)
+	_ FeedbackReader = (*memory.FeedbackRepository)(nil)
+	_ Publisher      = (*kafka.Producer)(nil)
+	_ Publisher      = (*kafka.AsyncProducer)(nil)
+	_ Publisher      = (*noop.Producer)(nil)
+	_ Publisher      = (*file.Producer)(nil)
+	_ Publisher      = (*brokerMemory.Recorder)(nil)
+	_ kafka.Journal  = (*Recorder)(nil)
+)
+
+// Metrics of the dead-letter queue.
+//
+//nolint:gochecknoglobals,exhaustivestruct,exhaustruct
+var (
+	depth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "dlq_depth",
+		Help: "Events in the dead-letter queue, they failed to be published.",
+	})
+	lettersTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dlq_letters_total",
+		Help: "Dead letters by result: added, replayed, failed, skipped, purged, sent (from the Kafka buffer).",
+	}, []string{"result"})
+)
+
+// Service lists, replays and purges the dead letters, they are added by Recorder.
+type Service struct {
+	logger    logger.Logger
+	repo      Repository
+	feedbacks FeedbackReader
+	producer  Publisher
+	interval  time.Duration
+	replaying atomic.Bool
+}
+
+// New gets the producer of the broker without Producer of this package, so the failed replay isn't added again.
+// The replay publishes at most rate events per second.
+func New(repo Repository, feedbacks FeedbackReader, producer Publisher, rate int, logger logger.Logger) *Service {
+	return &Service{
+		logger:    logger.Named("dlq"),
+		repo:      repo,
+		feedbacks: feedbacks,
+		producer:  producer,
+		interval:  time.Second / time.Duration(rate),
+		replaying: atomic.Bool{},
+	}
+}
+
+// List returns the page of the queue after the letter ID, the empty ID is the start.
+func (s *Service) List(ctx context.Context, limit int, after string) ([]*models.DeadLetter, error) {
+	afterID := uuid.Nil
+
+	if after != "" {
+		afterIDs, err := parseIDs([]string{after})
+		if err != nil {
+			return nil, err
+		}
+
+		afterID = afterIDs[0]
+	}
+
+	letters, err := s.repo.List(ctx, limit, afterID)
+	if err != nil {
+		return nil, fmt.Errorf("listing dead letters: %w", err)
+	}
+
+	return letters, nil
+}
+
+// Replay publishes the events of the letters by the IDs, the published ones are removed from the queue.
+func (s *Service) Replay(ctx context.Context, ids []string) (*models.ReplayReport, error) {
+	if len(ids) == 0 || len(ids) > MaxReplayIDs {
+		return nil, fmt.Errorf("%w: from 1 to %d IDs are expected", ErrInvalidRequest, MaxReplayIDs)
+	}
+
+	letterIDs, err := parseIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.replaying.CompareAndSwap(false, true) {
+		return nil, ErrReplayRunning
+	}
+	defer s.replaying.Store(false)
+
+	defer s.Refresh(ctx)
+
+	letters, err := s.repo.GetByIDs(ctx, letterIDs)
+	if err != nil {
+		return nil, fmt.Errorf("getting dead letters: %w", err)
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	report := &models.ReplayReport{NotFound: len(letterIDs) - len(letters)}
+
+	err = s.replay(ctx, letters, report)
+
+	return report, err
+}
+
+// ReplayAll publishes the whole queue once, the letters failed again are kept for the next run.
+func (s *Service) ReplayAll(ctx context.Context) (*models.ReplayReport, error) {
+	if !s.replaying.CompareAndSwap(false, true) {
+		return nil, ErrReplayRunning
+	}
+	defer s.replaying.Store(false)
+
+	defer s.Refresh(ctx)
+
+	//nolint:exhaustivestruct,exhaustruct
+	report := &models.ReplayReport{}
+	after := uuid.Nil
+
+	for {
+		letters, err := s.repo.List(ctx, replayChunkSize, after)
+		if err != nil {
+			return report, fmt.Errorf("listing dead letters: %w", err)
+		}
+
+		if len(letters) == 0 {
+			return report, nil
+		}
+
+		err = s.replay(ctx, letters, report)
+		if err != nil {
+			return report, err
+		}
+
+		after = letters[len(letters)-1].ID
+	}
+}
+
+// replay publishes the letters one by one with the interval of the rate.
+func (s *Service) replay(ctx context.Context, letters []*models.DeadLetter, report *models.ReplayReport) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for i, letter := range letters {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("replay is stopped: %w", ctx.Err())
+			case <-ticker.C:
+			}
+		}
+
+		published, err := s.publish(ctx, letter)
+		if err != nil {
+			report.Failed++
+			lettersTotal.WithLabelValues("failed").Inc()
+			s.logger.Error("replaying dead letter", logger.M{"letterID": letter.ID, "err": err})
+
+			err = s.repo.Retried(ctx, letter.ID, err.Error())
+			if err != nil {
+				return fmt.Errorf("saving the failed replay: %w", err)
+			}
+
+			continue
+		}
+
+		if published {
+			report.Replayed++
+			lettersTotal.WithLabelValues("replayed").Inc()
+		} else {
+			report.Skipped++
+			lettersTotal.WithLabelValues("skipped").Inc()
+		}
+
+		_, err = s.repo.Delete(ctx, []uuid.UUID{letter.ID})
+		if err != nil {
+			return fmt.Errorf("deleting the replayed letter: %w", err)
+		}
+	}
+
+	s.logger.Info("dead letters replayed", logger.M{"report": report})
+
+	return nil
+}
+
+// publish sends the event of the letter with the current state of the feedback,
+// it returns false if the feedback is deleted since, its tombstone is sent by the deletion.
+// The batch waits for the delivery, Publish of the async producer doesn't.
+func (s *Service) publish(ctx context.Context, letter *models.DeadLetter) (bool, error) {
+	ctx = reqctx.WithTenant(ctx, letter.Tenant)
+
+	event := models.NewDeletedEvent(letter.FeedbackID, letter.Tenant)
+
+	if letter.EventType != models.FeedbackDeleted {
+		feedback, err := s.feedbacks.GetByID(ctx, letter.FeedbackID)
+		if errors.Is(err, models.ErrFeedbackNotFound) {
+			return false, nil
+		}
+
+		if err != nil {
+			return false, fmt.Errorf("getting feedback: %w", err)
+		}
+
+		event = models.NewFeedbackEvent(letter.EventType, feedback)
+	}
+
+	err := s.producer.PublishBatch(ctx, []*models.FeedbackEvent{event})
+	if err != nil {
+		return false, fmt.Errorf("sending event: %w", err)
+	}
+
+	return true, nil
+}
+
+// Purge deletes the letters by the IDs without publishing.
+func (s *Service) Purge(ctx context.Context, ids []string) (int, error) {
+	letterIDs, err := parseIDs(ids)
+	if err != nil {
+		return 0, err
+	}
+
+	defer s.Refresh(ctx)
+
+	purged, err := s.repo.Delete(ctx, letterIDs)
+	if err != nil {
+		return 0, fmt.Errorf("purging dead letters: %w", err)
+	}
+
+	lettersTotal.WithLabelValues("purged").Add(float64(purged))
+	s.logger.Info("dead letters purged", logger.M{"count": purged})
+
+	return purged, nil
+}
+
+// PurgeBefore deletes the letters added before the time, the zero time means the whole queue.
+func (s *Service) PurgeBefore(ctx context.Context, before time.Time) (int, error) {
+	defer s.Refresh(ctx)
+
+	purged, err := s.repo.DeleteBefore(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("purging dead letters: %w", err)
+	}
+
+	lettersTotal.WithLabelValues("purged").Add(float64(purged))
+	s.logger.Info("dead letters purged", logger.M{"count": purged, "before": before})
+
+	return purged, nil
+}
+
+func parseIDs(ids []string) ([]uuid.UUID, error) {
+	letterIDs := make([]uuid.UUID, 0, len(ids))
+
+	for _, id := range ids {
+		letterID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: can't parse the ID '%s'", ErrInvalidRequest, id)
+		}
+
+		letterIDs = append(letterIDs, letterID)
+	}
+
+	return letterIDs, nil
+}
+
+// Refresh sets the depth metric, the queue is also changed by the 'dlq' command of other process.
+func (s *Service) Refresh(ctx context.Context) {
+	refresh(ctx, s.repo, s.logger)
+}
+
+func refresh(ctx context.Context, repo Repository, log logger.Logger) {
+	count, err := repo.Count(ctx)
+	if err != nil {
+		log.Error("counting dead letters", logger.M{"err": err})
+
+		return
+	}
+
+	depth.Set(float64(count))
+}
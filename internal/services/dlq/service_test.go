@@ -0,0 +1,320 @@
+package dlq_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	brokerMemory "github.com/andrsj/feedback-service/internal/infrastructure/broker/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/internal/services/dlq"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// The replay of the tests isn't paced.
+const rate = 1000
+
+var errBroker = errors.New("broker is down")
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) logger.Logger { return l }
+func (nopLogger) Debug(string, logger.M)       {}
+func (nopLogger) Info(string, logger.M)        {}
+func (nopLogger) Warn(string, logger.M)        {}
+func (nopLogger) Error(string, logger.M)       {}
+func (nopLogger) Fatal(string, logger.M)       {}
+
+type fixture struct {
+	letters   *memory.DeadLetterRepository
+	feedbacks *memory.FeedbackRepository
+	broker    *brokerMemory.Recorder
+	producer  *dlq.Producer
+	service   *dlq.Service
+}
+
+func newFixture() *fixture {
+	letters := memory.NewDeadLetterRepository(ids.NewV7(), nopLogger{})
+	feedbacks := memory.New(memory.NewAuditRepository(nil, nopLogger{}), ids.NewV7(), nopLogger{})
+	broker := brokerMemory.New(nopLogger{})
+
+	return &fixture{
+		letters:   letters,
+		feedbacks: feedbacks,
+		broker:    broker,
+		producer:  dlq.NewProducer(broker, dlq.NewRecorder(letters, nopLogger{})),
+		service:   dlq.New(letters, feedbacks, broker, rate, nopLogger{}),
+	}
+}
+
+func (f *fixture) create(t *testing.T, text string) *models.Feedback {
+	t.Helper()
+
+	//nolint:exhaustivestruct,exhaustruct
+	feedback := &models.Feedback{
+		CustomerName: "Jane",
+		Email:        "jane@example.com",
+		FeedbackText: text,
+		Source:       "https://shop.example.com",
+		Tenant:       "acme",
+	}
+
+	if _, err := f.feedbacks.Create(context.Background(), feedback); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	return feedback
+}
+
+// fail publishes the events while the broker is down, they become the letters.
+func (f *fixture) fail(t *testing.T, events ...*models.FeedbackEvent) []*models.DeadLetter {
+	t.Helper()
+
+	ctx := context.Background()
+	f.broker.SetError(errBroker)
+
+	for _, event := range events {
+		if err := f.producer.Publish(ctx, event); err != nil {
+			t.Fatalf("Publish with the queue: %v", err)
+		}
+	}
+
+	f.broker.SetError(nil)
+
+	letters, err := f.service.List(ctx, 100, "")
+	if err != nil || len(letters) != len(events) {
+		t.Fatalf("List: got %v, %v", letters, err)
+	}
+
+	return letters
+}
+
+func letterIDs(letters []*models.DeadLetter) []string {
+	result := make([]string, 0, len(letters))
+	for _, letter := range letters {
+		result = append(result, letter.ID.String())
+	}
+
+	return result
+}
+
+func TestFailedPublishIsQueued(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture()
+	feedback := f.create(t, "first")
+
+	letters := f.fail(t, models.NewFeedbackEvent(models.FeedbackCreated, feedback))
+
+	letter := letters[0]
+	if letter.FeedbackID != feedback.ID || letter.EventType != models.FeedbackCreated || letter.Tenant != "acme" ||
+		letter.Error != errBroker.Error() || letter.Attempts != 1 {
+		t.Errorf("letter: got %+v", letter)
+	}
+
+	// The write of the feedback doesn't fail with the broker, only without the queue too.
+	if len(f.broker.Messages()) != 0 {
+		t.Errorf("messages of the failed publish: got %v", f.broker.Messages())
+	}
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := newFixture()
+	changed, deleted, erased := f.create(t, "first"), f.create(t, "second"), f.create(t, "third")
+
+	letters := f.fail(t,
+		models.NewFeedbackEvent(models.FeedbackCreated, changed),
+		models.NewFeedbackEvent(models.FeedbackCreated, deleted),
+		models.NewDeletedEvent(erased.ID, erased.Tenant),
+	)
+
+	// The replay sends the current state of the feedback, the deleted one has nothing to send.
+	changed.Tags = []string{"vip"}
+	if err := f.feedbacks.Update(ctx, changed); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if _, err := f.feedbacks.Delete(ctx, []uuid.UUID{deleted.ID}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	report, err := f.service.Replay(ctx, append(letterIDs(letters), uuid.NewString()))
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	if *report != (models.ReplayReport{Replayed: 2, Skipped: 1, NotFound: 1}) {
+		t.Errorf("report: got %+v", report)
+	}
+
+	messages := f.broker.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("messages: got %d, want 2", len(messages))
+	}
+
+	if messages[0].FeedbackID != changed.ID || len(messages[0].Feedback.Tags) != 1 || messages[0].Tenant != "acme" {
+		t.Errorf("replayed event: got %+v", messages[0])
+	}
+
+	if messages[1].FeedbackID != erased.ID || messages[1].Type != models.FeedbackDeleted || messages[1].Feedback != nil {
+		t.Errorf("replayed tombstone: got %+v", messages[1])
+	}
+
+	if count, _ := f.letters.Count(ctx); count != 0 {
+		t.Errorf("letters left: %d", count)
+	}
+}
+
+func TestReplayFailsAgain(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := newFixture()
+	letters := f.fail(t,
+		models.NewFeedbackEvent(models.FeedbackCreated, f.create(t, "first")),
+		models.NewFeedbackEvent(models.FeedbackCreated, f.create(t, "second")),
+	)
+
+	errStill := errors.New("broker is still down")
+	f.broker.SetError(errStill)
+
+	report, err := f.service.ReplayAll(ctx)
+	if err != nil || report.Failed != 2 || report.Replayed != 0 {
+		t.Fatalf("ReplayAll: got %+v, %v", report, err)
+	}
+
+	// The letters are kept with the new error and counted attempt for the next replay.
+	kept, err := f.service.List(ctx, 100, "")
+	if err != nil || len(kept) != 2 {
+		t.Fatalf("List: got %v, %v", kept, err)
+	}
+
+	for i, letter := range kept {
+		if letter.ID != letters[i].ID || letter.Attempts != 2 || letter.Error == errBroker.Error() {
+			t.Errorf("letter %d: got %+v", i, letter)
+		}
+	}
+
+	f.broker.SetError(nil)
+
+	report, err = f.service.ReplayAll(ctx)
+	if err != nil || report.Replayed != 2 || len(f.broker.Messages()) != 2 {
+		t.Errorf("ReplayAll after the recovery: got %+v, %v", report, err)
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture()
+
+	tooMany := make([]string, dlq.MaxReplayIDs+1)
+	for i := range tooMany {
+		tooMany[i] = uuid.NewString()
+	}
+
+	for name, letterIDs := range map[string][]string{
+		"no IDs":       nil,
+		"too many IDs": tooMany,
+		"not an ID":    {"letter"},
+	} {
+		if _, err := f.service.Replay(context.Background(), letterIDs); !errors.Is(err, dlq.ErrInvalidRequest) {
+			t.Errorf("%s: got %v, want %v", name, err, dlq.ErrInvalidRequest)
+		}
+	}
+
+	if _, err := f.service.List(context.Background(), 10, "letter"); !errors.Is(err, dlq.ErrInvalidRequest) {
+		t.Errorf("List after the wrong ID: got %v, want %v", err, dlq.ErrInvalidRequest)
+	}
+}
+
+func TestReplayIsPaced(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture()
+	f.service = dlq.New(f.letters, f.feedbacks, f.broker, 20, nopLogger{})
+
+	letters := f.fail(t,
+		models.NewFeedbackEvent(models.FeedbackCreated, f.create(t, "first")),
+		models.NewFeedbackEvent(models.FeedbackCreated, f.create(t, "second")),
+		models.NewFeedbackEvent(models.FeedbackCreated, f.create(t, "third")),
+	)
+
+	// 3 events at 20 per second wait for two intervals of 50ms.
+	started := time.Now()
+
+	if _, err := f.service.Replay(context.Background(), letterIDs(letters)); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	if elapsed := time.Since(started); elapsed < 100*time.Millisecond {
+		t.Errorf("the replay took %s, want at least 100ms", elapsed)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := newFixture()
+	letters := f.fail(t,
+		models.NewFeedbackEvent(models.FeedbackCreated, f.create(t, "first")),
+		models.NewFeedbackEvent(models.FeedbackCreated, f.create(t, "second")),
+	)
+
+	purged, err := f.service.Purge(ctx, letterIDs(letters[:1]))
+	if err != nil || purged != 1 {
+		t.Errorf("Purge: got %d, %v", purged, err)
+	}
+
+	if purged, err = f.service.PurgeBefore(ctx, time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+		t.Errorf("PurgeBefore of the old letters: got %d, %v", purged, err)
+	}
+
+	if purged, err = f.service.PurgeBefore(ctx, time.Time{}); err != nil || purged != 1 {
+		t.Errorf("PurgeBefore of all letters: got %d, %v", purged, err)
+	}
+
+	// Nothing is published by the purge.
+	if len(f.broker.Messages()) != 0 {
+		t.Errorf("messages: got %v", f.broker.Messages())
+	}
+}
+
+func TestRecorderJournal(t *testing.T) {
+	t.Parallel()
+
+	ctx := reqctx.WithTenant(context.Background(), "acme")
+	f := newFixture()
+	recorder := dlq.NewRecorder(f.letters, nopLogger{})
+	event := models.NewDeletedEvent(uuid.New(), "")
+
+	// The event buffered while Kafka is down is kept in the queue until it's sent.
+	letterID, err := recorder.Keep(ctx, event, errBroker)
+	if err != nil {
+		t.Fatalf("Keep: %v", err)
+	}
+
+	letters, _ := f.letters.GetByIDs(ctx, []uuid.UUID{letterID})
+	if len(letters) != 1 || letters[0].FeedbackID != event.FeedbackID || letters[0].Tenant != "acme" {
+		t.Fatalf("kept letter: got %v", letters)
+	}
+
+	if err = recorder.Sent(ctx, letterID); err != nil {
+		t.Fatalf("Sent: %v", err)
+	}
+
+	if count, _ := f.letters.Count(ctx); count != 0 {
+		t.Errorf("letters after the send: %d", count)
+	}
+}
@@ -0,0 +1,236 @@
+package erasure
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/file"
+	"github.com/andrsj/feedback-service/internal/infrastructure/cache"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/sqlite"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+const iterateChunkSize = 500
+
+var ErrInvalidEmail = errors.New("invalid email")
+
+type Repository interface {
+	Iterate(
+		ctx context.Context,
+		filter *models.FeedbackFilter,
+		chunkSize int,
+		callback func(feedbacks []*models.Feedback) error,
+	) error
+	Delete(ctx context.Context, feedbackIDs []uuid.UUID) (int, error)
+}
+
+// Producer waits until the events of the deleted feedbacks are delivered.
+type Producer interface {
+	PublishBatch(ctx context.Context, events []*models.FeedbackEvent) error
+}
+
+// Purger removes the records of the customer from the broker which keeps them, e.g. the file log.
+type Purger interface {
+	PurgeCustomer(ctx context.Context, email string, feedbackIDs []uuid.UUID) (int, error)
+}
+
+// AuditLog saves the receipts.
+type AuditLog interface {
+	Record(ctx context.Context, entry *models.AuditEntry) error
+}
+
+// Check that actual implementation fits the interface.
+var (
+	_ Repository = (*gorm.FeedbackRepository)(nil)
+	_ Repository = (*memory.FeedbackRepository)(nil)
+	_ Repository = (*sqlite.FeedbackRepository)(nil)
+
+	_ AuditLog = (*gorm.AuditRepository)(nil)
+	_ AuditLog = (*memory.AuditRepository)(nil)
+	_ AuditLog = (*sqlite.AuditRepository)(nil)
+
+	_ Purger = (*file.Producer)(nil)
+)
+
+// Service erases all data of the customer by the email (right to erasure).
+// Feedbacks are the only customer data in this service.
+type Service struct {
+	logger     logger.Logger
+	repo       Repository
+	producer   Producer
+	purger     Purger
+	cache      cache.Cache
+	auditLog   AuditLog
+	receiptKey []byte
+}
+
+// New gets the key of the receipt signatures, it's HMAC-SHA256 key.
+// The purger is nil for the brokers which don't keep the events, e.g. Kafka has its own retention.
+func New(
+	repo Repository,
+	producer Producer,
+	purger Purger,
+	responses cache.Cache,
+	auditLog AuditLog,
+	receiptKey string,
+	logger logger.Logger,
+) *Service {
+	return &Service{
+		logger:     logger.Named("erasure"),
+		repo:       repo,
+		producer:   producer,
+		purger:     purger,
+		cache:      responses,
+		auditLog:   auditLog,
+		receiptKey: []byte(receiptKey),
+	}
+}
+
+// EraseByEmail deletes the feedbacks of the customer and returns the signed receipt,
+// the receipt is also saved in the audit log. The receipt is returned even if nothing is found,
+// it proves that the request is done.
+//
+// Tombstones are sent before the deletion, so the failed erasure can be repeated:
+// the feedbacks are still found and the consumers get the tombstones again.
+// The broker log is purged after the deletion by the email too, so the repeated erasure purges it
+// even if the feedbacks are already deleted. There is no receipt until the log is purged.
+func (s *Service) EraseByEmail(ctx context.Context, email string) (*models.ErasureReceipt, error) {
+	email = strings.TrimSpace(email)
+
+	if _, err := mail.ParseAddress(email); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEmail, err.Error())
+	}
+
+	var (
+		feedbackIDs = make([]uuid.UUID, 0)
+		events      = make([]*models.FeedbackEvent, 0)
+	)
+
+	// A lagging replica could miss the just created feedbacks, they would survive the erasure.
+	ctx = reqctx.WithPrimary(ctx)
+
+	//nolint:exhaustivestruct,exhaustruct
+	err := s.repo.Iterate(ctx, &models.FeedbackFilter{Email: email}, iterateChunkSize,
+		func(feedbacks []*models.Feedback) error {
+			for _, feedback := range feedbacks {
+				feedbackIDs = append(feedbackIDs, feedback.ID)
+				events = append(events, models.NewDeletedEvent(feedback.ID, feedback.Tenant))
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		s.logger.Error("finding feedbacks for erasure", logger.M{"err": err})
+
+		return nil, fmt.Errorf("finding feedbacks: %w", err)
+	}
+
+	s.logger.Info("erasing customer data", logger.M{"feedbacks": len(feedbackIDs)})
+
+	if len(feedbackIDs) > 0 {
+		err = s.producer.PublishBatch(ctx, events)
+		if err != nil {
+			s.logger.Error("sending tombstones", logger.M{"err": err})
+
+			return nil, fmt.Errorf("sending tombstones: %w", err)
+		}
+	}
+
+	erased, err := s.repo.Delete(ctx, feedbackIDs)
+	if err != nil {
+		s.logger.Error("deleting feedbacks", logger.M{"err": err})
+
+		return nil, fmt.Errorf("deleting feedbacks: %w", err)
+	}
+
+	purged := 0
+
+	if s.purger != nil {
+		purged, err = s.purger.PurgeCustomer(ctx, email, feedbackIDs)
+		if err != nil {
+			s.logger.Error("purging the broker log", logger.M{"purged": purged, "err": err})
+
+			return nil, fmt.Errorf("purging the broker log: %w", err)
+		}
+	}
+
+	// The listings with the feedbacks can't be found by the keys, all cached responses are dropped.
+	err = cache.Invalidate(ctx, s.cache)
+	if err != nil {
+		s.logger.Error("evicting cache", logger.M{"err": err})
+
+		return nil, fmt.Errorf("evicting cache: %w", err)
+	}
+
+	actor := reqctx.ActorFrom(ctx)
+	receipt := &models.ErasureReceipt{
+		ID:             uuid.New(),
+		EmailHash:      s.mac([]byte(strings.ToLower(email))),
+		FeedbackIDs:    feedbackIDs,
+		Erased:         erased,
+		TombstonesSent: len(feedbackIDs),
+		LogPurged:      purged,
+		CacheEvicted:   true,
+		ActorSubject:   actor.Subject,
+		ActorRole:      actor.Role,
+		RequestID:      reqctx.RequestID(ctx),
+		CompletedAt:    time.Now().UTC(),
+		Signature:      "",
+	}
+
+	err = s.sign(receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := audit.NewEntry(ctx, audit.EntityErasure, audit.ActionErase, receipt.ID, nil, receipt)
+	if err != nil {
+		return nil, fmt.Errorf("building audit entry: %w", err)
+	}
+
+	err = s.auditLog.Record(ctx, entry)
+	if err != nil {
+		// The data is already erased, the request can be repeated to get the receipt.
+		s.logger.Error("saving erasure receipt", logger.M{"receipt": receipt.ID, "err": err})
+
+		return nil, fmt.Errorf("saving receipt: %w", err)
+	}
+
+	s.logger.Info("customer data is erased", logger.M{"receipt": receipt.ID, "erased": erased, "purged": purged})
+
+	return receipt, nil
+}
+
+func (s *Service) sign(receipt *models.ErasureReceipt) error {
+	payload, err := json.Marshal(receipt)
+	if err != nil {
+		return fmt.Errorf("encoding receipt: %w", err)
+	}
+
+	receipt.Signature = s.mac(payload)
+
+	return nil
+}
+
+func (s *Service) mac(data []byte) string {
+	mac := hmac.New(sha256.New, s.receiptKey)
+	mac.Write(data)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
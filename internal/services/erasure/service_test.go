@@ -0,0 +1,239 @@
+package erasure_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/audit"
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/file"
+	cacheMemory "github.com/andrsj/feedback-service/internal/infrastructure/cache/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/internal/services/erasure"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+const receiptKey = "receipt-key"
+
+var errBroker = errors.New("broker is down")
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) logger.Logger { return l }
+func (nopLogger) Debug(string, logger.M)       {}
+func (nopLogger) Info(string, logger.M)        {}
+func (nopLogger) Warn(string, logger.M)        {}
+func (nopLogger) Error(string, logger.M)       {}
+func (nopLogger) Fatal(string, logger.M)       {}
+
+type failingProducer struct{}
+
+func (failingProducer) PublishBatch(context.Context, []*models.FeedbackEvent) error {
+	return errBroker
+}
+
+type fixture struct {
+	repo     *memory.FeedbackRepository
+	auditLog *memory.AuditRepository
+	log      *file.Producer
+	path     string
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+
+	log, err := file.New(nopLogger{}, path, file.Config{MaxSize: 0, MaxFiles: 0})
+	if err != nil {
+		t.Fatalf("file.New: %v", err)
+	}
+
+	t.Cleanup(func() { _ = log.Close() })
+
+	auditLog := memory.NewAuditRepository(nil, nopLogger{})
+
+	return &fixture{
+		repo:     memory.New(auditLog, ids.NewV7(), nopLogger{}),
+		auditLog: auditLog,
+		log:      log,
+		path:     path,
+	}
+}
+
+// create saves the feedback and appends its event to the log, as the feedback service does.
+func (f *fixture) create(t *testing.T, email string) *models.Feedback {
+	t.Helper()
+
+	//nolint:exhaustivestruct,exhaustruct
+	feedback := &models.Feedback{
+		CustomerName: "Ann",
+		Email:        email,
+		FeedbackText: "ok",
+		Source:       "https://shop.example.com",
+	}
+
+	ctx := context.Background()
+
+	if _, err := f.repo.Create(ctx, feedback); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := f.log.Publish(ctx, models.NewFeedbackEvent(models.FeedbackCreated, feedback)); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	return feedback
+}
+
+func (f *fixture) service(producer erasure.Producer) *erasure.Service {
+	return erasure.New(f.repo, producer, f.log, cacheMemory.New(nopLogger{}), f.auditLog, receiptKey, nopLogger{})
+}
+
+func (f *fixture) records(t *testing.T) []*file.Record {
+	t.Helper()
+
+	reader, err := os.Open(f.path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer reader.Close()
+
+	var records []*file.Record
+
+	err = file.ReadRecords(reader, func(_ int, record *file.Record) error {
+		records = append(records, record)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadRecords: %v", err)
+	}
+
+	return records
+}
+
+func TestEraseByEmail(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := newFixture(t)
+
+	first, second := f.create(t, "ann@example.com"), f.create(t, "ANN@example.com")
+	other := f.create(t, "bob@example.com")
+
+	receipt, err := f.service(f.log).EraseByEmail(ctx, " Ann@Example.com")
+	if err != nil {
+		t.Fatalf("EraseByEmail: %v", err)
+	}
+
+	if receipt.Erased != 2 || receipt.TombstonesSent != 2 || receipt.LogPurged != 2 || !receipt.CacheEvicted {
+		t.Errorf("receipt: got %+v", receipt)
+	}
+
+	// The signature is HMAC of the receipt with empty signature.
+	signature := receipt.Signature
+	receipt.Signature = ""
+	payload, _ := json.Marshal(receipt)
+	mac := hmac.New(sha256.New, []byte(receiptKey))
+	mac.Write(payload)
+
+	if hex.EncodeToString(mac.Sum(nil)) != signature {
+		t.Error("the receipt signature doesn't match")
+	}
+
+	if _, err = f.repo.GetByID(ctx, first.ID); !errors.Is(err, models.ErrFeedbackNotFound) {
+		t.Errorf("GetByID of the erased feedback: got %v", err)
+	}
+
+	if _, err = f.repo.GetByID(ctx, other.ID); err != nil {
+		t.Errorf("GetByID of the other customer: %v", err)
+	}
+
+	// Only the tombstones of the customer and the record of the other customer are left.
+	tombstones := 0
+
+	for _, record := range f.records(t) {
+		switch {
+		case record.Deleted && (record.ID == first.ID || record.ID == second.ID):
+			tombstones++
+		case record.Feedback != nil && record.ID == other.ID:
+		default:
+			t.Errorf("record left in the log: %+v", record)
+		}
+	}
+
+	if tombstones != 2 {
+		t.Errorf("tombstones: got %d, want 2", tombstones)
+	}
+
+	content, _ := os.ReadFile(f.path)
+	if strings.Contains(strings.ToLower(string(content)), "ann@example.com") {
+		t.Error("the email is left in the log")
+	}
+
+	entries, _ := f.auditLog.GetByEntity(ctx, audit.EntityErasure, receipt.ID)
+	if len(entries) != 1 || entries[0].Action != audit.ActionErase {
+		t.Errorf("audit entries of the receipt: got %v", entries)
+	}
+}
+
+func TestEraseByEmailPurgesLogAgain(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := newFixture(t)
+
+	feedback := f.create(t, "ann@example.com")
+
+	// The feedback is deleted, but its record is still in the log, e.g. the purge failed before.
+	if _, err := f.repo.Delete(ctx, []uuid.UUID{feedback.ID}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	receipt, err := f.service(f.log).EraseByEmail(ctx, "ann@example.com")
+	if err != nil {
+		t.Fatalf("EraseByEmail: %v", err)
+	}
+
+	if receipt.Erased != 0 || receipt.LogPurged != 1 || len(f.records(t)) != 0 {
+		t.Errorf("got %+v with %d records in the log", receipt, len(f.records(t)))
+	}
+}
+
+func TestEraseByEmailErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := newFixture(t)
+
+	if _, err := f.service(f.log).EraseByEmail(ctx, "not an email"); !errors.Is(err, erasure.ErrInvalidEmail) {
+		t.Errorf("invalid email: got %v, want %v", err, erasure.ErrInvalidEmail)
+	}
+
+	feedback := f.create(t, "ann@example.com")
+
+	// Without the tombstones nothing is deleted, the erasure can be repeated.
+	if _, err := f.service(failingProducer{}).EraseByEmail(ctx, "ann@example.com"); !errors.Is(err, errBroker) {
+		t.Errorf("failed tombstones: got %v, want %v", err, errBroker)
+	}
+
+	if _, err := f.repo.GetByID(ctx, feedback.ID); err != nil {
+		t.Errorf("GetByID after the failed erasure: %v", err)
+	}
+
+	if records := f.records(t); len(records) != 1 || records[0].Feedback == nil {
+		t.Errorf("log after the failed erasure: got %v", records)
+	}
+}
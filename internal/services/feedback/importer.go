@@ -0,0 +1,194 @@
+package feedback
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+)
+
+const (
+	ImportNDJSON = "ndjson"
+	ImportCSV    = "csv"
+)
+
+var (
+	// ErrInvalidImport is a problem of the whole file, not of a line.
+	ErrInvalidImport = errors.New("invalid import")
+	errTimestamp     = errors.New("invalid timestamp, use RFC 3339")
+	// Rules can't be applied, it's not a problem of the line.
+	errImportRules = errors.New("import is stopped")
+)
+
+// lineError is a problem of one line, the import goes on.
+type lineError struct {
+	line int
+	err  error
+}
+
+func (e *lineError) Error() string {
+	return e.err.Error()
+}
+
+// importReader returns records one by one and io.EOF at the end.
+type importReader interface {
+	Next() (line int, record *models.ImportRecord, err error)
+}
+
+func newImportReader(reader io.Reader, format string) (importReader, error) { //nolint:ireturn
+	switch format {
+	case ImportNDJSON, "":
+		return &ndjsonReader{reader: bufio.NewReader(reader), line: 0}, nil
+	case ImportCSV:
+		return newCSVReader(reader)
+	default:
+		return nil, fmt.Errorf("unknown format '%s': %w", format, ErrInvalidImport)
+	}
+}
+
+// ndjsonReader reads a JSON object per line, like the NDJSON export.
+type ndjsonReader struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func (r *ndjsonReader) Next() (int, *models.ImportRecord, error) {
+	for {
+		data, err := r.reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return r.line, nil, fmt.Errorf("can't read line %d: %w", r.line+1, err)
+		}
+
+		if len(data) == 0 && errors.Is(err, io.EOF) {
+			return r.line, nil, io.EOF
+		}
+
+		r.line++
+
+		// Empty lines are skipped.
+		if strings.TrimSpace(string(data)) == "" {
+			continue
+		}
+
+		var record models.ImportRecord
+
+		if err := json.Unmarshal(data, &record); err != nil {
+			return r.line, nil, &lineError{line: r.line, err: fmt.Errorf("invalid JSON: %w", err)}
+		}
+
+		return r.line, &record, nil
+	}
+}
+
+// csvReader reads CSV with header, the columns are found by names of the CSV export.
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(reader io.Reader) (*csvReader, error) {
+	csvReader := &csvReader{
+		reader:  csv.NewReader(reader),
+		columns: make(map[string]int),
+	}
+
+	// Rows with wrong number of fields are reported as line errors.
+	csvReader.reader.FieldsPerRecord = -1
+
+	header, err := csvReader.reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("can't read CSV header: %s: %w", err.Error(), ErrInvalidImport)
+	}
+
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+
+		// The rows are checked by the count of the columns.
+		if _, ok := csvReader.columns[column]; ok {
+			return nil, fmt.Errorf("duplicated CSV column '%s': %w", column, ErrInvalidImport)
+		}
+
+		csvReader.columns[column] = i
+	}
+
+	for _, column := range []string{"customer_name", "email", "feedback_text", "source"} {
+		if _, ok := csvReader.columns[column]; !ok {
+			return nil, fmt.Errorf("missing CSV column '%s': %w", column, ErrInvalidImport)
+		}
+	}
+
+	return csvReader, nil
+}
+
+func (r *csvReader) Next() (int, *models.ImportRecord, error) {
+	row, err := r.reader.Read()
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.StartLine, nil, &lineError{line: parseErr.StartLine, err: err}
+	}
+
+	if err != nil {
+		return 0, nil, err //nolint:wrapcheck
+	}
+
+	line, _ := r.reader.FieldPos(0)
+
+	if len(row) != len(r.columns) {
+		return line, nil, &lineError{
+			line: line,
+			err:  fmt.Errorf("expected %d fields, got %d", len(r.columns), len(row)), //nolint:goerr113
+		}
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	record := &models.ImportRecord{
+		FeedbackInput: models.FeedbackInput{
+			CustomerName: r.value(row, "customer_name"),
+			Email:        r.value(row, "email"),
+			FeedbackText: r.value(row, "feedback_text"),
+			Source:       r.value(row, "source"),
+		},
+	}
+
+	record.CreatedAt, err = parseTimestamp(r.value(row, "created_at"))
+	if err != nil {
+		return line, nil, &lineError{line: line, err: fmt.Errorf("created_at: %w", err)}
+	}
+
+	record.UpdatedAt, err = parseTimestamp(r.value(row, "updated_at"))
+	if err != nil {
+		return line, nil, &lineError{line: line, err: fmt.Errorf("updated_at: %w", err)}
+	}
+
+	return line, record, nil
+}
+
+// value returns the value of the column or empty string if there is no such column.
+func (r *csvReader) value(row []string, column string) string {
+	index, ok := r.columns[column]
+	if !ok {
+		return ""
+	}
+
+	return row[index]
+}
+
+func parseTimestamp(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, fmt.Errorf("'%s': %w", value, errTimestamp)
+	}
+
+	return &timestamp, nil
+}
@@ -0,0 +1,201 @@
+package feedback_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	brokerMemory "github.com/andrsj/feedback-service/internal/infrastructure/broker/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/internal/services/feedback"
+	"github.com/andrsj/feedback-service/internal/services/rules"
+	"github.com/andrsj/feedback-service/internal/services/sentiment"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+var errBroker = errors.New("broker is down")
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) logger.Logger { return l }
+func (nopLogger) Debug(string, logger.M)       {}
+func (nopLogger) Info(string, logger.M)        {}
+func (nopLogger) Warn(string, logger.M)        {}
+func (nopLogger) Error(string, logger.M)       {}
+func (nopLogger) Fatal(string, logger.M)       {}
+
+func newService(t *testing.T) (*feedback.Service, *memory.FeedbackRepository, *brokerMemory.Recorder) {
+	t.Helper()
+
+	analyzer, err := sentiment.New()
+	if err != nil {
+		t.Fatalf("sentiment.New: %v", err)
+	}
+
+	auditLog := memory.NewAuditRepository(nil, nopLogger{})
+	repo := memory.New(auditLog, ids.NewV7(), nopLogger{})
+	recorder := brokerMemory.New(nopLogger{})
+	router := rules.New(memory.NewRuleRepository(auditLog, nopLogger{}), nopLogger{})
+
+	return feedback.New(repo, recorder, analyzer, router, nopLogger{}), repo, recorder
+}
+
+func saved(t *testing.T, repo *memory.FeedbackRepository) []*models.Feedback {
+	t.Helper()
+
+	var feedbacks []*models.Feedback
+
+	err := repo.Iterate(context.Background(), nil, 10, func(chunk []*models.Feedback) error {
+		feedbacks = append(feedbacks, chunk...)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Iterate: %v", err)
+	}
+
+	return feedbacks
+}
+
+func checkErrors(t *testing.T, report *models.ImportReport, lines ...int) {
+	t.Helper()
+
+	if report.Failed != len(lines) || len(report.Errors) != len(lines) {
+		t.Fatalf("errors: got %d of %d, want lines %v", len(report.Errors), report.Failed, lines)
+	}
+
+	for i, line := range lines {
+		if report.Errors[i].Line != line || report.Errors[i].Error == "" {
+			t.Errorf("error %d: got %+v, want line %d", i, report.Errors[i], line)
+		}
+	}
+}
+
+const ndjsonImport = `{"customer_name":"Jane","email":"jane@example.com","feedback_text":"great","source":"https://a.example.com","created_at":"2021-05-01T10:00:00Z"}
+{"customer_name":"broken"
+{"customer_name":"John","email":"not an email","feedback_text":"ok","source":"https://a.example.com"}
+
+{"customer_name":"Ann","email":"ann@example.com","feedback_text":"ok","source":"https://b.example.com"}
+{"customer_name":"Bob","email":"bob@example.com","feedback_text":"ok","source":"not a url"}
+`
+
+func TestImportNDJSON(t *testing.T) {
+	t.Parallel()
+
+	service, repo, recorder := newService(t)
+
+	report, err := service.Import(context.Background(), strings.NewReader(ndjsonImport), feedback.ImportNDJSON, false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	// The empty line isn't counted, the bad lines don't stop the import.
+	if report.Total != 5 || report.Valid != 2 || report.Imported != 2 || report.DryRun {
+		t.Errorf("report: got %+v", report)
+	}
+
+	checkErrors(t, report, 2, 3, 6)
+
+	feedbacks := saved(t, repo)
+	if len(feedbacks) != 2 {
+		t.Fatalf("saved: got %d, want 2", len(feedbacks))
+	}
+
+	// The original timestamp is kept, the missing one is the time of the import.
+	for _, item := range feedbacks {
+		switch item.Email {
+		case "jane@example.com":
+			if !item.CreatedAt.Equal(time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)) {
+				t.Errorf("created_at of the imported feedback: got %s", item.CreatedAt)
+			}
+		case "ann@example.com":
+			if time.Since(item.CreatedAt) > time.Minute {
+				t.Errorf("created_at of the feedback without it: got %s", item.CreatedAt)
+			}
+		default:
+			t.Errorf("unexpected feedback %+v", item)
+		}
+	}
+
+	messages := recorder.Messages()
+	if len(messages) != 2 || messages[0].Type != models.FeedbackCreated || messages[1].Type != models.FeedbackCreated {
+		t.Errorf("events: got %v", messages)
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	t.Parallel()
+
+	service, repo, recorder := newService(t)
+
+	report, err := service.Import(context.Background(), strings.NewReader(ndjsonImport), feedback.ImportNDJSON, true)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	if !report.DryRun || report.Valid != 2 || report.Imported != 0 || report.Failed != 3 {
+		t.Errorf("report: got %+v", report)
+	}
+
+	if len(saved(t, repo)) != 0 || len(recorder.Messages()) != 0 {
+		t.Error("the dry run saved the feedbacks")
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	t.Parallel()
+
+	service, repo, _ := newService(t)
+
+	// The columns are found by the names, the order is free.
+	input := "source,email,customer_name,feedback_text,created_at\n" +
+		"https://a.example.com,jane@example.com,Jane,\"great,\nreally\",2021-05-01T10:00:00Z\n" +
+		"https://a.example.com,john@example.com,John\n" +
+		"https://a.example.com,ann@example.com,Ann,ok,yesterday\n" +
+		"https://a.example.com,bob@example.com,Bob,ok,\n"
+
+	report, err := service.Import(context.Background(), strings.NewReader(input), feedback.ImportCSV, false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	if report.Total != 4 || report.Imported != 2 {
+		t.Errorf("report: got %+v", report)
+	}
+
+	// The quoted value takes two lines, the line of the row is its first line.
+	checkErrors(t, report, 4, 5)
+
+	if feedbacks := saved(t, repo); len(feedbacks) != 2 || feedbacks[0].FeedbackText != "great,\nreally" {
+		t.Errorf("saved: got %v", feedbacks)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	service, _, recorder := newService(t)
+
+	for name, test := range map[string][2]string{
+		"unknown format":     {"xml", ""},
+		"missing CSV column": {feedback.ImportCSV, "customer_name,email,source\n"},
+		"empty CSV":          {feedback.ImportCSV, ""},
+	} {
+		if _, err := service.Import(ctx, strings.NewReader(test[1]), test[0], false); !errors.Is(err, feedback.ErrInvalidImport) {
+			t.Errorf("%s: got %v, want %v", name, err, feedback.ErrInvalidImport)
+		}
+	}
+
+	// The failed batch stops the import, the report has what is imported before it.
+	recorder.SetError(errBroker)
+
+	report, err := service.Import(ctx, strings.NewReader(ndjsonImport), feedback.ImportNDJSON, false)
+	if !errors.Is(err, errBroker) || report == nil || report.Imported != 0 {
+		t.Errorf("failed batch: got %+v, %v", report, err)
+	}
+}
@@ -1,13 +1,29 @@
 package feedback
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"strings"
 
 	"github.com/google/uuid"
 
 	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/file"
 	"github.com/andrsj/feedback-service/internal/infrastructure/broker/kafka"
+	brokerMemory "github.com/andrsj/feedback-service/internal/infrastructure/broker/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/noop"
 	"github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/sqlite"
+	"github.com/andrsj/feedback-service/internal/services/dlq"
+	"github.com/andrsj/feedback-service/internal/services/rules"
+	"github.com/andrsj/feedback-service/internal/services/sentiment"
+	"github.com/andrsj/feedback-service/internal/services/webhooks"
 	"github.com/andrsj/feedback-service/pkg/logger"
 )
 
@@ -19,40 +35,115 @@ This is a good practice to separate the interface:
 If we want to use only some part of logic.
 */
 type Repository interface {
-	Create(feedback *models.FeedbackInput) (feedbackID uuid.UUID, err error)
-	GetByID(feedbackID uuid.UUID) (feedback *models.Feedback, err error)
-	GetAll() (feedbacks []*models.Feedback, err error)
-	GetPage(limit int, next uuid.UUID) ([]*models.Feedback, uuid.UUID, error)
+	Create(ctx context.Context, feedback *models.Feedback) (feedbackID uuid.UUID, err error)
+	// CreateBatch keeps timestamps of the feedbacks if they are set.
+	CreateBatch(ctx context.Context, feedbacks []*models.Feedback) error
+	Update(ctx context.Context, feedback *models.Feedback) error
+	// Delete skips unknown IDs and returns the count of deleted feedbacks.
+	Delete(ctx context.Context, feedbackIDs []uuid.UUID) (deleted int, err error)
+	GetByID(ctx context.Context, feedbackID uuid.UUID) (feedback *models.Feedback, err error)
+	Iterate(
+		ctx context.Context,
+		filter *models.FeedbackFilter,
+		chunkSize int,
+		callback func(feedbacks []*models.Feedback) error,
+	) error
+	GetPage(
+		ctx context.Context,
+		limit int,
+		next models.PageCursor,
+		filter *models.FeedbackFilter,
+	) ([]*models.Feedback, models.PageCursor, error)
 }
 
 // Check that actual implementation fits the interface.
-var _ Repository = (*gorm.FeedbackRepository)(nil)
-
-// var _ Repository = (*memory.FeedbackRepository)(nil)
+var (
+	_ Repository = (*gorm.FeedbackRepository)(nil)
+	_ Repository = (*memory.FeedbackRepository)(nil)
+	_ Repository = (*sqlite.FeedbackRepository)(nil)
+)
 
+// Producer publishes the event of every change of the feedbacks, see models.FeedbackEvent.
 type Producer interface {
-	SendMessage(*models.Feedback) error
+	// Publish sends the event of the request, the async producer only queues it.
+	Publish(context.Context, *models.FeedbackEvent) error
+	// PublishBatch returns when all events are delivered.
+	PublishBatch(context.Context, []*models.FeedbackEvent) error
 	Close() error
 }
 
 // Check that actual implementation fits the interface.
-var _ Producer = (*kafka.Producer)(nil)
+var (
+	_ Producer = (*kafka.Producer)(nil)
+	_ Producer = (*kafka.AsyncProducer)(nil)
+	_ Producer = (*noop.Producer)(nil)
+	_ Producer = (*file.Producer)(nil)
+	_ Producer = (*brokerMemory.Recorder)(nil)
+	_ Producer = (*dlq.Producer)(nil)
+	_ Producer = (*webhooks.Producer)(nil)
+)
+
+// ErrBrokerBusy is returned when the queue of the producer is full, the client could retry later.
+var ErrBrokerBusy = broker.ErrQueueFull
+
+// backpressure is implemented by the producers with the bounded queue,
+// the feedback isn't saved if its event would be rejected. The check is only a hint:
+// the queue could be filled by other requests before the event is sent, see Create.
+type backpressure interface {
+	Rejects() bool
+}
+
+type SentimentAnalyzer interface {
+	Analyze(text string) sentiment.Result
+}
+
+// Check that actual implementation fits the interface.
+var _ SentimentAnalyzer = (*sentiment.Analyzer)(nil)
+
+// RuleRouter sets tags, team and priority of the feedback.
+// Rules are loaded once for the batches of import and backfill.
+type RuleRouter interface {
+	Apply(ctx context.Context, feedback *models.Feedback) ([]*models.Rule, error)
+	Rules(ctx context.Context) (*rules.Set, error)
+}
+
+// Check that actual implementation fits the interface.
+var _ RuleRouter = (*rules.Service)(nil)
+
+const (
+	// Rows per query of Stream.
+	streamChunkSize = 500
+	// Rows per insert and per batch of events of Import.
+	importBatchSize = 500
+	// Only the first errors are returned in the import report.
+	maxImportErrors = 1000
+)
 
 type Service struct {
 	logger   logger.Logger
 	repo     Repository
 	producer Producer
+	analyzer SentimentAnalyzer
+	router   RuleRouter
 }
 
-func New(feedbackRepository Repository, producer Producer, logger logger.Logger) *Service {
+func New(
+	feedbackRepository Repository,
+	producer Producer,
+	analyzer SentimentAnalyzer,
+	router RuleRouter,
+	logger logger.Logger,
+) *Service {
 	return &Service{
 		logger:   logger.Named("service"),
 		repo:     feedbackRepository,
 		producer: producer,
+		analyzer: analyzer,
+		router:   router,
 	}
 }
 
-func (s *Service) Create(feedback *models.FeedbackInput) (string, error) {
+func (s *Service) Create(ctx context.Context, feedback *models.FeedbackInput) (string, error) {
 	var (
 		feedbackID uuid.UUID
 		err        error
@@ -65,25 +156,47 @@ func (s *Service) Create(feedback *models.FeedbackInput) (string, error) {
 		return "", fmt.Errorf("validating feedback error: %w", err)
 	}
 
-	s.logger.Info("creating feedback", logger.M{"feedback": feedback})
+	if producer, ok := s.producer.(backpressure); ok && producer.Rejects() {
+		s.logger.Warn("broker queue is full, feedback is rejected", nil)
+
+		return "", ErrBrokerBusy
+	}
+
+	s.logger.Info("creating feedback", logger.M{
+		"feedback":  feedback,
+		"requestID": reqctx.RequestID(ctx),
+		"tenant":    reqctx.Tenant(ctx),
+	})
+
+	feedbackModel, fired, err := s.build(ctx, feedback)
+	if err != nil {
+		s.logger.Error("applying rules error", logger.M{"err": err})
+
+		return "", fmt.Errorf("applying rules error: %w", err)
+	}
+
+	s.logger.Info("rules fired", logger.M{"count": len(fired)})
 
-	feedbackID, err = s.repo.Create(feedback)
+	feedbackID, err = s.repo.Create(ctx, feedbackModel)
 	if err != nil {
 		s.logger.Error("creating feedback error", logger.M{"err": err})
 
 		return "", fmt.Errorf("creating feedback error: %w", err)
 	}
 
-	//nolint:exhaustivestruct,exhaustruct
-	err = s.producer.SendMessage(&models.Feedback{
-		ID:           feedbackID,
-		CustomerName: feedback.CustomerName,
-		Email:        feedback.Email,
-		FeedbackText: feedback.FeedbackText,
-		Source:       feedback.Source,
-		// I don't specify the createdAt, updatedAt from DB instance
-		// because it's a part of DB logic, not a Kafka.
-	})
+	// The feedback is already saved, so the event is sent even if the client is gone.
+	err = s.producer.Publish(reqctx.Detach(ctx), models.NewFeedbackEvent(models.FeedbackCreated, feedbackModel))
+	if errors.Is(err, ErrBrokerBusy) {
+		// The queue is filled after the check, the saved feedback isn't rejected: the retry would duplicate it.
+		// dlq.Producer keeps such events, the error gets here only if the dead-letter queue fails too.
+		s.logger.Error("broker queue is full, event of the saved feedback isn't sent", logger.M{
+			"feedbackID": feedbackID.String(),
+			"err":        err,
+		})
+
+		return feedbackID.String(), nil
+	}
+
 	if err != nil {
 		s.logger.Error("broker sending feedback error", logger.M{"err": err})
 
@@ -95,7 +208,255 @@ func (s *Service) Create(feedback *models.FeedbackInput) (string, error) {
 	return feedbackID.String(), nil
 }
 
-func (s *Service) GetByID(feedbackID string) (*models.Feedback, error) {
+// Import creates feedbacks from NDJSON or CSV, every row is validated like in Create.
+// Invalid rows are reported and skipped, valid ones are saved and published by batches.
+// With dry run nothing is saved, the report shows what would be imported.
+func (s *Service) Import(
+	ctx context.Context,
+	reader io.Reader,
+	format string,
+	dryRun bool,
+) (*models.ImportReport, error) {
+	s.logger.Info("importing feedbacks", logger.M{
+		"format":    format,
+		"dryRun":    dryRun,
+		"requestID": reqctx.RequestID(ctx),
+	})
+
+	records, err := newImportReader(reader, format)
+	if err != nil {
+		return nil, err
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	report := &models.ImportReport{DryRun: dryRun, Errors: []*models.ImportError{}}
+	batch := make([]*models.Feedback, 0, importBatchSize)
+
+	// The rules are loaded once per batch of lines, not for every line.
+	var (
+		ruleSet  *rules.Set
+		ruleUses int
+	)
+
+	for {
+		line, record, err := records.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var lineErr *lineError
+		if errors.As(err, &lineErr) {
+			report.Total++
+			s.reportImportError(report, line, err)
+
+			continue
+		}
+
+		if err != nil {
+			return report, fmt.Errorf("reading import, %d imported: %w", report.Imported, err)
+		}
+
+		report.Total++
+
+		if ruleSet == nil || ruleUses == importBatchSize {
+			ruleSet, err = s.router.Rules(ctx)
+			if err != nil {
+				err = fmt.Errorf("can't apply rules: %s: %w", err.Error(), errImportRules)
+
+				return report, fmt.Errorf("line %d, %d imported: %w", line, report.Imported, err)
+			}
+
+			ruleUses = 0
+		}
+
+		ruleUses++
+
+		feedback, err := s.buildImported(ctx, ruleSet, record)
+		if err != nil {
+			s.reportImportError(report, line, err)
+
+			continue
+		}
+
+		report.Valid++
+
+		if dryRun {
+			continue
+		}
+
+		batch = append(batch, feedback)
+		if len(batch) == importBatchSize {
+			if err = s.importBatch(ctx, batch); err != nil {
+				return report, fmt.Errorf("line %d, %d imported: %w", line, report.Imported, err)
+			}
+
+			report.Imported += len(batch)
+			batch = make([]*models.Feedback, 0, importBatchSize)
+		}
+	}
+
+	if len(batch) > 0 {
+		if err = s.importBatch(ctx, batch); err != nil {
+			return report, fmt.Errorf("%d imported: %w", report.Imported, err)
+		}
+
+		report.Imported += len(batch)
+	}
+
+	s.logger.Info("successfully imported feedbacks", logger.M{
+		"total":    report.Total,
+		"imported": report.Imported,
+		"failed":   report.Failed,
+		"dryRun":   dryRun,
+	})
+
+	return report, nil
+}
+
+// buildImported validates the record and builds the model with original timestamps.
+func (s *Service) buildImported(
+	ctx context.Context,
+	ruleSet *rules.Set,
+	record *models.ImportRecord,
+) (*models.Feedback, error) {
+	err := Validate(&record.FeedbackInput)
+	if err != nil {
+		return nil, err
+	}
+
+	feedback := s.newModel(ctx, &record.FeedbackInput)
+	ruleSet.Apply(feedback)
+
+	if record.CreatedAt != nil {
+		feedback.CreatedAt = record.CreatedAt.UTC()
+	}
+
+	if record.UpdatedAt != nil {
+		feedback.UpdatedAt = record.UpdatedAt.UTC()
+	}
+
+	return feedback, nil
+}
+
+func (s *Service) importBatch(ctx context.Context, feedbacks []*models.Feedback) error {
+	err := s.repo.CreateBatch(ctx, feedbacks)
+	if err != nil {
+		s.logger.Error("creating batch of feedbacks error", logger.M{"err": err})
+
+		return fmt.Errorf("creating batch of feedbacks error: %w", err)
+	}
+
+	err = s.producer.PublishBatch(reqctx.Detach(ctx), models.FeedbackEvents(models.FeedbackCreated, feedbacks))
+	if err != nil {
+		s.logger.Error("broker sending feedbacks error", logger.M{"err": err})
+
+		return fmt.Errorf("broker sending feedbacks error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) reportImportError(report *models.ImportReport, line int, err error) {
+	report.Failed++
+
+	if len(report.Errors) == maxImportErrors {
+		report.ErrorsTruncated = true
+
+		return
+	}
+
+	report.Errors = append(report.Errors, &models.ImportError{Line: line, Error: err.Error()})
+}
+
+// DryRun shows which rules would fire for the feedback, nothing is saved.
+func (s *Service) DryRun(ctx context.Context, feedback *models.FeedbackInput) (*models.DryRunResult, error) {
+	err := Validate(feedback)
+	if err != nil {
+		return nil, fmt.Errorf("validating feedback error: %w", err)
+	}
+
+	feedbackModel, fired, err := s.build(ctx, feedback)
+	if err != nil {
+		s.logger.Error("applying rules error", logger.M{"err": err})
+
+		return nil, fmt.Errorf("applying rules error: %w", err)
+	}
+
+	return &models.DryRunResult{
+		Rules:    fired,
+		Tags:     feedbackModel.Tags,
+		Team:     feedbackModel.Team,
+		Priority: feedbackModel.Priority,
+	}, nil
+}
+
+// ReapplyRules evaluates the current rules for all saved feedbacks
+// and returns the count of feedbacks with changed routing.
+// The changed feedbacks of the page are published by one batch: tagged if the tags are changed, updated otherwise.
+func (s *Service) ReapplyRules(ctx context.Context, batchSize int) (int, error) {
+	var (
+		updated int
+		next    models.PageCursor
+	)
+
+	s.logger.Info("re-applying rules to existing feedbacks", logger.M{"batch": batchSize})
+
+	for {
+		feedbacks, cursor, err := s.repo.GetPage(ctx, batchSize, next, nil)
+		if err != nil {
+			return updated, fmt.Errorf("can't get page of feedbacks: %w", err)
+		}
+
+		if len(feedbacks) == 0 {
+			break
+		}
+
+		ruleSet, err := s.router.Rules(ctx)
+		if err != nil {
+			return updated, fmt.Errorf("applying rules error: %w", err)
+		}
+
+		events := make([]*models.FeedbackEvent, 0, len(feedbacks))
+
+		for _, feedback := range feedbacks {
+			before := *feedback
+
+			ruleSet.Apply(feedback)
+
+			if sameRouting(&before, feedback) {
+				continue
+			}
+
+			err = s.repo.Update(ctx, feedback)
+			if err != nil {
+				return updated, fmt.Errorf("updating feedback '%s': %w", feedback.ID, err)
+			}
+
+			eventType := models.FeedbackUpdated
+			if !sameTags(&before, feedback) {
+				eventType = models.FeedbackTagged
+			}
+
+			events = append(events, models.NewFeedbackEvent(eventType, feedback))
+			updated++
+		}
+
+		if len(events) > 0 {
+			err = s.producer.PublishBatch(reqctx.Detach(ctx), events)
+			if err != nil {
+				return updated, fmt.Errorf("broker sending changed feedbacks error: %w", err)
+			}
+		}
+
+		next = cursor
+	}
+
+	s.logger.Info("rules re-applied", logger.M{"updated": updated})
+
+	return updated, nil
+}
+
+func (s *Service) GetByID(ctx context.Context, feedbackID string) (*models.Feedback, error) {
 	var (
 		feedback *models.Feedback
 		err      error
@@ -113,7 +474,7 @@ func (s *Service) GetByID(feedbackID string) (*models.Feedback, error) {
 		return nil, fmt.Errorf("can't parse the ID: %w", err)
 	}
 
-	feedback, err = s.repo.GetByID(feedbackUUID)
+	feedback, err = s.repo.GetByID(ctx, feedbackUUID)
 	if err != nil {
 		s.logger.Error("getting by ID", logger.M{
 			"feedbackID": feedbackID,
@@ -128,31 +489,37 @@ func (s *Service) GetByID(feedbackID string) (*models.Feedback, error) {
 	return feedback, nil
 }
 
-func (s *Service) GetPage(limit int, next string) ([]*models.Feedback, string, error) {
+func (s *Service) GetPage(
+	ctx context.Context,
+	limit int,
+	next string,
+	filter *models.FeedbackFilter,
+) ([]*models.Feedback, string, error) {
 	var (
 		feedbacks []*models.Feedback
-		nextUUID  = uuid.Nil
+		cursor    models.PageCursor
 		err       error
 	)
 
 	s.logger.Info("Getting page of feedbacks", logger.M{
-		"limit": limit,
-		"next":  next,
+		"limit":  limit,
+		"next":   next,
+		"filter": filter,
 	})
 
 	if next != "" {
-		nextUUID, err = uuid.Parse(next)
+		cursor, err = models.ParsePageCursor(next)
 		if err != nil {
-			s.logger.Error("parsing UUID", logger.M{
+			s.logger.Error("parsing cursor", logger.M{
 				"next":  next,
 				"error": err,
 			})
 
-			return nil, "", fmt.Errorf("can't parse the ID: %w", err)
+			return nil, "", fmt.Errorf("can't parse the cursor: %w", err)
 		}
 	}
 
-	feedbacks, nextUUID, err = s.repo.GetPage(limit, nextUUID)
+	feedbacks, cursor, err = s.repo.GetPage(ctx, limit, cursor, filter)
 	if err != nil {
 		s.logger.Error("can't get page of feedbacks", logger.M{
 			"next":  next,
@@ -163,29 +530,97 @@ func (s *Service) GetPage(limit int, next string) ([]*models.Feedback, string, e
 	}
 
 	s.logger.Info("successfully return page of feedbacks", logger.M{
-		"next":   nextUUID,
+		"next":   cursor.String(),
 		"result": len(feedbacks),
 	})
 
-	return feedbacks, nextUUID.String(), nil
+	return feedbacks, cursor.String(), nil
 }
 
-func (s *Service) GetAll() ([]*models.Feedback, error) {
-	var (
-		feedbacks []*models.Feedback
-		err       error
-	)
+// Stream passes all feedbacks matched by the filter to the callback in chunks,
+// the error of the callback stops the streaming and it's returned as is.
+func (s *Service) Stream(
+	ctx context.Context,
+	filter *models.FeedbackFilter,
+	callback func(feedbacks []*models.Feedback) error,
+) error {
+	s.logger.Info("streaming feedbacks", logger.M{"filter": filter})
 
-	s.logger.Info("getting All feedbacks", nil)
+	var total int
 
-	feedbacks, err = s.repo.GetAll()
+	err := s.repo.Iterate(ctx, filter, streamChunkSize, func(feedbacks []*models.Feedback) error {
+		total += len(feedbacks)
+
+		return callback(feedbacks)
+	})
 	if err != nil {
-		s.logger.Error("getting by ID", logger.M{"error": err})
+		s.logger.Error("streaming feedbacks", logger.M{"error": err, "streamed": total})
 
-		return nil, fmt.Errorf("error by getting from repository: %w", err)
+		return fmt.Errorf("streaming feedbacks: %w", err)
 	}
 
-	s.logger.Info("returning successful result", logger.M{"result": len(feedbacks)})
+	s.logger.Info("successfully streamed feedbacks", logger.M{"result": total})
+
+	return nil
+}
+
+// SourceHost returns host of the source URL,
+// the source is already validated, so on error it's just empty.
+func SourceHost(source string) string {
+	sourceURL, err := url.Parse(source)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(sourceURL.Hostname())
+}
+
+// build creates the model with derived and routing fields.
+func (s *Service) build(
+	ctx context.Context,
+	feedback *models.FeedbackInput,
+) (*models.Feedback, []*models.Rule, error) {
+	feedbackModel := s.newModel(ctx, feedback)
+
+	fired, err := s.router.Apply(ctx, feedbackModel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't apply rules: %w", err)
+	}
+
+	return feedbackModel, fired, nil
+}
+
+// newModel creates the model with derived fields, the routing fields are set by the rules.
+func (s *Service) newModel(ctx context.Context, feedback *models.FeedbackInput) *models.Feedback {
+	result := s.analyzer.Analyze(feedback.FeedbackText)
+
+	//nolint:exhaustivestruct,exhaustruct
+	return &models.Feedback{
+		CustomerName:   feedback.CustomerName,
+		Email:          feedback.Email,
+		FeedbackText:   feedback.FeedbackText,
+		Source:         feedback.Source,
+		Tenant:         reqctx.Tenant(ctx),
+		SourceHost:     SourceHost(feedback.Source),
+		SentimentScore: result.Score,
+		SentimentLabel: result.Label,
+	}
+}
+
+func sameRouting(a, b *models.Feedback) bool {
+	return a.Team == b.Team && a.Priority == b.Priority && sameTags(a, b)
+}
+
+func sameTags(a, b *models.Feedback) bool {
+	if len(a.Tags) != len(b.Tags) {
+		return false
+	}
+
+	for i := range a.Tags {
+		if a.Tags[i] != b.Tags[i] {
+			return false
+		}
+	}
 
-	return feedbacks, nil
+	return true
 }
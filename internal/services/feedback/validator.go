@@ -6,11 +6,13 @@ import (
 	"regexp"
 
 	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/envelope"
 )
 
 var (
 	errValidEmail = errors.New("invalid email address")
 	errValidURL   = errors.New("invalid source URL")
+	errValidName  = errors.New("customer name can't start with the prefix of the encrypted values")
 
 	regexURL = regexp.MustCompile(`^(https?|ftp)://[^\s/$.?#].[^\s]*$`)
 )
@@ -25,5 +27,10 @@ func Validate(feedback *models.FeedbackInput) error {
 		return errValidURL
 	}
 
+	// The repository would read it as the encrypted value.
+	if envelope.IsEncrypted(feedback.CustomerName) || envelope.IsEncrypted(feedback.Email) {
+		return errValidName
+	}
+
 	return nil
 }
@@ -0,0 +1,111 @@
+package projection
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/broker/kafka"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/sqlite"
+	"github.com/andrsj/feedback-service/internal/services/feedback"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// Repository applies the event to all projections at once and skips the applied events by the ID.
+type Repository interface {
+	Apply(ctx context.Context, event *models.FeedbackEvent, latest int) (applied bool, err error)
+	// DeleteEventsBefore removes the IDs of the events applied before the time.
+	DeleteEventsBefore(ctx context.Context, before time.Time) (int, error)
+}
+
+// The IDs of the applied events are pruned once per interval.
+const cleanupInterval = time.Hour
+
+// Check that actual implementations fit the interfaces.
+var (
+	_ Repository    = (*gorm.ProjectionRepository)(nil)
+	_ Repository    = (*sqlite.ProjectionRepository)(nil)
+	_ kafka.Handler = (*Service)(nil)
+)
+
+// Metrics of the applied events.
+//
+//nolint:gochecknoglobals,exhaustivestruct,exhaustruct
+var eventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "projection_events_total",
+	Help: "Feedback events of the projections by type and result: applied, duplicate.",
+}, []string{"type", "result"})
+
+// Service builds the read models of the stats and the latest feedbacks from the feedback events,
+// it's the handler of the consumer in the 'worker' command.
+type Service struct {
+	logger   logger.Logger
+	repo     Repository
+	latest   int
+	eventTTL time.Duration
+}
+
+// New gets the number of the latest feedbacks kept per source host and the time the IDs
+// of the applied events are kept for the deduplication, 0 keeps them forever.
+func New(repo Repository, latest int, eventTTL time.Duration, logger logger.Logger) *Service {
+	return &Service{
+		logger:   logger.Named("projection"),
+		repo:     repo,
+		latest:   latest,
+		eventTTL: eventTTL,
+	}
+}
+
+// Run prunes the IDs of the applied events older than the TTL until the context is cancelled.
+// The TTL must be longer than the redelivery of the events: the pruned event is applied again.
+func (s *Service) Run(ctx context.Context) {
+	if s.eventTTL <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := s.repo.DeleteEventsBefore(ctx, time.Now().Add(-s.eventTTL))
+		if err != nil {
+			s.logger.Error("cleaning applied events", logger.M{"err": err})
+		} else if deleted > 0 {
+			s.logger.Info("old applied events are removed", logger.M{"count": deleted})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Handle derives the source host like the feedback service, the events don't have it.
+func (s *Service) Handle(ctx context.Context, event *models.FeedbackEvent) error {
+	if event.Feedback != nil {
+		event.Feedback.SourceHost = feedback.SourceHost(event.Feedback.Source)
+	}
+
+	applied, err := s.repo.Apply(ctx, event, s.latest)
+	if err != nil {
+		return fmt.Errorf("applying event '%s': %w", event.ID, err)
+	}
+
+	if !applied {
+		eventsTotal.WithLabelValues(event.Type, "duplicate").Inc()
+		s.logger.Info("Event is already applied", logger.M{"eventID": event.ID, "type": event.Type})
+
+		return nil
+	}
+
+	eventsTotal.WithLabelValues(event.Type, "applied").Inc()
+
+	return nil
+}
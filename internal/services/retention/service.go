@@ -0,0 +1,380 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/cache"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/sqlite"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+var ErrInvalidPolicy = errors.New("invalid retention policy")
+
+type Repository interface {
+	CountExpired(ctx context.Context, filter *models.RetentionFilter) (int, error)
+	GetExpired(ctx context.Context, filter *models.RetentionFilter, limit int) ([]uuid.UUID, error)
+	Delete(ctx context.Context, feedbackIDs []uuid.UUID) (int, error)
+	Anonymize(ctx context.Context, feedbackIDs []uuid.UUID) ([]*models.Feedback, error)
+}
+
+// Producer waits until the events are delivered.
+type Producer interface {
+	PublishBatch(ctx context.Context, events []*models.FeedbackEvent) error
+}
+
+// Check that actual implementation fits the interface.
+var (
+	_ Repository = (*gorm.FeedbackRepository)(nil)
+	_ Repository = (*memory.FeedbackRepository)(nil)
+	_ Repository = (*sqlite.FeedbackRepository)(nil)
+)
+
+// Metrics of the purge runs, dry runs aren't counted.
+//
+//nolint:gochecknoglobals,exhaustivestruct,exhaustruct
+var (
+	runsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "retention_runs_total",
+		Help: "Purge runs by result.",
+	}, []string{"result"})
+	feedbacksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "retention_feedbacks_total",
+		Help: "Feedbacks deleted or anonymized by retention policies.",
+	}, []string{"policy", "action"})
+	lastRun = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "retention_last_run_timestamp_seconds",
+		Help: "Start time of the last purge run.",
+	})
+	lastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "retention_last_success_timestamp_seconds",
+		Help: "Start time of the last successful purge run.",
+	})
+	lastDuration = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "retention_last_run_duration_seconds",
+		Help: "Duration of the last purge run.",
+	})
+)
+
+// Service applies the retention policies: the expired feedbacks are deleted or anonymized.
+// Every policy is applied separately, so the feedback matched by several policies gets the strictest one.
+type Service struct {
+	logger    logger.Logger
+	repo      Repository
+	producer  Producer
+	cache     cache.Cache
+	policies  []*models.RetentionPolicy
+	batchSize int
+}
+
+func New(
+	repo Repository,
+	producer Producer,
+	responses cache.Cache,
+	policies []*models.RetentionPolicy,
+	batchSize int,
+	logger logger.Logger,
+) *Service {
+	return &Service{
+		logger:    logger.Named("retention"),
+		repo:      repo,
+		producer:  producer,
+		cache:     responses,
+		policies:  policies,
+		batchSize: batchSize,
+	}
+}
+
+// Policies returns the configured policies.
+func (s *Service) Policies() []*models.RetentionPolicy {
+	return s.policies
+}
+
+// Purge processes the expired feedbacks of every policy by batches, every batch is a separate transaction.
+// Deleted feedbacks get tombstones in the broker, anonymized ones are sent again without personal data.
+// With dry run the expired feedbacks are only counted. Zero batch size means the size from the config.
+func (s *Service) Purge(ctx context.Context, dryRun bool, batchSize int) (*models.RetentionReport, error) {
+	if batchSize <= 0 {
+		batchSize = s.batchSize
+	}
+
+	startedAt := time.Now().UTC()
+	report := &models.RetentionReport{
+		DryRun:     dryRun,
+		StartedAt:  startedAt,
+		Duration:   "",
+		Deleted:    0,
+		Anonymized: 0,
+		Policies:   make([]*models.RetentionResult, 0, len(s.policies)),
+	}
+
+	s.logger.Info("purging expired feedbacks", logger.M{"policies": len(s.policies), "dryRun": dryRun})
+
+	err := s.purge(ctx, report, dryRun, batchSize)
+	duration := time.Since(startedAt)
+	report.Duration = duration.String()
+
+	if !dryRun {
+		lastRun.Set(float64(startedAt.Unix()))
+		lastDuration.Set(duration.Seconds())
+
+		if err != nil {
+			runsTotal.WithLabelValues("error").Inc()
+		} else {
+			runsTotal.WithLabelValues("success").Inc()
+			lastSuccess.Set(float64(startedAt.Unix()))
+		}
+	}
+
+	if err != nil {
+		s.logger.Error("purging expired feedbacks", logger.M{"err": err, "deleted": report.Deleted, "anonymized": report.Anonymized})
+
+		return report, err
+	}
+
+	return report, nil
+}
+
+func (s *Service) purge(ctx context.Context, report *models.RetentionReport, dryRun bool, batchSize int) error {
+	for _, policy := range s.policies {
+		result := &models.RetentionResult{
+			Policy:        policy.String(),
+			Action:        policy.Action,
+			CreatedBefore: report.StartedAt.Add(-policy.MaxAge),
+			Matched:       0,
+			Processed:     0,
+		}
+		report.Policies = append(report.Policies, result)
+
+		//nolint:exhaustivestruct,exhaustruct
+		filter := &models.RetentionFilter{
+			CreatedBefore:    result.CreatedBefore,
+			WithPersonalData: policy.Action == models.RetentionAnonymize,
+		}
+
+		switch policy.Scope {
+		case models.RetentionScopeTenant:
+			filter.Tenant = policy.Value
+		case models.RetentionScopeSource:
+			filter.SourceHost = policy.Value
+		}
+
+		if dryRun {
+			count, err := s.repo.CountExpired(ctx, filter)
+			if err != nil {
+				return fmt.Errorf("counting expired feedbacks of '%s': %w", result.Policy, err)
+			}
+
+			result.Matched = count
+
+			s.logger.Info("expired feedbacks", logger.M{"policy": result.Policy, "matched": count})
+
+			continue
+		}
+
+		err := s.apply(ctx, policy, filter, result, batchSize)
+
+		switch policy.Action {
+		case models.RetentionDelete:
+			report.Deleted += result.Processed
+		case models.RetentionAnonymize:
+			report.Anonymized += result.Processed
+		}
+
+		if err != nil {
+			return fmt.Errorf("applying '%s': %w", result.Policy, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) apply(
+	ctx context.Context,
+	policy *models.RetentionPolicy,
+	filter *models.RetentionFilter,
+	result *models.RetentionResult,
+	batchSize int,
+) error {
+	for {
+		feedbackIDs, err := s.repo.GetExpired(ctx, filter, batchSize)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if len(feedbackIDs) == 0 {
+			return nil
+		}
+
+		result.Matched += len(feedbackIDs)
+
+		processed, err := s.process(ctx, policy.Action, feedbackIDs)
+		result.Processed += processed
+		feedbacksTotal.WithLabelValues(result.Policy, policy.Action).Add(float64(processed))
+
+		if err != nil {
+			return err
+		}
+
+		s.logger.Info("purged batch", logger.M{"policy": result.Policy, "processed": result.Processed})
+
+		// Nothing is changed: the feedbacks are changed by another run, they are skipped till the next run.
+		if processed == 0 || len(feedbackIDs) < batchSize {
+			return nil
+		}
+	}
+}
+
+// process changes one batch and returns the count of changed feedbacks.
+// Tombstones are sent before the deletion, so the failed batch is sent again by the next run.
+func (s *Service) process(ctx context.Context, action string, feedbackIDs []uuid.UUID) (int, error) {
+	var (
+		processed int
+		err       error
+	)
+
+	switch action {
+	case models.RetentionDelete:
+		err = s.producer.PublishBatch(ctx, models.DeletedEvents(feedbackIDs))
+		if err != nil {
+			return 0, fmt.Errorf("sending tombstones: %w", err)
+		}
+
+		processed, err = s.repo.Delete(ctx, feedbackIDs)
+		if err != nil {
+			return 0, err //nolint:wrapcheck
+		}
+	case models.RetentionAnonymize:
+		var anonymized []*models.Feedback
+
+		anonymized, err = s.repo.Anonymize(ctx, feedbackIDs)
+		if err != nil {
+			return 0, err //nolint:wrapcheck
+		}
+
+		processed = len(anonymized)
+
+		err = s.producer.PublishBatch(ctx, models.FeedbackEvents(models.FeedbackUpdated, anonymized))
+		if err != nil {
+			return processed, fmt.Errorf("sending anonymized feedbacks: %w", err)
+		}
+	}
+
+	err = cache.Invalidate(ctx, s.cache)
+	if err != nil {
+		return processed, fmt.Errorf("evicting cache: %w", err)
+	}
+
+	return processed, nil
+}
+
+// ParsePolicies parses the policies "<scope>=<action>:<age>" separated by commas,
+// the scope is "*", "tenant:<tenant>" or "source:<host>", the action is "delete" or "anonymize",
+// the age is days like "90d" or Go duration like "12h".
+func ParsePolicies(config string) ([]*models.RetentionPolicy, error) {
+	policies := make([]*models.RetentionPolicy, 0)
+
+	for _, item := range strings.Split(config, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		policy, err := parsePolicy(item)
+		if err != nil {
+			return nil, fmt.Errorf("%w '%s': %s", ErrInvalidPolicy, item, err.Error())
+		}
+
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
+
+//nolint:goerr113
+func parsePolicy(item string) (*models.RetentionPolicy, error) {
+	scope, rule, found := strings.Cut(item, "=")
+	if !found {
+		return nil, errors.New("expected '<scope>=<action>:<age>'")
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	policy := &models.RetentionPolicy{}
+
+	scope = strings.TrimSpace(scope)
+	if scope == models.RetentionScopeAll {
+		policy.Scope = scope
+	} else {
+		kind, value, _ := strings.Cut(scope, ":")
+
+		switch kind {
+		case models.RetentionScopeTenant:
+			policy.Value = value
+		case models.RetentionScopeSource:
+			policy.Value = strings.ToLower(value)
+		default:
+			return nil, fmt.Errorf("unknown scope '%s', expected '*', 'tenant:<tenant>' or 'source:<host>'", kind)
+		}
+
+		if value == "" {
+			return nil, fmt.Errorf("empty value of scope '%s'", kind)
+		}
+
+		policy.Scope = kind
+	}
+
+	action, age, found := strings.Cut(strings.TrimSpace(rule), ":")
+	if !found {
+		return nil, errors.New("expected '<action>:<age>'")
+	}
+
+	switch action {
+	case models.RetentionDelete, models.RetentionAnonymize:
+		policy.Action = action
+	default:
+		return nil, fmt.Errorf("unknown action '%s', expected 'delete' or 'anonymize'", action)
+	}
+
+	maxAge, err := parseAge(age)
+	if err != nil {
+		return nil, err
+	}
+
+	policy.MaxAge = maxAge
+
+	return policy, nil
+}
+
+//nolint:goerr113
+func parseAge(age string) (time.Duration, error) {
+	var (
+		maxAge time.Duration
+		err    error
+	)
+
+	if strings.HasSuffix(age, "d") {
+		var count int
+
+		count, err = strconv.Atoi(strings.TrimSuffix(age, "d"))
+		maxAge = time.Duration(count) * 24 * time.Hour //nolint:gomnd
+	} else {
+		maxAge, err = time.ParseDuration(age)
+	}
+
+	if err != nil || maxAge <= 0 {
+		return 0, fmt.Errorf("invalid age '%s', expected days like '90d' or duration like '12h'", age)
+	}
+
+	return maxAge, nil
+}
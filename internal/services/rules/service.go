@@ -0,0 +1,360 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/sqlite"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// Rules of the other instances are seen after cacheTTL at the latest,
+// the changes made by this instance are seen at once.
+const cacheTTL = time.Minute
+
+var (
+	ErrInvalidRule = errors.New("invalid rule")
+
+	// Literal like /refund|chargeback/i.
+	regexLiteral = regexp.MustCompile(`^/(.*)/([ims]*)$`)
+)
+
+type Repository interface {
+	Create(ctx context.Context, rule *models.Rule) (ruleID uuid.UUID, err error)
+	GetByID(ctx context.Context, ruleID uuid.UUID) (rule *models.Rule, err error)
+	GetAll(ctx context.Context) (rules []*models.Rule, err error)
+	Update(ctx context.Context, rule *models.Rule) error
+	Delete(ctx context.Context, ruleID uuid.UUID) error
+}
+
+// Check that actual implementation fits the interface.
+var (
+	_ Repository = (*gorm.RuleRepository)(nil)
+	_ Repository = (*memory.RuleRepository)(nil)
+	_ Repository = (*sqlite.RuleRepository)(nil)
+)
+
+type Service struct {
+	logger logger.Logger
+	repo   Repository
+
+	// Rules of Apply, nil means that they must be loaded.
+	mu     sync.Mutex
+	cached *Set
+}
+
+// Set is the snapshot of the rules with the compiled patterns,
+// the patterns of the changed and deleted rules go away with the old snapshot.
+type Set struct {
+	rules    []*models.Rule
+	patterns map[string]*regexp.Regexp
+	loadedAt time.Time
+}
+
+func New(ruleRepository Repository, logger logger.Logger) *Service {
+	return &Service{
+		logger: logger.Named("rules"),
+		repo:   ruleRepository,
+		mu:     sync.Mutex{},
+		cached: nil,
+	}
+}
+
+func (s *Service) Create(ctx context.Context, input *models.RuleInput) (*models.Rule, error) {
+	s.logger.Info("creating rule", logger.M{"rule": input})
+
+	//nolint:exhaustivestruct,exhaustruct
+	rule := &models.Rule{}
+
+	err := s.fill(rule, input)
+	if err != nil {
+		s.logger.Error("validating rule error", logger.M{"err": err})
+
+		return nil, err
+	}
+
+	_, err = s.repo.Create(ctx, rule)
+	if err != nil {
+		s.logger.Error("creating rule error", logger.M{"err": err})
+
+		return nil, fmt.Errorf("creating rule error: %w", err)
+	}
+
+	s.invalidate()
+
+	s.logger.Info("successfully created rule", logger.M{"ruleID": rule.ID})
+
+	return rule, nil
+}
+
+func (s *Service) GetByID(ctx context.Context, ruleID string) (*models.Rule, error) {
+	ruleUUID, err := uuid.Parse(ruleID)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse the ID: %w", err)
+	}
+
+	rule, err := s.repo.GetByID(ctx, ruleUUID)
+	if err != nil {
+		s.logger.Error("getting rule by ID", logger.M{"ruleID": ruleID, "error": err})
+
+		return nil, fmt.Errorf("getting rule by ID: %w", err)
+	}
+
+	return rule, nil
+}
+
+func (s *Service) GetAll(ctx context.Context) ([]*models.Rule, error) {
+	rules, err := s.repo.GetAll(ctx)
+	if err != nil {
+		s.logger.Error("getting all rules", logger.M{"error": err})
+
+		return nil, fmt.Errorf("error by getting rules from repository: %w", err)
+	}
+
+	return rules, nil
+}
+
+func (s *Service) Update(ctx context.Context, ruleID string, input *models.RuleInput) (*models.Rule, error) {
+	s.logger.Info("updating rule", logger.M{"ruleID": ruleID, "rule": input})
+
+	rule, err := s.GetByID(ctx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.fill(rule, input)
+	if err != nil {
+		s.logger.Error("validating rule error", logger.M{"err": err})
+
+		return nil, err
+	}
+
+	err = s.repo.Update(ctx, rule)
+	if err != nil {
+		s.logger.Error("updating rule error", logger.M{"err": err})
+
+		return nil, fmt.Errorf("updating rule error: %w", err)
+	}
+
+	s.invalidate()
+
+	s.logger.Info("successfully updated rule", logger.M{"ruleID": rule.ID})
+
+	return rule, nil
+}
+
+func (s *Service) Delete(ctx context.Context, ruleID string) error {
+	s.logger.Info("deleting rule", logger.M{"ruleID": ruleID})
+
+	ruleUUID, err := uuid.Parse(ruleID)
+	if err != nil {
+		return fmt.Errorf("can't parse the ID: %w", err)
+	}
+
+	err = s.repo.Delete(ctx, ruleUUID)
+	if err != nil {
+		s.logger.Error("deleting rule error", logger.M{"ruleID": ruleID, "err": err})
+
+		return fmt.Errorf("deleting rule error: %w", err)
+	}
+
+	s.invalidate()
+
+	return nil
+}
+
+// Apply evaluates enabled rules against the feedback,
+// overwrites routing fields of the feedback and returns fired rules.
+func (s *Service) Apply(ctx context.Context, feedback *models.Feedback) ([]*models.Rule, error) {
+	set, err := s.Rules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fired := set.Apply(feedback)
+
+	s.logger.Info("rules evaluated", logger.M{"fired": len(fired), "total": len(set.rules)})
+
+	return fired, nil
+}
+
+// Apply is Service.Apply by the loaded rules, e.g. for a batch of feedbacks.
+func (set *Set) Apply(feedback *models.Feedback) []*models.Rule {
+	var (
+		fired []*models.Rule
+		tags  []string
+		seen  = make(map[string]struct{})
+	)
+
+	feedback.Team = ""
+	feedback.Priority = ""
+
+	for _, rule := range set.rules {
+		if !rule.Enabled || !set.match(rule, feedback) {
+			continue
+		}
+
+		fired = append(fired, rule)
+
+		for _, tag := range rule.Tags {
+			if _, ok := seen[tag]; !ok {
+				seen[tag] = struct{}{}
+				tags = append(tags, tag)
+			}
+		}
+
+		if feedback.Team == "" {
+			feedback.Team = rule.Team
+		}
+
+		if feedback.Priority == "" {
+			feedback.Priority = rule.Priority
+		}
+	}
+
+	feedback.Tags = tags
+
+	return fired
+}
+
+// Rules returns the cached rules, they are loaded again after the changes or cacheTTL.
+// The lock is held while loading, so the rules are loaded once for the concurrent calls
+// and the snapshot loaded before a change can't overwrite the invalidation.
+func (s *Service) Rules(ctx context.Context) (*Set, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached != nil && time.Since(s.cached.loadedAt) < cacheTTL {
+		return s.cached, nil
+	}
+
+	rules, err := s.repo.GetAll(ctx)
+	if err != nil {
+		s.logger.Error("getting rules for evaluating", logger.M{"error": err})
+
+		return nil, fmt.Errorf("error by getting rules from repository: %w", err)
+	}
+
+	set := &Set{
+		rules:    rules,
+		patterns: make(map[string]*regexp.Regexp),
+		loadedAt: time.Now(),
+	}
+
+	for _, rule := range rules {
+		if rule.TextPattern == "" {
+			continue
+		}
+
+		pattern, err := compilePattern(rule.TextPattern)
+		if err != nil {
+			// Pattern is validated on saving, so it's unreachable for valid rules.
+			s.logger.Error("can't compile the pattern", logger.M{"ruleID": rule.ID, "err": err})
+
+			continue
+		}
+
+		set.patterns[rule.TextPattern] = pattern
+	}
+
+	s.cached = set
+
+	return set, nil
+}
+
+// invalidate drops the cached rules after the change.
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cached = nil
+}
+
+// match skips the rule with the invalid pattern, it isn't in the patterns.
+func (set *Set) match(rule *models.Rule, feedback *models.Feedback) bool {
+	if rule.SourceHost != "" && !matchHost(rule.SourceHost, feedback.SourceHost) {
+		return false
+	}
+
+	if rule.TextPattern != "" {
+		pattern, ok := set.patterns[rule.TextPattern]
+		if !ok || !pattern.MatchString(feedback.FeedbackText) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *Service) fill(rule *models.Rule, input *models.RuleInput) error {
+	if strings.TrimSpace(input.Name) == "" {
+		return fmt.Errorf("name is missing: %w", ErrInvalidRule)
+	}
+
+	if input.TextPattern == "" && input.SourceHost == "" {
+		return fmt.Errorf("'text_pattern' or 'source_host' is required: %w", ErrInvalidRule)
+	}
+
+	if len(input.Tags) == 0 && input.Team == "" && input.Priority == "" {
+		return fmt.Errorf("'tags', 'team' or 'priority' is required: %w", ErrInvalidRule)
+	}
+
+	switch input.Priority {
+	case "", models.PriorityLow, models.PriorityNormal, models.PriorityHigh, models.PriorityUrgent:
+	default:
+		return fmt.Errorf("wrong priority '%s': %w", input.Priority, ErrInvalidRule)
+	}
+
+	if input.TextPattern != "" {
+		if _, err := compilePattern(input.TextPattern); err != nil {
+			return fmt.Errorf("%s: %w", err.Error(), ErrInvalidRule)
+		}
+	}
+
+	rule.Name = input.Name
+	rule.TextPattern = input.TextPattern
+	rule.SourceHost = strings.ToLower(input.SourceHost)
+	rule.Tags = input.Tags
+	rule.Team = input.Team
+	rule.Priority = input.Priority
+	rule.Position = input.Position
+	rule.Enabled = input.Enabled == nil || *input.Enabled
+
+	return nil
+}
+
+// compilePattern accepts Go regexp or JS-like literal: /refund|chargeback/i.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if parts := regexLiteral.FindStringSubmatch(pattern); parts != nil {
+		pattern = parts[1]
+		if parts[2] != "" {
+			pattern = fmt.Sprintf("(?%s)%s", parts[2], pattern)
+		}
+	}
+
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid text pattern: %w", err)
+	}
+
+	return compiled, nil
+}
+
+// matchHost supports exact host or wildcard for subdomains: *.example.com.
+func matchHost(ruleHost, host string) bool {
+	if strings.HasPrefix(ruleHost, "*.") {
+		return strings.HasSuffix(host, ruleHost[1:])
+	}
+
+	return ruleHost == host
+}
@@ -0,0 +1,234 @@
+package sentiment
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	LabelPositive = "positive"
+	LabelNegative = "negative"
+	LabelNeutral  = "neutral"
+
+	// Scores inside (-threshold, threshold) are treated as neutral.
+	threshold = 0.05
+
+	// Normalization constant: score = sum / sqrt(sum^2 + alpha).
+	alpha = 15
+
+	// How many following words a negation word affects,
+	// the scope also ends at the clause punctuation.
+	negationScope = 3
+
+	// Negated words don't flip completely: "not bad" isn't "good".
+	negationFactor = -0.74
+
+	// clauseBreak is the token for the punctuation ending a clause.
+	clauseBreak = "."
+)
+
+//go:embed lexicon.txt
+var lexiconFile string
+
+//nolint:gochecknoglobals
+var (
+	negations = map[string]struct{}{
+		"not": {}, "no": {}, "never": {}, "none": {}, "nobody": {}, "nothing": {},
+		"neither": {}, "nor": {}, "without": {}, "hardly": {}, "barely": {},
+		"cannot": {}, "cant": {}, "dont": {}, "doesnt": {}, "didnt": {}, "isnt": {},
+		"wasnt": {}, "arent": {}, "werent": {}, "wont": {}, "wouldnt": {}, "shouldnt": {},
+	}
+
+	intensifiers = map[string]float64{
+		"very":       1.5,
+		"really":     1.4,
+		"extremely":  1.8,
+		"so":         1.3,
+		"too":        1.3,
+		"totally":    1.5,
+		"absolutely": 1.7,
+		"incredibly": 1.7,
+		"super":      1.5,
+		"completely": 1.6,
+		"highly":     1.5,
+		"quite":      1.2,
+		"slightly":   0.6,
+		"somewhat":   0.7,
+		"kinda":      0.7,
+		"little":     0.7,
+	}
+)
+
+// Result is a sentiment of a text.
+type Result struct {
+	Score float64
+	Label string
+}
+
+// Analyzer is a lexicon based sentiment analyzer
+// that handles negations ("not good") and intensifiers ("very bad").
+type Analyzer struct {
+	lexicon map[string]float64
+}
+
+// New returns analyzer with the embedded lexicon.
+func New() (*Analyzer, error) {
+	lexicon, err := parseLexicon(lexiconFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse the embedded lexicon: %w", err)
+	}
+
+	return &Analyzer{lexicon: lexicon}, nil
+}
+
+// Analyze returns a score in range [-1, 1] and a label for text.
+func (a *Analyzer) Analyze(text string) Result {
+	var (
+		sum         float64
+		multiplier  = 1.0
+		negatedLeft int
+	)
+
+	for _, word := range tokenize(text) {
+		if word == clauseBreak {
+			multiplier = 1.0
+			negatedLeft = 0
+
+			continue
+		}
+
+		if _, ok := negations[word]; ok {
+			negatedLeft = negationScope
+
+			continue
+		}
+
+		if boost, ok := intensifiers[word]; ok {
+			multiplier *= boost
+
+			if negatedLeft > 0 {
+				negatedLeft--
+			}
+
+			continue
+		}
+
+		valence, ok := a.lexicon[word]
+		if ok {
+			valence *= multiplier
+			if negatedLeft > 0 {
+				valence *= negationFactor
+			}
+
+			sum += valence
+		}
+
+		multiplier = 1.0
+
+		if negatedLeft > 0 {
+			negatedLeft--
+		}
+	}
+
+	score := sum / math.Sqrt(sum*sum+alpha)
+
+	return Result{
+		Score: math.Round(score*1000) / 1000, //nolint:gomnd
+		Label: Label(score),
+	}
+}
+
+// Label returns the label for the score.
+func Label(score float64) string {
+	switch {
+	case score >= threshold:
+		return LabelPositive
+	case score <= -threshold:
+		return LabelNegative
+	default:
+		return LabelNeutral
+	}
+}
+
+// IsValidLabel checks if the label is one of known labels.
+func IsValidLabel(label string) bool {
+	switch label {
+	case LabelPositive, LabelNegative, LabelNeutral:
+		return true
+	default:
+		return false
+	}
+}
+
+// tokenize splits text into lowercase words,
+// apostrophes are dropped so "don't" becomes "dont".
+// The clause punctuation is returned as clauseBreak tokens.
+func tokenize(text string) []string {
+	text = strings.ToLower(strings.NewReplacer("'", "", "’", "").Replace(text))
+
+	var (
+		tokens []string
+		word   strings.Builder
+	)
+
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		case strings.ContainsRune(".,;:!?()\n…", r):
+			flush()
+
+			if len(tokens) > 0 && tokens[len(tokens)-1] != clauseBreak {
+				tokens = append(tokens, clauseBreak)
+			}
+		default:
+			flush()
+		}
+	}
+
+	flush()
+
+	return tokens
+}
+
+func parseLexicon(data string) (map[string]float64, error) {
+	lexicon := make(map[string]float64)
+	scanner := bufio.NewScanner(strings.NewReader(data))
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		word, valueStr, found := strings.Cut(text, "\t")
+		if !found {
+			return nil, fmt.Errorf("line %d: missing tab separator", line) //nolint:goerr113
+		}
+
+		value, err := strconv.ParseFloat(valueStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		lexicon[word] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanning lexicon: %w", err)
+	}
+
+	return lexicon, nil
+}
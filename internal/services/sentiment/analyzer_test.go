@@ -0,0 +1,92 @@
+package sentiment_test
+
+import (
+	"testing"
+
+	"github.com/andrsj/feedback-service/internal/services/sentiment"
+)
+
+func TestAnalyze(t *testing.T) {
+	t.Parallel()
+
+	analyzer, err := sentiment.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	for _, test := range []struct {
+		text  string
+		label string
+	}{
+		{"", sentiment.LabelNeutral},
+		{"The app is good", sentiment.LabelPositive},
+		{"Awful app", sentiment.LabelNegative},
+		{"The app is not good", sentiment.LabelNegative},
+		{"It isn't bad", sentiment.LabelPositive},
+		{"It's not very good", sentiment.LabelNegative},
+		// The negation doesn't cross the clause punctuation.
+		{"Never again. Awful app", sentiment.LabelNegative},
+		{"No complaints, terrible support", sentiment.LabelNegative},
+		{"The app is not good, very slow", sentiment.LabelNegative},
+		{"Not bad! Great support", sentiment.LabelPositive},
+		// The intensifiers count against the negation scope.
+		{"not very really extremely bad", sentiment.LabelNegative},
+		{"The parcel arrived on Tuesday", sentiment.LabelNeutral},
+	} {
+		result := analyzer.Analyze(test.text)
+		if result.Label != test.label {
+			t.Errorf("%q: got %s (%.3f), want %s", test.text, result.Label, result.Score, test.label)
+		}
+
+		if result.Score < -1 || result.Score > 1 {
+			t.Errorf("%q: score %.3f is out of range", test.text, result.Score)
+		}
+	}
+}
+
+func TestAnalyzeIntensifiers(t *testing.T) {
+	t.Parallel()
+
+	analyzer, err := sentiment.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	for _, test := range []struct{ weaker, stronger string }{
+		{"good", "very good"},
+		{"slightly good", "good"},
+		{"bad", "extremely bad"},
+	} {
+		weaker := analyzer.Analyze(test.weaker).Score
+		stronger := analyzer.Analyze(test.stronger).Score
+
+		if abs(stronger) <= abs(weaker) {
+			t.Errorf("%q (%.3f) isn't stronger than %q (%.3f)", test.stronger, stronger, test.weaker, weaker)
+		}
+	}
+}
+
+func TestLabel(t *testing.T) {
+	t.Parallel()
+
+	for score, label := range map[float64]string{
+		-1: sentiment.LabelNegative, -0.05: sentiment.LabelNegative, 0: sentiment.LabelNeutral,
+		0.049: sentiment.LabelNeutral, 0.05: sentiment.LabelPositive, 1: sentiment.LabelPositive,
+	} {
+		if got := sentiment.Label(score); got != label {
+			t.Errorf("Label(%v): got %s, want %s", score, got, label)
+		}
+	}
+
+	if sentiment.IsValidLabel("happy") || !sentiment.IsValidLabel(sentiment.LabelNeutral) {
+		t.Error("IsValidLabel")
+	}
+}
+
+func abs(value float64) float64 {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
@@ -0,0 +1,270 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/cache"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/sqlite"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+var (
+	ErrInvalidQuery = errors.New("invalid stats query")
+	// ErrNoProjections is returned by Latest for the repository without the projections (memory).
+	ErrNoProjections = errors.New("projections aren't kept by the repository")
+)
+
+type Repository interface {
+	GetStats(ctx context.Context, groupBy []string, filter *models.FeedbackFilter) ([]*models.FeedbackStat, error)
+	GetSentimentStats(ctx context.Context) ([]*models.SentimentStat, error)
+}
+
+// Projections are the read models built by the 'worker' command from the feedback events,
+// they are updated with the delay of the consumer.
+type Projections interface {
+	Repository
+	GetLatest(ctx context.Context, sourceHost string, limit int) ([]*models.LatestFeedback, error)
+}
+
+// Check that actual implementation fits the interface.
+var (
+	_ Repository  = (*gorm.FeedbackRepository)(nil)
+	_ Repository  = (*memory.FeedbackRepository)(nil)
+	_ Repository  = (*sqlite.FeedbackRepository)(nil)
+	_ Projections = (*gorm.ProjectionRepository)(nil)
+	_ Projections = (*sqlite.ProjectionRepository)(nil)
+)
+
+// cached is the value in the cache, the cache keeps the responses longer,
+// so the expiration is checked by the service.
+type cached struct {
+	ExpiresAt int64                  `json:"expires_at"` //nolint:tagliatelle
+	Stats     []*models.FeedbackStat `json:"stats"`
+}
+
+// Service counts feedbacks for dashboards, the results are cached for the short TTL.
+type Service struct {
+	logger      logger.Logger
+	repo        Repository
+	projections Projections
+	// The stats are read from the projections instead of scanning the feedbacks.
+	fromProjections bool
+	cache           cache.Cache
+	ttl             time.Duration
+}
+
+// New gets the TTL of the cached results, zero TTL means no cache.
+// The projections are nil for the memory repository, the stats are read from them only by fromProjections,
+// they are empty until the worker consumes the topic.
+func New(
+	repo Repository,
+	projections Projections,
+	fromProjections bool,
+	responses cache.Cache,
+	ttl time.Duration,
+	logger logger.Logger,
+) *Service {
+	return &Service{
+		logger:          logger.Named("stats"),
+		repo:            repo,
+		projections:     projections,
+		fromProjections: fromProjections && projections != nil,
+		cache:           responses,
+		ttl:             ttl,
+	}
+}
+
+// Get returns the counts by the groups of the dimensions, see models.FeedbackStat.
+// Without dimensions it's one group with all feedbacks matched by the filter.
+func (s *Service) Get(
+	ctx context.Context,
+	groupBy []string,
+	filter *models.FeedbackFilter,
+) ([]*models.FeedbackStat, error) {
+	err := validate(groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	key := cacheKey(groupBy, filter)
+
+	if stats, ok := s.fromCache(ctx, key); ok {
+		return stats, nil
+	}
+
+	s.logger.Info("getting stats", logger.M{"groupBy": groupBy, "filter": filter})
+
+	var repo Repository = s.repo
+	if s.fromProjections && projected(groupBy, filter) {
+		repo = s.projections
+	}
+
+	stats, err := repo.GetStats(ctx, groupBy, filter)
+	if err != nil {
+		s.logger.Error("getting stats", logger.M{"error": err})
+
+		return nil, fmt.Errorf("error by getting stats from repository: %w", err)
+	}
+
+	s.toCache(ctx, key, stats)
+
+	return stats, nil
+}
+
+// Sentiment returns the average sentiment by the day and the source host.
+func (s *Service) Sentiment(ctx context.Context) ([]*models.SentimentStat, error) {
+	s.logger.Info("getting daily sentiment stats", nil)
+
+	var repo Repository = s.repo
+	if s.fromProjections {
+		repo = s.projections
+	}
+
+	stats, err := repo.GetSentimentStats(ctx)
+	if err != nil {
+		s.logger.Error("getting sentiment stats", logger.M{"error": err})
+
+		return nil, fmt.Errorf("error by getting sentiment stats from repository: %w", err)
+	}
+
+	return stats, nil
+}
+
+// Latest returns the latest feedbacks of the source host from the projection, the newest first.
+func (s *Service) Latest(ctx context.Context, sourceHost string, limit int) ([]*models.LatestFeedback, error) {
+	if s.projections == nil {
+		return nil, ErrNoProjections
+	}
+
+	if sourceHost == "" {
+		return nil, fmt.Errorf("%w: source is required", ErrInvalidQuery)
+	}
+
+	latest, err := s.projections.GetLatest(ctx, strings.ToLower(sourceHost), limit)
+	if err != nil {
+		s.logger.Error("getting latest feedbacks", logger.M{"error": err})
+
+		return nil, fmt.Errorf("error by getting latest feedbacks from repository: %w", err)
+	}
+
+	return latest, nil
+}
+
+// projected tells if the projections have the dimensions and the filter of the query:
+// they don't count the tags and don't keep the emails.
+func projected(groupBy []string, filter *models.FeedbackFilter) bool {
+	for _, dimension := range groupBy {
+		if dimension == models.StatsByTag {
+			return false
+		}
+	}
+
+	return filter == nil || filter.Email == ""
+}
+
+// fromCache returns the fresh result, the cache failures are only logged,
+// the stats are read from the repository then.
+func (s *Service) fromCache(ctx context.Context, key string) ([]*models.FeedbackStat, bool) {
+	if s.ttl <= 0 {
+		return nil, false
+	}
+
+	generationKey, err := cache.Key(ctx, s.cache, key)
+	if err != nil {
+		s.logger.Error("getting stats from cache", logger.M{"error": err})
+
+		return nil, false
+	}
+
+	value, found, err := s.cache.Get(ctx, generationKey)
+	if err != nil || !found {
+		if err != nil {
+			s.logger.Error("getting stats from cache", logger.M{"error": err})
+		}
+
+		return nil, false
+	}
+
+	var result cached
+
+	err = json.Unmarshal(value, &result)
+	if err != nil || time.Now().Unix() >= result.ExpiresAt {
+		return nil, false
+	}
+
+	return result.Stats, true
+}
+
+func (s *Service) toCache(ctx context.Context, key string, stats []*models.FeedbackStat) {
+	if s.ttl <= 0 {
+		return
+	}
+
+	value, err := json.Marshal(&cached{ExpiresAt: time.Now().Add(s.ttl).Unix(), Stats: stats})
+	if err != nil {
+		s.logger.Error("encoding stats for cache", logger.M{"error": err})
+
+		return
+	}
+
+	generationKey, err := cache.Key(ctx, s.cache, key)
+	if err == nil {
+		err = s.cache.Set(ctx, generationKey, value)
+	}
+
+	if err != nil {
+		s.logger.Error("setting stats to cache", logger.M{"error": err})
+	}
+}
+
+func validate(groupBy []string) error {
+	seen := make(map[string]struct{}, len(groupBy))
+	periods := 0
+
+	for _, dimension := range groupBy {
+		switch dimension {
+		case models.StatsBySource, models.StatsByTag:
+		case models.StatsByDay, models.StatsByWeek, models.StatsByMonth:
+			periods++
+		default:
+			return fmt.Errorf(
+				"%w: unknown dimension '%s', available: source, day, week, month, tag", ErrInvalidQuery, dimension,
+			)
+		}
+
+		if _, ok := seen[dimension]; ok {
+			return fmt.Errorf("%w: duplicated dimension '%s'", ErrInvalidQuery, dimension)
+		}
+
+		seen[dimension] = struct{}{}
+	}
+
+	if periods > 1 {
+		return fmt.Errorf("%w: only one of day, week and month", ErrInvalidQuery)
+	}
+
+	return nil
+}
+
+// cacheKey doesn't depend on the order of the query params.
+func cacheKey(groupBy []string, filter *models.FeedbackFilter) string {
+	query := url.Values{}
+	query.Set("group_by", strings.Join(groupBy, ","))
+
+	if filter != nil {
+		query.Set("sentiment", filter.Sentiment)
+		query.Set("search", filter.Search)
+		query.Set("email", strings.ToLower(filter.Email))
+	}
+
+	return "stats?" + query.Encode()
+}
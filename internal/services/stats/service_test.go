@@ -0,0 +1,166 @@
+package stats_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/cache"
+	cacheMemory "github.com/andrsj/feedback-service/internal/infrastructure/cache/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/internal/services/stats"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) logger.Logger { return l }
+func (nopLogger) Debug(string, logger.M)       {}
+func (nopLogger) Info(string, logger.M)        {}
+func (nopLogger) Warn(string, logger.M)        {}
+func (nopLogger) Error(string, logger.M)       {}
+func (nopLogger) Fatal(string, logger.M)       {}
+
+// projections count the calls, the stats of the projections are always one group of 100.
+type projections struct {
+	calls int
+}
+
+func (p *projections) GetStats(context.Context, []string, *models.FeedbackFilter) ([]*models.FeedbackStat, error) {
+	p.calls++
+
+	//nolint:exhaustivestruct,exhaustruct
+	return []*models.FeedbackStat{{Group: map[string]string{}, Count: 100}}, nil
+}
+
+func (p *projections) GetSentimentStats(context.Context) ([]*models.SentimentStat, error) {
+	return nil, nil
+}
+
+func (p *projections) GetLatest(context.Context, string, int) ([]*models.LatestFeedback, error) {
+	return nil, nil
+}
+
+func newRepository() *memory.FeedbackRepository {
+	return memory.New(memory.NewAuditRepository(nil, nopLogger{}), ids.NewV7(), nopLogger{})
+}
+
+func create(t *testing.T, repo *memory.FeedbackRepository) {
+	t.Helper()
+
+	//nolint:exhaustivestruct,exhaustruct
+	_, err := repo.Create(context.Background(), &models.Feedback{
+		CustomerName: "Jane",
+		Email:        "jane@example.com",
+		FeedbackText: "ok",
+		Source:       "https://shop.example.com",
+		SourceHost:   "shop.example.com",
+		Tags:         []string{"shop"},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+}
+
+func count(t *testing.T, service *stats.Service, groupBy ...string) int64 {
+	t.Helper()
+
+	result, err := service.Get(context.Background(), groupBy, nil)
+	if err != nil || len(result) != 1 {
+		t.Fatalf("Get: got %v, %v", result, err)
+	}
+
+	return result[0].Count
+}
+
+func TestGetIsCached(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo := newRepository()
+	responses := cacheMemory.New(nopLogger{})
+	service := stats.New(repo, nil, false, responses, time.Minute, nopLogger{})
+
+	create(t, repo)
+
+	if got := count(t, service); got != 1 {
+		t.Fatalf("first Get: got %d, want 1", got)
+	}
+
+	// The new feedback is counted after the TTL or the invalidation.
+	create(t, repo)
+
+	if got := count(t, service); got != 1 {
+		t.Errorf("cached Get: got %d, want 1", got)
+	}
+
+	// The other query isn't the cached one.
+	if got := count(t, service, models.StatsBySource); got != 2 {
+		t.Errorf("Get by source: got %d, want 2", got)
+	}
+
+	if err := cache.Invalidate(ctx, responses); err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+
+	if got := count(t, service); got != 2 {
+		t.Errorf("Get after the invalidation: got %d, want 2", got)
+	}
+
+	// Zero TTL is no cache.
+	uncached := stats.New(repo, nil, false, responses, 0, nopLogger{})
+	create(t, repo)
+
+	if got := count(t, uncached); got != 3 {
+		t.Errorf("Get without cache: got %d, want 3", got)
+	}
+}
+
+func TestGetFromProjections(t *testing.T) {
+	t.Parallel()
+
+	repo := newRepository()
+	projected := &projections{calls: 0}
+	service := stats.New(repo, projected, true, cacheMemory.New(nopLogger{}), 0, nopLogger{})
+
+	create(t, repo)
+
+	if got := count(t, service, models.StatsBySource); got != 100 || projected.calls != 1 {
+		t.Errorf("Get by source: got %d with %d calls of the projections", got, projected.calls)
+	}
+
+	// The projections don't count the tags and don't keep the emails.
+	if got := count(t, service, models.StatsByTag); got != 1 {
+		t.Errorf("Get by tag: got %d, want 1 from the feedbacks", got)
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	result, err := service.Get(context.Background(), nil, &models.FeedbackFilter{Email: "jane@example.com"})
+	if err != nil || len(result) != 1 || result[0].Count != 1 || projected.calls != 1 {
+		t.Errorf("Get by email: got %v, %v with %d calls of the projections", result, err, projected.calls)
+	}
+}
+
+func TestGetValidatesDimensions(t *testing.T) {
+	t.Parallel()
+
+	service := stats.New(newRepository(), nil, false, cacheMemory.New(nopLogger{}), 0, nopLogger{})
+
+	for _, groupBy := range [][]string{
+		{"status"},
+		{models.StatsBySource, models.StatsBySource},
+		{models.StatsByDay, models.StatsByMonth},
+	} {
+		if _, err := service.Get(context.Background(), groupBy, nil); !errors.Is(err, stats.ErrInvalidQuery) {
+			t.Errorf("%v: got %v, want %v", groupBy, err, stats.ErrInvalidQuery)
+		}
+	}
+
+	// The memory repository has no projections.
+	if _, err := service.Latest(context.Background(), "shop.example.com", 10); !errors.Is(err, stats.ErrNoProjections) {
+		t.Errorf("Latest: got %v, want %v", err, stats.ErrNoProjections)
+	}
+}
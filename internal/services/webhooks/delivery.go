@@ -0,0 +1,530 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// Headers of the deliveries.
+const (
+	HeaderSignature  = "X-Signature"
+	HeaderTimestamp  = "X-Timestamp"
+	HeaderEventType  = "X-Event-Type"
+	HeaderDeliveryID = "X-Delivery-ID"
+	HeaderWebhookID  = "X-Webhook-ID"
+)
+
+const (
+	// Due deliveries per poll and the concurrent requests.
+	pollInterval = time.Second
+	pollBatch    = 100
+	workers      = 4
+	// The completed deliveries are removed once per interval.
+	cleanupInterval = time.Hour
+	// Bytes of the response kept in the error.
+	maxResponseError = 256
+)
+
+// Metrics of the deliveries.
+//
+//nolint:gochecknoglobals,exhaustivestruct,exhaustruct
+var (
+	deliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_deliveries_total",
+		Help: "Webhook delivery attempts by result: delivered, retried, failed, skipped.",
+	}, []string{"result"})
+	disabledTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "webhook_disabled_total",
+		Help: "Webhooks disabled after the failures in a row.",
+	})
+)
+
+// Payload is the body of the delivery.
+type Payload struct {
+	ID        uuid.UUID   `json:"id"`
+	Type      string      `json:"type"`
+	Time      time.Time   `json:"time"`
+	WebhookID uuid.UUID   `json:"webhook_id"` //nolint:tagliatelle
+	Tenant    string      `json:"tenant,omitempty"`
+	Data      interface{} `json:"data"`
+}
+
+// Sign returns the value of X-Signature: HMAC-SHA256 of "<X-Timestamp>.<body>" by the secret of the webhook.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Enqueue adds the deliveries of the events to the matching enabled webhooks.
+// The deleted feedback isn't known anymore, its event goes to every webhook of the type and the tenant.
+func (s *Service) Enqueue(ctx context.Context, events []*models.FeedbackEvent) error {
+	webhooks, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("getting webhooks: %w", err)
+	}
+
+	var deliveries []*models.WebhookDelivery
+
+	for _, webhook := range webhooks {
+		if !webhook.Enabled {
+			continue
+		}
+
+		for _, event := range events {
+			tenant := event.Tenant
+			if tenant == "" {
+				tenant = reqctx.Tenant(ctx)
+			}
+
+			if subscribed(webhook, event.Type) && match(webhook, event.Feedback, tenant) {
+				deliveries = append(deliveries, newDelivery(webhook.ID, event.Type, event.FeedbackID, tenant))
+			}
+		}
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	err = s.repo.AddDeliveries(ctx, deliveries)
+	if err != nil {
+		return fmt.Errorf("adding webhook deliveries: %w", err)
+	}
+
+	s.logger.Info("webhook deliveries are enqueued", logger.M{"count": len(deliveries)})
+
+	return nil
+}
+
+// Run delivers the due deliveries until the context is cancelled. Every instance of the server runs it,
+// the delivery is claimed before the attempt, so it's sent by one instance.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Time{}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Polls until the due deliveries are over.
+		for s.poll(ctx) == pollBatch {
+		}
+
+		if time.Since(lastCleanup) >= cleanupInterval && s.config.LogTTL > 0 {
+			lastCleanup = time.Now()
+
+			deleted, err := s.repo.DeleteDeliveriesBefore(ctx, time.Now().Add(-s.config.LogTTL))
+			if err != nil {
+				s.logger.Error("cleaning webhook deliveries", logger.M{"err": err})
+			} else if deleted > 0 {
+				s.logger.Info("old webhook deliveries are removed", logger.M{"count": deleted})
+			}
+		}
+	}
+}
+
+// poll sends the due deliveries by the workers and returns the number of them.
+func (s *Service) poll(ctx context.Context) int {
+	moment := time.Now().UTC()
+
+	deliveries, err := s.repo.Due(ctx, moment, pollBatch)
+	if err != nil {
+		s.logger.Error("getting due webhook deliveries", logger.M{"err": err})
+
+		return 0
+	}
+
+	var (
+		wg    sync.WaitGroup
+		slots = make(chan struct{}, workers)
+	)
+
+	for _, delivery := range deliveries {
+		// The lease is longer than the attempt, the crashed instance leaves the delivery to others after it.
+		claimed, err := s.repo.Claim(ctx, delivery.ID, moment, moment.Add(2*s.config.Timeout)) //nolint:gomnd
+		if err != nil {
+			s.logger.Error("claiming webhook delivery", logger.M{"err": err, "deliveryID": delivery.ID})
+
+			continue
+		}
+
+		if !claimed {
+			continue
+		}
+
+		slots <- struct{}{}
+
+		wg.Add(1)
+
+		go func(delivery *models.WebhookDelivery) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			s.attempt(ctx, delivery)
+		}(delivery)
+	}
+
+	wg.Wait()
+
+	return len(deliveries)
+}
+
+// attempt sends the delivery and saves the result, the failed one is retried by the backoff.
+func (s *Service) attempt(ctx context.Context, delivery *models.WebhookDelivery) {
+	webhook, err := s.repo.GetByID(ctx, delivery.WebhookID)
+	if err != nil {
+		// The delivery is removed with the webhook.
+		s.logger.Warn("webhook of the delivery is gone", logger.M{"deliveryID": delivery.ID, "err": err})
+
+		return
+	}
+
+	if !webhook.Enabled {
+		s.complete(ctx, delivery, models.DeliveryFailed, "webhook is disabled")
+		deliveriesTotal.WithLabelValues("failed").Inc()
+
+		return
+	}
+
+	data, err := s.data(ctx, webhook, delivery)
+	if errors.Is(err, models.ErrFeedbackNotFound) {
+		s.complete(ctx, delivery, models.DeliverySkipped, "feedback is deleted")
+		deliveriesTotal.WithLabelValues("skipped").Inc()
+
+		return
+	}
+
+	if err == nil {
+		err = s.send(ctx, webhook, delivery, data)
+	}
+
+	failed := err != nil
+
+	switch {
+	case !failed:
+		s.complete(ctx, delivery, models.DeliveryDelivered, "")
+		deliveriesTotal.WithLabelValues("delivered").Inc()
+	case delivery.Attempts >= s.config.MaxAttempts:
+		s.complete(ctx, delivery, models.DeliveryFailed, err.Error())
+		deliveriesTotal.WithLabelValues("failed").Inc()
+	default:
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = time.Now().UTC().Add(s.backoff(delivery.Attempts))
+		s.save(ctx, delivery)
+		deliveriesTotal.WithLabelValues("retried").Inc()
+	}
+
+	disabled, err := s.repo.Counted(ctx, webhook.ID, failed, s.config.DisableAfter)
+	if err != nil {
+		s.logger.Error("counting webhook failures", logger.M{"err": err, "webhookID": webhook.ID})
+
+		return
+	}
+
+	if disabled {
+		disabledTotal.Inc()
+		s.logger.Warn("webhook is disabled after the failures in a row", logger.M{
+			"webhookID": webhook.ID,
+			"failures":  s.config.DisableAfter,
+		})
+	}
+}
+
+// Test sends the test event to the webhook once, even to the disabled one,
+// the delivery is saved to the log, but it doesn't change the failures of the webhook.
+func (s *Service) Test(ctx context.Context, webhookID string) (*models.WebhookDelivery, error) {
+	webhook, err := s.GetByID(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The delivery is added as claimed, so the poller doesn't send it meanwhile.
+	delivery := newDelivery(webhook.ID, models.WebhookTest, uuid.Nil, reqctx.Tenant(ctx))
+	delivery.NextAttemptAt = delivery.NextAttemptAt.Add(2 * s.config.Timeout) //nolint:gomnd
+
+	err = s.repo.AddDeliveries(ctx, []*models.WebhookDelivery{delivery})
+	if err != nil {
+		return nil, fmt.Errorf("adding test delivery: %w", err)
+	}
+
+	data, _ := s.data(ctx, webhook, delivery)
+
+	err = s.send(ctx, webhook, delivery, data)
+	if err != nil {
+		s.complete(ctx, delivery, models.DeliveryFailed, err.Error())
+	} else {
+		s.complete(ctx, delivery, models.DeliveryDelivered, "")
+	}
+
+	s.logger.Info("test event is sent", logger.M{"webhookID": webhook.ID, "status": delivery.Status})
+
+	return delivery, nil
+}
+
+// data is the current state of the feedback, the deleted event has only the ID.
+// The name and the email of the customer are sent only to the webhook with IncludePII.
+func (s *Service) data(
+	ctx context.Context,
+	webhook *models.Webhook,
+	delivery *models.WebhookDelivery,
+) (interface{}, error) {
+	switch delivery.EventType {
+	case models.WebhookTest:
+		return map[string]string{"message": "test event of the feedback service"}, nil
+	case models.FeedbackDeleted:
+		return map[string]interface{}{"id": delivery.FeedbackID, "deleted": true}, nil
+	}
+
+	feedback, err := s.feedbacks.GetByID(reqctx.WithTenant(ctx, delivery.Tenant), delivery.FeedbackID)
+	if err != nil {
+		return nil, fmt.Errorf("getting feedback: %w", err)
+	}
+
+	if !webhook.IncludePII {
+		redacted := *feedback
+		redacted.CustomerName, redacted.Email = "", ""
+
+		return &redacted, nil
+	}
+
+	return feedback, nil
+}
+
+// send makes one attempt, the response out of 2xx is the failure.
+func (s *Service) send(
+	ctx context.Context,
+	webhook *models.Webhook,
+	delivery *models.WebhookDelivery,
+	data interface{},
+) error {
+	delivery.Attempts++
+
+	body, err := json.Marshal(&Payload{
+		ID:        delivery.ID,
+		Type:      delivery.EventType,
+		Time:      delivery.CreatedAt,
+		WebhookID: webhook.ID,
+		Tenant:    delivery.Tenant,
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling payload: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("building request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "feedback-service-webhooks")
+	request.Header.Set(HeaderTimestamp, timestamp)
+	request.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, body))
+	request.Header.Set(HeaderEventType, delivery.EventType)
+	request.Header.Set(HeaderDeliveryID, delivery.ID.String())
+	request.Header.Set(HeaderWebhookID, webhook.ID.String())
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		delivery.ResponseStatus = 0
+
+		// The URL is in the log already.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+
+		return fmt.Errorf("sending: %w", err)
+	}
+	defer response.Body.Close()
+
+	delivery.ResponseStatus = response.StatusCode
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		text, _ := io.ReadAll(io.LimitReader(response.Body, maxResponseError))
+
+		//nolint:goerr113
+		return fmt.Errorf("response %d: %s", response.StatusCode, strings.TrimSpace(string(text)))
+	}
+
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	return nil
+}
+
+// newTransport connects only to the public addresses unless the private ones are allowed.
+// The address is checked after the resolution, so the name can't be rebound to the internal host,
+// and the proxy of the environment isn't used, it would be checked instead of the webhook.
+func newTransport(allowPrivate bool) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert
+
+	if allowPrivate {
+		return transport
+	}
+
+	//nolint:exhaustivestruct,exhaustruct,gomnd
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, address)
+			}
+
+			if ip := net.ParseIP(host); ip == nil || !public(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+
+			return nil
+		},
+	}
+
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return transport
+}
+
+// public reports whether the address isn't private, loopback, link-local, multicast or unspecified.
+func public(ip net.IP) bool {
+	return !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// backoff is the exponential delay after the attempt with the jitter of its half.
+func (s *Service) backoff(attempts int) time.Duration {
+	delay := s.config.Backoff
+
+	for i := 1; i < attempts && delay < s.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > s.config.MaxBackoff {
+		delay = s.config.MaxBackoff
+	}
+
+	half := delay / 2 //nolint:gomnd
+
+	return half + time.Duration(rand.Int63n(int64(half)+1)) //nolint:gosec
+}
+
+func (s *Service) complete(ctx context.Context, delivery *models.WebhookDelivery, status, reason string) {
+	completedAt := time.Now().UTC()
+
+	delivery.Status = status
+	delivery.Error = reason
+	delivery.CompletedAt = &completedAt
+
+	s.save(ctx, delivery)
+}
+
+func (s *Service) save(ctx context.Context, delivery *models.WebhookDelivery) {
+	err := s.repo.SaveDelivery(ctx, delivery)
+	if err != nil {
+		s.logger.Error("saving webhook delivery", logger.M{"err": err, "deliveryID": delivery.ID})
+	}
+}
+
+func newDelivery(webhookID uuid.UUID, eventType string, feedbackID uuid.UUID, tenant string) *models.WebhookDelivery {
+	//nolint:exhaustivestruct,exhaustruct
+	return &models.WebhookDelivery{
+		WebhookID:     webhookID,
+		EventType:     eventType,
+		FeedbackID:    feedbackID,
+		Tenant:        tenant,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: time.Now().UTC(),
+	}
+}
+
+func subscribed(webhook *models.Webhook, eventType string) bool {
+	if len(webhook.EventTypes) == 0 {
+		return true
+	}
+
+	for _, subscribed := range webhook.EventTypes {
+		if subscribed == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// match checks the filters of the webhook, only the tenant is checked without the feedback.
+func match(webhook *models.Webhook, feedback *models.Feedback, tenant string) bool {
+	switch {
+	case webhook.Tenant != "" && webhook.Tenant != tenant:
+		return false
+	case feedback == nil:
+		return true
+	case webhook.SourceHost != "" && webhook.SourceHost != sourceHost(feedback),
+		webhook.Sentiment != "" && webhook.Sentiment != feedback.SentimentLabel,
+		webhook.Team != "" && webhook.Team != feedback.Team:
+		return false
+	}
+
+	if webhook.Tag == "" {
+		return true
+	}
+
+	for _, tag := range feedback.Tags {
+		if tag == webhook.Tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sourceHost is set by the service, the imported feedback could be without it.
+func sourceHost(feedback *models.Feedback) string {
+	if feedback.SourceHost != "" {
+		return feedback.SourceHost
+	}
+
+	sourceURL, err := url.Parse(feedback.Source)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(sourceURL.Hostname())
+}
@@ -0,0 +1,64 @@
+package webhooks
+
+import (
+	"context"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/domain/reqctx"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+// Publisher is the producer of the broker decorated by Producer.
+type Publisher interface {
+	Publish(ctx context.Context, event *models.FeedbackEvent) error
+	PublishBatch(ctx context.Context, events []*models.FeedbackEvent) error
+	Close() error
+}
+
+// Producer enqueues the webhook deliveries of the events after they are sent to the broker.
+// The change is saved already, so the deliveries are enqueued for the failed send too,
+// and the error of the enqueue is only logged, the webhooks don't fail the writes.
+type Producer struct {
+	inner   Publisher
+	service *Service
+}
+
+func NewProducer(inner Publisher, service *Service) *Producer {
+	return &Producer{
+		inner:   inner,
+		service: service,
+	}
+}
+
+func (p *Producer) Publish(ctx context.Context, event *models.FeedbackEvent) error {
+	err := p.inner.Publish(ctx, event)
+	p.enqueue(ctx, []*models.FeedbackEvent{event})
+
+	return err //nolint:wrapcheck
+}
+
+func (p *Producer) PublishBatch(ctx context.Context, events []*models.FeedbackEvent) error {
+	err := p.inner.PublishBatch(ctx, events)
+	p.enqueue(ctx, events)
+
+	return err //nolint:wrapcheck
+}
+
+// Rejects passes the backpressure of the producer with the bounded queue.
+func (p *Producer) Rejects() bool {
+	producer, ok := p.inner.(interface{ Rejects() bool })
+
+	return ok && producer.Rejects()
+}
+
+func (p *Producer) Close() error {
+	return p.inner.Close() //nolint:wrapcheck
+}
+
+// enqueue outlives the request like the dead-letter queue.
+func (p *Producer) enqueue(ctx context.Context, events []*models.FeedbackEvent) {
+	err := p.service.Enqueue(reqctx.Detach(ctx), events)
+	if err != nil {
+		p.service.logger.Error("webhook deliveries are lost", logger.M{"count": len(events), "err": err})
+	}
+}
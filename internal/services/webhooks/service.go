@@ -0,0 +1,323 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/gorm"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/sqlite"
+	"github.com/andrsj/feedback-service/internal/services/dlq"
+	"github.com/andrsj/feedback-service/internal/services/sentiment"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+var (
+	ErrInvalidWebhook = errors.New("invalid webhook")
+	// ErrPrivateAddress is returned for the webhook on the private, loopback or link-local address.
+	ErrPrivateAddress = errors.New("address of the webhook isn't public")
+)
+
+// Bytes of the generated secret.
+const secretSize = 32
+
+type Repository interface {
+	Create(ctx context.Context, webhook *models.Webhook) (uuid.UUID, error)
+	GetByID(ctx context.Context, webhookID uuid.UUID) (*models.Webhook, error)
+	GetAll(ctx context.Context) ([]*models.Webhook, error)
+	Update(ctx context.Context, webhook *models.Webhook) error
+	Delete(ctx context.Context, webhookID uuid.UUID) error
+	// Counted resets the failures in a row by the success or adds the failure,
+	// the webhook is disabled after disableAfter of them, true means it's disabled now.
+	Counted(ctx context.Context, webhookID uuid.UUID, failed bool, disableAfter int) (bool, error)
+
+	// AddDeliveries sets the IDs and the creation time.
+	AddDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error
+	// Due returns the pending deliveries with the attempt before the moment.
+	Due(ctx context.Context, moment time.Time, limit int) ([]*models.WebhookDelivery, error)
+	// Claim moves the attempt of the due delivery to until, false means another instance took it.
+	Claim(ctx context.Context, deliveryID uuid.UUID, moment, until time.Time) (bool, error)
+	SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	// Deliveries returns the log from the newest delivery, before is the last ID of the previous page.
+	Deliveries(ctx context.Context, webhookID uuid.UUID, limit int, before uuid.UUID) ([]*models.WebhookDelivery, error)
+	// DeleteDeliveriesBefore removes the completed deliveries.
+	DeleteDeliveriesBefore(ctx context.Context, before time.Time) (int, error)
+}
+
+// FeedbackReader gives the current state of the feedback for the payload.
+type FeedbackReader interface {
+	GetByID(ctx context.Context, feedbackID uuid.UUID) (*models.Feedback, error)
+}
+
+// Check that actual implementations fit the interfaces.
+var (
+	_ Repository     = (*gorm.WebhookRepository)(nil)
+	_ Repository     = (*sqlite.WebhookRepository)(nil)
+	_ Repository     = (*memory.WebhookRepository)(nil)
+	_ FeedbackReader = (*gorm.FeedbackRepository)(nil)
+	_ FeedbackReader = (*sqlite.FeedbackRepository)(nil)
+	_ FeedbackReader = (*memory.FeedbackRepository)(nil)
+	_ Publisher      = (*dlq.Producer)(nil)
+)
+
+// Config of the deliveries, see the README for the defaults.
+type Config struct {
+	// Timeout of one request to the webhook.
+	Timeout time.Duration
+	// Attempts of the delivery before it fails.
+	MaxAttempts int
+	// Delay before the second attempt, it's doubled for every next one up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Failed deliveries in a row which disable the webhook, zero is never.
+	DisableAfter int
+	// Lifetime of the completed deliveries in the log.
+	LogTTL time.Duration
+	// The webhooks on the private, loopback and link-local addresses are allowed, e.g. for the local runs.
+	AllowPrivate bool
+}
+
+// Service manages the subscriptions, enqueues the events for them and delivers them, see Run.
+type Service struct {
+	logger    logger.Logger
+	repo      Repository
+	feedbacks FeedbackReader
+	client    *http.Client
+	config    Config
+}
+
+func New(repo Repository, feedbacks FeedbackReader, config Config, logger logger.Logger) *Service {
+	return &Service{
+		logger:    logger.Named("webhooks"),
+		repo:      repo,
+		feedbacks: feedbacks,
+		//nolint:exhaustivestruct,exhaustruct
+		client: &http.Client{
+			Timeout:   config.Timeout,
+			Transport: newTransport(config.AllowPrivate),
+			// The redirect could lead the signed payload to another host.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		config: config,
+	}
+}
+
+// Create returns the webhook with the secret, it isn't returned later.
+func (s *Service) Create(ctx context.Context, input *models.WebhookInput) (*models.Webhook, error) {
+	s.logger.Info("creating webhook", logger.M{"url": input.URL, "eventTypes": input.EventTypes})
+
+	//nolint:exhaustivestruct,exhaustruct
+	webhook := &models.Webhook{}
+
+	err := s.fill(webhook, input)
+	if err != nil {
+		s.logger.Error("validating webhook error", logger.M{"err": err})
+
+		return nil, err
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret, err = newSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = s.repo.Create(ctx, webhook)
+	if err != nil {
+		s.logger.Error("creating webhook error", logger.M{"err": err})
+
+		return nil, fmt.Errorf("creating webhook error: %w", err)
+	}
+
+	s.logger.Info("successfully created webhook", logger.M{"webhookID": webhook.ID})
+
+	return webhook, nil
+}
+
+func (s *Service) GetByID(ctx context.Context, webhookID string) (*models.Webhook, error) {
+	webhookUUID, err := uuid.Parse(webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse the ID: %w", err)
+	}
+
+	webhook, err := s.repo.GetByID(ctx, webhookUUID)
+	if err != nil {
+		s.logger.Error("getting webhook by ID", logger.M{"webhookID": webhookID, "error": err})
+
+		return nil, fmt.Errorf("getting webhook by ID: %w", err)
+	}
+
+	return webhook, nil
+}
+
+func (s *Service) GetAll(ctx context.Context) ([]*models.Webhook, error) {
+	webhooks, err := s.repo.GetAll(ctx)
+	if err != nil {
+		s.logger.Error("getting all webhooks", logger.M{"error": err})
+
+		return nil, fmt.Errorf("error by getting webhooks from repository: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// Update replaces the webhook by the input, the empty secret keeps the current one.
+func (s *Service) Update(ctx context.Context, webhookID string, input *models.WebhookInput) (*models.Webhook, error) {
+	s.logger.Info("updating webhook", logger.M{"webhookID": webhookID, "url": input.URL})
+
+	webhook, err := s.GetByID(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, enabled := webhook.Secret, webhook.Enabled
+
+	err = s.fill(webhook, input)
+	if err != nil {
+		s.logger.Error("validating webhook error", logger.M{"err": err})
+
+		return nil, err
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret = secret
+	}
+
+	// Enabling gives the webhook the new chance.
+	if webhook.Enabled && !enabled {
+		webhook.Failures = 0
+	}
+
+	err = s.repo.Update(ctx, webhook)
+	if err != nil {
+		s.logger.Error("updating webhook error", logger.M{"err": err})
+
+		return nil, fmt.Errorf("updating webhook error: %w", err)
+	}
+
+	s.logger.Info("successfully updated webhook", logger.M{"webhookID": webhook.ID})
+
+	return webhook, nil
+}
+
+func (s *Service) Delete(ctx context.Context, webhookID string) error {
+	s.logger.Info("deleting webhook", logger.M{"webhookID": webhookID})
+
+	webhookUUID, err := uuid.Parse(webhookID)
+	if err != nil {
+		return fmt.Errorf("can't parse the ID: %w", err)
+	}
+
+	err = s.repo.Delete(ctx, webhookUUID)
+	if err != nil {
+		s.logger.Error("deleting webhook error", logger.M{"webhookID": webhookID, "err": err})
+
+		return fmt.Errorf("deleting webhook error: %w", err)
+	}
+
+	return nil
+}
+
+// Deliveries returns the log of the webhook from the newest delivery.
+func (s *Service) Deliveries(
+	ctx context.Context,
+	webhookID string,
+	limit int,
+	before string,
+) ([]*models.WebhookDelivery, error) {
+	webhook, err := s.GetByID(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	beforeID := uuid.Nil
+
+	if before != "" {
+		beforeID, err = uuid.Parse(before)
+		if err != nil {
+			return nil, fmt.Errorf("%w: can't parse the delivery ID '%s'", ErrInvalidWebhook, before)
+		}
+	}
+
+	deliveries, err := s.repo.Deliveries(ctx, webhook.ID, limit, beforeID)
+	if err != nil {
+		return nil, fmt.Errorf("getting webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// fill validates the input and copies it to the webhook. The host given by the IP is checked here,
+// the resolved addresses of the name are checked by the dialer of every attempt.
+func (s *Service) fill(webhook *models.Webhook, input *models.WebhookInput) error {
+	target, err := url.Parse(strings.TrimSpace(input.URL))
+	if err != nil || target.Scheme != "https" || target.Hostname() == "" {
+		return fmt.Errorf("%w: url must be absolute https URL", ErrInvalidWebhook)
+	}
+
+	if ip := net.ParseIP(target.Hostname()); ip != nil && !s.config.AllowPrivate && !public(ip) {
+		return fmt.Errorf("%w: %s", ErrInvalidWebhook, ErrPrivateAddress.Error())
+	}
+
+	eventTypes := make([]string, 0, len(input.EventTypes))
+	seen := make(map[string]struct{}, len(input.EventTypes))
+
+	for _, eventType := range input.EventTypes {
+		switch eventType {
+		case models.FeedbackCreated, models.FeedbackUpdated, models.FeedbackTagged, models.FeedbackDeleted:
+		default:
+			return fmt.Errorf("%w: unknown event type '%s'", ErrInvalidWebhook, eventType)
+		}
+
+		if _, ok := seen[eventType]; !ok {
+			seen[eventType] = struct{}{}
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+
+	sentimentLabel := strings.ToLower(strings.TrimSpace(input.Sentiment))
+
+	switch sentimentLabel {
+	case "", sentiment.LabelPositive, sentiment.LabelNegative, sentiment.LabelNeutral:
+	default:
+		return fmt.Errorf("%w: unknown sentiment '%s'", ErrInvalidWebhook, input.Sentiment)
+	}
+
+	webhook.URL = target.String()
+	webhook.EventTypes = eventTypes
+	webhook.Secret = input.Secret
+	webhook.IncludePII = input.IncludePII
+	webhook.Tenant = strings.TrimSpace(input.Tenant)
+	webhook.SourceHost = strings.ToLower(strings.TrimSpace(input.SourceHost))
+	webhook.Sentiment = sentimentLabel
+	webhook.Tag = strings.TrimSpace(input.Tag)
+	webhook.Team = strings.TrimSpace(input.Team)
+	webhook.Enabled = input.Enabled == nil || *input.Enabled
+
+	return nil
+}
+
+func newSecret() (string, error) {
+	secret := make([]byte, secretSize)
+
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", fmt.Errorf("generating webhook secret: %w", err)
+	}
+
+	return hex.EncodeToString(secret), nil
+}
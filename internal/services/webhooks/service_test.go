@@ -0,0 +1,155 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrsj/feedback-service/internal/domain/ids"
+	"github.com/andrsj/feedback-service/internal/domain/models"
+	"github.com/andrsj/feedback-service/internal/infrastructure/db/memory"
+	"github.com/andrsj/feedback-service/pkg/logger"
+)
+
+type nopLogger struct{}
+
+func (l nopLogger) Named(string) logger.Logger { return l }
+func (nopLogger) Debug(string, logger.M)       {}
+func (nopLogger) Info(string, logger.M)        {}
+func (nopLogger) Warn(string, logger.M)        {}
+func (nopLogger) Error(string, logger.M)       {}
+func (nopLogger) Fatal(string, logger.M)       {}
+
+func newService(allowPrivate bool) (*Service, *memory.FeedbackRepository) {
+	auditLog := memory.NewAuditRepository(nil, nopLogger{})
+	feedbacks := memory.New(auditLog, ids.NewV7(), nopLogger{})
+	repo := memory.NewWebhookRepository(auditLog, ids.NewV7(), nopLogger{})
+
+	//nolint:exhaustivestruct,exhaustruct
+	return New(repo, feedbacks, Config{
+		Timeout:      time.Second,
+		MaxAttempts:  1,
+		Backoff:      time.Second,
+		MaxBackoff:   time.Second,
+		AllowPrivate: allowPrivate,
+	}, nopLogger{}), feedbacks
+}
+
+func TestCreateValidatesURL(t *testing.T) {
+	t.Parallel()
+
+	service, _ := newService(false)
+
+	for _, target := range []string{
+		"http://example.com/hooks",
+		"ftp://example.com/hooks",
+		"https://",
+		"https://127.0.0.1/hooks",
+		"https://10.0.0.5:8443/hooks",
+		"https://169.254.169.254/latest/meta-data",
+		"https://[::1]/hooks",
+	} {
+		//nolint:exhaustivestruct,exhaustruct
+		_, err := service.Create(context.Background(), &models.WebhookInput{URL: target})
+		if !errors.Is(err, ErrInvalidWebhook) {
+			t.Errorf("%s: got %v, want %v", target, err, ErrInvalidWebhook)
+		}
+	}
+
+	//nolint:exhaustivestruct,exhaustruct
+	webhook, err := service.Create(context.Background(), &models.WebhookInput{URL: "https://example.com/hooks"})
+	if err != nil || webhook.Secret == "" {
+		t.Errorf("public https URL: got %+v, %v", webhook, err)
+	}
+}
+
+func TestDeliveryRefusesPrivateAddress(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	service, _ := newService(false)
+
+	// The name passes the validation, its address is checked by the dialer.
+	//nolint:exhaustivestruct,exhaustruct
+	webhook, err := service.Create(ctx, &models.WebhookInput{URL: "https://localhost:1/hooks"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	delivery, err := service.Test(ctx, webhook.ID.String())
+	if err != nil {
+		t.Fatalf("Test: %v", err)
+	}
+
+	if delivery.Status != models.DeliveryFailed || !strings.Contains(delivery.Error, ErrPrivateAddress.Error()) {
+		t.Errorf("got %s: %s", delivery.Status, delivery.Error)
+	}
+}
+
+func TestDeliveryRedactsPII(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	bodies := make(chan []byte, 2) //nolint:gomnd
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	service, feedbacks := newService(true)
+	service.client.Transport = server.Client().Transport
+
+	//nolint:exhaustivestruct,exhaustruct
+	feedbackID, err := feedbacks.Create(ctx, &models.Feedback{
+		CustomerName: "Jane",
+		Email:        "jane@example.com",
+		FeedbackText: "ok",
+		Source:       "https://shop.example.com",
+	})
+	if err != nil {
+		t.Fatalf("Create feedback: %v", err)
+	}
+
+	for _, includePII := range []bool{false, true} {
+		//nolint:exhaustivestruct,exhaustruct
+		webhook, err := service.Create(ctx, &models.WebhookInput{URL: server.URL, IncludePII: includePII})
+		if err != nil {
+			t.Fatalf("Create webhook: %v", err)
+		}
+
+		delivery := newDelivery(webhook.ID, models.FeedbackCreated, feedbackID, "")
+
+		err = service.repo.AddDeliveries(ctx, []*models.WebhookDelivery{delivery})
+		if err != nil {
+			t.Fatalf("AddDeliveries: %v", err)
+		}
+
+		service.attempt(ctx, delivery)
+
+		if delivery.Status != models.DeliveryDelivered {
+			t.Fatalf("include_pii %t: got %s: %s", includePII, delivery.Status, delivery.Error)
+		}
+
+		var payload struct {
+			Data models.Feedback `json:"data"`
+		}
+
+		err = json.Unmarshal(<-bodies, &payload)
+		if err != nil {
+			t.Fatalf("payload: %v", err)
+		}
+
+		if payload.Data.FeedbackText != "ok" || (payload.Data.Email != "") != includePII ||
+			(payload.Data.CustomerName != "") != includePII {
+			t.Errorf("include_pii %t: got %+v", includePII, payload.Data)
+		}
+	}
+}